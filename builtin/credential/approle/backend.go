@@ -56,6 +56,14 @@ type backend struct {
 	// secretIDListingLock is a dedicated lock for listing SecretIDAccessors
 	// for all the SecretIDs issued against an approle
 	secretIDListingLock sync.RWMutex
+
+	// SecretID uses are recorded in the background by a single worker,
+	// started on the first use. secretIDUseWG tracks the queued uses.
+	secretIDUses       chan *secretIDUse
+	secretIDUseStop    chan struct{}
+	secretIDUseStart   sync.Once
+	secretIDUseStopped sync.Once
+	secretIDUseWG      sync.WaitGroup
 }
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -87,6 +95,9 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 		secretIDAccessorLocks: locksutil.CreateLocks(),
 
 		tidySecretIDCASGuard: new(uint32),
+
+		secretIDUses:    make(chan *secretIDUse, secretIDUseQueueSize),
+		secretIDUseStop: make(chan struct{}),
 	}
 
 	// Attach the paths and secrets that are to be handled by the backend
@@ -112,6 +123,7 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			},
 		),
 		Invalidate:  b.invalidate,
+		Clean:       b.cleanup,
 		BackendType: logical.TypeCredential,
 	}
 	return b, nil
@@ -149,6 +161,14 @@ func (b *backend) invalidate(_ context.Context, key string) {
 	}
 }
 
+// cleanup waits for the queued SecretID uses to be recorded.
+func (b *backend) cleanup(_ context.Context) {
+	b.secretIDUseWG.Wait()
+	b.secretIDUseStopped.Do(func() {
+		close(b.secretIDUseStop)
+	})
+}
+
 // periodicFunc of the backend will be invoked once a minute by the RollbackManager.
 // RoleRole backend utilizes this function to delete expired SecretID entries.
 // This could mean that the SecretID may live in the backend upto 1 min after its
@@ -157,7 +177,7 @@ func (b *backend) invalidate(_ context.Context, key string) {
 func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error {
 	// Initiate clean-up of expired SecretID entries
 	if b.System().LocalMount() || !b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary|consts.ReplicationPerformanceStandby) {
-		b.tidySecretID(ctx, req)
+		b.tidySecretID(ctx, req, 0)
 	}
 	return nil
 }
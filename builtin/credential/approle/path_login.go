@@ -225,6 +225,10 @@ func (b *backend) pathLoginUpdate(ctx context.Context, req *logical.Request, dat
 					).Error()), nil
 				}
 			}
+
+			// Record the use of the SecretID in the background, so that
+			// logins sharing it are not serialized on its write lock
+			b.recordSecretIDUse(req, role.SecretIDPrefix, roleNameHMAC, secretIDHMAC)
 		default:
 			//
 			// If the SecretIDNumUses is non-zero, it means that its use-count should be updated
@@ -262,6 +266,11 @@ func (b *backend) pathLoginUpdate(ctx context.Context, req *logical.Request, dat
 				// If the use count is greater than one, decrement it and update the last updated time.
 				entry.SecretIDNumUses -= 1
 				entry.LastUpdatedTime = time.Now()
+				var sourceIP string
+				if req.Connection != nil {
+					sourceIP = req.Connection.RemoteAddr
+				}
+				entry.recordUse(entry.LastUpdatedTime, sourceIP)
 
 				sEntry, err := logical.StorageEntryJSON(entryIndex, &entry)
 				if err != nil {
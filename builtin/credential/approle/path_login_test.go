@@ -2,6 +2,7 @@ package approle
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -390,3 +391,151 @@ func TestAppRole_RoleDoesNotExist(t *testing.T) {
 		t.Fatalf("Error was not due to invalid role ID. Error: %s", errString)
 	}
 }
+
+func TestAppRole_LoginRecordsSecretIDUsage(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	for _, numUses := range []int{0, 5} {
+		t.Run(fmt.Sprintf("secret_id_num_uses=%d", numUses), func(t *testing.T) {
+			roleName := fmt.Sprintf("role%d", numUses)
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.CreateOperation,
+				Path:      "role/" + roleName,
+				Storage:   storage,
+				Data: map[string]interface{}{
+					"policies":           "default",
+					"secret_id_num_uses": numUses,
+				},
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("err:%v resp:%#v", err, resp)
+			}
+
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "role/" + roleName + "/role-id",
+				Storage:   storage,
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("err:%v resp:%#v", err, resp)
+			}
+			roleID := resp.Data["role_id"]
+
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "role/" + roleName + "/secret-id",
+				Storage:   storage,
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("err:%v resp:%#v", err, resp)
+			}
+			secretID := resp.Data["secret_id"]
+
+			for i := 0; i < 2; i++ {
+				resp, err = b.HandleRequest(context.Background(), &logical.Request{
+					Operation: logical.UpdateOperation,
+					Path:      "login",
+					Storage:   storage,
+					Data: map[string]interface{}{
+						"role_id":   roleID,
+						"secret_id": secretID,
+					},
+					Connection: &logical.Connection{
+						RemoteAddr: "127.0.0.1",
+					},
+				})
+				if err != nil || (resp != nil && resp.IsError()) {
+					t.Fatalf("err:%v resp:%#v", err, resp)
+				}
+			}
+			b.secretIDUseWG.Wait()
+
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "role/" + roleName + "/secret-id/lookup",
+				Storage:   storage,
+				Data: map[string]interface{}{
+					"secret_id": secretID,
+				},
+			})
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("err:%v resp:%#v", err, resp)
+			}
+			if resp.Data["use_count"] != 2 {
+				t.Fatalf("expected a use count of 2, got %v", resp.Data["use_count"])
+			}
+			if resp.Data["last_used_source_ip"] != "127.0.0.1" {
+				t.Fatalf("unexpected last_used_source_ip %v", resp.Data["last_used_source_ip"])
+			}
+			if lastUsed := resp.Data["last_used_time"].(time.Time); time.Since(lastUsed) > time.Minute {
+				t.Fatalf("unexpected last_used_time %s", lastUsed)
+			}
+		})
+	}
+}
+
+// readOnlyStorage fails writes like the storage of a performance standby.
+type readOnlyStorage struct {
+	logical.Storage
+}
+
+func (s readOnlyStorage) Put(context.Context, *logical.StorageEntry) error {
+	return logical.ErrReadOnly
+}
+
+func TestAppRole_LoginReadOnlyStorage(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/role1",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"policies": "default",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "role/role1/role-id",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	roleID := resp.Data["role_id"]
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/role1/secret-id",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	secretID := resp.Data["secret_id"]
+
+	// Logins do not fail when the use of the SecretID cannot be recorded
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login",
+		Storage:   readOnlyStorage{storage},
+		Data: map[string]interface{}{
+			"role_id":   roleID,
+			"secret_id": secretID,
+		},
+		Connection: &logical.Connection{
+			RemoteAddr: "127.0.0.1",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Auth == nil {
+		t.Fatal("expected auth in the login response")
+	}
+	b.secretIDUseWG.Wait()
+}
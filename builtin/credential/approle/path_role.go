@@ -17,6 +17,7 @@ import (
 	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/helper/policyutil"
+	"github.com/hashicorp/vault/sdk/helper/template"
 	"github.com/hashicorp/vault/sdk/helper/tokenutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -77,6 +78,19 @@ type roleStorageEntry struct {
 	// SecretIDPrefix is the storage prefix for persisting secret IDs. This
 	// differs based on whether the secret IDs are cluster local or not.
 	SecretIDPrefix string `json:"secret_id_prefix" mapstructure:"secret_id_prefix"`
+
+	// SecretIDWrappingRequired, if set, requires SecretIDs generated against
+	// the role to be response-wrapped
+	SecretIDWrappingRequired bool `json:"secret_id_wrapping_required" mapstructure:"secret_id_wrapping_required"`
+
+	// Bounds on the TTL of the response-wrapping token when generating
+	// SecretIDs against the role
+	SecretIDMinWrappingTTL time.Duration `json:"secret_id_min_wrapping_ttl" mapstructure:"secret_id_min_wrapping_ttl"`
+	SecretIDMaxWrappingTTL time.Duration `json:"secret_id_max_wrapping_ttl" mapstructure:"secret_id_max_wrapping_ttl"`
+
+	// SecretIDMetadataTemplate maps SecretID metadata keys to templates
+	// rendered with information about the request generating the SecretID
+	SecretIDMetadataTemplate map[string]string `json:"secret_id_metadata_template" mapstructure:"secret_id_metadata_template"`
 }
 
 // roleIDStorageEntry represents the reverse mapping from RoleID to Role
@@ -167,6 +181,32 @@ to 0, meaning no expiration.`,
 				Description: `If set, the secret IDs generated using this role will be cluster local. This
 can only be set during role creation and once set, it can't be reset later.`,
 			},
+
+			"secret_id_wrapping_required": {
+				Type: framework.TypeBool,
+				Description: `If set, requests generating secret IDs against this role must be response-wrapped,
+so that the secret ID is only revealed to whoever unwraps it. Defaults to false.`,
+			},
+
+			"secret_id_min_wrapping_ttl": {
+				Type: framework.TypeDurationSecond,
+				Description: `Minimum TTL of the response-wrapping token when generating secret IDs against
+this role. Defaults to 0, meaning no minimum.`,
+			},
+
+			"secret_id_max_wrapping_ttl": {
+				Type: framework.TypeDurationSecond,
+				Description: `Maximum TTL of the response-wrapping token when generating secret IDs against
+this role. Defaults to 0, meaning no maximum.`,
+			},
+
+			"secret_id_metadata_template": {
+				Type: framework.TypeKVPairs,
+				Description: `Map of secret ID metadata keys to templates rendered when generating a secret ID,
+overriding any metadata supplied with the request. Templates can reference
+{{.EntityID}}, {{.DisplayName}} and {{.RemoteAddr}} of the requester, the
+{{.CIDRList}} bound to the secret ID and the {{.Timestamp}} of the request.`,
+			},
 		},
 		ExistenceCheck: b.pathRoleExistenceCheck,
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -481,6 +521,23 @@ the role.`,
 					Type:        framework.TypeCommaStringSlice,
 					Description: defTokenFields["token_bound_cidrs"].Description,
 				},
+				"unused_for": {
+					Type: framework.TypeDurationSecond,
+					Description: `When listing, only list SecretIDs that have not been used to log in for at least
+this duration, counting from their creation if never used.`,
+				},
+				"expiring_within": {
+					Type:        framework.TypeDurationSecond,
+					Description: `When listing, only list SecretIDs expiring within this duration.`,
+				},
+				"metadata_key": {
+					Type:        framework.TypeString,
+					Description: `When listing, only list SecretIDs with this metadata key.`,
+				},
+				"metadata_value": {
+					Type:        framework.TypeString,
+					Description: `When listing with 'metadata_key', only list SecretIDs with this value for the key.`,
+				},
 			},
 			Callbacks: map[logical.Operation]framework.OperationFunc{
 				logical.UpdateOperation: b.pathRoleSecretIDUpdate,
@@ -555,6 +612,10 @@ the role.`,
 					Type:        framework.TypeString,
 					Description: "Accessor of the SecretID",
 				},
+				"secret_id_accessors": {
+					Type:        framework.TypeCommaStringSlice,
+					Description: "Comma separated string or list of accessors of SecretIDs to destroy together.",
+				},
 			},
 			Callbacks: map[logical.Operation]framework.OperationFunc{
 				logical.UpdateOperation: b.pathRoleSecretIDAccessorDestroyUpdateDelete,
@@ -658,6 +719,17 @@ func (b *backend) pathRoleSecretIDList(ctx context.Context, req *logical.Request
 		return nil, fmt.Errorf("failed to create HMAC of role_name: %w", err)
 	}
 
+	filter := &secretIDListFilter{
+		unusedFor:      time.Duration(data.Get("unused_for").(int)) * time.Second,
+		expiringWithin: time.Duration(data.Get("expiring_within").(int)) * time.Second,
+		metadataKey:    data.Get("metadata_key").(string),
+		metadataValue:  data.Get("metadata_value").(string),
+	}
+	if filter.metadataValue != "" && filter.metadataKey == "" {
+		return logical.ErrorResponse("metadata_value requires metadata_key to be set"), nil
+	}
+	now := time.Now()
+
 	// Listing works one level at a time. Get the first level of data
 	// which could then be used to get the actual SecretID storage entries.
 	secretIDHMACs, err := req.Storage.List(ctx, fmt.Sprintf("%s%s/", role.SecretIDPrefix, roleNameHMAC))
@@ -666,6 +738,7 @@ func (b *backend) pathRoleSecretIDList(ctx context.Context, req *logical.Request
 	}
 
 	var listItems []string
+	keyInfo := make(map[string]interface{})
 	for _, secretIDHMAC := range secretIDHMACs {
 		// For sanity
 		if secretIDHMAC == "" {
@@ -696,11 +769,46 @@ func (b *backend) pathRoleSecretIDList(ctx context.Context, req *logical.Request
 			secretIDLock.RUnlock()
 			return nil, err
 		}
-		listItems = append(listItems, result.SecretIDAccessor)
 		secretIDLock.RUnlock()
+
+		if !filter.matches(&result, now) {
+			continue
+		}
+		listItems = append(listItems, result.SecretIDAccessor)
+		keyInfo[result.SecretIDAccessor] = map[string]interface{}{
+			"creation_time":   result.CreationTime,
+			"expiration_time": result.ExpirationTime,
+			"last_used_time":  result.LastUsedTime,
+			"use_count":       result.UseCount,
+		}
 	}
 
-	return logical.ListResponse(listItems), nil
+	return logical.ListResponseWithInfo(listItems, keyInfo), nil
+}
+
+// secretIDListFilter selects the SecretIDs listed against a role.
+type secretIDListFilter struct {
+	unusedFor      time.Duration
+	expiringWithin time.Duration
+	metadataKey    string
+	metadataValue  string
+}
+
+// matches returns whether the SecretID passes all the set filters.
+func (f *secretIDListFilter) matches(entry *secretIDStorageEntry, now time.Time) bool {
+	if f.unusedFor != 0 && !entry.unusedFor(f.unusedFor, now) {
+		return false
+	}
+	if f.expiringWithin != 0 && (entry.ExpirationTime.IsZero() || entry.ExpirationTime.After(now.Add(f.expiringWithin))) {
+		return false
+	}
+	if f.metadataKey != "" {
+		value, ok := entry.Metadata[f.metadataKey]
+		if !ok || (f.metadataValue != "" && value != f.metadataValue) {
+			return false
+		}
+	}
+	return true
 }
 
 // validateRoleConstraints checks if the role has at least one constraint
@@ -968,6 +1076,31 @@ func (b *backend) pathRoleCreateUpdate(ctx context.Context, req *logical.Request
 		role.SecretIDTTL = time.Second * time.Duration(data.Get("secret_id_ttl").(int))
 	}
 
+	if wrappingRequiredRaw, ok := data.GetOk("secret_id_wrapping_required"); ok {
+		role.SecretIDWrappingRequired = wrappingRequiredRaw.(bool)
+	}
+	if minWrappingTTLRaw, ok := data.GetOk("secret_id_min_wrapping_ttl"); ok {
+		role.SecretIDMinWrappingTTL = time.Second * time.Duration(minWrappingTTLRaw.(int))
+	}
+	if maxWrappingTTLRaw, ok := data.GetOk("secret_id_max_wrapping_ttl"); ok {
+		role.SecretIDMaxWrappingTTL = time.Second * time.Duration(maxWrappingTTLRaw.(int))
+	}
+	if role.SecretIDMinWrappingTTL < 0 || role.SecretIDMaxWrappingTTL < 0 {
+		return logical.ErrorResponse("secret_id_min_wrapping_ttl and secret_id_max_wrapping_ttl cannot be negative"), nil
+	}
+	if role.SecretIDMaxWrappingTTL != 0 && role.SecretIDMinWrappingTTL > role.SecretIDMaxWrappingTTL {
+		return logical.ErrorResponse("secret_id_min_wrapping_ttl cannot be greater than secret_id_max_wrapping_ttl"), nil
+	}
+
+	if metadataTemplateRaw, ok := data.GetOk("secret_id_metadata_template"); ok {
+		role.SecretIDMetadataTemplate = metadataTemplateRaw.(map[string]string)
+		for key, tmpl := range role.SecretIDMetadataTemplate {
+			if _, err := template.NewTemplate(template.Template(tmpl)); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("invalid secret_id_metadata_template for %q: %v", key, err)), nil
+			}
+		}
+	}
+
 	// handle upgrade cases
 	{
 		if err := tokenutil.UpgradeValue(data, "policies", "token_policies", &role.Policies, &role.TokenPolicies); err != nil {
@@ -1022,6 +1155,11 @@ func (b *backend) pathRoleRead(ctx context.Context, req *logical.Request, data *
 		"secret_id_num_uses":    role.SecretIDNumUses,
 		"secret_id_ttl":         role.SecretIDTTL / time.Second,
 		"local_secret_ids":      false,
+
+		"secret_id_wrapping_required": role.SecretIDWrappingRequired,
+		"secret_id_min_wrapping_ttl":  role.SecretIDMinWrappingTTL / time.Second,
+		"secret_id_max_wrapping_ttl":  role.SecretIDMaxWrappingTTL / time.Second,
+		"secret_id_metadata_template": role.SecretIDMetadataTemplate,
 	}
 	role.PopulateTokenData(respData)
 
@@ -1202,6 +1340,10 @@ func (entry *secretIDStorageEntry) ToResponseData() map[string]interface{} {
 		"metadata":           entry.Metadata,
 		"cidr_list":          entry.CIDRList,
 		"token_bound_cidrs":  entry.TokenBoundCIDRs,
+
+		"last_used_time":      entry.LastUsedTime,
+		"use_count":           entry.UseCount,
+		"last_used_source_ip": entry.LastUsedSourceIP,
 	}
 	if len(entry.TokenBoundCIDRs) == 0 {
 		ret["token_bound_cidrs"] = []string{}
@@ -1339,7 +1481,8 @@ func (b *backend) pathRoleSecretIDAccessorDestroyUpdateDelete(ctx context.Contex
 	}
 
 	secretIDAccessor := data.Get("secret_id_accessor").(string)
-	if secretIDAccessor == "" {
+	secretIDAccessors := data.Get("secret_id_accessors").([]string)
+	if secretIDAccessor == "" && len(secretIDAccessors) == 0 {
 		return logical.ErrorResponse("missing secret_id_accessor"), nil
 	}
 
@@ -1355,17 +1498,49 @@ func (b *backend) pathRoleSecretIDAccessorDestroyUpdateDelete(ctx context.Contex
 		return nil, fmt.Errorf("role %q does not exist", roleName)
 	}
 
-	accessorEntry, err := b.secretIDAccessorEntry(ctx, req.Storage, secretIDAccessor, role.SecretIDPrefix)
+	roleNameHMAC, err := createHMAC(role.HMACKey, role.name)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create HMAC of role_name: %w", err)
 	}
-	if accessorEntry == nil {
-		return nil, fmt.Errorf("failed to find accessor entry for secret_id_accessor: %q", secretIDAccessor)
+
+	if secretIDAccessor != "" {
+		found, err := b.destroySecretIDAccessor(ctx, req.Storage, role, roleNameHMAC, secretIDAccessor)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("failed to find accessor entry for secret_id_accessor: %q", secretIDAccessor)
+		}
 	}
 
-	roleNameHMAC, err := createHMAC(role.HMACKey, role.name)
+	// Accessors destroyed together are expected to come from a listing that
+	// may be stale, so missing ones are reported rather than failing.
+	var resp *logical.Response
+	for _, accessor := range secretIDAccessors {
+		found, err := b.destroySecretIDAccessor(ctx, req.Storage, role, roleNameHMAC, accessor)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			if resp == nil {
+				resp = &logical.Response{}
+			}
+			resp.AddWarning(fmt.Sprintf("failed to find accessor entry for secret_id_accessor: %q", accessor))
+		}
+	}
+
+	return resp, nil
+}
+
+// destroySecretIDAccessor deletes the SecretID with the given accessor,
+// returning false if the accessor was not found.
+func (b *backend) destroySecretIDAccessor(ctx context.Context, s logical.Storage, role *roleStorageEntry, roleNameHMAC, secretIDAccessor string) (bool, error) {
+	accessorEntry, err := b.secretIDAccessorEntry(ctx, s, secretIDAccessor, role.SecretIDPrefix)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create HMAC of role_name: %w", err)
+		return false, err
+	}
+	if accessorEntry == nil {
+		return false, nil
 	}
 
 	entryIndex := fmt.Sprintf("%s%s/%s", role.SecretIDPrefix, roleNameHMAC, accessorEntry.SecretIDHMAC)
@@ -1375,16 +1550,16 @@ func (b *backend) pathRoleSecretIDAccessorDestroyUpdateDelete(ctx context.Contex
 	defer lock.Unlock()
 
 	// Delete the accessor of the SecretID first
-	if err := b.deleteSecretIDAccessorEntry(ctx, req.Storage, secretIDAccessor, role.SecretIDPrefix); err != nil {
-		return nil, err
+	if err := b.deleteSecretIDAccessorEntry(ctx, s, secretIDAccessor, role.SecretIDPrefix); err != nil {
+		return false, err
 	}
 
 	// Delete the storage entry that corresponds to the SecretID
-	if err := req.Storage.Delete(ctx, entryIndex); err != nil {
-		return nil, fmt.Errorf("failed to delete secret_id: %w", err)
+	if err := s.Delete(ctx, entryIndex); err != nil {
+		return false, fmt.Errorf("failed to delete secret_id: %w", err)
 	}
 
-	return nil, nil
+	return true, nil
 }
 
 func (b *backend) pathRoleBoundCIDRUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
@@ -2319,6 +2494,10 @@ func (b *backend) handleRoleSecretIDCommon(ctx context.Context, req *logical.Req
 		return logical.ErrorResponse("bind_secret_id is not set on the role"), nil
 	}
 
+	if err := validateSecretIDWrapping(req, role); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
 	secretIDCIDRs := data.Get("cidr_list").([]string)
 
 	// Validate the list of CIDR blocks
@@ -2367,6 +2546,10 @@ func (b *backend) handleRoleSecretIDCommon(ctx context.Context, req *logical.Req
 		return logical.ErrorResponse(fmt.Sprintf("failed to parse metadata: %v", err)), nil
 	}
 
+	if err := renderSecretIDMetadata(req, role, secretIDStorage); err != nil {
+		return nil, err
+	}
+
 	if secretIDStorage, err = b.registerSecretIDEntry(ctx, req.Storage, role.name, secretID, role.HMACKey, role.SecretIDPrefix, secretIDStorage); err != nil {
 		return nil, fmt.Errorf("failed to store secret_id: %w", err)
 	}
@@ -2382,6 +2565,67 @@ func (b *backend) handleRoleSecretIDCommon(ctx context.Context, req *logical.Req
 	return resp, nil
 }
 
+// validateSecretIDWrapping checks that a request generating a SecretID
+// against the role is response-wrapped as required by the role.
+func validateSecretIDWrapping(req *logical.Request, role *roleStorageEntry) error {
+	if req.WrapInfo == nil || req.WrapInfo.TTL == 0 {
+		if role.SecretIDWrappingRequired {
+			return fmt.Errorf("role %q requires secret IDs to be response-wrapped", role.name)
+		}
+		return nil
+	}
+
+	switch {
+	case role.SecretIDMinWrappingTTL != 0 && req.WrapInfo.TTL < role.SecretIDMinWrappingTTL:
+		return fmt.Errorf("wrapping TTL %s is less than the minimum of %s for role %q", req.WrapInfo.TTL, role.SecretIDMinWrappingTTL, role.name)
+	case role.SecretIDMaxWrappingTTL != 0 && req.WrapInfo.TTL > role.SecretIDMaxWrappingTTL:
+		return fmt.Errorf("wrapping TTL %s is greater than the maximum of %s for role %q", req.WrapInfo.TTL, role.SecretIDMaxWrappingTTL, role.name)
+	}
+	return nil
+}
+
+// secretIDMetadataTemplateData is the data available to the templates of
+// the secret_id_metadata_template set on a role.
+type secretIDMetadataTemplateData struct {
+	EntityID    string
+	DisplayName string
+	RemoteAddr  string
+	CIDRList    string
+	Timestamp   string
+}
+
+// renderSecretIDMetadata renders the secret_id_metadata_template set on the
+// role into the metadata of the SecretID, overriding any metadata supplied
+// with the request.
+func renderSecretIDMetadata(req *logical.Request, role *roleStorageEntry, secretIDEntry *secretIDStorageEntry) error {
+	if len(role.SecretIDMetadataTemplate) == 0 {
+		return nil
+	}
+
+	data := secretIDMetadataTemplateData{
+		EntityID:    req.EntityID,
+		DisplayName: req.DisplayName,
+		CIDRList:    strings.Join(secretIDEntry.CIDRList, ","),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if req.Connection != nil {
+		data.RemoteAddr = req.Connection.RemoteAddr
+	}
+
+	for key, tmpl := range role.SecretIDMetadataTemplate {
+		up, err := template.NewTemplate(template.Template(tmpl))
+		if err != nil {
+			return fmt.Errorf("unable to initialize secret_id_metadata_template for %q: %w", key, err)
+		}
+		value, err := up.Generate(data)
+		if err != nil {
+			return fmt.Errorf("failed to render secret_id_metadata_template for %q: %w", key, err)
+		}
+		secretIDEntry.Metadata[key] = value
+	}
+	return nil
+}
+
 func (b *backend) roleIDLock(roleID string) *locksutil.LockEntry {
 	return locksutil.LockForKey(b.roleIDLocks, roleID)
 }
@@ -2585,7 +2829,12 @@ this endpoint.`,
 just this role and none else. The properties of this SecretID will be
 based on the options set on the role. It will expire after a period
 defined by the 'secret_id_ttl' option on the role and/or the backend
-mount's maximum TTL value.`,
+mount's maximum TTL value.
+
+Listing returns the accessors of the SecretIDs issued against the role,
+optionally filtered by 'unused_for', 'expiring_within' and 'metadata_key'.
+The listed accessors can be passed to 'secret-id-accessor/destroy' in
+'secret_id_accessors' to destroy them together.`,
 	},
 	"role-custom-secret-id": {
 		"Assign a SecretID of choice against the role.",
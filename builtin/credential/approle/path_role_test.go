@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -2180,3 +2181,274 @@ func TestAppRole_SecretID_WithTTL(t *testing.T) {
 		})
 	}
 }
+
+func TestAppRole_SecretIDWrapping(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	roleData := map[string]interface{}{
+		"policies":                    "default",
+		"secret_id_wrapping_required": true,
+		"secret_id_min_wrapping_ttl":  60,
+		"secret_id_max_wrapping_ttl":  300,
+	}
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/role1",
+		Storage:   storage,
+		Data:      roleData,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "role/role1",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["secret_id_wrapping_required"] != true ||
+		resp.Data["secret_id_min_wrapping_ttl"] != time.Duration(60) ||
+		resp.Data["secret_id_max_wrapping_ttl"] != time.Duration(300) {
+		t.Fatalf("unexpected role data: %#v", resp.Data)
+	}
+
+	tests := map[string]struct {
+		wrapInfo *logical.RequestWrapInfo
+		valid    bool
+	}{
+		"unwrapped":          {nil, false},
+		"below minimum":      {&logical.RequestWrapInfo{TTL: 30 * time.Second}, false},
+		"above maximum":      {&logical.RequestWrapInfo{TTL: time.Hour}, false},
+		"within the bounds":  {&logical.RequestWrapInfo{TTL: 2 * time.Minute}, true},
+		"at the lower bound": {&logical.RequestWrapInfo{TTL: time.Minute}, true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "role/role1/secret-id",
+				Storage:   storage,
+				WrapInfo:  tt.wrapInfo,
+			})
+			if tt.valid {
+				if err != nil || (resp != nil && resp.IsError()) {
+					t.Fatalf("err:%v resp:%#v", err, resp)
+				}
+				return
+			}
+			if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+				t.Fatalf("expected the request to be rejected, err:%v resp:%#v", err, resp)
+			}
+		})
+	}
+
+	roleData["secret_id_min_wrapping_ttl"] = 600
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/role1",
+		Storage:   storage,
+		Data:      roleData,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for a minimum above the maximum, err:%v resp:%#v", err, resp)
+	}
+}
+
+func TestAppRole_SecretIDMetadataTemplate(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "role/role1",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"policies": "default",
+			"secret_id_metadata_template": map[string]interface{}{
+				"created_by":   "{{.EntityID}}",
+				"created_from": "{{.RemoteAddr}} {{.CIDRList}}",
+				"created_at":   "{{.Timestamp}}",
+			},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "role/role1/secret-id",
+		Storage:    storage,
+		EntityID:   "entity-id",
+		Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+		Data: map[string]interface{}{
+			"cidr_list": "127.0.0.0/8",
+			// Supplied metadata cannot override the templated keys.
+			"metadata": `{"created_by": "someone-else", "team": "ci"}`,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/role1/secret-id/lookup",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"secret_id": resp.Data["secret_id"],
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	metadata := resp.Data["metadata"].(map[string]string)
+	if metadata["created_by"] != "entity-id" || metadata["created_from"] != "127.0.0.1 127.0.0.0/8" || metadata["team"] != "ci" {
+		t.Fatalf("unexpected metadata: %#v", metadata)
+	}
+	if _, err := time.Parse(time.RFC3339, metadata["created_at"]); err != nil {
+		t.Fatalf("unexpected created_at: %v", err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/role1",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"secret_id_metadata_template": map[string]interface{}{
+				"created_by": "{{.EntityID",
+			},
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error for an invalid template, err:%v resp:%#v", err, resp)
+	}
+}
+
+// updateSecretIDEntries applies f to the storage entries of all SecretIDs
+// issued against the role.
+func updateSecretIDEntries(t *testing.T, b *backend, storage logical.Storage, roleName string, f func(entry *secretIDStorageEntry)) {
+	t.Helper()
+
+	role, err := b.roleEntry(context.Background(), storage, roleName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roleNameHMAC, err := createHMAC(role.HMACKey, role.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretIDHMACs, err := storage.List(context.Background(), role.SecretIDPrefix+roleNameHMAC+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secretIDHMAC := range secretIDHMACs {
+		entry, err := b.nonLockedSecretIDStorageEntry(context.Background(), storage, role.SecretIDPrefix, roleNameHMAC, secretIDHMAC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f(entry)
+		if err := b.nonLockedSetSecretIDStorageEntry(context.Background(), storage, role.SecretIDPrefix, roleNameHMAC, secretIDHMAC, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAppRole_RoleSecretIDListFilters(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+	createRole(t, b, storage, "role1", "default")
+
+	generate := func(metadata string) string {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/role1/secret-id",
+			Storage:   storage,
+			Data: map[string]interface{}{
+				"metadata": metadata,
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		return resp.Data["secret_id_accessor"].(string)
+	}
+	stale := generate(`{"team": "ci"}`)
+	expiring := generate(`{"team": "ops"}`)
+	fresh := generate(`{}`)
+
+	now := time.Now()
+	updateSecretIDEntries(t, b, storage, "role1", func(entry *secretIDStorageEntry) {
+		entry.ExpirationTime = time.Time{}
+		switch entry.SecretIDAccessor {
+		case stale:
+			entry.CreationTime = now.Add(-60 * 24 * time.Hour)
+			entry.LastUsedTime = now.Add(-40 * 24 * time.Hour)
+		case expiring:
+			entry.CreationTime = now.Add(-40 * 24 * time.Hour)
+			entry.LastUsedTime = now.Add(-time.Hour)
+			entry.ExpirationTime = now.Add(time.Hour)
+		}
+	})
+
+	list := func(data map[string]interface{}) []string {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "role/role1/secret-id",
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		keys, _ := resp.Data["keys"].([]string)
+		sort.Strings(keys)
+		return keys
+	}
+	sorted := func(accessors ...string) []string {
+		sort.Strings(accessors)
+		return accessors
+	}
+
+	testCases := map[string]struct {
+		data     map[string]interface{}
+		expected []string
+	}{
+		"no filters":      {nil, sorted(stale, expiring, fresh)},
+		"unused for":      {map[string]interface{}{"unused_for": "720h"}, sorted(stale)},
+		"expiring within": {map[string]interface{}{"expiring_within": "2h"}, sorted(expiring)},
+		"metadata key":    {map[string]interface{}{"metadata_key": "team"}, sorted(stale, expiring)},
+		"metadata value":  {map[string]interface{}{"metadata_key": "team", "metadata_value": "ops"}, sorted(expiring)},
+		"combined":        {map[string]interface{}{"metadata_key": "team", "unused_for": "720h", "expiring_within": "2h"}, nil},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if diff := deep.Equal(list(tc.data), tc.expected); diff != nil {
+				t.Fatal(diff)
+			}
+		})
+	}
+
+	// The listed accessors can be destroyed together, with missing ones
+	// reported as warnings.
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "role/role1/secret-id-accessor/destroy",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"secret_id_accessors": []string{stale, expiring, "missing"},
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp == nil || len(resp.Warnings) != 1 {
+		t.Fatalf("expected a warning for the missing accessor, resp:%#v", resp)
+	}
+	if diff := deep.Equal(list(nil), []string{fresh}); diff != nil {
+		t.Fatal(diff)
+	}
+}
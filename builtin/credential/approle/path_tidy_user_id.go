@@ -17,6 +17,14 @@ func pathTidySecretID(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "tidy/secret-id$",
 
+		Fields: map[string]*framework.FieldSchema{
+			"unused_for": {
+				Type: framework.TypeDurationSecond,
+				Description: `If set, also delete SecretIDs that have not been used to log in for at least
+this duration, counting from their creation if never used.`,
+			},
+		},
+
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathTidySecretIDUpdate,
 		},
@@ -26,8 +34,9 @@ func pathTidySecretID(b *backend) *framework.Path {
 	}
 }
 
-// tidySecretID is used to delete entries in the whitelist that are expired.
-func (b *backend) tidySecretID(ctx context.Context, req *logical.Request) (*logical.Response, error) {
+// tidySecretID is used to delete entries in the whitelist that are expired,
+// or if unusedFor is non-zero, that have not been used for that long.
+func (b *backend) tidySecretID(ctx context.Context, req *logical.Request, unusedFor time.Duration) (*logical.Response, error) {
 	// If we are a performance standby forward the request to the active node
 	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
 		return nil, logical.ErrReadOnly
@@ -39,7 +48,7 @@ func (b *backend) tidySecretID(ctx context.Context, req *logical.Request) (*logi
 		return resp, nil
 	}
 
-	go b.tidySecretIDinternal(req.Storage)
+	go b.tidySecretIDinternal(req.Storage, unusedFor)
 
 	resp := &logical.Response{}
 	resp.AddWarning("Tidy operation successfully started. Any information from the operation will be printed to Vault's server logs.")
@@ -51,7 +60,7 @@ type tidyHelperSecretIDAccessor struct {
 	saltedSecretIDAccessor string
 }
 
-func (b *backend) tidySecretIDinternal(s logical.Storage) {
+func (b *backend) tidySecretIDinternal(s logical.Storage, unusedFor time.Duration) {
 	defer atomic.StoreUint32(b.tidySecretIDCASGuard, 0)
 
 	logger := b.Logger().Named("tidy")
@@ -145,8 +154,10 @@ func (b *backend) tidySecretIDinternal(s logical.Storage) {
 			}
 
 			// ExpirationTime not being set indicates non-expiring SecretIDs
-			if !result.ExpirationTime.IsZero() && time.Now().After(result.ExpirationTime) {
-				logger.Trace("found expired secret ID")
+			expired := !result.ExpirationTime.IsZero() && time.Now().After(result.ExpirationTime)
+			unused := unusedFor != 0 && result.unusedFor(unusedFor, time.Now())
+			if expired || unused {
+				logger.Trace("found expired or unused secret ID", "expired", expired, "unused", unused)
 				// Clean up the accessor of the secret ID first
 				err = b.deleteSecretIDAccessorEntry(ctx, s, result.SecretIDAccessor, secretIDPrefixToUse)
 				if err != nil {
@@ -260,12 +271,13 @@ func (b *backend) tidySecretIDinternal(s logical.Storage) {
 
 // pathTidySecretIDUpdate is used to delete the expired SecretID entries
 func (b *backend) pathTidySecretIDUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return b.tidySecretID(ctx, req)
+	return b.tidySecretID(ctx, req, time.Duration(data.Get("unused_for").(int))*time.Second)
 }
 
 const (
 	pathTidySecretIDSyn  = "Trigger the clean-up of expired SecretID entries."
 	pathTidySecretIDDesc = `SecretIDs will have expiration time attached to them. The periodic function
 of the backend will look for expired entries and delete them. This happens once in a minute. Invoking
-this endpoint will trigger the clean-up action, without waiting for the backend's periodic function.`
+this endpoint will trigger the clean-up action, without waiting for the backend's periodic function.
+If 'unused_for' is set, SecretIDs that have not been used to log in for that long are deleted as well.`
 )
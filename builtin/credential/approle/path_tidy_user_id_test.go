@@ -75,7 +75,7 @@ func TestAppRole_TidyDanglingAccessors_Normal(t *testing.T) {
 
 	_, err = b.tidySecretID(context.Background(), &logical.Request{
 		Storage: storage,
-	})
+	}, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -118,7 +118,7 @@ func TestAppRole_TidyDanglingAccessors_RaceTest(t *testing.T) {
 		if time.Now().Sub(start) > 100*time.Millisecond && atomic.LoadUint32(b.tidySecretIDCASGuard) == 0 {
 			_, err = b.tidySecretID(context.Background(), &logical.Request{
 				Storage: storage,
-			})
+			}, 0)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -169,7 +169,7 @@ func TestAppRole_TidyDanglingAccessors_RaceTest(t *testing.T) {
 	// Run tidy again
 	secret, err := b.tidySecretID(context.Background(), &logical.Request{
 		Storage: storage,
-	})
+	}, 0)
 	if err != nil || len(secret.Warnings) > 0 {
 		t.Fatal(err, secret.Warnings)
 	}
@@ -204,3 +204,58 @@ func TestAppRole_TidyDanglingAccessors_RaceTest(t *testing.T) {
 		t.Fatalf("bad: len(secretIDs); expect %d, got %d", count, len(secretIDs))
 	}
 }
+
+func TestAppRole_TidyUnusedSecretIDs(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+	createRole(t, b, storage, "role1", "default")
+
+	var accessors []string
+	for i := 0; i < 2; i++ {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "role/role1/secret-id",
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("err:%v resp:%#v", err, resp)
+		}
+		accessors = append(accessors, resp.Data["secret_id_accessor"].(string))
+	}
+
+	// The first secret ID was last used 40 days ago.
+	updateSecretIDEntries(t, b, storage, "role1", func(entry *secretIDStorageEntry) {
+		if entry.SecretIDAccessor == accessors[0] {
+			entry.LastUsedTime = time.Now().Add(-40 * 24 * time.Hour)
+		}
+	})
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "tidy/secret-id",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"unused_for": "720h",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	// Let tidy finish
+	for atomic.LoadUint32(b.tidySecretIDCASGuard) != 0 {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "role/role1/secret-id",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	keys := resp.Data["keys"].([]string)
+	if len(keys) != 1 || keys[0] != accessors[1] {
+		t.Fatalf("expected only the recently created secret ID to remain, got %v", keys)
+	}
+}
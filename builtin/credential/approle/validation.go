@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	uuid "github.com/hashicorp/go-uuid"
@@ -56,6 +57,15 @@ type secretIDStorageEntry struct {
 	// restrictions on the usage of the token generated by this SecretID
 	TokenBoundCIDRs []string `json:"token_cidr_list" mapstructure:"token_bound_cidrs"`
 
+	// The time when the SecretID was last used to log in
+	LastUsedTime time.Time `json:"last_used_time" mapstructure:"last_used_time"`
+
+	// Number of times the SecretID was used to log in
+	UseCount int `json:"use_count" mapstructure:"use_count"`
+
+	// Source address of the last login using the SecretID
+	LastUsedSourceIP string `json:"last_used_source_ip" mapstructure:"last_used_source_ip"`
+
 	// This is a deprecated field
 	SecretIDNumUsesDeprecated int `json:"SecretIDNumUses" mapstructure:"SecretIDNumUses"`
 }
@@ -70,6 +80,116 @@ type secretIDAccessorStorageEntry struct {
 	SecretIDHMAC string `json:"secret_id_hmac" mapstructure:"secret_id_hmac"`
 }
 
+// recordUse records a login using the SecretID.
+func (entry *secretIDStorageEntry) recordUse(usedAt time.Time, sourceIP string) {
+	entry.UseCount++
+	entry.LastUsedTime = usedAt
+	if sourceIP != "" {
+		entry.LastUsedSourceIP = sourceIP
+	}
+}
+
+// secretIDUseQueueSize is the number of SecretID uses that can wait to be
+// recorded. Uses beyond it are not recorded.
+const secretIDUseQueueSize = 1024
+
+// secretIDUse is a login using a SecretID, waiting to be recorded.
+type secretIDUse struct {
+	storage            logical.Storage
+	roleSecretIDPrefix string
+	roleNameHMAC       string
+	secretIDHMAC       string
+	usedAt             time.Time
+	sourceIP           string
+}
+
+// recordSecretIDUse queues a login using the SecretID to be recorded in the
+// background. Recording is best-effort: uses are dropped when the queue is
+// full, failures are logged and do not affect the login, and nothing is
+// recorded on nodes whose storage is read-only.
+func (b *backend) recordSecretIDUse(req *logical.Request, roleSecretIDPrefix, roleNameHMAC, secretIDHMAC string) {
+	b.secretIDUseStart.Do(func() {
+		go b.secretIDUseWorker()
+	})
+
+	// The request, including its storage, is not usable once the login
+	// returns
+	use := &secretIDUse{
+		storage:            req.Storage,
+		roleSecretIDPrefix: roleSecretIDPrefix,
+		roleNameHMAC:       roleNameHMAC,
+		secretIDHMAC:       secretIDHMAC,
+		usedAt:             time.Now(),
+	}
+	if req.Connection != nil {
+		use.sourceIP = req.Connection.RemoteAddr
+	}
+
+	b.secretIDUseWG.Add(1)
+	select {
+	case b.secretIDUses <- use:
+	default:
+		b.secretIDUseWG.Done()
+		b.Logger().Warn("too many secret ID uses waiting to be recorded, dropping one")
+	}
+}
+
+// secretIDUseWorker records the queued SecretID uses one at a time, until the
+// backend is cleaned up.
+func (b *backend) secretIDUseWorker() {
+	for {
+		select {
+		case use := <-b.secretIDUses:
+			b.storeSecretIDUse(use)
+			b.secretIDUseWG.Done()
+		case <-b.secretIDUseStop:
+			return
+		}
+	}
+}
+
+// storeSecretIDUse records a use in the storage entry of the SecretID.
+func (b *backend) storeSecretIDUse(use *secretIDUse) {
+	// The request context is canceled once the login returns
+	ctx := context.Background()
+
+	lock := b.secretIDLock(use.secretIDHMAC)
+	lock.Lock()
+	defer lock.Unlock()
+
+	entry, err := b.nonLockedSecretIDStorageEntry(ctx, use.storage, use.roleSecretIDPrefix, use.roleNameHMAC, use.secretIDHMAC)
+	if err != nil {
+		b.Logger().Warn("failed to read secret ID to record its use", "error", err)
+		return
+	}
+	if entry == nil {
+		// The SecretID was deleted in the meantime
+		return
+	}
+
+	entry.recordUse(use.usedAt, use.sourceIP)
+	err = b.nonLockedSetSecretIDStorageEntry(ctx, use.storage, use.roleSecretIDPrefix, use.roleNameHMAC, use.secretIDHMAC, entry)
+	switch {
+	case err == nil:
+	case strings.Contains(err.Error(), logical.ErrReadOnly.Error()):
+		if b.Logger().IsDebug() {
+			b.Logger().Debug("not recording secret ID use on read-only node")
+		}
+	default:
+		b.Logger().Warn("failed to record secret ID use", "error", err)
+	}
+}
+
+// unusedFor returns whether the SecretID has not been used to log in for at
+// least the given duration, counting from its creation if it was never used.
+func (entry *secretIDStorageEntry) unusedFor(d time.Duration, now time.Time) bool {
+	lastUsed := entry.LastUsedTime
+	if lastUsed.IsZero() {
+		lastUsed = entry.CreationTime
+	}
+	return !now.Before(lastUsed.Add(d))
+}
+
 // verifyCIDRRoleSecretIDSubset checks if the CIDR blocks set on the secret ID
 // are a subset of CIDR blocks set on the role
 func verifyCIDRRoleSecretIDSubset(secretIDCIDRs []string, roleBoundCIDRList []string) error {
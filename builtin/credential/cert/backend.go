@@ -2,9 +2,11 @@ package cert
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"sync"
 
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -33,12 +35,15 @@ func Backend() *backend {
 			pathCerts(&b),
 			pathCRLs(&b),
 		},
-		AuthRenew:   b.pathLoginRenew,
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeCredential,
+		AuthRenew:    b.pathLoginRenew,
+		Invalidate:   b.invalidate,
+		PeriodicFunc: b.refreshSpiffeBundles,
+		BackendType:  logical.TypeCredential,
 	}
 
 	b.crlUpdateMutex = &sync.RWMutex{}
+	b.spiffeBundles = make(map[string]*spiffeBundle)
+	b.spiffeBundleClient = cleanhttp.DefaultClient()
 
 	return &b
 }
@@ -49,6 +54,15 @@ type backend struct {
 
 	crls           map[string]CRLInfo
 	crlUpdateMutex *sync.RWMutex
+
+	// spiffeBundles holds the SPIFFE bundles of the certificate entries,
+	// keyed by their source.
+	spiffeBundles     map[string]*spiffeBundle
+	spiffeBundlesLock sync.Mutex
+
+	// spiffeBundleClient is used to fetch SPIFFE bundles from their
+	// endpoints.
+	spiffeBundleClient *http.Client
 }
 
 func (b *backend) invalidate(_ context.Context, key string) {
@@ -69,4 +83,7 @@ Trusted certificates are configured using the "certs/" endpoint
 by a user with root access. A certificate authority can be trusted,
 which permits all keys signed by it. Alternatively, self-signed
 certificates can be trusted avoiding the need for a CA.
+The certificate authorities of SPIFFE trust bundles can be trusted
+as well, read from a local file or kept up to date from a bundle
+endpoint.
 `
@@ -42,7 +42,7 @@ func pathCerts(b *backend) *framework.Path {
 			"certificate": {
 				Type: framework.TypeString,
 				Description: `The public certificate that should be trusted.
-Must be x509 PEM encoded.`,
+Must be x509 PEM encoded. May be omitted if spiffe_bundle is set.`,
 				DisplayAttrs: &framework.DisplayAttributes{
 					EditType: "file",
 				},
@@ -98,6 +98,32 @@ At least one must exist in the SANs. Supports globbing.`,
 				},
 			},
 
+			"allowed_spiffe_ids": {
+				Type: framework.TypeCommaStringSlice,
+				Description: `A comma-separated list of SPIFFE IDs, formatted as
+"spiffe://<trust domain>[/<path>]". The certificate must be an X509-SVID whose
+SPIFFE ID matches at least one. Without a path, any SPIFFE ID of the trust
+domain matches. Supports globbing on the path.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:  "Allowed SPIFFE IDs",
+					Group: "Constraints",
+				},
+			},
+
+			"spiffe_bundle": {
+				Type: framework.TypeString,
+				Description: `The absolute path of a local file, or the https URL of a
+SPIFFE bundle endpoint, holding a SPIFFE trust bundle whose X.509 authorities
+should be trusted. The bundle may be in the SPIFFE (JWKS) bundle format or
+PEM encoded. Files are read when the certificate is written.`,
+			},
+
+			"spiffe_bundle_refresh_interval": {
+				Type: framework.TypeDurationSecond,
+				Description: `How often a spiffe_bundle endpoint is reloaded. If not
+set, the refresh hint of the bundle is used, or else 5 minutes.`,
+			},
+
 			"allowed_organizational_units": {
 				Type: framework.TypeCommaStringSlice,
 				Description: `A comma-separated list of Organizational Units names.
@@ -128,6 +154,12 @@ separated by a dash (-) instead of a dot (.) to allow usage in ACL templates.`,
 certificate.`,
 			},
 
+			"bind_token_to_cert": {
+				Type: framework.TypeBool,
+				Description: `If true, tokens issued on login can only be used
+over connections presenting the client certificate used to log in.`,
+			},
+
 			"policies": {
 				Type:        framework.TypeCommaStringSlice,
 				Description: tokenutil.DeprecationText("token_policies"),
@@ -249,10 +281,14 @@ func (b *backend) pathCertRead(ctx context.Context, req *logical.Request, d *fra
 		"allowed_dns_sans":             cert.AllowedDNSSANs,
 		"allowed_email_sans":           cert.AllowedEmailSANs,
 		"allowed_uri_sans":             cert.AllowedURISANs,
+		"allowed_spiffe_ids":           cert.AllowedSpiffeIDs,
+		"spiffe_bundle":                cert.SpiffeBundle,
 		"allowed_organizational_units": cert.AllowedOrganizationalUnits,
 		"required_extensions":          cert.RequiredExtensions,
 		"allowed_metadata_extensions":  cert.AllowedMetadataExtensions,
+		"bind_token_to_cert":           cert.BindTokenToCert,
 	}
+	data["spiffe_bundle_refresh_interval"] = int64(cert.SpiffeBundleRefreshInterval.Seconds())
 	cert.PopulateTokenData(data)
 
 	if cert.TTL > 0 {
@@ -312,6 +348,15 @@ func (b *backend) pathCertWrite(ctx context.Context, req *logical.Request, d *fr
 	if allowedURISANsRaw, ok := d.GetOk("allowed_uri_sans"); ok {
 		cert.AllowedURISANs = allowedURISANsRaw.([]string)
 	}
+	if allowedSpiffeIDsRaw, ok := d.GetOk("allowed_spiffe_ids"); ok {
+		cert.AllowedSpiffeIDs = allowedSpiffeIDsRaw.([]string)
+	}
+	if spiffeBundleRaw, ok := d.GetOk("spiffe_bundle"); ok {
+		cert.SpiffeBundle = spiffeBundleRaw.(string)
+	}
+	if spiffeBundleRefreshIntervalRaw, ok := d.GetOk("spiffe_bundle_refresh_interval"); ok {
+		cert.SpiffeBundleRefreshInterval = time.Duration(spiffeBundleRefreshIntervalRaw.(int)) * time.Second
+	}
 	if allowedOrganizationalUnitsRaw, ok := d.GetOk("allowed_organizational_units"); ok {
 		cert.AllowedOrganizationalUnits = allowedOrganizationalUnitsRaw.([]string)
 	}
@@ -322,11 +367,19 @@ func (b *backend) pathCertWrite(ctx context.Context, req *logical.Request, d *fr
 		cert.AllowedMetadataExtensions = allowedMetadataExtensionsRaw.([]string)
 	}
 
+	if bindTokenToCertRaw, ok := d.GetOk("bind_token_to_cert"); ok {
+		cert.BindTokenToCert = bindTokenToCertRaw.(bool)
+	}
+
 	// Get tokenutil fields
 	if err := cert.ParseTokenFields(req, d); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
+	if cert.BindTokenToCert && cert.TokenType == logical.TokenTypeBatch {
+		return logical.ErrorResponse("batch tokens cannot be bound to a certificate"), logical.ErrInvalidRequest
+	}
+
 	// Handle upgrade cases
 	{
 		if err := tokenutil.UpgradeValue(d, "policies", "token_policies", &cert.Policies, &cert.TokenPolicies); err != nil {
@@ -384,13 +437,54 @@ func (b *backend) pathCertWrite(ctx context.Context, req *logical.Request, d *fr
 		cert.DisplayName = name
 	}
 
-	parsed := parsePEM([]byte(cert.Certificate))
-	if len(parsed) == 0 {
-		return logical.ErrorResponse("failed to parse certificate"), nil
+	for _, allowedSpiffeID := range cert.AllowedSpiffeIDs {
+		if err := validateSpiffeIDPattern(allowedSpiffeID); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+	if cert.SpiffeBundleRefreshInterval < 0 {
+		return logical.ErrorResponse("spiffe_bundle_refresh_interval cannot be negative"), nil
+	}
+
+	var parsed []*x509.Certificate
+	if cert.Certificate != "" || cert.SpiffeBundle == "" {
+		parsed = parsePEM([]byte(cert.Certificate))
+		if len(parsed) == 0 {
+			return logical.ErrorResponse("failed to parse certificate"), nil
+		}
+	}
+
+	// Load the SPIFFE bundle now, so that mistakes are reported here rather
+	// than at login
+	cert.SpiffeBundleCertificates = ""
+	if cert.SpiffeBundle != "" {
+		if len(parsed) > 0 && !parsed[0].IsCA {
+			return logical.ErrorResponse("a SPIFFE bundle cannot be combined with a non-CA certificate"), nil
+		}
+		if err := validateSpiffeBundleSource(cert.SpiffeBundle); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		var bundle *spiffeBundle
+		var err error
+		if isSpiffeBundleFile(cert.SpiffeBundle) {
+			bundle, err = readSpiffeBundleFile(cert.SpiffeBundle)
+		} else {
+			bundle, err = b.fetchSpiffeBundle(ctx, cert.SpiffeBundle)
+		}
+		if err != nil {
+			b.Logger().Warn("failed to load SPIFFE bundle", "name", name, "source", cert.SpiffeBundle, "error", err)
+			return logical.ErrorResponse("failed to load SPIFFE bundle; details are in Vault's server logs"), nil
+		}
+		if isSpiffeBundleFile(cert.SpiffeBundle) {
+			cert.SpiffeBundleCertificates = encodeSpiffeBundleCerts(bundle.certs)
+		} else {
+			b.setSpiffeBundle(cert.SpiffeBundle, bundle)
+		}
 	}
 
 	// If the certificate is not a CA cert, then ensure that x509.ExtKeyUsageClientAuth is set
-	if !parsed[0].IsCA && parsed[0].ExtKeyUsage != nil {
+	if len(parsed) > 0 && !parsed[0].IsCA && parsed[0].ExtKeyUsage != nil {
 		var clientAuth bool
 		for _, usage := range parsed[0].ExtKeyUsage {
 			if usage == x509.ExtKeyUsageClientAuth || usage == x509.ExtKeyUsageAny {
@@ -434,10 +528,19 @@ type CertEntry struct {
 	AllowedDNSSANs             []string
 	AllowedEmailSANs           []string
 	AllowedURISANs             []string
+	AllowedSpiffeIDs           []string
 	AllowedOrganizationalUnits []string
 	RequiredExtensions         []string
 	AllowedMetadataExtensions  []string
 	BoundCIDRs                 []*sockaddr.SockAddrMarshaler
+	BindTokenToCert            bool
+
+	// SPIFFE trust bundle whose X.509 authorities are trusted, in addition
+	// to Certificate. The authorities of bundles read from files are stored
+	// in SpiffeBundleCertificates.
+	SpiffeBundle                string
+	SpiffeBundleRefreshInterval time.Duration
+	SpiffeBundleCertificates    string
 }
 
 const pathCertHelpSyn = `
@@ -448,6 +551,19 @@ const pathCertHelpDesc = `
 This endpoint allows you to create, read, update, and delete trusted certificates
 that are allowed to authenticate.
 
+With "bind_token_to_cert", tokens issued on login are bound to the client
+certificate used to log in: requests using them are denied unless made over a
+connection presenting the same certificate, so that a leaked token cannot be
+replayed by other clients. Such tokens cannot be batch tokens.
+
+Workloads presenting SPIFFE X509-SVIDs can be constrained with
+"allowed_spiffe_ids". Instead of, or in addition to, "certificate", the X.509
+authorities of a SPIFFE trust bundle can be trusted with "spiffe_bundle",
+either a local file or a bundle endpoint. Files are read when the certificate
+is written, which must be done again to pick up rotated authorities. Bundle
+endpoints are reloaded every "spiffe_bundle_refresh_interval", or as often as
+the bundle hints.
+
 Deleting a certificate will not revoke auth for prior authenticated connections.
 To do this, do a revoke on "login". If you don't need to revoke login immediately,
 then the next renew will cause the lease to expire.
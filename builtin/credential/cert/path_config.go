@@ -22,6 +22,11 @@ func pathConfig(b *backend) *framework.Path {
 				Default:     false,
 				Description: `If set, metadata of the certificate including the metadata corresponding to allowed_metadata_extensions will be stored in the alias. Defaults to false.`,
 			},
+			"spiffe_id_as_alias": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: `If set, the SPIFFE ID of X509-SVIDs is used as the name of the entity alias, instead of the Common Name. The Common Name is still used for other certificates. X509-SVIDs without a Common Name always use their SPIFFE ID. Defaults to false.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -34,10 +39,12 @@ func pathConfig(b *backend) *framework.Path {
 func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	disableBinding := data.Get("disable_binding").(bool)
 	enableIdentityAliasMetadata := data.Get("enable_identity_alias_metadata").(bool)
+	spiffeIDAsAlias := data.Get("spiffe_id_as_alias").(bool)
 
 	entry, err := logical.StorageEntryJSON("config", config{
 		DisableBinding:              disableBinding,
 		EnableIdentityAliasMetadata: enableIdentityAliasMetadata,
+		SpiffeIDAsAlias:             spiffeIDAsAlias,
 	})
 	if err != nil {
 		return nil, err
@@ -58,6 +65,7 @@ func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, d *f
 	data := map[string]interface{}{
 		"disable_binding":                cfg.DisableBinding,
 		"enable_identity_alias_metadata": cfg.EnableIdentityAliasMetadata,
+		"spiffe_id_as_alias":             cfg.SpiffeIDAsAlias,
 	}
 
 	return &logical.Response{
@@ -85,4 +93,5 @@ func (b *backend) Config(ctx context.Context, s logical.Storage) (*config, error
 type config struct {
 	DisableBinding              bool `json:"disable_binding"`
 	EnableIdentityAliasMetadata bool `json:"enable_identity_alias_metadata"`
+	SpiffeIDAsAlias             bool `json:"spiffe_id_as_alias"`
 }
@@ -67,15 +67,32 @@ func (b *backend) pathLoginAliasLookahead(ctx context.Context, req *logical.Requ
 		return nil, fmt.Errorf("no client certificate found")
 	}
 
+	config, err := b.Config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
 	return &logical.Response{
 		Auth: &logical.Auth{
 			Alias: &logical.Alias{
-				Name: clientCerts[0].Subject.CommonName,
+				Name: aliasName(clientCerts[0], config),
 			},
 		},
 	}, nil
 }
 
+// aliasName returns the entity alias name for a client certificate: its
+// SPIFFE ID if it is an X509-SVID and either the backend is configured to use
+// it or the certificate has no Common Name, or else its Common Name.
+func aliasName(clientCert *x509.Certificate, config *config) string {
+	if config.SpiffeIDAsAlias || clientCert.Subject.CommonName == "" {
+		if id := spiffeID(clientCert); id != nil {
+			return id.String()
+		}
+	}
+	return clientCert.Subject.CommonName
+}
+
 func (b *backend) pathLogin(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	config, err := b.Config(ctx, req.Storage)
 	if err != nil {
@@ -120,6 +137,10 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, data *fra
 		"authority_key_id": certutil.GetHexFormatted(clientCerts[0].AuthorityKeyId, ":"),
 	}
 
+	if id := spiffeID(clientCerts[0]); id != nil {
+		metadata["spiffe_id"] = id.String()
+	}
+
 	// Add metadata from allowed_metadata_extensions when present,
 	// with sanitized oids (dash-separated instead of dot-separated) as keys.
 	for k, v := range b.certificateExtensionsMetadata(clientCerts[0], matched) {
@@ -134,7 +155,7 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, data *fra
 		DisplayName: matched.Entry.DisplayName,
 		Metadata:    metadata,
 		Alias: &logical.Alias{
-			Name: clientCerts[0].Subject.CommonName,
+			Name: aliasName(clientCerts[0], config),
 		},
 	}
 
@@ -144,6 +165,10 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, data *fra
 
 	matched.Entry.PopulateTokenAuth(auth)
 
+	if matched.Entry.BindTokenToCert {
+		auth.BoundCertFingerprint = logical.CertFingerprint(clientCerts[0])
+	}
+
 	return &logical.Response{
 		Auth: auth,
 	}, nil
@@ -286,6 +311,7 @@ func (b *backend) matchesConstraints(clientCert *x509.Certificate, trustedChain
 		b.matchesDNSSANs(clientCert, config) &&
 		b.matchesEmailSANs(clientCert, config) &&
 		b.matchesURISANs(clientCert, config) &&
+		b.matchesSpiffeIDs(clientCert, config) &&
 		b.matchesOrganizationalUnits(clientCert, config) &&
 		b.matchesCertificateExtensions(clientCert, config)
 }
@@ -508,11 +534,19 @@ func (b *backend) loadTrustedCerts(ctx context.Context, storage logical.Storage,
 		}
 
 		parsed := parsePEM([]byte(entry.Certificate))
+		if entry.SpiffeBundle != "" {
+			bundleCerts, err := b.spiffeBundleCerts(entry)
+			if err != nil {
+				b.Logger().Error("failed to load SPIFFE bundle", "name", name, "source", entry.SpiffeBundle, "error", err)
+			}
+			parsed = append(parsed, bundleCerts...)
+		}
 		if len(parsed) == 0 {
 			b.Logger().Error("failed to parse certificate", "name", name)
 			continue
 		}
-		if !parsed[0].IsCA {
+		// The authorities of SPIFFE bundles are always trusted as CAs
+		if !parsed[0].IsCA && entry.SpiffeBundle == "" {
 			trustedNonCAs = append(trustedNonCAs, &ParsedCert{
 				Entry:        entry,
 				Certificates: parsed,
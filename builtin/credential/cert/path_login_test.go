@@ -1,16 +1,24 @@
 package cert
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"io/ioutil"
 	"math/big"
 	mathrand "math/rand"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -197,3 +205,381 @@ func TestCert_RoleResolve_RoleDoesNotExist(t *testing.T) {
 		},
 	})
 }
+
+func TestCert_BindTokenToCert(t *testing.T) {
+	certTemplate := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName: "example.com",
+		},
+		DNSNames:    []string{"example.com"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth,
+		},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement,
+		SerialNumber: big.NewInt(mathrand.Int63()),
+		NotBefore:    time.Now().Add(-30 * time.Second),
+		NotAfter:     time.Now().Add(262980 * time.Hour),
+	}
+
+	tempDir, connState, err := generateTestCertAndConnState(t, certTemplate)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	if err != nil {
+		t.Fatalf("error testing connection state: %v", err)
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(tempDir, "ca_cert.pem"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		CredentialBackend: testFactory(t),
+		Steps: []logicaltest.TestStep{
+			testAccStepCert(t, "web", ca, "foo", allowed{dns: "example.com"}, false),
+			{
+				Operation: logical.UpdateOperation,
+				Path:      "certs/web",
+				Data: map[string]interface{}{
+					"bind_token_to_cert": true,
+				},
+			},
+			{
+				Operation: logical.UpdateOperation,
+				Path:      "certs/web",
+				Data: map[string]interface{}{
+					"token_type": "batch",
+				},
+				ErrorOk: true,
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						t.Fatalf("expected error for certificate-bound batch tokens, got %#v", resp)
+					}
+					return nil
+				},
+			},
+			{
+				Operation: logical.ReadOperation,
+				Path:      "certs/web",
+				Check: func(resp *logical.Response) error {
+					if resp.Data["bind_token_to_cert"] != true {
+						t.Fatalf("bad: bind_token_to_cert: %#v", resp.Data["bind_token_to_cert"])
+					}
+					return nil
+				},
+			},
+			{
+				Operation:       logical.UpdateOperation,
+				Path:            "login",
+				Unauthenticated: true,
+				ConnState:       &connState,
+				Check: func(resp *logical.Response) error {
+					expected := logical.CertFingerprint(connState.PeerCertificates[0])
+					if resp.Auth.BoundCertFingerprint != expected {
+						t.Fatalf("expected token bound to %q, got %q", expected, resp.Auth.BoundCertFingerprint)
+					}
+					return nil
+				},
+				Data: map[string]interface{}{
+					"name": "web",
+				},
+			},
+		},
+	})
+}
+
+func TestCert_SpiffeID(t *testing.T) {
+	spiffeURI, err := url.Parse("spiffe://example.org/ns/prod/sa/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certTemplate := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName: "web",
+		},
+		URIs:        []*url.URL{spiffeURI},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth,
+		},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement,
+		SerialNumber: big.NewInt(mathrand.Int63()),
+		NotBefore:    time.Now().Add(-30 * time.Second),
+		NotAfter:     time.Now().Add(262980 * time.Hour),
+	}
+
+	tempDir, connState, err := generateTestCertAndConnState(t, certTemplate)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	if err != nil {
+		t.Fatalf("error testing connection state: %v", err)
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(tempDir, "ca_cert.pem"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Trust the CA through a SPIFFE bundle rather than the certificate
+	block, _ := pem.Decode(ca)
+	bundle, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]interface{}{
+			{
+				"use": "x509-svid",
+				"kty": "EC",
+				"x5c": []string{base64.StdEncoding.EncodeToString(block.Bytes)},
+			},
+		},
+		"spiffe_refresh_hint": 60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundleFile := filepath.Join(tempDir, "bundle.json")
+	if err := ioutil.WriteFile(bundleFile, bundle, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	b := testFactory(t).(*backend)
+	bundleURL := newSpiffeBundleServer(t, b, func() []byte { return bundle })
+
+	certStep := func(allowedSpiffeIDs string, expectError bool) logicaltest.TestStep {
+		return logicaltest.TestStep{
+			Operation: logical.UpdateOperation,
+			Path:      "certs/web",
+			ErrorOk:   expectError,
+			Data: map[string]interface{}{
+				"spiffe_bundle":      bundleURL,
+				"allowed_spiffe_ids": allowedSpiffeIDs,
+				"policies":           "foo",
+			},
+			Check: func(resp *logical.Response) error {
+				if expectError != (resp != nil && resp.IsError()) {
+					t.Fatalf("bad: expected error %t, got %#v", expectError, resp)
+				}
+				return nil
+			},
+		}
+	}
+	loginStep := func(expectedAlias string) logicaltest.TestStep {
+		return logicaltest.TestStep{
+			Operation:       logical.UpdateOperation,
+			Path:            "login",
+			Unauthenticated: true,
+			ConnState:       &connState,
+			ErrorOk:         expectedAlias == "",
+			Check: func(resp *logical.Response) error {
+				if expectedAlias == "" {
+					if resp.Auth != nil {
+						t.Fatalf("should not be authorized: %#v", resp)
+					}
+					return nil
+				}
+				if resp.Auth == nil {
+					t.Fatalf("expected login to succeed, got %#v", resp)
+				}
+				if resp.Auth.Alias.Name != expectedAlias {
+					t.Fatalf("expected alias %q, got %q", expectedAlias, resp.Auth.Alias.Name)
+				}
+				if resp.Auth.Metadata["spiffe_id"] != spiffeURI.String() {
+					t.Fatalf("bad: spiffe_id metadata: %q", resp.Auth.Metadata["spiffe_id"])
+				}
+				return nil
+			},
+			Data: map[string]interface{}{
+				"name": "web",
+			},
+		}
+	}
+
+	logicaltest.Test(t, logicaltest.TestCase{
+		CredentialBackend: b,
+		Steps: []logicaltest.TestStep{
+			// Bundle files must be given as absolute paths
+			{
+				Operation: logical.UpdateOperation,
+				Path:      "certs/web",
+				ErrorOk:   true,
+				Data: map[string]interface{}{
+					"spiffe_bundle": "bundle.json",
+				},
+				Check: func(resp *logical.Response) error {
+					if resp == nil || !resp.IsError() {
+						t.Fatalf("expected error, got %#v", resp)
+					}
+					return nil
+				},
+			},
+			// Bundle files are read when the certificate is written
+			{
+				Operation: logical.UpdateOperation,
+				Path:      "certs/web",
+				Data: map[string]interface{}{
+					"spiffe_bundle":      bundleFile,
+					"allowed_spiffe_ids": "spiffe://example.org",
+					"policies":           "foo",
+				},
+				Check: func(resp *logical.Response) error {
+					return os.Remove(bundleFile)
+				},
+			},
+			loginStep("web"),
+			certStep("example.org/ns/*", true),
+			certStep("spiffe://Example.org", true),
+			certStep("spiffe://example.org/ns/*/sa/web", false),
+			loginStep("web"),
+			{
+				Operation: logical.UpdateOperation,
+				Path:      "config",
+				Data: map[string]interface{}{
+					"spiffe_id_as_alias": true,
+				},
+			},
+			loginStep(spiffeURI.String()),
+			certStep("spiffe://example.org", false),
+			loginStep(spiffeURI.String()),
+			certStep("spiffe://example.org/ns/dev/*", false),
+			loginStep(""),
+			certStep("spiffe://other.org", false),
+			loginStep(""),
+		},
+	})
+}
+
+func TestCert_SpiffeBundleRefresh(t *testing.T) {
+	spiffeURI, err := url.Parse("spiffe://example.org/web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certTemplate := &x509.Certificate{
+		URIs:        []*url.URL{spiffeURI},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth,
+			x509.ExtKeyUsageClientAuth,
+		},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageKeyAgreement,
+		SerialNumber: big.NewInt(mathrand.Int63()),
+		NotBefore:    time.Now().Add(-30 * time.Second),
+		NotAfter:     time.Now().Add(262980 * time.Hour),
+	}
+
+	tempDir, connState, err := generateTestCertAndConnState(t, certTemplate)
+	if tempDir != "" {
+		defer os.RemoveAll(tempDir)
+	}
+	if err != nil {
+		t.Fatalf("error testing connection state: %v", err)
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(tempDir, "ca_cert.pem"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	otherCA, err := ioutil.ReadFile("test-fixtures/root/rootcacert.pem")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The bundle starts out with an unrelated CA
+	var bundle atomic.Value
+	bundle.Store(otherCA)
+
+	storage := &logical.InmemStorage{}
+	b := testFactory(t).(*backend)
+	bundleURL := newSpiffeBundleServer(t, b, func() []byte { return bundle.Load().([]byte) })
+	ctx := context.Background()
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "certs/web",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"spiffe_bundle":                  bundleURL,
+			"spiffe_bundle_refresh_interval": "1h",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v, resp: %#v", err, resp)
+	}
+
+	login := func() *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       "login",
+			Storage:    storage,
+			Connection: &logical.Connection{ConnState: &connState},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := login(); resp == nil || resp.Auth != nil {
+		t.Fatalf("expected login to fail, got %#v", resp)
+	}
+
+	// Rotating the bundle is only picked up once it is due for a refresh
+	bundle.Store(ca)
+	if err := b.refreshSpiffeBundles(ctx, &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	if resp := login(); resp == nil || resp.Auth != nil {
+		t.Fatalf("expected login to fail, got %#v", resp)
+	}
+
+	b.spiffeBundles[bundleURL].fetchedAt = time.Now().Add(-2 * time.Hour)
+	if err := b.refreshSpiffeBundles(ctx, &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	resp = login()
+	if resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, got %#v", resp)
+	}
+	// Without a Common Name, the SPIFFE ID is used as the alias name
+	if resp.Auth.Alias.Name != spiffeURI.String() {
+		t.Fatalf("expected alias %q, got %q", spiffeURI.String(), resp.Auth.Alias.Name)
+	}
+
+	// Bundles are not loaded while logging in, but by the next refresh
+	b.spiffeBundles = make(map[string]*spiffeBundle)
+	if resp := login(); resp == nil || resp.Auth != nil {
+		t.Fatalf("expected login to fail, got %#v", resp)
+	}
+	if err := b.refreshSpiffeBundles(ctx, &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	if resp := login(); resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, got %#v", resp)
+	}
+
+	// Bundles no longer used are forgotten
+	if _, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "certs/web",
+		Storage:   storage,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.refreshSpiffeBundles(ctx, &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.spiffeBundles) != 0 {
+		t.Fatalf("expected bundles to be forgotten, got %#v", b.spiffeBundles)
+	}
+}
+
+// newSpiffeBundleServer starts a SPIFFE bundle endpoint serving the bundle
+// returned by the given function, trusted by the backend, and returns its
+// URL.
+func newSpiffeBundleServer(t *testing.T, b *backend, bundle func() []byte) string {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bundle())
+	}))
+	t.Cleanup(srv.Close)
+	b.spiffeBundleClient = srv.Client()
+	return srv.URL + "/bundle"
+}
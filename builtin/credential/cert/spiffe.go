@@ -0,0 +1,353 @@
+package cert
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	glob "github.com/ryanuber/go-glob"
+)
+
+const (
+	spiffeScheme = "spiffe"
+
+	// defaultSpiffeBundleRefreshInterval is used for bundles that give no
+	// refresh hint, when the certificate entry does not set an interval.
+	defaultSpiffeBundleRefreshInterval = 5 * time.Minute
+
+	// maxSpiffeBundleSize bounds the size of the bundles read.
+	maxSpiffeBundleSize = 1 << 20
+
+	spiffeBundleFetchTimeout = 30 * time.Second
+)
+
+// spiffeBundle is a SPIFFE trust bundle loaded from a file or a bundle
+// endpoint.
+type spiffeBundle struct {
+	certs       []*x509.Certificate
+	refreshHint time.Duration
+	fetchedAt   time.Time
+}
+
+// spiffeID returns the SPIFFE ID of an X509-SVID, which has exactly one URI
+// SAN, with the spiffe scheme. It returns nil for other certificates.
+func spiffeID(cert *x509.Certificate) *url.URL {
+	if len(cert.URIs) != 1 {
+		return nil
+	}
+	id := cert.URIs[0]
+	if id.Scheme != spiffeScheme || id.Host == "" || id.User != nil || id.Port() != "" || id.RawQuery != "" || id.Fragment != "" {
+		return nil
+	}
+	return id
+}
+
+// validateSpiffeIDPattern checks that an allowed SPIFFE ID is of the form
+// spiffe://<trust domain>[/<path>], where the path may use globbing.
+func validateSpiffeIDPattern(pattern string) error {
+	trustDomain, path := splitSpiffeIDPattern(pattern)
+	if trustDomain == "" {
+		return fmt.Errorf("%q is not of the form spiffe://<trust domain>[/<path>]", pattern)
+	}
+	for _, c := range trustDomain {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return fmt.Errorf("trust domain of %q must only contain lowercase letters, numbers, dots, dashes and underscores", pattern)
+		}
+	}
+	if path != "" && !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("%q is not of the form spiffe://<trust domain>[/<path>]", pattern)
+	}
+	return nil
+}
+
+// splitSpiffeIDPattern splits an allowed SPIFFE ID into its trust domain and
+// its path, the latter being empty when the whole trust domain is allowed.
+func splitSpiffeIDPattern(pattern string) (string, string) {
+	rest := strings.TrimPrefix(pattern, spiffeScheme+"://")
+	if rest == pattern {
+		return "", ""
+	}
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		return rest[:i], rest[i:]
+	}
+	return rest, ""
+}
+
+// matchesSpiffeIDs verifies that the certificate is an X509-SVID whose SPIFFE
+// ID matches at least one configured allowed SPIFFE ID. An allowed SPIFFE ID
+// without a path allows any workload of its trust domain, otherwise the path
+// supports globbing.
+func (b *backend) matchesSpiffeIDs(clientCert *x509.Certificate, config *ParsedCert) bool {
+	// Default behavior (no SPIFFE IDs) is to allow all certificates
+	if len(config.Entry.AllowedSpiffeIDs) == 0 {
+		return true
+	}
+	id := spiffeID(clientCert)
+	if id == nil {
+		return false
+	}
+	for _, allowed := range config.Entry.AllowedSpiffeIDs {
+		trustDomain, path := splitSpiffeIDPattern(allowed)
+		if trustDomain != id.Host {
+			continue
+		}
+		if path == "" || glob.Glob(path, id.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSpiffeBundleFile returns whether a bundle source is a local file rather
+// than a bundle endpoint.
+func isSpiffeBundleFile(source string) bool {
+	return !strings.Contains(source, "://")
+}
+
+// validateSpiffeBundleSource checks that a bundle source is either the
+// absolute path of a local file or the https URL of a bundle endpoint.
+func validateSpiffeBundleSource(source string) error {
+	if isSpiffeBundleFile(source) {
+		if !filepath.IsAbs(source) {
+			return errors.New("SPIFFE bundle files must be given as absolute paths")
+		}
+		return nil
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("SPIFFE bundle endpoints must be https URLs")
+	}
+	return nil
+}
+
+// readSpiffeBundleFile reads and parses the bundle in the given local file.
+// Files are only read when the certificate entry is written, and only the
+// X.509 authorities they hold are kept.
+func readSpiffeBundleFile(path string) (*spiffeBundle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, errors.New("SPIFFE bundle is not a regular file")
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(f, maxSpiffeBundleSize))
+	if err != nil {
+		return nil, err
+	}
+	return parseSpiffeBundle(raw)
+}
+
+// encodeSpiffeBundleCerts PEM encodes the X.509 authorities of a bundle, to
+// be stored in the certificate entry.
+func encodeSpiffeBundleCerts(certs []*x509.Certificate) string {
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.String()
+}
+
+// fetchSpiffeBundle reads and parses the bundle at the given bundle endpoint.
+func (b *backend) fetchSpiffeBundle(ctx context.Context, source string) (*spiffeBundle, error) {
+	if err := validateSpiffeBundleSource(source); err != nil {
+		return nil, err
+	}
+	if isSpiffeBundleFile(source) {
+		return nil, errors.New("SPIFFE bundle files are not fetched")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, spiffeBundleFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.spiffeBundleClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %q from SPIFFE bundle endpoint", resp.Status)
+	}
+	raw, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSpiffeBundleSize))
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := parseSpiffeBundle(raw)
+	if err != nil {
+		return nil, err
+	}
+	bundle.fetchedAt = time.Now()
+	return bundle, nil
+}
+
+// parseSpiffeBundle parses a SPIFFE bundle, either in the JWKS-based SPIFFE
+// bundle format, from which the X.509 authorities are kept, or as PEM
+// encoded certificates.
+func parseSpiffeBundle(raw []byte) (*spiffeBundle, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '{' {
+		certs := parsePEM(raw)
+		if len(certs) == 0 {
+			return nil, errors.New("no certificates found in PEM SPIFFE bundle")
+		}
+		return &spiffeBundle{certs: certs}, nil
+	}
+
+	var doc struct {
+		Keys []struct {
+			Use string   `json:"use"`
+			X5c []string `json:"x5c"`
+		} `json:"keys"`
+		RefreshHint int64 `json:"spiffe_refresh_hint"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing SPIFFE bundle: %w", err)
+	}
+
+	bundle := &spiffeBundle{
+		refreshHint: time.Duration(doc.RefreshHint) * time.Second,
+	}
+	for i, key := range doc.Keys {
+		if key.Use != "x509-svid" {
+			continue
+		}
+		if len(key.X5c) != 1 {
+			return nil, fmt.Errorf("X.509 authority %d of SPIFFE bundle must have exactly one certificate", i)
+		}
+		der, err := base64.StdEncoding.DecodeString(key.X5c[0])
+		if err != nil {
+			return nil, fmt.Errorf("error decoding X.509 authority %d of SPIFFE bundle: %w", i, err)
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing X.509 authority %d of SPIFFE bundle: %w", i, err)
+		}
+		bundle.certs = append(bundle.certs, cert)
+	}
+	if len(bundle.certs) == 0 {
+		return nil, errors.New("no X.509 authorities found in SPIFFE bundle")
+	}
+	return bundle, nil
+}
+
+// spiffeBundleCerts returns the certificates of the SPIFFE bundle of a
+// certificate entry. Bundles read from files are stored in the entry, and
+// those of bundle endpoints are loaded when the entry is written or by
+// refreshSpiffeBundles, never while logging in, so that an unreachable
+// endpoint does not hold up logins.
+func (b *backend) spiffeBundleCerts(entry *CertEntry) ([]*x509.Certificate, error) {
+	if isSpiffeBundleFile(entry.SpiffeBundle) {
+		certs := parsePEM([]byte(entry.SpiffeBundleCertificates))
+		if len(certs) == 0 {
+			return nil, errors.New("no certificates stored for SPIFFE bundle file")
+		}
+		return certs, nil
+	}
+
+	b.spiffeBundlesLock.Lock()
+	defer b.spiffeBundlesLock.Unlock()
+
+	bundle, ok := b.spiffeBundles[entry.SpiffeBundle]
+	if !ok {
+		return nil, errors.New("SPIFFE bundle not loaded yet")
+	}
+	return bundle.certs, nil
+}
+
+// setSpiffeBundle records a freshly loaded bundle.
+func (b *backend) setSpiffeBundle(source string, bundle *spiffeBundle) {
+	b.spiffeBundlesLock.Lock()
+	defer b.spiffeBundlesLock.Unlock()
+	b.spiffeBundles[source] = bundle
+}
+
+// refreshSpiffeBundles loads the SPIFFE bundles of the certificate entries
+// that are not loaded yet, such as after a restart, reloads those whose
+// refresh interval has elapsed, and forgets those no longer used by any
+// entry. A bundle that fails to reload keeps being used until the next
+// attempt. Bundles read from files are stored in their entry and are only
+// reloaded when it is written.
+func (b *backend) refreshSpiffeBundles(ctx context.Context, req *logical.Request) error {
+	names, err := req.Storage.List(ctx, "cert/")
+	if err != nil {
+		return err
+	}
+
+	inUse := make(map[string]bool)
+	for _, name := range names {
+		entry, err := b.Cert(ctx, req.Storage, name)
+		if err != nil {
+			b.Logger().Error("failed to load trusted cert", "name", name, "error", err)
+			continue
+		}
+		if entry == nil || entry.SpiffeBundle == "" || isSpiffeBundleFile(entry.SpiffeBundle) {
+			continue
+		}
+		if inUse[entry.SpiffeBundle] {
+			// Shared with an entry handled already
+			continue
+		}
+		inUse[entry.SpiffeBundle] = true
+
+		b.spiffeBundlesLock.Lock()
+		bundle, ok := b.spiffeBundles[entry.SpiffeBundle]
+		b.spiffeBundlesLock.Unlock()
+		if ok && time.Since(bundle.fetchedAt) < spiffeBundleRefreshInterval(entry, bundle) {
+			continue
+		}
+
+		refreshed, err := b.fetchSpiffeBundle(ctx, entry.SpiffeBundle)
+		if err != nil {
+			b.Logger().Warn("failed to refresh SPIFFE bundle", "name", name, "source", entry.SpiffeBundle, "error", err)
+			continue
+		}
+		b.setSpiffeBundle(entry.SpiffeBundle, refreshed)
+	}
+
+	b.spiffeBundlesLock.Lock()
+	defer b.spiffeBundlesLock.Unlock()
+	for source := range b.spiffeBundles {
+		if !inUse[source] {
+			delete(b.spiffeBundles, source)
+		}
+	}
+	return nil
+}
+
+// spiffeBundleRefreshInterval returns how often the bundle of an entry is
+// reloaded: the interval set on the entry, or else the refresh hint of the
+// bundle.
+func spiffeBundleRefreshInterval(entry *CertEntry, bundle *spiffeBundle) time.Duration {
+	switch {
+	case entry.SpiffeBundleRefreshInterval > 0:
+		return entry.SpiffeBundleRefreshInterval
+	case bundle.refreshHint > 0:
+		return bundle.refreshHint
+	default:
+		return defaultSpiffeBundleRefreshInterval
+	}
+}
@@ -0,0 +1,104 @@
+package github
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/go-github/github"
+)
+
+const (
+	// appJWTLifetime is how long the JWTs authenticating as the GitHub App
+	// are valid for, GitHub allowing at most 10 minutes.
+	appJWTLifetime = 9 * time.Minute
+
+	// installationTokenExpiryDelta is how long before it expires an
+	// installation token is replaced.
+	installationTokenExpiryDelta = 5 * time.Minute
+)
+
+// parseAppPrivateKey parses the PEM encoded private key of a GitHub App.
+func parseAppPrivateKey(privateKey string) (*rsa.PrivateKey, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKey))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing app_private_key: %w", err)
+	}
+	return key, nil
+}
+
+// appJWT returns a JWT authenticating as the configured GitHub App.
+func (c *config) appJWT() (string, error) {
+	key, err := parseAppPrivateKey(c.AppPrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Backdate the JWT a little to allow for clock drift
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    strconv.FormatInt(c.AppID, 10),
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(appJWTLifetime)),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+}
+
+// appClient returns a GitHub client authenticating as the configured GitHub
+// App.
+func (b *backend) appClient(config *config) (*github.Client, error) {
+	appJWT, err := config.appJWT()
+	if err != nil {
+		return nil, err
+	}
+	return b.configuredClient(config, appJWT)
+}
+
+// setInstallationID sets the ID of the installation of the configured
+// GitHub App on the organization.
+func (c *config) setInstallationID(ctx context.Context, client *github.Client) error {
+	installation, _, err := client.Apps.FindOrganizationInstallation(ctx, c.Organization)
+	if err != nil {
+		return err
+	}
+
+	installationID := installation.GetID()
+	if installationID == 0 {
+		return fmt.Errorf("app_installation_id not found for %s", c.Organization)
+	}
+
+	c.AppInstallationID = installationID
+
+	return nil
+}
+
+// installationClient returns a GitHub client authenticating as the
+// installation of the configured GitHub App on the organization. Its access
+// token is reused until shortly before it expires.
+func (b *backend) installationClient(ctx context.Context, config *config) (*github.Client, error) {
+	b.appLock.Lock()
+	defer b.appLock.Unlock()
+
+	if b.installationToken == nil || time.Until(b.installationToken.GetExpiresAt()) < installationTokenExpiryDelta {
+		client, err := b.appClient(config)
+		if err != nil {
+			return nil, err
+		}
+		// The go-github version in use still creates installation tokens
+		// through an endpoint GitHub has since removed
+		tokenReq, err := client.NewRequest("POST", fmt.Sprintf("app/installations/%d/access_tokens", config.AppInstallationID), nil)
+		if err != nil {
+			return nil, err
+		}
+		var token github.InstallationToken
+		if _, err := client.Do(ctx, tokenReq, &token); err != nil {
+			return nil, fmt.Errorf("error creating GitHub App installation token: %w", err)
+		}
+		b.installationToken = &token
+	}
+
+	return b.configuredClient(config, b.installationToken.GetToken())
+}
@@ -2,12 +2,16 @@ package github
 
 import (
 	"context"
+	"fmt"
 	"net/url"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/github"
 	cleanhttp "github.com/hashicorp/go-cleanhttp"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/patrickmn/go-cache"
 	"golang.org/x/oauth2"
 )
 
@@ -20,7 +24,9 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 }
 
 func Backend() *backend {
-	var b backend
+	b := backend{
+		membershipCache: cache.New(cache.NoExpiration, time.Minute),
+	}
 	b.TeamMap = &framework.PolicyMap{
 		PathMap: framework.PathMap{
 			Name: "teams",
@@ -35,7 +41,14 @@ func Backend() *backend {
 		DefaultKey: "default",
 	}
 
+	b.OrgRoleMap = &framework.PolicyMap{
+		PathMap: framework.PathMap{
+			Name: "org_roles",
+		},
+	}
+
 	allPaths := append(b.TeamMap.Paths(), b.UserMap.Paths()...)
+	allPaths = append(allPaths, b.OrgRoleMap.Paths()...)
 	b.Backend = &framework.Backend{
 		Help: backendHelp,
 
@@ -43,10 +56,16 @@ func Backend() *backend {
 			Unauthenticated: []string{
 				"login",
 			},
+
+			SealWrapStorage: []string{
+				"config",
+			},
 		},
 
 		Paths:       append([]*framework.Path{pathConfig(&b), pathLogin(&b)}, allPaths...),
 		AuthRenew:   b.pathLoginRenew,
+		Invalidate:  b.invalidate,
+		Clean:       b.cleanup,
 		BackendType: logical.TypeCredential,
 	}
 
@@ -59,6 +78,40 @@ type backend struct {
 	TeamMap *framework.PolicyMap
 
 	UserMap *framework.PolicyMap
+
+	// OrgRoleMap maps the role of users in the organization, admin or
+	// member, to policies.
+	OrgRoleMap *framework.PolicyMap
+
+	// membershipCache caches the organization memberships of users, keyed by
+	// organization and user ID, for the configured membership_cache_ttl.
+	membershipCache *cache.Cache
+
+	// installationToken is the access token of the configured GitHub App
+	// installation, reused until shortly before it expires.
+	installationToken *github.InstallationToken
+	appLock           sync.Mutex
+}
+
+func (b *backend) invalidate(_ context.Context, key string) {
+	switch key {
+	case "config":
+		b.reset()
+	}
+}
+
+func (b *backend) cleanup(_ context.Context) {
+	b.reset()
+}
+
+// reset flushes the membership cache and the installation token, so that
+// they are fetched again with the current configuration.
+func (b *backend) reset() {
+	b.appLock.Lock()
+	b.installationToken = nil
+	b.appLock.Unlock()
+
+	b.membershipCache.Flush()
 }
 
 // Client returns the GitHub client to communicate to GitHub via the
@@ -80,6 +133,25 @@ func (b *backend) Client(token string) (*github.Client, error) {
 	return client, nil
 }
 
+// configuredClient returns a GitHub client authenticating with the given
+// token against the configured API endpoint.
+func (b *backend) configuredClient(config *config, token string) (*github.Client, error) {
+	client, err := b.Client(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.BaseURL != "" {
+		parsedURL, err := url.Parse(config.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("successfully parsed base_url when set but failing to parse now: %w", err)
+		}
+		client.BaseURL = parsedURL
+	}
+
+	return client, nil
+}
+
 // tokenSource is an oauth2.TokenSource implementation.
 type tokenSource struct {
 	Value string
@@ -95,7 +167,9 @@ The GitHub credential provider allows authentication via GitHub.
 Users provide a personal access token to log in, and the credential
 provider verifies they're part of the correct organization and then
 maps the user to a set of Vault policies according to the teams they're
-part of.
+part of and their role in the organization. When a GitHub App is
+configured, memberships are looked up as the App installation, and the
+token of the user is only used to identify them.
 
 After enabling the credential provider, use the "config" route to
 configure it.
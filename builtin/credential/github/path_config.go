@@ -36,6 +36,46 @@ API-compatible authentication server.`,
 					Group: "GitHub Options",
 				},
 			},
+			"app_id": {
+				Type: framework.TypeInt64,
+				Description: `The ID of a GitHub App installed on the
+organization. If set, organization and team memberships are
+looked up as the App, so that user tokens only need to identify
+the user.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:  "App ID",
+					Group: "GitHub App",
+				},
+			},
+			"app_private_key": {
+				Type:        framework.TypeString,
+				Description: "The PEM encoded private key of the GitHub App.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Group:     "GitHub App",
+					Sensitive: true,
+				},
+			},
+			"app_installation_id": {
+				Type: framework.TypeInt64,
+				Description: `The ID of the installation of the GitHub App
+on the organization. Looked up if not set.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:  "App installation ID",
+					Group: "GitHub App",
+				},
+			},
+			"membership_cache_ttl": {
+				Type: framework.TypeDurationSecond,
+				Description: `Duration for which the organization role and
+teams of a user are cached, so that logins within it do not look
+them up again. If zero, they are looked up on every login.`,
+			},
+			"map_teams_by_slug": {
+				Type: framework.TypeBool,
+				Description: `If set, teams are mapped to policies and
+group aliases by their slug only, rather than by both their name
+and slug.`,
+			},
 			"ttl": {
 				Type:        framework.TypeDurationSecond,
 				Description: tokenutil.DeprecationText("token_ttl"),
@@ -52,6 +92,9 @@ API-compatible authentication server.`,
 			logical.UpdateOperation: b.pathConfigWrite,
 			logical.ReadOperation:   b.pathConfigRead,
 		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
 	}
 
 	tokenutil.AddTokenFields(p.Fields)
@@ -113,6 +156,54 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, dat
 		}
 	}
 
+	if appIDRaw, ok := data.GetOk("app_id"); ok {
+		c.AppID = appIDRaw.(int64)
+	}
+	if appPrivateKeyRaw, ok := data.GetOk("app_private_key"); ok {
+		c.AppPrivateKey = appPrivateKeyRaw.(string)
+	}
+	if appInstallationIDRaw, ok := data.GetOk("app_installation_id"); ok {
+		c.AppInstallationID = appInstallationIDRaw.(int64)
+	}
+
+	if c.AppID != 0 {
+		if c.AppPrivateKey == "" {
+			return logical.ErrorResponse("app_private_key is required when app_id is set"), nil
+		}
+		if _, err := parseAppPrivateKey(c.AppPrivateKey); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		if c.AppInstallationID == 0 {
+			client, err := b.appClient(c)
+			if err != nil {
+				return nil, err
+			}
+			// ensure our client has the BaseURL if it was provided
+			if parsedURL != nil {
+				client.BaseURL = parsedURL
+			}
+
+			err = c.setInstallationID(ctx, client)
+			if err != nil {
+				errorMsg := fmt.Errorf("unable to fetch the app_installation_id, you must manually set it in the config: %s", err)
+				b.Logger().Error(errorMsg.Error())
+				return nil, errorMsg
+			}
+		}
+	}
+
+	if membershipCacheTTLRaw, ok := data.GetOk("membership_cache_ttl"); ok {
+		c.MembershipCacheTTL = time.Duration(membershipCacheTTLRaw.(int)) * time.Second
+	}
+	if c.MembershipCacheTTL < 0 {
+		return logical.ErrorResponse("membership_cache_ttl cannot be negative"), nil
+	}
+
+	if mapTeamsBySlugRaw, ok := data.GetOk("map_teams_by_slug"); ok {
+		c.MapTeamsBySlug = mapTeamsBySlugRaw.(bool)
+	}
+
 	if err := c.ParseTokenFields(req, data); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
@@ -137,6 +228,10 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, dat
 		return nil, err
 	}
 
+	// Cached memberships and installation tokens may no longer match the
+	// configuration
+	b.reset()
+
 	if len(resp.Warnings) == 0 {
 		return nil, nil
 	}
@@ -157,6 +252,11 @@ func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, data
 		"organization_id": config.OrganizationID,
 		"organization":    config.Organization,
 		"base_url":        config.BaseURL,
+
+		"app_id":               config.AppID,
+		"app_installation_id":  config.AppInstallationID,
+		"membership_cache_ttl": int64(config.MembershipCacheTTL.Seconds()),
+		"map_teams_by_slug":    config.MapTeamsBySlug,
 	}
 	config.PopulateTokenData(d)
 
@@ -207,6 +307,12 @@ type config struct {
 	BaseURL        string        `json:"base_url" structs:"base_url" mapstructure:"base_url"`
 	TTL            time.Duration `json:"ttl" structs:"ttl" mapstructure:"ttl"`
 	MaxTTL         time.Duration `json:"max_ttl" structs:"max_ttl" mapstructure:"max_ttl"`
+
+	AppID              int64         `json:"app_id" structs:"app_id" mapstructure:"app_id"`
+	AppPrivateKey      string        `json:"app_private_key" structs:"app_private_key" mapstructure:"app_private_key"`
+	AppInstallationID  int64         `json:"app_installation_id" structs:"app_installation_id" mapstructure:"app_installation_id"`
+	MembershipCacheTTL time.Duration `json:"membership_cache_ttl" structs:"membership_cache_ttl" mapstructure:"membership_cache_ttl"`
+	MapTeamsBySlug     bool          `json:"map_teams_by_slug" structs:"map_teams_by_slug" mapstructure:"map_teams_by_slug"`
 }
 
 func (c *config) setOrganizationID(ctx context.Context, client *github.Client) error {
@@ -224,3 +330,30 @@ func (c *config) setOrganizationID(ctx context.Context, client *github.Client) e
 
 	return nil
 }
+
+const pathConfigHelpSyn = `
+Configure the GitHub organization users must be part of.
+`
+
+const pathConfigHelpDesc = `
+This endpoint configures the GitHub organization that users must be part of
+to log in, and the API endpoint to use for GitHub Enterprise Server.
+
+By default, the organization, teams and role of a user are looked up with the
+token given at login, which needs the "read:org" scope. With "app_id" and
+"app_private_key", they are instead looked up as the installation of a GitHub
+App on the organization, which needs the "Members" organization permission.
+The token of the user is then only used to identify them, so fine-grained
+personal access tokens and user access tokens without organization
+permissions can be used.
+
+Policies are mapped to teams with "map/teams/<team>", to users with
+"map/users/<user>" and to the role of users in the organization with
+"map/org_roles/admin" and "map/org_roles/member". Without a GitHub App, the
+role of users is only looked up when at least one role is mapped. Team names
+can change, so "map_teams_by_slug" maps teams by their slug only.
+
+With "membership_cache_ttl", the memberships of a user are cached across
+logins, which spares the API rate limit at the cost of membership changes
+taking up to that long to be picked up.
+`
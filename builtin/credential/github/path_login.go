@@ -4,7 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/url"
+	"net/http"
+	"strings"
 
 	"github.com/google/go-github/github"
 	"github.com/hashicorp/vault/sdk/framework"
@@ -19,7 +20,7 @@ func pathLogin(b *backend) *framework.Path {
 		Fields: map[string]*framework.FieldSchema{
 			"token": {
 				Type:        framework.TypeString,
-				Description: "GitHub personal API token",
+				Description: "GitHub personal API token, fine-grained personal access token or user access token",
 			},
 		},
 
@@ -69,6 +70,9 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, data *fra
 			Name: *verifyResp.User.Login,
 		},
 	}
+	if verifyResp.OrgRole != "" {
+		auth.Metadata["org_role"] = verifyResp.OrgRole
+	}
 	verifyResp.Config.PopulateTokenAuth(auth)
 
 	// Add in configured policies from user/group mapping
@@ -152,19 +156,11 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 		}
 	}
 
-	client, err := b.Client(token)
+	client, err := b.configuredClient(config, token)
 	if err != nil {
 		return nil, err
 	}
 
-	if config.BaseURL != "" {
-		parsedURL, err := url.Parse(config.BaseURL)
-		if err != nil {
-			return nil, fmt.Errorf("successfully parsed base_url when set but failing to parse now: %w", err)
-		}
-		client.BaseURL = parsedURL
-	}
-
 	if config.OrganizationID == 0 {
 		// Previously we did not verify using the Org ID. So if the Org ID is
 		// not set, we will trust-on-first-use and set it now.
@@ -191,7 +187,118 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 		return nil, err
 	}
 
-	// Verify that the user is part of the organization
+	// The role of the user in the organization costs another API call with
+	// their token, which is only made when roles are mapped to policies
+	orgRoles, err := b.OrgRoleMap.List(ctx, req.Storage, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify that the user is part of the organization, and get the teams
+	// they are part of to determine the policies
+	membership, err := b.orgMembership(ctx, config, client, user, len(orgRoles) > 0)
+	if err != nil {
+		return nil, err
+	}
+
+	orgLoginName := membership.Org.GetLogin()
+	if orgLoginName != config.Organization {
+		warningMsg := fmt.Sprintf(
+			"the organization name has changed to %q. It is recommended to verify and update the organization name in the config: %s=%d",
+			orgLoginName,
+			"organization_id",
+			config.OrganizationID,
+		)
+		b.Logger().Warn(warningMsg)
+		warnings = append(warnings, warningMsg)
+	}
+
+	var teamNames []string
+	for _, t := range membership.Teams {
+		// Append the names so we can get the policies
+		if !config.MapTeamsBySlug {
+			teamNames = append(teamNames, t.GetName())
+		}
+		if config.MapTeamsBySlug || t.GetName() != t.GetSlug() {
+			teamNames = append(teamNames, t.GetSlug())
+		}
+	}
+
+	groupPoliciesList, err := b.TeamMap.Policies(ctx, req.Storage, teamNames...)
+	if err != nil {
+		return nil, err
+	}
+
+	userPoliciesList, err := b.UserMap.Policies(ctx, req.Storage, []string{*user.Login}...)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := append(groupPoliciesList, userPoliciesList...)
+	if membership.Role != "" {
+		rolePoliciesList, err := b.OrgRoleMap.Policies(ctx, req.Storage, membership.Role)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, rolePoliciesList...)
+	}
+
+	verifyResp := &verifyCredentialsResp{
+		User:      user,
+		Org:       membership.Org,
+		OrgRole:   membership.Role,
+		Policies:  policies,
+		TeamNames: teamNames,
+		Config:    config,
+		Warnings:  warnings,
+	}
+
+	return verifyResp, nil
+}
+
+// orgMembership is the membership of a user in the configured organization.
+type orgMembership struct {
+	Org *github.Organization
+
+	// Role is the role of the user in the organization, admin or member. It
+	// may be left empty when no role is mapped to policies.
+	Role string
+
+	// Teams are the teams of the organization the user is part of
+	Teams []*github.Team
+}
+
+// orgMembership returns the membership of a user in the configured
+// organization, looked up as the configured GitHub App if there is one, or
+// else with the client of the user, in which case the role of the user is
+// only looked up if needRole is set. Memberships are cached for the
+// configured membership_cache_ttl.
+func (b *backend) orgMembership(ctx context.Context, config *config, client *github.Client, user *github.User, needRole bool) (*orgMembership, error) {
+	cacheKey := fmt.Sprintf("%d/%d/%t", config.OrganizationID, user.GetID(), needRole)
+	if cached, ok := b.membershipCache.Get(cacheKey); ok && config.MembershipCacheTTL > 0 {
+		return cached.(*orgMembership), nil
+	}
+
+	var membership *orgMembership
+	var err error
+	if config.AppID != 0 {
+		membership, err = b.appOrgMembership(ctx, config, user)
+	} else {
+		membership, err = b.userOrgMembership(ctx, config, client, needRole)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if config.MembershipCacheTTL > 0 {
+		b.membershipCache.Set(cacheKey, membership, config.MembershipCacheTTL)
+	}
+	return membership, nil
+}
+
+// userOrgMembership looks up the membership of a user in the configured
+// organization with their own client.
+func (b *backend) userOrgMembership(ctx context.Context, config *config, client *github.Client, needRole bool) (*orgMembership, error) {
 	var org *github.Organization
 
 	orgOpt := &github.ListOptions{
@@ -211,11 +318,9 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 		orgOpt.Page = resp.NextPage
 	}
 
-	orgLoginName := ""
 	for _, o := range allOrgs {
 		if o.GetID() == config.OrganizationID {
 			org = o
-			orgLoginName = *o.Login
 			break
 		}
 	}
@@ -223,19 +328,16 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 		return nil, errors.New("user is not part of required org")
 	}
 
-	if orgLoginName != config.Organization {
-		warningMsg := fmt.Sprintf(
-			"the organization name has changed to %q. It is recommended to verify and update the organization name in the config: %s=%d",
-			orgLoginName,
-			"organization_id",
-			config.OrganizationID,
-		)
-		b.Logger().Warn(warningMsg)
-		warnings = append(warnings, warningMsg)
+	membership := &orgMembership{
+		Org: org,
+	}
+	if needRole {
+		orgMembershipResp, _, err := client.Organizations.GetOrgMembership(ctx, "", org.GetLogin())
+		if err != nil {
+			return nil, err
+		}
+		membership.Role = orgMembershipResp.GetRole()
 	}
-
-	// Get the teams that this user is part of to determine the policies
-	var teamNames []string
 
 	teamOpt := &github.ListOptions{
 		PerPage: 100,
@@ -259,39 +361,138 @@ func (b *backend) verifyCredentials(ctx context.Context, req *logical.Request, t
 		if *t.Organization.ID != *org.ID {
 			continue
 		}
+		membership.Teams = append(membership.Teams, t)
+	}
 
-		// Append the names so we can get the policies
-		teamNames = append(teamNames, *t.Name)
-		if *t.Name != *t.Slug {
-			teamNames = append(teamNames, *t.Slug)
-		}
+	return membership, nil
+}
+
+// appOrgMembership looks up the membership of a user in the configured
+// organization as the installation of the configured GitHub App.
+func (b *backend) appOrgMembership(ctx context.Context, config *config, user *github.User) (*orgMembership, error) {
+	client, err := b.installationClient(ctx, config)
+	if err != nil {
+		return nil, err
 	}
 
-	groupPoliciesList, err := b.TeamMap.Policies(ctx, req.Storage, teamNames...)
+	orgMembershipResp, resp, err := client.Organizations.GetOrgMembership(ctx, user.GetLogin(), config.Organization)
 	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, errors.New("user is not part of required org")
+		}
 		return nil, err
 	}
+	if orgMembershipResp.GetState() != "active" || orgMembershipResp.GetOrganization().GetID() != config.OrganizationID {
+		return nil, errors.New("user is not part of required org")
+	}
 
-	userPoliciesList, err := b.UserMap.Policies(ctx, req.Storage, []string{*user.Login}...)
+	teams, err := appUserTeams(ctx, client, config.Organization, user.GetLogin())
 	if err != nil {
 		return nil, err
 	}
 
-	verifyResp := &verifyCredentialsResp{
-		User:      user,
-		Org:       org,
-		Policies:  append(groupPoliciesList, userPoliciesList...),
-		TeamNames: teamNames,
-		Config:    config,
-		Warnings:  warnings,
+	return &orgMembership{
+		Org:   orgMembershipResp.Organization,
+		Role:  orgMembershipResp.GetRole(),
+		Teams: teams,
+	}, nil
+}
+
+// appUserTeamsQuery lists the teams of an organization a user is part of.
+const appUserTeamsQuery = `query($org: String!, $login: String!, $cursor: String) {
+  organization(login: $org) {
+    teams(first: 100, after: $cursor, userLogins: [$login]) {
+      nodes {
+        databaseId
+        name
+        slug
+      }
+      pageInfo {
+        hasNextPage
+        endCursor
+      }
+    }
+  }
+}`
+
+// appUserTeams returns the teams of the organization the user is part of.
+// The REST API can only list the teams of the user making the request, so
+// they are queried through the GraphQL API rather than by checking the
+// membership of the user in every team of the organization.
+func appUserTeams(ctx context.Context, client *github.Client, org, login string) ([]*github.Team, error) {
+	// GitHub Enterprise Server serves the REST API under /api/v3/ and the
+	// GraphQL API under /api/graphql
+	endpoint := "graphql"
+	if strings.HasSuffix(client.BaseURL.Path, "/api/v3/") {
+		endpoint = "../graphql"
 	}
 
-	return verifyResp, nil
+	variables := map[string]interface{}{
+		"org":   org,
+		"login": login,
+	}
+
+	var teams []*github.Team
+	for {
+		req, err := client.NewRequest("POST", endpoint, map[string]interface{}{
+			"query":     appUserTeamsQuery,
+			"variables": variables,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Data struct {
+				Organization *struct {
+					Teams struct {
+						Nodes []struct {
+							DatabaseID int64  `json:"databaseId"`
+							Name       string `json:"name"`
+							Slug       string `json:"slug"`
+						} `json:"nodes"`
+						PageInfo struct {
+							HasNextPage bool   `json:"hasNextPage"`
+							EndCursor   string `json:"endCursor"`
+						} `json:"pageInfo"`
+					} `json:"teams"`
+				} `json:"organization"`
+			} `json:"data"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if _, err := client.Do(ctx, req, &result); err != nil {
+			return nil, err
+		}
+		if len(result.Errors) > 0 {
+			return nil, fmt.Errorf("error listing teams of user: %s", result.Errors[0].Message)
+		}
+		if result.Data.Organization == nil {
+			return nil, errors.New("error listing teams of user: organization not found")
+		}
+
+		page := result.Data.Organization.Teams
+		for _, node := range page.Nodes {
+			teams = append(teams, &github.Team{
+				ID:   github.Int64(node.DatabaseID),
+				Name: github.String(node.Name),
+				Slug: github.String(node.Slug),
+			})
+		}
+		if !page.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = page.PageInfo.EndCursor
+	}
+
+	return teams, nil
 }
 
 type verifyCredentialsResp struct {
 	User      *github.User
 	Org       *github.Organization
+	OrgRole   string
 	Policies  []string
 	TeamNames []string
 
@@ -2,9 +2,21 @@ package github
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/assert"
@@ -184,3 +196,167 @@ func TestGitHub_Login_NoOrgID(t *testing.T) {
 	// the ID should be set, we grab it from the GET /orgs API
 	assert.Equal(t, int64(12345), resp.Data["organization_id"])
 }
+
+// setupAppTestServer configures an httptest server answering as GitHub does
+// for a GitHub App installed on the organization, on which user-foo is an
+// admin and part of the "FooTeam" team only. It counts the membership
+// lookups made as the installation.
+func setupAppTestServer(t *testing.T, key *rsa.PrivateKey, lookups *int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		var resp string
+		switch r.URL.Path {
+		case "/orgs/foo-org":
+			resp = getOrgResponse
+		case "/user":
+			if auth != "user-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			resp = getUserResponse
+		case "/orgs/foo-org/installation", "/app/installations/42/access_tokens":
+			// Requests as the App must be signed with its private key
+			_, err := jwt.Parse(auth, func(*jwt.Token) (interface{}, error) {
+				return &key.PublicKey, nil
+			})
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Path == "/orgs/foo-org/installation" {
+				resp = `{"id": 42}`
+			} else {
+				resp = fmt.Sprintf(`{"token": "installation-token", "expires_at": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+			}
+		default:
+			if auth != "installation-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch r.URL.Path {
+			case "/orgs/foo-org/memberships/user-foo":
+				atomic.AddInt32(lookups, 1)
+				resp = fmt.Sprintf(`{"state": "active", "role": "admin", "organization": %v}`, getOrgResponse)
+			case "/graphql":
+				// The teams of the user are listed in one query
+				var query struct {
+					Variables map[string]interface{} `json:"variables"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&query); err != nil || query.Variables["org"] != "foo-org" || query.Variables["login"] != "user-foo" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				resp = `{"data": {"organization": {"teams": {"nodes": [{"databaseId": 1, "name": "FooTeam", "slug": "foo-team"}], "pageInfo": {"hasNextPage": false}}}}}`
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		fmt.Fprintln(w, resp)
+	}))
+}
+
+// TestGitHub_Login_App tests that memberships are looked up as the
+// configured GitHub App, mapped to policies by team slug and organization
+// role, and cached
+func TestGitHub_Login_App(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	var lookups int32
+	ts := setupAppTestServer(t, key, &lookups)
+	defer ts.Close()
+
+	// Write the config, the installation ID is looked up
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Path:      "config",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"organization":         "foo-org",
+			"base_url":             ts.URL,
+			"app_id":               1,
+			"app_private_key":      string(keyPEM),
+			"map_teams_by_slug":    true,
+			"membership_cache_ttl": "1m",
+		},
+		Storage: s,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, resp.Error())
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Path:      "config",
+		Operation: logical.ReadOperation,
+		Storage:   s,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), resp.Data["app_installation_id"])
+	assert.NotContains(t, resp.Data, "app_private_key")
+
+	for path, policy := range map[string]string{
+		"map/teams/foo-team":  "team-policy",
+		"map/teams/FooTeam":   "team-name-policy",
+		"map/teams/bar-team":  "bar-policy",
+		"map/org_roles/admin": "admin-policy",
+	} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Path:      path,
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"value": policy,
+			},
+			Storage: s,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, resp.Error())
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Path:      "login",
+			Operation: logical.UpdateOperation,
+			Data: map[string]interface{}{
+				"token": "user-token",
+			},
+			Storage: s,
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, resp.Error())
+
+		assert.Equal(t, map[string]string{
+			"org":      "foo-org",
+			"username": "user-foo",
+			"org_role": "admin",
+		}, resp.Auth.Metadata)
+		assert.ElementsMatch(t, []string{"admin-policy", "team-policy"}, resp.Auth.Policies)
+		assert.Len(t, resp.Auth.GroupAliases, 1)
+		assert.Equal(t, "foo-team", resp.Auth.GroupAliases[0].Name)
+	}
+
+	// The second login used the cached membership
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lookups))
+
+	// Tokens GitHub does not accept cannot log in, even with cached
+	// memberships
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Path:      "login",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"token": "other-token",
+		},
+		Storage: s,
+	})
+	assert.Error(t, err)
+}
@@ -2,13 +2,17 @@ package ldap
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/go-secure-stdlib/strutil"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/ldaputil"
 	"github.com/hashicorp/vault/sdk/logical"
+	cache "github.com/patrickmn/go-cache"
 )
 
 const errUserBindFailed = `ldap operation failed: failed to bind as user`
@@ -22,7 +26,9 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 }
 
 func Backend() *backend {
-	var b backend
+	b := backend{
+		groupCache: cache.New(cache.NoExpiration, time.Minute),
+	}
 	b.Backend = &framework.Backend{
 		Help: backendHelp,
 
@@ -46,6 +52,8 @@ func Backend() *backend {
 		},
 
 		AuthRenew:   b.pathLoginRenew,
+		Invalidate:  b.invalidate,
+		Clean:       b.cleanup,
 		BackendType: logical.TypeCredential,
 	}
 
@@ -54,6 +62,82 @@ func Backend() *backend {
 
 type backend struct {
 	*framework.Backend
+
+	// pool holds the connections to the configured servers. It is created
+	// on first use and reset whenever the configuration changes.
+	pool     *ldaputil.Pool
+	poolLock sync.Mutex
+
+	// groupCache caches the LDAP groups of users, keyed by user DN and
+	// username, for the configured group_cache_ttl.
+	groupCache *cache.Cache
+}
+
+func (b *backend) invalidate(_ context.Context, key string) {
+	switch key {
+	case "config":
+		b.reset()
+	}
+}
+
+func (b *backend) cleanup(_ context.Context) {
+	b.reset()
+}
+
+// reset closes the connection pool and flushes the group cache, so that
+// they are rebuilt from the current configuration.
+func (b *backend) reset() {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	if b.pool != nil {
+		b.pool.Close()
+		b.pool = nil
+	}
+	b.groupCache.Flush()
+}
+
+// syncIdentityGroups creates external identity groups for the LDAP groups
+// that have none yet. Failures are logged rather than failing the login, as
+// the groups are created again on the next login.
+func (b *backend) syncIdentityGroups(ctx context.Context, ldapGroups []string) {
+	manager, ok := b.System().(logical.ExternalGroupManager)
+	if !ok {
+		return
+	}
+
+	names := make([]string, 0, len(ldapGroups))
+	for _, name := range ldapGroups {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	err := manager.EnsureExternalGroups(ctx, names)
+	switch {
+	case err == nil:
+	case errors.Is(err, logical.ErrReadOnly):
+		if b.Logger().IsDebug() {
+			b.Logger().Debug("not syncing identity groups on read-only node")
+		}
+	default:
+		b.Logger().Warn("failed to sync identity groups", "error", err)
+	}
+}
+
+// ldapPool returns the connection pool for the given configuration,
+// creating it if needed.
+func (b *backend) ldapPool(cfg *ldapConfigEntry) *ldaputil.Pool {
+	b.poolLock.Lock()
+	defer b.poolLock.Unlock()
+
+	if b.pool == nil {
+		b.pool = ldaputil.NewPool(&ldaputil.Client{
+			Logger: b.Logger(),
+			LDAP:   ldaputil.NewLDAP(),
+		}, cfg.ConfigEntry)
+	}
+	return b.pool
 }
 
 func (b *backend) Login(ctx context.Context, req *logical.Request, username string, password string, usernameAsAlias bool) (string, []string, *logical.Response, []string, error) {
@@ -74,7 +158,8 @@ func (b *backend) Login(ctx context.Context, req *logical.Request, username stri
 		LDAP:   ldaputil.NewLDAP(),
 	}
 
-	c, err := ldapClient.DialLDAP(cfg.ConfigEntry)
+	pool := b.ldapPool(cfg)
+	c, err := pool.Get()
 	if err != nil {
 		return "", nil, logical.ErrorResponse(err.Error()), nil, nil
 	}
@@ -82,8 +167,8 @@ func (b *backend) Login(ctx context.Context, req *logical.Request, username stri
 		return "", nil, logical.ErrorResponse("invalid connection returned from LDAP dial"), nil, nil
 	}
 
-	// Clean connection
-	defer c.Close()
+	// Return connection to the pool
+	defer pool.Put(c)
 
 	userBindDN, err := ldapClient.GetUserBindDN(cfg.ConfigEntry, c, username)
 	if err != nil {
@@ -129,20 +214,45 @@ func (b *backend) Login(ctx context.Context, req *logical.Request, username stri
 		return "", nil, logical.ErrorResponse(err.Error()), nil, nil
 	}
 
-	if cfg.AnonymousGroupSearch {
-		c, err = ldapClient.DialLDAP(cfg.ConfigEntry)
+	var ldapGroups []string
+	groupCacheKey := userDN + "\x00" + username
+	if cached, ok := b.groupCache.Get(groupCacheKey); ok && cfg.GroupCacheTTL > 0 {
+		ldapGroups = cached.([]string)
+		if b.Logger().IsDebug() {
+			b.Logger().Debug("groups fetched from cache", "num_server_groups", len(ldapGroups), "server_groups", ldapGroups)
+		}
+	} else {
+		if cfg.AnonymousGroupSearch {
+			c, err = pool.Get()
+			if err != nil {
+				return "", nil, logical.ErrorResponse("ldap operation failed: failed to connect to LDAP server"), nil, nil
+			}
+			defer pool.Put(c) // Defer returning this connection as the deferal above returns the other defined connection
+
+			// Connections from the pool may be bound, so bind anonymously
+			if err := c.UnauthenticatedBind(""); err != nil {
+				if b.Logger().IsDebug() {
+					b.Logger().Debug("error while attempting to bind anonymously", "error", err)
+				}
+				return "", nil, logical.ErrorResponse("ldap operation failed: failed to bind anonymously"), nil, nil
+			}
+		}
+
+		ldapGroups, err = ldapClient.GetLdapGroups(cfg.ConfigEntry, c, userDN, username)
 		if err != nil {
-			return "", nil, logical.ErrorResponse("ldap operation failed: failed to connect to LDAP server"), nil, nil
+			return "", nil, logical.ErrorResponse(err.Error()), nil, nil
+		}
+		if b.Logger().IsDebug() {
+			b.Logger().Debug("groups fetched from server", "num_server_groups", len(ldapGroups), "server_groups", ldapGroups)
 		}
-		defer c.Close() // Defer closing of this connection as the deferal above closes the other defined connection
-	}
 
-	ldapGroups, err := ldapClient.GetLdapGroups(cfg.ConfigEntry, c, userDN, username)
-	if err != nil {
-		return "", nil, logical.ErrorResponse(err.Error()), nil, nil
+		if cfg.GroupCacheTTL > 0 {
+			b.groupCache.Set(groupCacheKey, ldapGroups, cfg.GroupCacheTTL)
+		}
 	}
-	if b.Logger().IsDebug() {
-		b.Logger().Debug("groups fetched from server", "num_server_groups", len(ldapGroups), "server_groups", ldapGroups)
+
+	if cfg.SyncIdentityGroups {
+		b.syncIdentityGroups(ctx, ldapGroups)
 	}
 
 	ldapResponse := &logical.Response{
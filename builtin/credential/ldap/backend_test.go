@@ -1224,6 +1224,7 @@ func TestLdapAuthBackend_ConfigUpgrade(t *testing.T) {
 			BindDN:                   cfg.BindDN,
 			BindPassword:             cfg.BindPassword,
 			GroupFilter:              defParams.GroupFilter,
+			NestedGroupFilter:        defParams.NestedGroupFilter,
 			DenyNullBind:             defParams.DenyNullBind,
 			TLSMinVersion:            defParams.TLSMinVersion,
 			TLSMaxVersion:            defParams.TLSMaxVersion,
@@ -1231,6 +1232,8 @@ func TestLdapAuthBackend_ConfigUpgrade(t *testing.T) {
 			UsePre111GroupCNBehavior: new(bool),
 			RequestTimeout:           cfg.RequestTimeout,
 			UsernameAsAlias:          false,
+			ServerSelection:          defParams.ServerSelection,
+			HealthCheckInterval:      defParams.HealthCheckInterval,
 		},
 	}
 
@@ -1262,3 +1265,105 @@ func TestLdapAuthBackend_ConfigUpgrade(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+func TestLdapAuthBackend_GroupCacheReset(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+	ctx := context.Background()
+
+	configReq := &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data: map[string]interface{}{
+			"url":                  "ldap://ldap1,ldap://ldap2",
+			"userdn":               "ou=users,dc=example,dc=org",
+			"server_selection":     "round_robin",
+			"connection_pool_size": 2,
+			"group_cache_ttl":      "1m",
+		},
+		Storage: storage,
+	}
+	resp, err := b.HandleRequest(ctx, configReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if resp.Data["group_cache_ttl"] != int64(60) {
+		t.Fatalf("bad: group_cache_ttl: %#v", resp.Data["group_cache_ttl"])
+	}
+	if resp.Data["server_selection"] != "round_robin" || resp.Data["connection_pool_size"] != 2 {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	cfg, err := b.Config(ctx, configReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := b.ldapPool(cfg)
+	if b.ldapPool(cfg) != pool {
+		t.Fatal("expected pool to be reused")
+	}
+
+	// Writing the configuration drops the pool and the cached groups
+	b.groupCache.Set("user", []string{"group"}, time.Minute)
+	configReq.Data = map[string]interface{}{
+		"group_cache_ttl": "2m",
+	}
+	resp, err = b.HandleRequest(ctx, configReq)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err:%v resp:%#v", err, resp)
+	}
+	if b.groupCache.ItemCount() != 0 {
+		t.Fatal("expected group cache to be flushed")
+	}
+	if b.ldapPool(cfg) == pool {
+		t.Fatal("expected pool to be reset")
+	}
+
+	// So does an invalidation of it
+	b.groupCache.Set("user", []string{"group"}, time.Minute)
+	b.Invalidate(ctx, "config")
+	if b.groupCache.ItemCount() != 0 {
+		t.Fatal("expected group cache to be flushed")
+	}
+
+	configReq.Data = map[string]interface{}{
+		"server_selection": "random",
+	}
+	resp, err = b.HandleRequest(ctx, configReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for invalid server_selection, got %#v", resp)
+	}
+}
+
+func TestLdapAuthBackend_SyncIdentityGroupsUnsupported(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Data: map[string]interface{}{
+			"url":                  "ldap://ldap1",
+			"userdn":               "ou=users,dc=example,dc=org",
+			"nested_group_depth":   3,
+			"sync_identity_groups": true,
+		},
+		Storage: storage,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected error as the system view cannot manage groups, got %#v", resp)
+	}
+}
@@ -3,6 +3,7 @@ package ldap
 import (
 	"context"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/consts"
@@ -30,6 +31,16 @@ func pathConfig(b *backend) *framework.Path {
 		},
 	}
 
+	p.Fields["group_cache_ttl"] = &framework.FieldSchema{
+		Type:        framework.TypeDurationSecond,
+		Description: "Duration for which the LDAP groups of a user are cached, so that logins within it do not search for them again. If zero, groups are searched for on every login.",
+	}
+
+	p.Fields["sync_identity_groups"] = &framework.FieldSchema{
+		Type:        framework.TypeBool,
+		Description: "If true, an external identity group with a group alias on this mount is created for each LDAP group found at login that has none yet, so that policies can be attached to it in the identity store.",
+	}
+
 	tokenutil.AddTokenFields(p.Fields)
 	p.Fields["token_policies"].Description += ". This will apply to all tokens generated by this auth method, in addition to any configured for specific users/groups."
 	return p
@@ -111,6 +122,8 @@ func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, d *f
 	}
 
 	data := cfg.PasswordlessMap()
+	data["group_cache_ttl"] = int64(cfg.GroupCacheTTL.Seconds())
+	data["sync_identity_groups"] = cfg.SyncIdentityGroups
 	cfg.PopulateTokenData(data)
 
 	resp := &logical.Response{
@@ -174,6 +187,25 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 		*cfg.UsePre111GroupCNBehavior = false
 	}
 
+	if groupCacheTTL, ok := d.GetOk("group_cache_ttl"); ok {
+		cfg.GroupCacheTTL = time.Duration(groupCacheTTL.(int)) * time.Second
+	}
+	if cfg.GroupCacheTTL < 0 {
+		return logical.ErrorResponse("group_cache_ttl cannot be negative"), nil
+	}
+
+	if syncIdentityGroups, ok := d.GetOk("sync_identity_groups"); ok {
+		cfg.SyncIdentityGroups = syncIdentityGroups.(bool)
+	}
+	if cfg.SyncIdentityGroups {
+		if _, ok := b.System().(logical.ExternalGroupManager); !ok {
+			return logical.ErrorResponse("syncing identity groups is not supported"), nil
+		}
+		if b.System().LocalMount() {
+			return logical.ErrorResponse("identity groups cannot be synced on local mounts"), nil
+		}
+	}
+
 	if err := cfg.ParseTokenFields(req, d); err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
@@ -186,6 +218,9 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 		return nil, err
 	}
 
+	// Connections and cached groups may no longer match the configuration
+	b.reset()
+
 	if warnings := b.checkConfigUserFilter(cfg); len(warnings) > 0 {
 		return &logical.Response{
 			Warnings: warnings,
@@ -218,6 +253,9 @@ func (b *backend) getConfigFieldData() (*framework.FieldData, error) {
 type ldapConfigEntry struct {
 	tokenutil.TokenParams
 	*ldaputil.ConfigEntry
+
+	GroupCacheTTL      time.Duration `json:"group_cache_ttl"`
+	SyncIdentityGroups bool          `json:"sync_identity_groups"`
 }
 
 const pathConfigHelpSyn = `
@@ -233,6 +271,24 @@ case, an unencrypted connection will be made with a default port of 389, unless
 the "starttls" parameter is set to true, in which case TLS will be used. In the
 latter case, a SSL connection will be established with a default port of 636.
 
+Several comma-separated URLs can be given. Servers that cannot be reached are
+marked unhealthy and skipped until a periodic health check, run every
+"health_check_interval", finds them back. With a "server_selection" of
+"failover", the first healthy server is used; with "round_robin", logins are
+spread across the healthy servers. A "connection_pool_size" above zero keeps
+that many idle connections per server open for reuse across logins, and a
+"group_cache_ttl" caches the LDAP groups of users across logins.
+
+For directories without transitive membership queries, such as OpenLDAP and
+389 Directory Server, "nested_group_depth" makes logins also search for the
+groups that the groups of a user are members of, using "nested_group_filter",
+down to that many levels.
+
+With "sync_identity_groups", logins create an external identity group for each
+LDAP group that has no group alias on this mount yet. Policies can then be
+attached to those identity groups instead of mapping them through
+"groups/<name>".
+
 ## A NOTE ON ESCAPING
 
 It is up to the administrator to provide properly escaped DNs. This includes
@@ -0,0 +1,112 @@
+package radius
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	pwd "github.com/hashicorp/go-secure-stdlib/password"
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/mapstructure"
+)
+
+type CLIHandler struct {
+	DefaultMount string
+}
+
+func (h *CLIHandler) Auth(c *api.Client, m map[string]string) (*api.Secret, error) {
+	var data struct {
+		Username string `mapstructure:"username"`
+		Password string `mapstructure:"password"`
+		Mount    string `mapstructure:"mount"`
+	}
+	if err := mapstructure.WeakDecode(m, &data); err != nil {
+		return nil, err
+	}
+
+	if data.Username == "" {
+		return nil, fmt.Errorf("'username' must be specified")
+	}
+	if data.Password == "" {
+		fmt.Fprintf(os.Stderr, "Password (will be hidden): ")
+		password, err := pwd.Read(os.Stdin)
+		fmt.Fprintf(os.Stderr, "\n")
+		if err != nil {
+			return nil, err
+		}
+		data.Password = password
+	}
+	if data.Mount == "" {
+		data.Mount = h.DefaultMount
+	}
+
+	options := map[string]interface{}{
+		"password": data.Password,
+	}
+
+	path := fmt.Sprintf("auth/%s/login/%s", data.Mount, data.Username)
+	for {
+		secret, err := c.Logical().Write(path, options)
+		if err != nil {
+			return nil, err
+		}
+		if secret == nil {
+			return nil, fmt.Errorf("empty response from credential provider")
+		}
+
+		state, ok := secret.Data["state"].(string)
+		if secret.Auth != nil || !ok {
+			return secret, nil
+		}
+
+		// The authentication server challenges the user, such as for the
+		// next token code
+		if message, _ := secret.Data["reply_message"].(string); message != "" {
+			fmt.Fprintln(os.Stderr, message)
+		}
+		fmt.Fprintf(os.Stderr, "Response (will be hidden): ")
+		response, err := pwd.Read(os.Stdin)
+		fmt.Fprintf(os.Stderr, "\n")
+		if err != nil {
+			return nil, err
+		}
+
+		options = map[string]interface{}{
+			"password": response,
+			"state":    state,
+		}
+	}
+}
+
+func (h *CLIHandler) Help() string {
+	help := `
+Usage: vault login -method=radius [CONFIG K=V...]
+
+  The RADIUS auth method allows users to authenticate against a RADIUS
+  server.
+
+  Authenticate as "sally":
+
+      $ vault login -method=radius username=sally
+      Password (will be hidden):
+
+  Authenticate as "bob":
+
+      $ vault login -method=radius username=bob password=password
+
+  If the RADIUS server challenges the user, for instance for the next code of
+  a hardware token, its message is printed and the CLI prompts for the
+  response on stdin.
+
+Configuration:
+
+  password=<string>
+      Password to use for authentication. If not provided, the CLI will prompt
+      for this on stdin.
+
+  username=<string>
+      Username to use for authentication.
+`
+
+	return strings.TrimSpace(help)
+}
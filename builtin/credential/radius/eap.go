@@ -0,0 +1,226 @@
+package radius
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"layeh.com/radius"
+	. "layeh.com/radius/rfc2865"
+	. "layeh.com/radius/rfc2869"
+)
+
+// EAP codes and types (RFC 3748), and EAP-MSCHAPv2 opcodes
+// (draft-kamath-pppext-eap-mschapv2).
+const (
+	eapCodeRequest  = 1
+	eapCodeResponse = 2
+
+	eapTypeIdentity = 1
+	eapTypeNak      = 3
+	eapTypeMSCHAPv2 = 26
+
+	eapMSCHAPv2Challenge = 1
+	eapMSCHAPv2Response  = 2
+	eapMSCHAPv2Success   = 3
+	eapMSCHAPv2Failure   = 4
+
+	// maxEAPRounds bounds the number of Access-Challenges of an EAP
+	// conversation.
+	maxEAPRounds = 10
+)
+
+// eapPacket is an EAP packet, as carried by EAP-Message attributes.
+type eapPacket struct {
+	code       byte
+	identifier byte
+	typ        byte
+	data       []byte
+}
+
+func (p *eapPacket) encode() []byte {
+	b := make([]byte, 5+len(p.data))
+	b[0] = p.code
+	b[1] = p.identifier
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+	b[4] = p.typ
+	copy(b[5:], p.data)
+	return b
+}
+
+func parseEAPPacket(b []byte) (*eapPacket, error) {
+	if len(b) < 4 {
+		return nil, errors.New("EAP packet too short")
+	}
+	length := int(binary.BigEndian.Uint16(b[2:4]))
+	if length < 4 || length > len(b) {
+		return nil, errors.New("invalid EAP packet length")
+	}
+
+	p := &eapPacket{
+		code:       b[0],
+		identifier: b[1],
+	}
+	if length > 4 {
+		p.typ = b[4]
+		p.data = b[5:length]
+	}
+	return p, nil
+}
+
+// eapMSCHAPv2Exchange authenticates the user with EAP-MSCHAPv2, and returns
+// the final answer of the authentication server. Once a server has answered,
+// the rest of the conversation is held with it.
+func (b *backend) eapMSCHAPv2Exchange(ctx context.Context, cfg *ConfigEntry, servers []string, username, password string) (*radius.Packet, error) {
+	response := &eapPacket{
+		code: eapCodeResponse,
+		typ:  eapTypeIdentity,
+		data: []byte(username),
+	}
+
+	var state []byte
+	var m *msCHAPv2
+	var nakSent, succeeded bool
+	for round := 0; round < maxEAPRounds; round++ {
+		packet := cfg.newAccessRequest(username)
+		if err := EAPMessage_Set(packet, response.encode()); err != nil {
+			return nil, err
+		}
+		if state != nil {
+			State_Set(packet, state)
+		}
+		if err := setMessageAuthenticator(packet); err != nil {
+			return nil, err
+		}
+
+		received, server, err := b.exchange(ctx, cfg, packet, servers)
+		if err != nil {
+			return nil, err
+		}
+		servers = servers[server : server+1]
+
+		switch received.Code {
+		case radius.CodeAccessAccept:
+			if !succeeded {
+				return nil, errors.New("authentication server accepted the user before EAP-MSCHAPv2 completed")
+			}
+			return received, nil
+		case radius.CodeAccessChallenge:
+		default:
+			return received, nil
+		}
+
+		state = State_Get(received)
+		request, err := parseEAPPacket(EAPMessage_Get(received))
+		if err != nil {
+			return nil, err
+		}
+		if request.code != eapCodeRequest {
+			return nil, fmt.Errorf("unexpected EAP code %d", request.code)
+		}
+
+		switch request.typ {
+		case eapTypeIdentity:
+			response = &eapPacket{
+				code:       eapCodeResponse,
+				identifier: request.identifier,
+				typ:        eapTypeIdentity,
+				data:       []byte(username),
+			}
+			continue
+
+		case eapTypeMSCHAPv2:
+
+		default:
+			// Ask for EAP-MSCHAPv2 instead of the proposed type
+			if nakSent {
+				return nil, errors.New("authentication server does not support EAP-MSCHAPv2")
+			}
+			nakSent = true
+			response = &eapPacket{
+				code:       eapCodeResponse,
+				identifier: request.identifier,
+				typ:        eapTypeNak,
+				data:       []byte{eapTypeMSCHAPv2},
+			}
+			continue
+		}
+
+		// EAP-MSCHAPv2 requests start with the opcode, the MS-CHAPv2
+		// identifier and the MS-CHAPv2 length
+		if len(request.data) < 4 {
+			return nil, errors.New("EAP-MSCHAPv2 request too short")
+		}
+		switch request.data[0] {
+		case eapMSCHAPv2Challenge:
+			// The challenge follows its size, and is followed by the name
+			// of the authentication server
+			if len(request.data) < 21 || request.data[4] != 16 {
+				return nil, errors.New("invalid EAP-MSCHAPv2 challenge")
+			}
+			m, err = newMSCHAPv2(username, password, request.data[5:21])
+			if err != nil {
+				return nil, err
+			}
+
+			value := append([]byte{eapMSCHAPv2Response, request.data[1], 0, 0, 49}, m.response()...)
+			value = append(value, username...)
+			binary.BigEndian.PutUint16(value[2:4], uint16(len(value)))
+			response = &eapPacket{
+				code:       eapCodeResponse,
+				identifier: request.identifier,
+				typ:        eapTypeMSCHAPv2,
+				data:       value,
+			}
+
+		case eapMSCHAPv2Success:
+			if m == nil {
+				return nil, errors.New("unexpected EAP-MSCHAPv2 success")
+			}
+			if err := m.verifySuccess(request.data[4:]); err != nil {
+				return nil, err
+			}
+			succeeded = true
+			response = &eapPacket{
+				code:       eapCodeResponse,
+				identifier: request.identifier,
+				typ:        eapTypeMSCHAPv2,
+				data:       []byte{eapMSCHAPv2Success},
+			}
+
+		case eapMSCHAPv2Failure:
+			// Acknowledge the failure, for the server to reject the user
+			response = &eapPacket{
+				code:       eapCodeResponse,
+				identifier: request.identifier,
+				typ:        eapTypeMSCHAPv2,
+				data:       []byte{eapMSCHAPv2Failure},
+			}
+
+		default:
+			return nil, fmt.Errorf("unexpected EAP-MSCHAPv2 opcode %d", request.data[0])
+		}
+	}
+
+	return nil, errors.New("too many EAP rounds")
+}
+
+// setMessageAuthenticator sets the Message-Authenticator attribute (RFC
+// 3579) required along with EAP-Message attributes, which must be set last.
+// The answers of the server are already authenticated by the RADIUS client,
+// so their Message-Authenticator is not checked.
+func setMessageAuthenticator(packet *radius.Packet) error {
+	if err := MessageAuthenticator_Set(packet, make([]byte, md5.Size)); err != nil {
+		return err
+	}
+	wire, err := packet.Encode()
+	if err != nil {
+		return err
+	}
+	hash := hmac.New(md5.New, packet.Secret)
+	hash.Write(wire)
+	return MessageAuthenticator_Set(packet, hash.Sum(nil))
+}
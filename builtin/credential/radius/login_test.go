@@ -0,0 +1,388 @@
+package radius
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"encoding/base64"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"layeh.com/radius"
+	. "layeh.com/radius/rfc2865"
+	. "layeh.com/radius/rfc2869"
+)
+
+const testRadiusSecret = "testing123"
+
+// startTestRadiusServer serves RADIUS requests on a local UDP port with the
+// given handler, and returns its address.
+func startTestRadiusServer(t *testing.T, handler radius.HandlerFunc) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &radius.PacketServer{
+		Handler:      handler,
+		SecretSource: radius.StaticSecretSource([]byte(testRadiusSecret)),
+	}
+	go server.Serve(conn)
+	t.Cleanup(func() {
+		server.Shutdown(context.Background())
+	})
+	return conn.LocalAddr().String()
+}
+
+// unusedUDPAddr returns a local address on which nothing listens.
+func unusedUDPAddr(t *testing.T) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+	return addr
+}
+
+func testLoginBackend(t *testing.T, config map[string]interface{}) (*backend, logical.Storage) {
+	t.Helper()
+
+	storage := &logical.InmemStorage{}
+	b, err := Factory(context.Background(), &logical.BackendConfig{
+		StorageView: storage,
+		System: &logical.StaticSystemView{
+			DefaultLeaseTTLVal: testSysTTL,
+			MaxLeaseTTLVal:     testSysMaxTTL,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config["secret"] = testRadiusSecret
+	config["read_timeout"] = 2
+	config["unregistered_user_policies"] = "unregistered"
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "config",
+		Storage:   storage,
+		Data:      config,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+	return b.(*backend), storage
+}
+
+func testLogin(t *testing.T, b *backend, storage logical.Storage, username string, data map[string]interface{}) *logical.Response {
+	t.Helper()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "login/" + username,
+		Storage:   storage,
+		Data:      data,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestBackend_Login_Failover(t *testing.T) {
+	server := startTestRadiusServer(t, func(w radius.ResponseWriter, r *radius.Request) {
+		code := radius.CodeAccessReject
+		if UserName_GetString(r.Packet) == "jane" && UserPassword_GetString(r.Packet) == "secret" {
+			code = radius.CodeAccessAccept
+		}
+		w.Write(r.Response(code))
+	})
+
+	b, storage := testLoginBackend(t, map[string]interface{}{
+		"host": unusedUDPAddr(t) + "," + server,
+	})
+
+	resp := testLogin(t, b, storage, "jane", map[string]interface{}{
+		"password": "secret",
+	})
+	if resp == nil || resp.Auth == nil {
+		t.Fatalf("expected a successful login, got %#v", resp)
+	}
+	if !reflect.DeepEqual(resp.Auth.Policies, []string{"unregistered"}) {
+		t.Fatalf("bad policies: %v", resp.Auth.Policies)
+	}
+
+	resp = testLogin(t, b, storage, "jane", map[string]interface{}{
+		"password": "wrong",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+}
+
+func TestBackend_Login_Challenge(t *testing.T) {
+	server := startTestRadiusServer(t, func(w radius.ResponseWriter, r *radius.Request) {
+		state := State_GetString(r.Packet)
+		password := UserPassword_GetString(r.Packet)
+		switch {
+		case state == "" && password == "1234":
+			resp := r.Response(radius.CodeAccessChallenge)
+			ReplyMessage_SetString(resp, "Enter the next token code")
+			State_SetString(resp, "next-token-code")
+			w.Write(resp)
+		case state == "next-token-code" && password == "654321":
+			w.Write(r.Response(radius.CodeAccessAccept))
+		default:
+			w.Write(r.Response(radius.CodeAccessReject))
+		}
+	})
+
+	b, storage := testLoginBackend(t, map[string]interface{}{
+		"host": unusedUDPAddr(t) + "," + server,
+	})
+
+	resp := testLogin(t, b, storage, "jane", map[string]interface{}{
+		"password": "1234",
+	})
+	if resp == nil || resp.IsError() || resp.Auth != nil {
+		t.Fatalf("expected a challenge, got %#v", resp)
+	}
+	if resp.Data["reply_message"] != "Enter the next token code" {
+		t.Fatalf("bad reply message: %v", resp.Data["reply_message"])
+	}
+	state := resp.Data["state"].(string)
+
+	// The state cannot be redirected to another server or used for another
+	// user
+	payload, mac := state[:strings.LastIndexByte(state, '.')], state[strings.LastIndexByte(state, '.'):]
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[0] = 0
+	for username, tampered := range map[string]string{
+		"jane": base64.RawURLEncoding.EncodeToString(raw) + mac,
+		"john": state,
+	} {
+		resp = testLogin(t, b, storage, username, map[string]interface{}{
+			"password": "654321",
+			"state":    tampered,
+		})
+		if resp == nil || !resp.IsError() {
+			t.Fatalf("expected an error, got %#v", resp)
+		}
+	}
+
+	resp = testLogin(t, b, storage, "jane", map[string]interface{}{
+		"password": "000000",
+		"state":    state,
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+
+	resp = testLogin(t, b, storage, "jane", map[string]interface{}{
+		"password": "654321",
+		"state":    state,
+	})
+	if resp == nil || resp.Auth == nil {
+		t.Fatalf("expected a successful login, got %#v", resp)
+	}
+	if _, ok := resp.Auth.InternalData["password"]; ok {
+		t.Fatal("challenge response should not be kept for renewals")
+	}
+
+	// The user cannot be authenticated again, so the token cannot be renewed
+	if resp.Auth.Renewable {
+		t.Fatal("expected the token not to be renewable")
+	}
+	auth := resp.Auth
+	auth.TokenPolicies = auth.Policies
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Path:      "login",
+		Storage:   storage,
+		Auth:      auth,
+	})
+	if err == nil {
+		t.Fatal("expected renewal to fail")
+	}
+
+	resp = testLogin(t, b, storage, "jane", map[string]interface{}{
+		"password": "654321",
+		"state":    "!",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+}
+
+// testMSCHAPv2Server returns the MS-CHAPv2 authentication of a user by the
+// authentication server, from the challenge it sent and the response value
+// of the user, or nil if the response is wrong.
+func testMSCHAPv2Server(t *testing.T, username, password string, challenge, response []byte) *msCHAPv2 {
+	if len(response) != 49 {
+		return nil
+	}
+	m, err := computeMSCHAPv2(username, password, challenge, response[:16])
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	if !bytes.Equal(m.ntResponse, response[24:48]) {
+		return nil
+	}
+	return m
+}
+
+func TestBackend_Login_MSCHAPv2(t *testing.T) {
+	var forgeSuccess int32
+	server := startTestRadiusServer(t, func(w radius.ResponseWriter, r *radius.Request) {
+		value := microsoftAttribute(r.Packet, msCHAP2Response)
+		if len(value) < 2 {
+			w.Write(r.Response(radius.CodeAccessReject))
+			return
+		}
+		m := testMSCHAPv2Server(t, UserName_GetString(r.Packet), "secret", microsoftAttribute(r.Packet, msCHAPChallenge), value[2:])
+		if m == nil {
+			w.Write(r.Response(radius.CodeAccessReject))
+			return
+		}
+
+		message := m.authenticatorResponse()
+		if atomic.LoadInt32(&forgeSuccess) == 1 {
+			message = "S=0000000000000000000000000000000000000000"
+		}
+		resp := r.Response(radius.CodeAccessAccept)
+		success, err := newMicrosoftAttribute(msCHAP2Success, append([]byte{value[0]}, message...))
+		if err != nil {
+			t.Error(err)
+		}
+		resp.Add(26, success)
+		w.Write(resp)
+	})
+
+	b, storage := testLoginBackend(t, map[string]interface{}{
+		"host":                    server,
+		"authentication_protocol": "mschapv2",
+	})
+
+	resp := testLogin(t, b, storage, "jane", map[string]interface{}{
+		"password": "secret",
+	})
+	if resp == nil || resp.Auth == nil {
+		t.Fatalf("expected a successful login, got %#v", resp)
+	}
+
+	resp = testLogin(t, b, storage, "jane", map[string]interface{}{
+		"password": "wrong",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+
+	// The server must prove that it knows the password
+	atomic.StoreInt32(&forgeSuccess, 1)
+	resp = testLogin(t, b, storage, "jane", map[string]interface{}{
+		"password": "secret",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+}
+
+func TestBackend_Login_EAPMSCHAPv2(t *testing.T) {
+	challenge := []byte("0123456789abcdef")
+	server := startTestRadiusServer(t, func(w radius.ResponseWriter, r *radius.Request) {
+		// Check the Message-Authenticator of the request
+		messageAuthenticator := MessageAuthenticator_Get(r.Packet)
+		MessageAuthenticator_Set(r.Packet, make([]byte, md5.Size))
+		wire, err := r.Packet.Encode()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		hash := hmac.New(md5.New, r.Secret)
+		hash.Write(wire)
+		if !hmac.Equal(hash.Sum(nil), messageAuthenticator) {
+			t.Error("bad Message-Authenticator")
+			w.Write(r.Response(radius.CodeAccessReject))
+			return
+		}
+
+		request, err := parseEAPPacket(EAPMessage_Get(r.Packet))
+		if err != nil || request.code != eapCodeResponse {
+			t.Errorf("bad EAP packet: %#v, %v", request, err)
+			w.Write(r.Response(radius.CodeAccessReject))
+			return
+		}
+
+		challengeWith := func(state string, p *eapPacket) {
+			resp := r.Response(radius.CodeAccessChallenge)
+			State_SetString(resp, state)
+			EAPMessage_Set(resp, p.encode())
+			w.Write(resp)
+		}
+
+		switch State_GetString(r.Packet) {
+		case "":
+			// Propose EAP-MD5 first
+			challengeWith("identity", &eapPacket{code: eapCodeRequest, identifier: 1, typ: 4, data: []byte{16}})
+		case "identity":
+			if request.typ != eapTypeNak || !bytes.Equal(request.data, []byte{eapTypeMSCHAPv2}) {
+				t.Errorf("expected a NAK, got %#v", request)
+				return
+			}
+			data := append([]byte{eapMSCHAPv2Challenge, 7, 0, 0, 16}, challenge...)
+			challengeWith("challenge", &eapPacket{code: eapCodeRequest, identifier: 2, typ: eapTypeMSCHAPv2, data: append(data, "server"...)})
+		case "challenge":
+			if len(request.data) < 54 || request.data[0] != eapMSCHAPv2Response || request.data[1] != 7 {
+				t.Errorf("bad EAP-MSCHAPv2 response: %#v", request)
+				return
+			}
+			m := testMSCHAPv2Server(t, string(request.data[54:]), "secret", challenge, request.data[5:54])
+			if m == nil {
+				challengeWith("failure", &eapPacket{code: eapCodeRequest, identifier: 3, typ: eapTypeMSCHAPv2, data: append([]byte{eapMSCHAPv2Failure, 7, 0, 0}, "E=691 R=0"...)})
+				return
+			}
+			data := append([]byte{eapMSCHAPv2Success, 7, 0, 0}, m.authenticatorResponse()+" M=Welcome"...)
+			challengeWith("success", &eapPacket{code: eapCodeRequest, identifier: 3, typ: eapTypeMSCHAPv2, data: data})
+		case "success":
+			if request.typ != eapTypeMSCHAPv2 || !bytes.Equal(request.data, []byte{eapMSCHAPv2Success}) {
+				t.Errorf("bad EAP-MSCHAPv2 success response: %#v", request)
+				return
+			}
+			w.Write(r.Response(radius.CodeAccessAccept))
+		default:
+			w.Write(r.Response(radius.CodeAccessReject))
+		}
+	})
+
+	b, storage := testLoginBackend(t, map[string]interface{}{
+		"host":                    server,
+		"authentication_protocol": "eap-mschapv2",
+	})
+
+	resp := testLogin(t, b, storage, "jane", map[string]interface{}{
+		"password": "secret",
+	})
+	if resp == nil || resp.Auth == nil {
+		t.Fatalf("expected a successful login, got %#v", resp)
+	}
+
+	resp = testLogin(t, b, storage, "jane", map[string]interface{}{
+		"password": "wrong",
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, got %#v", resp)
+	}
+}
@@ -0,0 +1,207 @@
+package radius
+
+import (
+	"bytes"
+	"crypto/des"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/crypto/md4"
+	"layeh.com/radius"
+)
+
+// Microsoft vendor-specific attributes carrying MS-CHAPv2 (RFC 2548).
+const (
+	vendorMicrosoft = 311
+
+	msCHAPChallenge = 11
+	msCHAP2Response = 25
+	msCHAP2Success  = 26
+)
+
+var (
+	// Constants used to compute the authenticator response (RFC 2759, 8.7)
+	msCHAPv2Magic1 = []byte("Magic server to client signing constant")
+	msCHAPv2Magic2 = []byte("Pad to make it do more than one iteration")
+)
+
+// msCHAPv2 holds the values of an MS-CHAPv2 authentication (RFC 2759) of a
+// user, from which the authentication server's response is checked.
+type msCHAPv2 struct {
+	username string
+
+	authenticatorChallenge []byte
+	peerChallenge          []byte
+	passwordHash           []byte
+	ntResponse             []byte
+}
+
+// newMSCHAPv2 computes the response of a user to an authenticator
+// challenge, with a random peer challenge.
+func newMSCHAPv2(username, password string, authenticatorChallenge []byte) (*msCHAPv2, error) {
+	peerChallenge := make([]byte, 16)
+	if _, err := rand.Read(peerChallenge); err != nil {
+		return nil, err
+	}
+	return computeMSCHAPv2(username, password, authenticatorChallenge, peerChallenge)
+}
+
+func computeMSCHAPv2(username, password string, authenticatorChallenge, peerChallenge []byte) (*msCHAPv2, error) {
+	m := &msCHAPv2{
+		username:               username,
+		authenticatorChallenge: authenticatorChallenge,
+		peerChallenge:          peerChallenge,
+		passwordHash:           ntPasswordHash(password),
+	}
+
+	response := make([]byte, 0, 24)
+	challenge := m.challengeHash()
+	for i := 0; i < 3; i++ {
+		// The 16 bytes password hash is padded to three 7 bytes DES keys
+		key := make([]byte, 7)
+		copy(key, m.passwordHash[i*7:])
+		block, err := des.NewCipher(desKey(key))
+		if err != nil {
+			return nil, err
+		}
+		out := make([]byte, 8)
+		block.Encrypt(out, challenge)
+		response = append(response, out...)
+	}
+	m.ntResponse = response
+
+	return m, nil
+}
+
+// ntPasswordHash returns the MD4 hash of the UTF-16LE encoded password.
+func ntPasswordHash(password string) []byte {
+	h := md4.New()
+	for _, c := range utf16.Encode([]rune(password)) {
+		h.Write([]byte{byte(c), byte(c >> 8)})
+	}
+	return h.Sum(nil)
+}
+
+// challengeHash hashes the challenges and username into the 8 bytes
+// challenge the NT response is computed from. Any domain the username is
+// qualified with is left out.
+func (m *msCHAPv2) challengeHash() []byte {
+	username := m.username
+	if i := strings.LastIndex(username, `\`); i >= 0 {
+		username = username[i+1:]
+	}
+
+	h := sha1.New()
+	h.Write(m.peerChallenge)
+	h.Write(m.authenticatorChallenge)
+	h.Write([]byte(username))
+	return h.Sum(nil)[:8]
+}
+
+// authenticatorResponse returns the "S=" response by which the
+// authentication server proves that it knows the password too.
+func (m *msCHAPv2) authenticatorResponse() string {
+	h := md4.New()
+	h.Write(m.passwordHash)
+	passwordHashHash := h.Sum(nil)
+
+	s := sha1.New()
+	s.Write(passwordHashHash)
+	s.Write(m.ntResponse)
+	s.Write(msCHAPv2Magic1)
+	digest := s.Sum(nil)
+
+	s = sha1.New()
+	s.Write(digest)
+	s.Write(m.challengeHash())
+	s.Write(msCHAPv2Magic2)
+	return "S=" + strings.ToUpper(hex.EncodeToString(s.Sum(nil)))
+}
+
+// response returns the 49 bytes response value, made of the peer
+// challenge, 8 reserved bytes, the NT response and zero flags.
+func (m *msCHAPv2) response() []byte {
+	response := make([]byte, 0, 49)
+	response = append(response, m.peerChallenge...)
+	response = append(response, make([]byte, 8)...)
+	response = append(response, m.ntResponse...)
+	return append(response, 0)
+}
+
+// verifySuccess checks the message of the authentication server reporting
+// success, which starts with the authenticator response.
+func (m *msCHAPv2) verifySuccess(message []byte) error {
+	if !bytes.HasPrefix(message, []byte(m.authenticatorResponse())) {
+		return errors.New("authentication server did not prove knowledge of the password")
+	}
+	return nil
+}
+
+// addAttributes adds the MS-CHAP-Challenge and MS-CHAP2-Response attributes
+// to an Access-Request.
+func (m *msCHAPv2) addAttributes(packet *radius.Packet, ident byte) error {
+	challenge, err := newMicrosoftAttribute(msCHAPChallenge, m.authenticatorChallenge)
+	if err != nil {
+		return err
+	}
+	packet.Add(26, challenge)
+
+	// The MS-CHAP2-Response value is the identifier, the flags and then the
+	// response
+	response, err := newMicrosoftAttribute(msCHAP2Response, append([]byte{ident, 0}, m.response()...))
+	if err != nil {
+		return err
+	}
+	packet.Add(26, response)
+
+	return nil
+}
+
+// verifyAccept checks the MS-CHAP2-Success attribute of an Access-Accept.
+func (m *msCHAPv2) verifyAccept(packet *radius.Packet) error {
+	success := microsoftAttribute(packet, msCHAP2Success)
+	if len(success) < 1 {
+		return errors.New("authentication server did not prove knowledge of the password")
+	}
+	// Skip the identifier
+	return m.verifySuccess(success[1:])
+}
+
+// newMicrosoftAttribute returns a Microsoft vendor-specific attribute.
+func newMicrosoftAttribute(vendorType byte, value []byte) (radius.Attribute, error) {
+	return radius.NewVendorSpecific(vendorMicrosoft, append([]byte{vendorType, byte(2 + len(value))}, value...))
+}
+
+// microsoftAttribute returns the value of the first Microsoft
+// vendor-specific attribute of the given type in the packet, or nil.
+func microsoftAttribute(packet *radius.Packet, vendorType byte) []byte {
+	for _, attr := range packet.Attributes[26] {
+		vendorID, value, err := radius.VendorSpecific(attr)
+		if err != nil || vendorID != vendorMicrosoft {
+			continue
+		}
+		if len(value) >= 2 && value[0] == vendorType && int(value[1]) == len(value) {
+			return value[2:]
+		}
+	}
+	return nil
+}
+
+// desKey spreads the 56 bits of a 7 bytes key into an 8 bytes DES key,
+// leaving out the parity bits.
+func desKey(key []byte) []byte {
+	return []byte{
+		key[0] & 0xfe,
+		(key[0]<<7 | key[1]>>1) & 0xfe,
+		(key[1]<<6 | key[2]>>2) & 0xfe,
+		(key[2]<<5 | key[3]>>3) & 0xfe,
+		(key[3]<<4 | key[4]>>4) & 0xfe,
+		(key[4]<<3 | key[5]>>5) & 0xfe,
+		(key[5]<<2 | key[6]>>6) & 0xfe,
+		key[6] << 1,
+	}
+}
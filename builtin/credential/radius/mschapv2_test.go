@@ -0,0 +1,45 @@
+package radius
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestMSCHAPv2(t *testing.T) {
+	// Sample values from RFC 2759, section 9.2
+	decode := func(s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	m, err := computeMSCHAPv2("User", "clientPass",
+		decode("5B5D7C7D7B3F2F3E3C2C602132262628"),
+		decode("21402324255E262A28295F2B3A337C7E"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if challenge := m.challengeHash(); !bytes.Equal(challenge, decode("D02E4386BCE91226")) {
+		t.Fatalf("bad challenge hash: %X", challenge)
+	}
+	if !bytes.Equal(m.passwordHash, decode("44EBBA8D5312B8D611474411F56989AE")) {
+		t.Fatalf("bad password hash: %X", m.passwordHash)
+	}
+	if !bytes.Equal(m.ntResponse, decode("82309ECD8D708B5EA08FAA3981CD83544233114A3D85D6DF")) {
+		t.Fatalf("bad NT response: %X", m.ntResponse)
+	}
+	if resp := m.authenticatorResponse(); resp != "S=407A5589115FD0D6209F510FE9C04566932CDA56" {
+		t.Fatalf("bad authenticator response: %s", resp)
+	}
+
+	if err := m.verifySuccess([]byte("S=407A5589115FD0D6209F510FE9C04566932CDA56 M=Welcome")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.verifySuccess([]byte("S=0000000000000000000000000000000000000000")); err == nil {
+		t.Fatal("expected an error for a bad authenticator response")
+	}
+}
@@ -2,6 +2,8 @@ package radius
 
 import (
 	"context"
+	"net"
+	"strconv"
 	"strings"
 
 	"github.com/hashicorp/vault/sdk/framework"
@@ -9,13 +11,19 @@ import (
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
+const (
+	authProtocolPAP         = "pap"
+	authProtocolMSCHAPv2    = "mschapv2"
+	authProtocolEAPMSCHAPv2 = "eap-mschapv2"
+)
+
 func pathConfig(b *backend) *framework.Path {
 	p := &framework.Path{
 		Pattern: "config",
 		Fields: map[string]*framework.FieldSchema{
 			"host": {
 				Type:        framework.TypeString,
-				Description: "RADIUS server host",
+				Description: "Comma-separated list of RADIUS server hosts, each optionally followed by a port. Servers are tried in order until one answers.",
 				DisplayAttrs: &framework.DisplayAttributes{
 					Name: "Host",
 				},
@@ -23,7 +31,7 @@ func pathConfig(b *backend) *framework.Path {
 			"port": {
 				Type:        framework.TypeInt,
 				Default:     1812,
-				Description: "RADIUS server port (default: 1812)",
+				Description: "RADIUS server port, for hosts given without one (default: 1812)",
 				DisplayAttrs: &framework.DisplayAttributes{
 					Value: 1812,
 				},
@@ -73,6 +81,16 @@ func pathConfig(b *backend) *framework.Path {
 					Name: "NAS Identifier",
 				},
 			},
+			"authentication_protocol": {
+				Type:          framework.TypeString,
+				Default:       authProtocolPAP,
+				AllowedValues: []interface{}{authProtocolPAP, authProtocolMSCHAPv2, authProtocolEAPMSCHAPv2},
+				Description:   `Protocol used to authenticate users: "pap", "mschapv2" or "eap-mschapv2" (default: "pap")`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:  "Authentication protocol",
+					Value: authProtocolPAP,
+				},
+			},
 		},
 
 		ExistenceCheck: b.configExistenceCheck,
@@ -144,6 +162,7 @@ func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, d *f
 		"read_timeout":               cfg.ReadTimeout,
 		"nas_port":                   cfg.NasPort,
 		"nas_identifier":             cfg.NasIdentifier,
+		"authentication_protocol":    cfg.authenticationProtocol(),
 	}
 	cfg.PopulateTokenData(data)
 
@@ -172,9 +191,17 @@ func (b *backend) pathConfigCreateUpdate(ctx context.Context, req *logical.Reque
 	} else if req.Operation == logical.CreateOperation {
 		cfg.Host = strings.ToLower(d.Get("host").(string))
 	}
-	if cfg.Host == "" {
+	servers := cfg.servers()
+	if len(servers) == 0 {
 		return logical.ErrorResponse("config parameter `host` cannot be empty"), nil
 	}
+	for _, server := range servers {
+		if _, port, err := net.SplitHostPort(server); err != nil {
+			return logical.ErrorResponse("invalid host %q: %s", server, err), nil
+		} else if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return logical.ErrorResponse("invalid port in host %q", server), nil
+		}
+	}
 
 	port, ok := d.GetOk("port")
 	if ok {
@@ -238,6 +265,18 @@ func (b *backend) pathConfigCreateUpdate(ctx context.Context, req *logical.Reque
 		cfg.NasIdentifier = d.Get("nas_identifier").(string)
 	}
 
+	authProtocol, ok := d.GetOk("authentication_protocol")
+	if ok {
+		cfg.AuthenticationProtocol = authProtocol.(string)
+	} else if req.Operation == logical.CreateOperation {
+		cfg.AuthenticationProtocol = d.Get("authentication_protocol").(string)
+	}
+	switch cfg.authenticationProtocol() {
+	case authProtocolPAP, authProtocolMSCHAPv2, authProtocolEAPMSCHAPv2:
+	default:
+		return logical.ErrorResponse("invalid authentication_protocol %q", cfg.AuthenticationProtocol), nil
+	}
+
 	entry, err := logical.StorageEntryJSON("config", cfg)
 	if err != nil {
 		return nil, err
@@ -260,6 +299,35 @@ type ConfigEntry struct {
 	ReadTimeout              int      `json:"read_timeout" structs:"read_timeout" mapstructure:"read_timeout"`
 	NasPort                  int      `json:"nas_port" structs:"nas_port" mapstructure:"nas_port"`
 	NasIdentifier            string   `json:"nas_identifier" structs:"nas_identifier" mapstructure:"nas_identifier"`
+
+	AuthenticationProtocol string `json:"authentication_protocol" structs:"authentication_protocol" mapstructure:"authentication_protocol"`
+}
+
+// servers returns the addresses of the configured RADIUS servers, in the
+// order they are tried, using the configured port for hosts given without
+// one.
+func (c *ConfigEntry) servers() []string {
+	var servers []string
+	for _, host := range strings.Split(c.Host, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(c.Port))
+		}
+		servers = append(servers, host)
+	}
+	return servers
+}
+
+// authenticationProtocol returns the configured authentication protocol,
+// configurations predating the option using PAP.
+func (c *ConfigEntry) authenticationProtocol() string {
+	if c.AuthenticationProtocol == "" {
+		return authProtocolPAP
+	}
+	return c.AuthenticationProtocol
 }
 
 const pathConfigHelpSyn = `
@@ -269,4 +337,13 @@ Configure the RADIUS server to connect to, along with its options.
 const pathConfigHelpDesc = `
 This endpoint allows you to configure the RADIUS server to connect to and its
 configuration options.
+
+Several comma-separated hosts can be given, each optionally as "host:port".
+They are tried in order, moving on to the next one when a server cannot be
+reached or does not answer within "read_timeout".
+
+Users are authenticated with PAP by default. An "authentication_protocol" of
+"mschapv2" uses MS-CHAPv2 instead, and "eap-mschapv2" runs EAP-MSCHAPv2 through
+EAP-Message attributes. With MS-CHAPv2, the server must prove in its
+Access-Accept that it knows the password of the user too.
 `
@@ -2,9 +2,12 @@ package radius
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net"
-	"strconv"
 	"strings"
 	"time"
 
@@ -33,7 +36,12 @@ func pathLogin(b *backend) *framework.Path {
 
 			"password": {
 				Type:        framework.TypeString,
-				Description: "Password for this user.",
+				Description: "Password for this user, or the response to the challenge when state is given.",
+			},
+
+			"state": {
+				Type:        framework.TypeString,
+				Description: "State returned along with a challenge of the authentication server, when answering it.",
 			},
 		},
 
@@ -84,6 +92,7 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 
 	username := d.Get("username").(string)
 	password := d.Get("password").(string)
+	state := d.Get("state").(string)
 
 	if username == "" {
 		username = d.Get("urlusername").(string)
@@ -96,7 +105,7 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		return logical.ErrorResponse("password cannot be empty"), nil
 	}
 
-	policies, resp, err := b.RadiusLogin(ctx, req, username, password)
+	policies, resp, err := b.radiusLogin(ctx, req, username, password, state)
 	// Handle an internal error
 	if err != nil {
 		return nil, err
@@ -106,6 +115,18 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		if resp.IsError() {
 			return resp, nil
 		}
+		// Hand the challenge of the authentication server to the client
+		if _, ok := resp.Data["state"]; ok {
+			return resp, nil
+		}
+	}
+
+	// A challenge response cannot be used again, so tokens of logins that
+	// answered one cannot be renewed, as the user could not be
+	// authenticated again
+	internalData := map[string]interface{}{}
+	if state == "" {
+		internalData["password"] = password
 	}
 
 	auth := &logical.Auth{
@@ -113,15 +134,16 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 			"username": username,
 			"policies": strings.Join(policies, ","),
 		},
-		InternalData: map[string]interface{}{
-			"password": password,
-		},
-		DisplayName: username,
+		InternalData: internalData,
+		DisplayName:  username,
 		Alias: &logical.Alias{
 			Name: username,
 		},
 	}
 	cfg.PopulateTokenAuth(auth)
+	if state != "" {
+		auth.Renewable = false
+	}
 
 	resp.Auth = auth
 	if policies != nil {
@@ -141,15 +163,19 @@ func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *f
 	}
 
 	username := req.Auth.Metadata["username"]
-	password := req.Auth.InternalData["password"].(string)
 
-	var resp *logical.Response
-	var loginPolicies []string
+	password, ok := req.Auth.InternalData["password"].(string)
+	if !ok {
+		return nil, fmt.Errorf("token was issued by answering a challenge, not renewing")
+	}
 
-	loginPolicies, resp, err = b.RadiusLogin(ctx, req, username, password)
+	loginPolicies, resp, err := b.RadiusLogin(ctx, req, username, password)
 	if err != nil || (resp != nil && resp.IsError()) {
 		return resp, err
 	}
+	if _, ok := resp.Data["state"]; ok {
+		return nil, fmt.Errorf("authentication server requires a challenge response, not renewing")
+	}
 	finalPolicies := cfg.TokenPolicies
 	if loginPolicies != nil {
 		finalPolicies = append(finalPolicies, loginPolicies...)
@@ -166,6 +192,15 @@ func (b *backend) pathLoginRenew(ctx context.Context, req *logical.Request, d *f
 }
 
 func (b *backend) RadiusLogin(ctx context.Context, req *logical.Request, username string, password string) ([]string, *logical.Response, error) {
+	return b.radiusLogin(ctx, req, username, password, "")
+}
+
+// radiusLogin authenticates the user against the configured RADIUS servers.
+// When state is given, the password is the response to the challenge it was
+// returned with, and is sent to the server which issued the challenge. If
+// the server answers with a challenge, the returned response holds it, along
+// with the state to answer it with, instead of policies.
+func (b *backend) radiusLogin(ctx context.Context, req *logical.Request, username, password, state string) ([]string, *logical.Response, error) {
 	cfg, err := b.Config(ctx, req)
 	if err != nil {
 		return nil, nil, err
@@ -174,43 +209,228 @@ func (b *backend) RadiusLogin(ctx context.Context, req *logical.Request, usernam
 		return nil, logical.ErrorResponse("radius backend not configured"), nil
 	}
 
-	hostport := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	servers := cfg.servers()
+	var serverOffset int
+	var radiusState []byte
+	if state != "" {
+		if cfg.authenticationProtocol() == authProtocolEAPMSCHAPv2 {
+			return nil, logical.ErrorResponse("challenges are not supported with EAP-MSCHAPv2"), nil
+		}
+		serverOffset, radiusState, err = b.decodeChallengeState(ctx, username, servers, state)
+		if err != nil {
+			if b.Logger().IsDebug() {
+				b.Logger().Debug("invalid challenge state", "username", username, "error", err)
+			}
+			return nil, logical.ErrorResponse("invalid state"), nil
+		}
+		servers = servers[serverOffset : serverOffset+1]
+	}
+
+	var received *radius.Packet
+	var server int
+	switch cfg.authenticationProtocol() {
+	case authProtocolEAPMSCHAPv2:
+		received, err = b.eapMSCHAPv2Exchange(ctx, cfg, servers, username, password)
+		if err != nil {
+			return nil, logical.ErrorResponse(err.Error()), nil
+		}
 
-	packet := radius.New(radius.CodeAccessRequest, []byte(cfg.Secret))
+	case authProtocolMSCHAPv2:
+		packet := cfg.newAccessRequest(username)
+		if radiusState != nil {
+			State_Set(packet, radiusState)
+		}
+		challenge := make([]byte, 16)
+		if _, err := rand.Read(challenge); err != nil {
+			return nil, nil, err
+		}
+		m, err := newMSCHAPv2(username, password, challenge)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := m.addAttributes(packet, packet.Identifier); err != nil {
+			return nil, nil, err
+		}
+		received, server, err = b.exchange(ctx, cfg, packet, servers)
+		if err != nil {
+			return nil, logical.ErrorResponse(err.Error()), nil
+		}
+		if received.Code == radius.CodeAccessAccept {
+			if err := m.verifyAccept(received); err != nil {
+				return nil, logical.ErrorResponse(err.Error()), nil
+			}
+		}
+
+	default:
+		packet := cfg.newAccessRequest(username)
+		if radiusState != nil {
+			State_Set(packet, radiusState)
+		}
+		if err := setUserPassword(packet, password); err != nil {
+			return nil, logical.ErrorResponse(err.Error()), nil
+		}
+		received, server, err = b.exchange(ctx, cfg, packet, servers)
+		if err != nil {
+			return nil, logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	if received.Code == radius.CodeAccessChallenge {
+		resp, err := b.challengeResponse(ctx, username, cfg.servers(), serverOffset+server, received)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, resp, nil
+	}
+	if received.Code != radius.CodeAccessAccept {
+		return nil, logical.ErrorResponse("access denied by the authentication server"), nil
+	}
+
+	policies, err := b.userPolicies(ctx, req.Storage, cfg, username)
+	if err != nil {
+		return nil, logical.ErrorResponse("could not retrieve user entry from storage"), err
+	}
+
+	return policies, &logical.Response{}, nil
+}
+
+// userPolicies returns the policies of a user, or the policies of
+// unregistered users if it has no entry.
+func (b *backend) userPolicies(ctx context.Context, s logical.Storage, cfg *ConfigEntry, username string) ([]string, error) {
+	// Retrieve user entry from storage
+	user, err := b.user(ctx, s, username)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		return user.Policies, nil
+	}
+	return cfg.UnregisteredUserPolicies, nil
+}
+
+// newAccessRequest returns an Access-Request for the user, carrying the
+// configured NAS attributes.
+func (c *ConfigEntry) newAccessRequest(username string) *radius.Packet {
+	packet := radius.New(radius.CodeAccessRequest, []byte(c.Secret))
 	UserName_SetString(packet, username)
-	UserPassword_SetString(packet, password)
-	if cfg.NasIdentifier != "" {
-		NASIdentifier_AddString(packet, cfg.NasIdentifier)
+	if c.NasIdentifier != "" {
+		NASIdentifier_AddString(packet, c.NasIdentifier)
+	}
+	packet.Add(5, radius.NewInteger(uint32(c.NasPort)))
+	return packet
+}
+
+// setUserPassword sets the User-Password attribute of an Access-Request.
+// The password is padded with NULs to a multiple of 16 bytes (RFC 2865,
+// 5.2) beforehand, as the RADIUS library reads past shorter passwords.
+func setUserPassword(packet *radius.Packet, password string) error {
+	padded := make([]byte, (len(password)+15)/16*16)
+	if len(padded) == 0 {
+		padded = make([]byte, 16)
+	}
+	copy(padded, password)
+	a, err := radius.NewUserPassword(padded, packet.Secret, packet.Authenticator[:])
+	if err != nil {
+		return err
 	}
-	packet.Add(5, radius.NewInteger(uint32(cfg.NasPort)))
+	packet.Set(UserPassword_Type, a)
+	return nil
+}
 
+// exchange sends the request to the given servers in turn until one of them
+// answers, and returns the answer along with the index of that server.
+func (b *backend) exchange(ctx context.Context, cfg *ConfigEntry, packet *radius.Packet, servers []string) (*radius.Packet, int, error) {
 	client := radius.Client{
 		Dialer: net.Dialer{
 			Timeout: time.Duration(cfg.DialTimeout) * time.Second,
 		},
 	}
-	clientCtx, cancelFunc := context.WithTimeout(ctx, time.Duration(cfg.ReadTimeout)*time.Second)
-	received, err := client.Exchange(clientCtx, packet, hostport)
-	cancelFunc()
+
+	err := errors.New("no RADIUS server configured")
+	for i, server := range servers {
+		var received *radius.Packet
+		clientCtx, cancelFunc := context.WithTimeout(ctx, time.Duration(cfg.ReadTimeout)*time.Second)
+		received, err = client.Exchange(clientCtx, packet, server)
+		cancelFunc()
+		if err == nil {
+			return received, i, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if i < len(servers)-1 {
+			b.Logger().Warn("RADIUS server did not answer, trying the next one", "server", server, "error", err)
+		}
+	}
+	return nil, 0, err
+}
+
+// challengeResponse returns the response handing an Access-Challenge to the
+// client, with the state to send back along with the response to it.
+func (b *backend) challengeResponse(ctx context.Context, username string, servers []string, server int, challenge *radius.Packet) (*logical.Response, error) {
+	state, err := b.encodeChallengeState(ctx, username, servers, server, State_Get(challenge))
 	if err != nil {
-		return nil, logical.ErrorResponse(err.Error()), nil
+		return nil, err
 	}
-	if received.Code != radius.CodeAccessAccept {
-		return nil, logical.ErrorResponse("access denied by the authentication server"), nil
+
+	messages, _ := ReplyMessage_GetStrings(challenge)
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"reply_message": strings.Join(messages, "\n"),
+			"state":         state,
+		},
+	}, nil
+}
+
+// encodeChallengeState encodes the State attribute of an Access-Challenge
+// along with the index of the server which issued it, so that the response
+// is sent to that same server. The state is sealed with an HMAC over the
+// username and the address of the server, so that clients cannot redirect
+// the response to another server or use the state for another user.
+func (b *backend) encodeChallengeState(ctx context.Context, username string, servers []string, server int, radiusState []byte) (string, error) {
+	payload := append([]byte{byte(server)}, radiusState...)
+	mac, err := b.challengeStateHMAC(ctx, username, servers[server], payload)
+	if err != nil {
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + mac, nil
+}
 
-	policies := cfg.UnregisteredUserPolicies
+// decodeChallengeState verifies a state returned by encodeChallengeState, and
+// returns the index of the server and the State attribute it holds.
+func (b *backend) decodeChallengeState(ctx context.Context, username string, servers []string, state string) (int, []byte, error) {
+	i := strings.LastIndexByte(state, '.')
+	if i < 0 {
+		return 0, nil, errors.New("missing state HMAC")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(state[:i])
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) == 0 {
+		return 0, nil, errors.New("empty state")
+	}
+	server := int(payload[0])
+	if server >= len(servers) {
+		return 0, nil, errors.New("unknown server")
+	}
 
-	// Retrieve user entry from storage
-	user, err := b.user(ctx, req.Storage, username)
+	mac, err := b.challengeStateHMAC(ctx, username, servers[server], payload)
 	if err != nil {
-		return nil, logical.ErrorResponse("could not retrieve user entry from storage"), err
+		return 0, nil, err
 	}
-	if user != nil {
-		policies = user.Policies
+	if !hmac.Equal([]byte(state[i+1:]), []byte(mac)) {
+		return 0, nil, errors.New("state HMAC mismatch")
 	}
+	return server, payload[1:], nil
+}
 
-	return policies, &logical.Response{}, nil
+func (b *backend) challengeStateHMAC(ctx context.Context, username, server string, payload []byte) (string, error) {
+	salt, err := b.Salt(ctx)
+	if err != nil {
+		return "", err
+	}
+	return salt.GetHMAC(strings.Join([]string{username, server, string(payload)}, "\x00")), nil
 }
 
 const pathLoginSyn = `
@@ -220,4 +440,15 @@ Log in with a username and password.
 const pathLoginDesc = `
 This endpoint authenticates using a username and password. Please be sure to
 read the note on escaping from the path-help for the 'config' endpoint.
+
+When the authentication server answers with a challenge, such as asking for
+the next token code, no token is issued. The response instead holds the
+"reply_message" of the server and a "state". Logging in again with the
+response to the challenge as "password", along with that "state", completes
+the login. The state is only valid for the same username.
+
+Tokens issued by answering a challenge cannot be renewed, as renewing tokens
+authenticates the user again and challenge responses cannot be replayed. They
+expire at the end of their initial TTL, after which the user must log in
+again.
 `
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -16,7 +17,9 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 }
 
 func Backend() *backend {
-	var b backend
+	b := backend{
+		userLocks: locksutil.CreateLocks(),
+	}
 	b.Backend = &framework.Backend{
 		Help: backendHelp,
 
@@ -31,6 +34,9 @@ func Backend() *backend {
 			pathUsersList(&b),
 			pathUserPolicies(&b),
 			pathUserPassword(&b),
+			pathUserChangePassword(&b),
+			pathUserUnlock(&b),
+			pathConfig(&b),
 			pathLogin(&b),
 		},
 
@@ -43,6 +49,10 @@ func Backend() *backend {
 
 type backend struct {
 	*framework.Backend
+
+	// userLocks guard the lockout state of users, which is updated on
+	// every login attempt.
+	userLocks []*locksutil.LockEntry
 }
 
 const backendHelp = `
@@ -412,3 +412,373 @@ func TestBackend_UserUpgrade(t *testing.T) {
 		t.Fatal(diff)
 	}
 }
+
+// testPasswordPolicySystemView is a system view with a single password
+// policy, "length", requiring passwords of at least 12 characters.
+type testPasswordPolicySystemView struct {
+	*logical.StaticSystemView
+}
+
+func (testPasswordPolicySystemView) ValidatePasswordWithPolicy(_ context.Context, policyName, password string) error {
+	if policyName != "length" {
+		return fmt.Errorf("policy %q not found", policyName)
+	}
+	if len(password) < 12 {
+		return fmt.Errorf("password must be at least 12 characters")
+	}
+	return nil
+}
+
+func testBackendWithConfig(t *testing.T, cfg map[string]interface{}) (*backend, logical.Storage) {
+	t.Helper()
+
+	storage := &logical.InmemStorage{}
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+	config.System = testPasswordPolicySystemView{logical.TestSystemView()}
+
+	b := Backend()
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Path:      "config",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data:      cfg,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	return b, storage
+}
+
+func testLogin(t *testing.T, b *backend, storage logical.Storage, data map[string]interface{}) (*logical.Response, error) {
+	t.Helper()
+
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Path:       "login/testuser",
+		Operation:  logical.UpdateOperation,
+		Storage:    storage,
+		Data:       data,
+		Connection: &logical.Connection{RemoteAddr: "127.0.0.1"},
+	})
+}
+
+func TestBackend_PasswordPolicy(t *testing.T) {
+	b, storage := testBackendWithConfig(t, map[string]interface{}{
+		"password_policy": "length",
+	})
+	ctx := context.Background()
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/testuser",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "short",
+		},
+	})
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected the password to be rejected, resp: %#v\nerr: %v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/testuser",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "long enough password",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/testuser/password",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "short",
+		},
+	})
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected the password to be rejected, resp: %#v\nerr: %v", resp, err)
+	}
+
+	// The configuration is rejected by system views without password policies.
+	b = Backend()
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+	if err := b.Setup(ctx, config); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "config",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password_policy": "length",
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error, resp: %#v\nerr: %v", resp, err)
+	}
+}
+
+func TestBackend_Lockout(t *testing.T) {
+	b, storage := testBackendWithConfig(t, map[string]interface{}{
+		"lockout_threshold": 3,
+	})
+	ctx := context.Background()
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/testuser",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "testpassword",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	// A successful login resets the count of failed attempts.
+	for i := 0; i < 2; i++ {
+		if resp, _ := testLogin(t, b, storage, map[string]interface{}{"password": "wrong"}); resp == nil || !resp.IsError() {
+			t.Fatalf("expected login to fail, resp: %#v", resp)
+		}
+	}
+	if resp, err := testLogin(t, b, storage, map[string]interface{}{"password": "testpassword"}); err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, resp: %#v\nerr: %v", resp, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if resp, _ := testLogin(t, b, storage, map[string]interface{}{"password": "wrong"}); resp == nil || !resp.IsError() {
+			t.Fatalf("expected login to fail, resp: %#v", resp)
+		}
+	}
+	// A locked out user gets the same response as a wrong password or an
+	// unknown user, even with the right password.
+	resp, err = testLogin(t, b, storage, map[string]interface{}{"password": "testpassword"})
+	if err != nil || resp == nil || resp.Auth != nil || resp.Error().Error() != "invalid username or password" {
+		t.Fatalf("expected the user to be locked out, resp: %#v\nerr: %v", resp, err)
+	}
+
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/testuser/unlock",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if resp, err := testLogin(t, b, storage, map[string]interface{}{"password": "testpassword"}); err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, resp: %#v\nerr: %v", resp, err)
+	}
+
+	// Failed attempts against unknown users are not tracked.
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Path:      "login/unknown",
+		Operation: logical.UpdateOperation,
+		Storage:   storage,
+		Data:      map[string]interface{}{"password": "wrong"},
+	})
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected login to fail, resp: %#v\nerr: %v", resp, err)
+	}
+	if entry, err := storage.Get(ctx, "lockout/unknown"); err != nil || entry != nil {
+		t.Fatalf("unexpected lockout entry %v, err: %v", entry, err)
+	}
+
+	// Failed attempts are not recorded on nodes that cannot write, without
+	// failing the login.
+	readOnly := &readOnlyStorage{Storage: storage}
+	resp, err = testLogin(t, b, readOnly, map[string]interface{}{"password": "wrong"})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected login to fail, resp: %#v\nerr: %v", resp, err)
+	}
+	if entry, err := storage.Get(ctx, "lockout/testuser"); err != nil || entry != nil {
+		t.Fatalf("unexpected lockout entry %v, err: %v", entry, err)
+	}
+	testLogin(t, b, storage, map[string]interface{}{"password": "wrong"})
+	if resp, err := testLogin(t, b, readOnly, map[string]interface{}{"password": "testpassword"}); err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, resp: %#v\nerr: %v", resp, err)
+	}
+}
+
+// readOnlyStorage fails writes the way the storage of a performance standby
+// does.
+type readOnlyStorage struct {
+	logical.Storage
+}
+
+func (s *readOnlyStorage) Put(context.Context, *logical.StorageEntry) error {
+	return logical.ErrReadOnly
+}
+
+func (s *readOnlyStorage) Delete(context.Context, string) error {
+	return logical.ErrReadOnly
+}
+
+func TestBackend_LockoutDuration(t *testing.T) {
+	b, storage := testBackendWithConfig(t, map[string]interface{}{
+		"lockout_threshold": 1,
+		"lockout_duration":  60,
+	})
+
+	cfg, err := b.config(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockout := &LockoutEntry{FailedAttempts: 1, LockedAt: time.Now().Add(-time.Minute)}
+	if lockout.locked(cfg, time.Now()) {
+		t.Fatal("expected the lockout to have ended")
+	}
+	lockout.LockedAt = time.Now()
+	if !lockout.locked(cfg, time.Now()) {
+		t.Fatal("expected the user to be locked out")
+	}
+
+	cfg.LockoutDuration = 0
+	lockout.LockedAt = time.Now().Add(-24 * time.Hour)
+	if !lockout.locked(cfg, time.Now()) {
+		t.Fatal("expected the user to remain locked out until unlocked")
+	}
+}
+
+func TestBackend_PasswordMaxAge(t *testing.T) {
+	b, storage := testBackendWithConfig(t, map[string]interface{}{
+		"password_policy":  "length",
+		"password_max_age": 3600,
+	})
+	ctx := context.Background()
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/testuser",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "first password",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if resp, err := testLogin(t, b, storage, map[string]interface{}{"password": "first password"}); err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, resp: %#v\nerr: %v", resp, err)
+	}
+
+	// Expire the password.
+	user, err := b.user(ctx, storage, "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.PasswordSetAt = time.Now().Add(-2 * time.Hour)
+	if err := b.setUser(ctx, storage, "testuser", user); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = testLogin(t, b, storage, map[string]interface{}{"password": "first password"})
+	if err != nil || resp == nil || resp.Auth != nil || resp.Data["password_expired"] != true {
+		t.Fatalf("expected the password to have expired, resp: %#v\nerr: %v", resp, err)
+	}
+
+	// The new password must satisfy the password policy.
+	resp, err = testLogin(t, b, storage, map[string]interface{}{"password": "first password", "new_password": "short"})
+	if err != logical.ErrInvalidRequest || resp == nil || !resp.IsError() {
+		t.Fatalf("expected the new password to be rejected, resp: %#v\nerr: %v", resp, err)
+	}
+
+	resp, err = testLogin(t, b, storage, map[string]interface{}{"password": "first password", "new_password": "second password"})
+	if err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login to succeed, resp: %#v\nerr: %v", resp, err)
+	}
+	if resp, err := testLogin(t, b, storage, map[string]interface{}{"password": "second password"}); err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login with the new password to succeed, resp: %#v\nerr: %v", resp, err)
+	}
+}
+
+func TestBackend_ChangePassword(t *testing.T) {
+	b, storage := testBackendWithConfig(t, map[string]interface{}{
+		"password_policy":  "length",
+		"password_history": 2,
+	})
+	ctx := context.Background()
+
+	b.System().(testPasswordPolicySystemView).EntityVal = &logical.Entity{
+		ID: "entity-id",
+		Aliases: []*logical.Alias{
+			{MountAccessor: "userpass_accessor", Name: "testuser"},
+		},
+	}
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Path:      "users/testuser",
+		Operation: logical.CreateOperation,
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"password": "first password",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	changePassword := func(entityID, mountAccessor, current, new string) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Path:          "users/testuser/change-password",
+			Operation:     logical.UpdateOperation,
+			Storage:       storage,
+			EntityID:      entityID,
+			MountAccessor: mountAccessor,
+			Data: map[string]interface{}{
+				"current_password": current,
+				"new_password":     new,
+			},
+		})
+	}
+
+	// The request must be made as the user.
+	if _, err := changePassword("", "userpass_accessor", "first password", "second password"); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied without an entity, got %v", err)
+	}
+	if _, err := changePassword("entity-id", "other_accessor", "first password", "second password"); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied for an alias on another mount, got %v", err)
+	}
+
+	if _, err := changePassword("entity-id", "userpass_accessor", "wrong password", "second password"); err != logical.ErrPermissionDenied {
+		t.Fatalf("expected permission denied for a wrong current password, got %v", err)
+	}
+	if _, err := changePassword("entity-id", "userpass_accessor", "first password", "short"); err != logical.ErrInvalidRequest {
+		t.Fatalf("expected the new password to be rejected by the policy, got %v", err)
+	}
+	if _, err := changePassword("entity-id", "userpass_accessor", "first password", "first password"); err != logical.ErrInvalidRequest {
+		t.Fatalf("expected the current password to be rejected, got %v", err)
+	}
+
+	resp, err = changePassword("entity-id", "userpass_accessor", "first password", "second password")
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	if resp, err := testLogin(t, b, storage, map[string]interface{}{"password": "second password"}); err != nil || resp == nil || resp.Auth == nil {
+		t.Fatalf("expected login with the new password to succeed, resp: %#v\nerr: %v", resp, err)
+	}
+
+	// The history holds the previous password, which cannot be reused, until
+	// it is pushed out by newer ones.
+	if _, err := changePassword("entity-id", "userpass_accessor", "second password", "first password"); err != logical.ErrInvalidRequest {
+		t.Fatalf("expected the previous password to be rejected, got %v", err)
+	}
+	resp, err = changePassword("entity-id", "userpass_accessor", "second password", "third password")
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+	resp, err = changePassword("entity-id", "userpass_accessor", "third password", "first password")
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+}
@@ -0,0 +1,151 @@
+package userpass
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathConfig(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config",
+		Fields: map[string]*framework.FieldSchema{
+			"password_policy": {
+				Type:        framework.TypeString,
+				Description: `Name of the password policy, configured under sys/policies/password, that passwords of users must satisfy. If unset, any password is accepted.`,
+			},
+			"lockout_threshold": {
+				Type:        framework.TypeInt,
+				Description: `Number of consecutive failed login attempts after which a user is locked out. If zero, users are never locked out.`,
+			},
+			"lockout_duration": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Duration for which a user is locked out. If zero, a locked out user remains locked until unlocked through users/<username>/unlock.`,
+			},
+			"password_max_age": {
+				Type:        framework.TypeDurationSecond,
+				Description: `Maximum age of a password, after which the user must change it on login. If zero, passwords never expire.`,
+			},
+			"password_history": {
+				Type:        framework.TypeInt,
+				Description: `Number of most recent passwords of a user, including the current one, that cannot be reused when changing passwords. If zero, passwords can be reused.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathConfigWrite,
+			logical.ReadOperation:   b.pathConfigRead,
+		},
+
+		HelpSynopsis:    pathConfigHelpSyn,
+		HelpDescription: pathConfigHelpDesc,
+	}
+}
+
+func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	cfg, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if passwordPolicy, ok := d.GetOk("password_policy"); ok {
+		cfg.PasswordPolicy = passwordPolicy.(string)
+	}
+	if lockoutThreshold, ok := d.GetOk("lockout_threshold"); ok {
+		cfg.LockoutThreshold = lockoutThreshold.(int)
+	}
+	if lockoutDuration, ok := d.GetOk("lockout_duration"); ok {
+		cfg.LockoutDuration = time.Duration(lockoutDuration.(int)) * time.Second
+	}
+	if passwordMaxAge, ok := d.GetOk("password_max_age"); ok {
+		cfg.PasswordMaxAge = time.Duration(passwordMaxAge.(int)) * time.Second
+	}
+	if passwordHistory, ok := d.GetOk("password_history"); ok {
+		cfg.PasswordHistory = passwordHistory.(int)
+	}
+
+	switch {
+	case cfg.LockoutThreshold < 0:
+		return logical.ErrorResponse("lockout_threshold cannot be negative"), nil
+	case cfg.LockoutDuration < 0:
+		return logical.ErrorResponse("lockout_duration cannot be negative"), nil
+	case cfg.PasswordMaxAge < 0:
+		return logical.ErrorResponse("password_max_age cannot be negative"), nil
+	case cfg.PasswordHistory < 0:
+		return logical.ErrorResponse("password_history cannot be negative"), nil
+	}
+
+	if cfg.PasswordPolicy != "" {
+		if _, ok := b.System().(logical.PasswordPolicyValidator); !ok {
+			return logical.ErrorResponse("password policies are not supported"), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON("config", cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (b *backend) pathConfigRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	cfg, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"password_policy":   cfg.PasswordPolicy,
+			"lockout_threshold": cfg.LockoutThreshold,
+			"lockout_duration":  int64(cfg.LockoutDuration.Seconds()),
+			"password_max_age":  int64(cfg.PasswordMaxAge.Seconds()),
+			"password_history":  cfg.PasswordHistory,
+		},
+	}, nil
+}
+
+// config returns the configuration for this backend.
+func (b *backend) config(ctx context.Context, s logical.Storage) (*userpassConfig, error) {
+	entry, err := s.Get(ctx, "config")
+	if err != nil {
+		return nil, err
+	}
+
+	// Returning a default configuration if an entry is not found
+	var result userpassConfig
+	if entry != nil {
+		if err := entry.DecodeJSON(&result); err != nil {
+			return nil, fmt.Errorf("error reading configuration: %w", err)
+		}
+	}
+	return &result, nil
+}
+
+type userpassConfig struct {
+	PasswordPolicy   string        `json:"password_policy"`
+	LockoutThreshold int           `json:"lockout_threshold"`
+	LockoutDuration  time.Duration `json:"lockout_duration"`
+	PasswordMaxAge   time.Duration `json:"password_max_age"`
+	PasswordHistory  int           `json:"password_history"`
+}
+
+const pathConfigHelpSyn = `
+Configure password requirements and lockout for users.
+`
+
+const pathConfigHelpDesc = `
+This endpoint configures the password policy passwords of users must
+satisfy, the number of consecutive failed login attempts after which
+a user is locked out and for how long, the maximum age of passwords and
+how many recent passwords cannot be reused.
+
+A user whose password has expired can only log in by providing a new
+password in the "new_password" field of the login request.
+`
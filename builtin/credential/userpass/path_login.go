@@ -5,9 +5,11 @@ import (
 	"crypto/subtle"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/cidrutil"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/helper/policyutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	"golang.org/x/crypto/bcrypt"
@@ -26,6 +28,11 @@ func pathLogin(b *backend) *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Password for this user.",
 			},
+
+			"new_password": {
+				Type:        framework.TypeString,
+				Description: "New password for this user, replacing the current one on a successful login. Required to log in once the password has expired.",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -61,6 +68,27 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		return nil, fmt.Errorf("missing password")
 	}
 
+	cfg, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failed attempts are counted under the user's lock, so that concurrent
+	// guesses cannot exceed the lockout threshold.
+	lock := locksutil.LockForKey(b.userLocks, username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var lockout *LockoutEntry
+	var locked bool
+	if cfg.LockoutThreshold > 0 {
+		lockout, err = b.lockout(ctx, req.Storage, username)
+		if err != nil {
+			return nil, err
+		}
+		locked = lockout.locked(cfg, time.Now())
+	}
+
 	// Get the user and validate auth
 	user, userError := b.user(ctx, req.Storage, username)
 
@@ -86,14 +114,28 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 	// Check for a password match. Check for a hash collision for Vault 0.2+,
 	// but handle the older legacy passwords with a constant time comparison.
 	passwordBytes := []byte(password)
+	var passwordMatch bool
 	if !legacyPassword {
-		if err := bcrypt.CompareHashAndPassword(userPassword, passwordBytes); err != nil {
-			return logical.ErrorResponse("invalid username or password"), nil
-		}
+		passwordMatch = bcrypt.CompareHashAndPassword(userPassword, passwordBytes) == nil
 	} else {
-		if subtle.ConstantTimeCompare(userPassword, passwordBytes) != 1 {
-			return logical.ErrorResponse("invalid username or password"), nil
+		passwordMatch = subtle.ConstantTimeCompare(userPassword, passwordBytes) == 1
+	}
+	if locked {
+		// Locked out users get the response of a wrong password, after the
+		// same password check, so that lockouts do not reveal which users
+		// exist.
+		b.Logger().Warn("login attempt for locked out user", "username", username)
+		return logical.ErrorResponse("invalid username or password"), nil
+	}
+	if !passwordMatch {
+		// Only failed attempts against existing users are counted, so that
+		// guessing usernames does not fill storage.
+		if user != nil && userError == nil && cfg.LockoutThreshold > 0 {
+			if err := b.recordFailedLogin(ctx, req.Storage, cfg, username, lockout); err != nil {
+				return nil, err
+			}
 		}
+		return logical.ErrorResponse("invalid username or password"), nil
 	}
 
 	if userError != nil {
@@ -114,6 +156,34 @@ func (b *backend) pathLogin(ctx context.Context, req *logical.Request, d *framew
 		}
 	}
 
+	if lockout != nil {
+		if err := b.clearLockout(ctx, req.Storage, username); err != nil {
+			return nil, err
+		}
+	}
+
+	if newPassword := d.Get("new_password").(string); newPassword != "" {
+		userErr, intErr := b.updateUserPassword(ctx, req, newPassword, user)
+		if intErr != nil {
+			return nil, intErr
+		}
+		if userErr != nil {
+			return logical.ErrorResponse(userErr.Error()), logical.ErrInvalidRequest
+		}
+		if err := b.setUser(ctx, req.Storage, username, user); err != nil {
+			return nil, err
+		}
+	} else if cfg.PasswordMaxAge > 0 && !user.PasswordSetAt.IsZero() && time.Since(user.PasswordSetAt) > cfg.PasswordMaxAge {
+		// No token is issued until the expired password is changed.
+		resp := &logical.Response{
+			Data: map[string]interface{}{
+				"password_expired": true,
+			},
+		}
+		resp.AddWarning("password has expired, log in again with new_password set to change it")
+		return resp, nil
+	}
+
 	auth := &logical.Auth{
 		Metadata: map[string]string{
 			"username": username,
@@ -158,4 +228,8 @@ Log in with a username and password.
 
 const pathLoginDesc = `
 This endpoint authenticates using a username and password.
+
+If the password has expired, no token is issued and the response has
+"password_expired" set. Logging in again with "new_password" set changes
+the password and issues a token.
 `
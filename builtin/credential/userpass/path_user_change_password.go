@@ -0,0 +1,166 @@
+package userpass
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathUserChangePassword(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "users/" + framework.GenericNameRegex("username") + "/change-password$",
+		Fields: map[string]*framework.FieldSchema{
+			"username": {
+				Type:        framework.TypeString,
+				Description: "Username for this user.",
+			},
+
+			"current_password": {
+				Type:        framework.TypeString,
+				Description: "Current password of this user.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Sensitive: true,
+				},
+			},
+
+			"new_password": {
+				Type:        framework.TypeString,
+				Description: "New password for this user.",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Sensitive: true,
+				},
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathUserChangePasswordUpdate,
+		},
+
+		HelpSynopsis:    pathUserChangePasswordHelpSyn,
+		HelpDescription: pathUserChangePasswordHelpDesc,
+	}
+}
+
+func (b *backend) pathUserChangePasswordUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	username := strings.ToLower(d.Get("username").(string))
+
+	currentPassword := d.Get("current_password").(string)
+	if currentPassword == "" {
+		return logical.ErrorResponse("missing current_password"), logical.ErrInvalidRequest
+	}
+	newPassword := d.Get("new_password").(string)
+	if newPassword == "" {
+		return logical.ErrorResponse("missing new_password"), logical.ErrInvalidRequest
+	}
+
+	// Only the user themselves may change their password here; operators
+	// reset passwords through users/<username>/password.
+	ok, err := b.requestedByUser(req, username)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	cfg, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// The current password is checked like a login attempt, so that this
+	// endpoint cannot be used to get around the lockout.
+	lock := locksutil.LockForKey(b.userLocks, username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var lockout *LockoutEntry
+	if cfg.LockoutThreshold > 0 {
+		lockout, err = b.lockout(ctx, req.Storage, username)
+		if err != nil {
+			return nil, err
+		}
+		if lockout.locked(cfg, time.Now()) {
+			return logical.ErrorResponse("user is locked out"), logical.ErrPermissionDenied
+		}
+	}
+
+	user, err := b.user(ctx, req.Storage, username)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, logical.ErrPermissionDenied
+	}
+
+	if !user.passwordMatches(currentPassword) {
+		if cfg.LockoutThreshold > 0 {
+			if err := b.recordFailedLogin(ctx, req.Storage, cfg, username, lockout); err != nil {
+				return nil, err
+			}
+		}
+		return logical.ErrorResponse("invalid current password"), logical.ErrPermissionDenied
+	}
+	if lockout != nil {
+		if err := b.clearLockout(ctx, req.Storage, username); err != nil {
+			return nil, err
+		}
+	}
+
+	userErr, intErr := b.updateUserPassword(ctx, req, newPassword, user)
+	if intErr != nil {
+		return nil, intErr
+	}
+	if userErr != nil {
+		return logical.ErrorResponse(userErr.Error()), logical.ErrInvalidRequest
+	}
+
+	return nil, b.setUser(ctx, req.Storage, username, user)
+}
+
+// requestedByUser returns whether the request was made with a token whose
+// entity has an alias for the user on this mount.
+func (b *backend) requestedByUser(req *logical.Request, username string) (bool, error) {
+	if req.EntityID == "" {
+		return false, nil
+	}
+
+	entity, err := b.System().EntityInfo(req.EntityID)
+	if err != nil {
+		return false, err
+	}
+	if entity == nil {
+		return false, nil
+	}
+
+	for _, alias := range entity.Aliases {
+		if alias.MountAccessor == req.MountAccessor && strings.ToLower(alias.Name) == username {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+const pathUserChangePasswordHelpSyn = `
+Change the password of the requesting user.
+`
+
+const pathUserChangePasswordHelpDesc = `
+This endpoint allows users to change their own password, given their
+current one. It must be called with a token whose entity has an alias
+for the user on this mount, such as one issued by logging in as the
+user, and a policy granting access to the path, which can be templated
+on the alias name:
+
+    path "auth/userpass/users/{{identity.entity.aliases.<mount accessor>.name}}/change-password" {
+      capabilities = ["update"]
+    }
+
+The new password must satisfy the configured password policy and
+cannot be one of the configured number of recent passwords. Failed
+attempts count towards the lockout of the user.
+`
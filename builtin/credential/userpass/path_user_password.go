@@ -2,7 +2,9 @@ package userpass
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
@@ -45,9 +47,9 @@ func (b *backend) pathUserPasswordUpdate(ctx context.Context, req *logical.Reque
 		return nil, fmt.Errorf("username does not exist")
 	}
 
-	userErr, intErr := b.updateUserPassword(req, d, userEntry)
+	userErr, intErr := b.updateUserPassword(ctx, req, d.Get("password").(string), userEntry)
 	if intErr != nil {
-		return nil, err
+		return nil, intErr
 	}
 	if userErr != nil {
 		return logical.ErrorResponse(userErr.Error()), logical.ErrInvalidRequest
@@ -56,20 +58,80 @@ func (b *backend) pathUserPasswordUpdate(ctx context.Context, req *logical.Reque
 	return nil, b.setUser(ctx, req.Storage, username, userEntry)
 }
 
-func (b *backend) updateUserPassword(req *logical.Request, d *framework.FieldData, userEntry *UserEntry) (error, error) {
-	password := d.Get("password").(string)
+func (b *backend) updateUserPassword(ctx context.Context, req *logical.Request, password string, userEntry *UserEntry) (error, error) {
 	if password == "" {
 		return fmt.Errorf("missing password"), nil
 	}
+	cfg, err := b.config(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if userErr, intErr := b.validatePassword(ctx, cfg, password); userErr != nil || intErr != nil {
+		return userErr, intErr
+	}
+	if cfg.PasswordHistory > 0 && userEntry.passwordUsedRecently(password) {
+		return fmt.Errorf("password was used recently, choose a different password"), nil
+	}
 	// Generate a hash of the password
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, err
 	}
+
+	// The history holds the hashes of the previous passwords, which together
+	// with the current one make up the configured number of passwords.
+	var history [][]byte
+	if cfg.PasswordHistory > 1 && userEntry.PasswordHash != nil {
+		history = append([][]byte{userEntry.PasswordHash}, userEntry.PasswordHistory...)
+		if len(history) > cfg.PasswordHistory-1 {
+			history = history[:cfg.PasswordHistory-1]
+		}
+	}
+	userEntry.PasswordHistory = history
 	userEntry.PasswordHash = hash
+	userEntry.PasswordSetAt = time.Now()
+	return nil, nil
+}
+
+// validatePassword checks the password against the configured password
+// policy, if any.
+func (b *backend) validatePassword(ctx context.Context, cfg *userpassConfig, password string) (error, error) {
+	if cfg.PasswordPolicy == "" {
+		return nil, nil
+	}
+
+	validator, ok := b.System().(logical.PasswordPolicyValidator)
+	if !ok {
+		return nil, fmt.Errorf("password policies are not supported")
+	}
+	if err := validator.ValidatePasswordWithPolicy(ctx, cfg.PasswordPolicy, password); err != nil {
+		return err, nil
+	}
 	return nil, nil
 }
 
+// passwordMatches returns whether the password is the user's current one.
+func (u *UserEntry) passwordMatches(password string) bool {
+	if u.PasswordHash == nil {
+		return subtle.ConstantTimeCompare([]byte(u.Password), []byte(password)) == 1
+	}
+	return bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(password)) == nil
+}
+
+// passwordUsedRecently returns whether the password is the user's current
+// one or one in their password history.
+func (u *UserEntry) passwordUsedRecently(password string) bool {
+	if (u.PasswordHash != nil || u.Password != "") && u.passwordMatches(password) {
+		return true
+	}
+	for _, hash := range u.PasswordHistory {
+		if bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil {
+			return true
+		}
+	}
+	return false
+}
+
 const pathUserPasswordHelpSyn = `
 Reset user's password.
 `
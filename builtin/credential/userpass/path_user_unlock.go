@@ -0,0 +1,129 @@
+package userpass
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathUserUnlock(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "users/" + framework.GenericNameRegex("username") + "/unlock$",
+		Fields: map[string]*framework.FieldSchema{
+			"username": {
+				Type:        framework.TypeString,
+				Description: "Username for this user.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathUserUnlockUpdate,
+		},
+
+		HelpSynopsis:    pathUserUnlockHelpSyn,
+		HelpDescription: pathUserUnlockHelpDesc,
+	}
+}
+
+func (b *backend) pathUserUnlockUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	username := strings.ToLower(d.Get("username").(string))
+
+	lock := locksutil.LockForKey(b.userLocks, username)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return nil, req.Storage.Delete(ctx, "lockout/"+username)
+}
+
+// LockoutEntry tracks the consecutive failed login attempts of a user.
+type LockoutEntry struct {
+	FailedAttempts int       `json:"failed_attempts"`
+	LockedAt       time.Time `json:"locked_at"`
+}
+
+// locked returns whether the user is locked out at the given time.
+func (l *LockoutEntry) locked(cfg *userpassConfig, now time.Time) bool {
+	if l == nil || l.LockedAt.IsZero() {
+		return false
+	}
+	return cfg.LockoutDuration == 0 || now.Before(l.LockedAt.Add(cfg.LockoutDuration))
+}
+
+// lockout returns the lockout state of the user. The caller must hold the
+// user's lock.
+func (b *backend) lockout(ctx context.Context, s logical.Storage, username string) (*LockoutEntry, error) {
+	entry, err := s.Get(ctx, "lockout/"+username)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result LockoutEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// recordFailedLogin counts a failed login attempt of the user, locking them
+// out once the configured threshold is reached. The caller must hold the
+// user's lock.
+func (b *backend) recordFailedLogin(ctx context.Context, s logical.Storage, cfg *userpassConfig, username string, lockout *LockoutEntry) error {
+	if lockout == nil {
+		lockout = &LockoutEntry{}
+	}
+
+	// A lockout that has ended starts a new count.
+	now := time.Now()
+	if !lockout.LockedAt.IsZero() && !lockout.locked(cfg, now) {
+		lockout = &LockoutEntry{}
+	}
+
+	lockout.FailedAttempts++
+	if lockout.FailedAttempts >= cfg.LockoutThreshold && lockout.LockedAt.IsZero() {
+		lockout.LockedAt = now
+		b.Logger().Warn("user locked out after failed login attempts", "username", username, "failed_attempts", lockout.FailedAttempts)
+	}
+
+	entry, err := logical.StorageEntryJSON("lockout/"+username, lockout)
+	if err != nil {
+		return err
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		// Failed attempts are only counted on nodes that can write, such
+		// as not on performance standbys
+		if !strings.Contains(err.Error(), logical.ErrReadOnly.Error()) {
+			return err
+		}
+		b.Logger().Debug("not recording failed login attempt on read-only node", "username", username)
+	}
+	return nil
+}
+
+// clearLockout resets the count of failed attempts of the user after a
+// successful login. The caller must hold the user's lock.
+func (b *backend) clearLockout(ctx context.Context, s logical.Storage, username string) error {
+	if err := s.Delete(ctx, "lockout/"+username); err != nil {
+		if !strings.Contains(err.Error(), logical.ErrReadOnly.Error()) {
+			return err
+		}
+		b.Logger().Debug("not clearing failed login attempts on read-only node", "username", username)
+	}
+	return nil
+}
+
+const pathUserUnlockHelpSyn = `
+Unlock a user locked out after failed login attempts.
+`
+
+const pathUserUnlockHelpDesc = `
+This endpoint unlocks a user locked out after reaching the configured
+number of consecutive failed login attempts, and resets the count of
+failed attempts.
+`
@@ -15,6 +15,10 @@ type backend struct {
 	view      logical.Storage
 	salt      *salt.Salt
 	saltMutex sync.RWMutex
+
+	// issuersLock serializes writes to the issuers and the default issuer
+	// configuration.
+	issuersLock sync.Mutex
 }
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -47,6 +51,7 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			SealWrapStorage: []string{
 				caPrivateKey,
 				caPrivateKeyStoragePath,
+				issuerPrefix,
 				"keys/",
 			},
 		},
@@ -63,6 +68,11 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			pathSign(&b),
 			pathIssue(&b),
 			pathFetchPublicKey(&b),
+			pathListIssuers(&b),
+			pathIssuersGenerate(&b),
+			pathIssuersImport(&b),
+			pathIssuer(&b),
+			pathConfigIssuers(&b),
 		},
 
 		Secrets: []*framework.Secret{
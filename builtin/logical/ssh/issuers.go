@@ -0,0 +1,319 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	issuerRefParam   = "issuer_ref"
+	issuerPrefix     = "config/issuer/"
+	issuerConfigPath = "config/issuers"
+
+	// defaultRef is the reserved issuer reference which always resolves to
+	// the mount's current default issuer.
+	defaultRef = "default"
+)
+
+var issuerNameMatcher = regexp.MustCompile("^" + framework.GenericNameRegex("issuer_name") + "$")
+
+// issuerEntry is a single named SSH CA key pair. Every issuer stored on the
+// mount is considered active: its public key is served from public_key so
+// that hosts can trust both the outgoing and incoming CA during rotation.
+type issuerEntry struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	PublicKey  string `json:"public_key"`
+	PrivateKey string `json:"private_key"`
+}
+
+type issuerConfigEntry struct {
+	DefaultIssuerID string `json:"default"`
+}
+
+func listIssuers(ctx context.Context, s logical.Storage) ([]string, error) {
+	return s.List(ctx, issuerPrefix)
+}
+
+func fetchIssuerByID(ctx context.Context, s logical.Storage, id string) (*issuerEntry, error) {
+	entry, err := s.Get(ctx, issuerPrefix+id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issuer %q: %w", id, err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var issuer issuerEntry
+	if err := entry.DecodeJSON(&issuer); err != nil {
+		return nil, fmt.Errorf("failed to decode issuer %q: %w", id, err)
+	}
+
+	return &issuer, nil
+}
+
+func writeIssuer(ctx context.Context, s logical.Storage, issuer *issuerEntry) error {
+	entry, err := logical.StorageEntryJSON(issuerPrefix+issuer.ID, issuer)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func getIssuersConfig(ctx context.Context, s logical.Storage) (*issuerConfigEntry, error) {
+	entry, err := s.Get(ctx, issuerConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issuers configuration: %w", err)
+	}
+
+	config := &issuerConfigEntry{}
+	if entry != nil {
+		if err := entry.DecodeJSON(config); err != nil {
+			return nil, fmt.Errorf("failed to decode issuers configuration: %w", err)
+		}
+	}
+
+	return config, nil
+}
+
+func setIssuersConfig(ctx context.Context, s logical.Storage, config *issuerConfigEntry) error {
+	entry, err := logical.StorageEntryJSON(issuerConfigPath, config)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// resolveIssuerReference maps an issuer reference (the reserved "default"
+// value, an issuer ID or an issuer name) to the ID of a stored issuer. An
+// empty ID with no error is returned when the reference does not match.
+func resolveIssuerReference(ctx context.Context, s logical.Storage, reference string) (string, error) {
+	if reference == "" || reference == defaultRef {
+		config, err := getIssuersConfig(ctx, s)
+		if err != nil {
+			return "", err
+		}
+		return config.DefaultIssuerID, nil
+	}
+
+	issuer, err := fetchIssuerByID(ctx, s, reference)
+	if err != nil {
+		return "", err
+	}
+	if issuer != nil {
+		return issuer.ID, nil
+	}
+
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return "", err
+	}
+
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(ctx, s, id)
+		if err != nil {
+			return "", err
+		}
+		if issuer != nil && issuer.Name == reference {
+			return issuer.ID, nil
+		}
+	}
+
+	return "", nil
+}
+
+// fetchIssuerByRef returns the issuer matching the given reference, or nil
+// if there is none. When the default issuer is requested on a mount which
+// still holds a CA written by an older version (and has not yet been
+// migrated by a write), the legacy key pair is returned instead.
+func fetchIssuerByRef(ctx context.Context, s logical.Storage, reference string) (*issuerEntry, error) {
+	id, err := resolveIssuerReference(ctx, s, reference)
+	if err != nil {
+		return nil, err
+	}
+	if id != "" {
+		return fetchIssuerByID(ctx, s, id)
+	}
+
+	if reference != "" && reference != defaultRef {
+		return nil, nil
+	}
+
+	return fetchLegacyIssuer(ctx, s)
+}
+
+// fetchAllIssuers returns every stored issuer, with the default issuer
+// first and the remainder ordered by name and then ID.
+func fetchAllIssuers(ctx context.Context, s logical.Storage) ([]*issuerEntry, error) {
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		legacy, err := fetchLegacyIssuer(ctx, s)
+		if err != nil || legacy == nil {
+			return nil, err
+		}
+		return []*issuerEntry{legacy}, nil
+	}
+
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	issuers := make([]*issuerEntry, 0, len(ids))
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer != nil {
+			issuers = append(issuers, issuer)
+		}
+	}
+
+	sort.SliceStable(issuers, func(i, j int) bool {
+		iDefault := issuers[i].ID == config.DefaultIssuerID
+		jDefault := issuers[j].ID == config.DefaultIssuerID
+		if iDefault != jDefault {
+			return iDefault
+		}
+		if issuers[i].Name != issuers[j].Name {
+			return issuers[i].Name < issuers[j].Name
+		}
+		return issuers[i].ID < issuers[j].ID
+	})
+
+	return issuers, nil
+}
+
+func fetchLegacyIssuer(ctx context.Context, s logical.Storage) (*issuerEntry, error) {
+	publicKeyEntry, err := caKey(ctx, s, caPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA public key: %w", err)
+	}
+
+	privateKeyEntry, err := caKey(ctx, s, caPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA private key: %w", err)
+	}
+
+	if publicKeyEntry == nil || publicKeyEntry.Key == "" || privateKeyEntry == nil || privateKeyEntry.Key == "" {
+		return nil, nil
+	}
+
+	return &issuerEntry{
+		PublicKey:  publicKeyEntry.Key,
+		PrivateKey: privateKeyEntry.Key,
+	}, nil
+}
+
+// migrateLegacyCA moves a CA key pair stored by config/ca in older versions
+// into a regular issuer, making it the default if none is set. It is a no-op
+// once the legacy entries are gone, so it is safe to call before every write
+// to the issuer storage.
+func migrateLegacyCA(ctx context.Context, s logical.Storage) error {
+	legacy, err := fetchLegacyIssuer(ctx, s)
+	if err != nil {
+		return err
+	}
+	if legacy == nil {
+		return nil
+	}
+
+	if _, err := createIssuer(ctx, s, "", legacy.PublicKey, legacy.PrivateKey, false); err != nil {
+		return fmt.Errorf("failed to migrate CA key pair to issuer: %w", err)
+	}
+
+	if err := s.Delete(ctx, caPrivateKeyStoragePath); err != nil {
+		return err
+	}
+	if err := s.Delete(ctx, caPublicKeyStoragePath); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// createIssuer stores a new issuer from an already validated key pair and
+// name. The issuer becomes the default if requested or if no default exists
+// yet.
+func createIssuer(ctx context.Context, s logical.Storage, name, publicKey, privateKey string, setDefault bool) (*issuerEntry, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := &issuerEntry{
+		ID:         id,
+		Name:       name,
+		PublicKey:  publicKey,
+		PrivateKey: privateKey,
+	}
+	if err := writeIssuer(ctx, s, issuer); err != nil {
+		return nil, err
+	}
+
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if setDefault || config.DefaultIssuerID == "" {
+		config.DefaultIssuerID = issuer.ID
+		if err := setIssuersConfig(ctx, s, config); err != nil {
+			return nil, err
+		}
+	}
+
+	return issuer, nil
+}
+
+// deleteIssuer removes the issuer with the given ID, clearing the default
+// issuer configuration if it pointed at it.
+func deleteIssuer(ctx context.Context, s logical.Storage, id string) error {
+	if err := s.Delete(ctx, issuerPrefix+id); err != nil {
+		return err
+	}
+
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if config.DefaultIssuerID == id {
+		config.DefaultIssuerID = ""
+		return setIssuersConfig(ctx, s, config)
+	}
+
+	return nil
+}
+
+// validateIssuerName checks that a user-supplied issuer name can be used as
+// an issuer reference.
+func validateIssuerName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if strings.ToLower(name) == defaultRef {
+		return fmt.Errorf("issuer name %q is reserved", name)
+	}
+	if !issuerNameMatcher.MatchString(name) {
+		return fmt.Errorf("issuer name %q contains invalid characters", name)
+	}
+	if _, err := uuid.ParseUUID(name); err == nil {
+		return fmt.Errorf("issuer name %q must not be a UUID", name)
+	}
+	return nil
+}
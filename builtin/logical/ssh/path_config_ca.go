@@ -14,7 +14,6 @@ import (
 	"fmt"
 	"io"
 
-	multierror "github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 	"golang.org/x/crypto/ssh"
@@ -73,26 +72,30 @@ func pathConfigCA(b *backend) *framework.Path {
 		HelpSynopsis: `Set the SSH private key used for signing certificates.`,
 		HelpDescription: `This sets the CA information used for certificates generated by this
 by this mount. The fields must be in the standard private and public SSH format.
+The key pair is stored as the mount's default issuer; use the issuers/ endpoints
+to manage additional issuers.
 
 For security reasons, the private key cannot be retrieved later.
 
-Read operations will return the public key, if already stored/generated.`,
+Read operations will return the public key of the default issuer, if already
+stored/generated. Delete operations remove all issuers of the mount, not only
+the default one.`,
 	}
 }
 
 func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, defaultRef)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA public key: %w", err)
 	}
 
-	if publicKeyEntry == nil {
+	if issuer == nil {
 		return logical.ErrorResponse("keys haven't been configured yet"), nil
 	}
 
 	response := &logical.Response{
 		Data: map[string]interface{}{
-			"public_key": publicKeyEntry.Key,
+			"public_key": issuer.PublicKey,
 		},
 	}
 
@@ -100,12 +103,25 @@ func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, da
 }
 
 func (b *backend) pathConfigCADelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	if err := req.Storage.Delete(ctx, caPrivateKeyStoragePath); err != nil {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
 		return nil, err
 	}
-	if err := req.Storage.Delete(ctx, caPublicKeyStoragePath); err != nil {
+
+	// This endpoint predates multiple issuers; deleting the CA of the mount
+	// removes all of its issuers.
+	ids, err := listIssuers(ctx, req.Storage)
+	if err != nil {
 		return nil, err
 	}
+	for _, id := range ids {
+		if err := deleteIssuer(ctx, req.Storage, id); err != nil {
+			return nil, err
+		}
+	}
+
 	return nil, nil
 }
 
@@ -162,9 +178,50 @@ func caKey(ctx context.Context, storage logical.Storage, keyType string) (*keySt
 }
 
 func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	var err error
-	publicKey := data.Get("public_key").(string)
-	privateKey := data.Get("private_key").(string)
+	publicKey, privateKey, generated, errResp, err := b.keyPairFromRequest(data)
+	if err != nil || errResp != nil {
+		return errResp, err
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	defaultID, err := resolveIssuerReference(ctx, req.Storage, defaultRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read default issuer: %w", err)
+	}
+
+	if defaultID != "" {
+		return logical.ErrorResponse("keys are already configured; delete them before reconfiguring"), nil
+	}
+
+	if _, err := createIssuer(ctx, req.Storage, "", publicKey, privateKey, true); err != nil {
+		return nil, fmt.Errorf("failed to store CA key pair: %w", err)
+	}
+
+	if generated {
+		response := &logical.Response{
+			Data: map[string]interface{}{
+				"public_key": publicKey,
+			},
+		}
+
+		return response, nil
+	}
+
+	return nil, nil
+}
+
+// keyPairFromRequest validates the private_key/public_key pair supplied in
+// the request or, when asked to (or when neither is supplied), generates a
+// new pair from key_type and key_bits.
+func (b *backend) keyPairFromRequest(data *framework.FieldData) (publicKey string, privateKey string, generated bool, errResp *logical.Response, err error) {
+	publicKey = data.Get("public_key").(string)
+	privateKey = data.Get("private_key").(string)
 
 	var generateSigningKey bool
 
@@ -173,7 +230,7 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 	// explicitly set true
 	case ok && generateSigningKeyRaw.(bool):
 		if publicKey != "" || privateKey != "" {
-			return logical.ErrorResponse("public_key and private_key must not be set when generate_signing_key is set to true"), nil
+			return "", "", false, logical.ErrorResponse("public_key and private_key must not be set when generate_signing_key is set to true"), nil
 		}
 
 		generateSigningKey = true
@@ -181,21 +238,21 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 	// explicitly set to false, or not set and we have both a public and private key
 	case ok, publicKey != "" && privateKey != "":
 		if publicKey == "" {
-			return logical.ErrorResponse("missing public_key"), nil
+			return "", "", false, logical.ErrorResponse("missing public_key"), nil
 		}
 
 		if privateKey == "" {
-			return logical.ErrorResponse("missing private_key"), nil
+			return "", "", false, logical.ErrorResponse("missing private_key"), nil
 		}
 
 		_, err := ssh.ParsePrivateKey([]byte(privateKey))
 		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf("Unable to parse private_key as an SSH private key: %v", err)), nil
+			return "", "", false, logical.ErrorResponse(fmt.Sprintf("Unable to parse private_key as an SSH private key: %v", err)), nil
 		}
 
 		_, err = parsePublicSSHKey(publicKey)
 		if err != nil {
-			return logical.ErrorResponse(fmt.Sprintf("Unable to parse public_key as an SSH public key: %v", err)), nil
+			return "", "", false, logical.ErrorResponse(fmt.Sprintf("Unable to parse public_key as an SSH public key: %v", err)), nil
 		}
 
 	// not set and no public/private key provided so generate
@@ -204,7 +261,7 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 
 	// not set, but one or the other supplied
 	default:
-		return logical.ErrorResponse("only one of public_key and private_key set; both must be set to use, or both must be blank to auto-generate"), nil
+		return "", "", false, logical.ErrorResponse("only one of public_key and private_key set; both must be set to use, or both must be blank to auto-generate"), nil
 	}
 
 	if generateSigningKey {
@@ -213,76 +270,15 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 
 		publicKey, privateKey, err = generateSSHKeyPair(b.Backend.GetRandomReader(), keyType, keyBits)
 		if err != nil {
-			return nil, err
+			return "", "", false, nil, err
 		}
 	}
 
 	if publicKey == "" || privateKey == "" {
-		return nil, fmt.Errorf("failed to generate or parse the keys")
-	}
-
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CA public key: %w", err)
+		return "", "", false, nil, fmt.Errorf("failed to generate or parse the keys")
 	}
 
-	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CA private key: %w", err)
-	}
-
-	if (publicKeyEntry != nil && publicKeyEntry.Key != "") || (privateKeyEntry != nil && privateKeyEntry.Key != "") {
-		return logical.ErrorResponse("keys are already configured; delete them before reconfiguring"), nil
-	}
-
-	entry, err := logical.StorageEntryJSON(caPublicKeyStoragePath, &keyStorageEntry{
-		Key: publicKey,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	// Save the public key
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
-	}
-
-	entry, err = logical.StorageEntryJSON(caPrivateKeyStoragePath, &keyStorageEntry{
-		Key: privateKey,
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	// Save the private key
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		var mErr *multierror.Error
-
-		mErr = multierror.Append(mErr, fmt.Errorf("failed to store CA private key: %w", err))
-
-		// If storing private key fails, the corresponding public key should be
-		// removed
-		if delErr := req.Storage.Delete(ctx, caPublicKeyStoragePath); delErr != nil {
-			mErr = multierror.Append(mErr, fmt.Errorf("failed to cleanup CA public key: %w", delErr))
-			return nil, mErr
-		}
-
-		return nil, err
-	}
-
-	if generateSigningKey {
-		response := &logical.Response{
-			Data: map[string]interface{}{
-				"public_key": publicKey,
-			},
-		}
-
-		return response, nil
-	}
-
-	return nil, nil
+	return publicKey, privateKey, generateSigningKey, nil, nil
 }
 
 func generateSSHKeyPair(randomSource io.Reader, keyType string, keyBits int) (string, string, error) {
@@ -2,6 +2,7 @@ package ssh
 
 import (
 	"context"
+	"strings"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -15,24 +16,35 @@ func pathFetchPublicKey(b *backend) *framework.Path {
 			logical.ReadOperation: b.pathFetchPublicKey,
 		},
 
-		HelpSynopsis:    `Retrieve the public key.`,
-		HelpDescription: `This allows the public key, that this backend has been configured with, to be fetched.`,
+		HelpSynopsis:    `Retrieve the public keys of all issuers.`,
+		HelpDescription: `This allows the public keys of the issuers this backend has been configured with to be fetched, one per line with the default issuer first.`,
 	}
 }
 
 func (b *backend) pathFetchPublicKey(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	issuers, err := fetchAllIssuers(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
-	if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+
+	// Serve the public key of every issuer, default first, so the response
+	// can be used directly as a TrustedUserCAKeys file while rotating CAs.
+	var publicKeys strings.Builder
+	for _, issuer := range issuers {
+		if issuer.PublicKey == "" {
+			continue
+		}
+		publicKeys.WriteString(strings.TrimSpace(issuer.PublicKey))
+		publicKeys.WriteString("\n")
+	}
+	if publicKeys.Len() == 0 {
 		return nil, nil
 	}
 
 	response := &logical.Response{
 		Data: map[string]interface{}{
 			logical.HTTPContentType: "text/plain",
-			logical.HTTPRawBody:     []byte(publicKeyEntry.Key),
+			logical.HTTPRawBody:     []byte(publicKeys.String()),
 			logical.HTTPStatusCode:  200,
 		},
 	}
@@ -98,15 +98,18 @@ func (b *backend) pathSignIssueCertificateHelper(ctx context.Context, req *logic
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, role.IssuerRef)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA private key: %w", err)
 	}
-	if privateKeyEntry == nil || privateKeyEntry.Key == "" {
+	if issuer == nil || issuer.PrivateKey == "" {
+		if role.IssuerRef != "" && role.IssuerRef != defaultRef {
+			return nil, fmt.Errorf("failed to read CA private key for issuer %q", role.IssuerRef)
+		}
 		return nil, errors.New("failed to read CA private key")
 	}
 
-	signer, err := ssh.ParsePrivateKey([]byte(privateKeyEntry.Key))
+	signer, err := ssh.ParsePrivateKey([]byte(issuer.PrivateKey))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse stored CA private key: %w", err)
 	}
@@ -0,0 +1,437 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+func pathListIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathIssuersList,
+		},
+
+		HelpSynopsis:    pathListIssuersHelpSyn,
+		HelpDescription: pathListIssuersHelpDesc,
+	}
+}
+
+func issuerNameField() *framework.FieldSchema {
+	return &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Optional name for the issuer; may be used in place of the issuer ID when referencing it. Must be unique and may not be "default".`,
+	}
+}
+
+func setDefaultField() *framework.FieldSchema {
+	return &framework.FieldSchema{
+		Type:        framework.TypeBool,
+		Description: `Make the new issuer the default issuer of this mount. The first issuer created on a mount always becomes the default.`,
+	}
+}
+
+func pathIssuersGenerate(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/generate",
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_name": issuerNameField(),
+			"set_default": setDefaultField(),
+			"key_type": {
+				Type:        framework.TypeString,
+				Description: `Specifies the desired key type; could be a OpenSSH key type identifier (ssh-rsa, ecdsa-sha2-nistp256, ecdsa-sha2-nistp384, ecdsa-sha2-nistp521, or ssh-ed25519) or an algorithm (rsa, ec, ed25519).`,
+				Default:     "ssh-rsa",
+			},
+			"key_bits": {
+				Type:        framework.TypeInt,
+				Description: `Specifies the desired key bits for variable-length keys (such as when key_type="ssh-rsa") or which NIST P-curve to use when key_type="ec" (256, 384, or 521).`,
+				Default:     0,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathIssuersGenerate,
+		},
+
+		HelpSynopsis:    pathIssuersGenerateHelpSyn,
+		HelpDescription: pathIssuersGenerateHelpDesc,
+	}
+}
+
+func pathIssuersImport(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/import",
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_name": issuerNameField(),
+			"set_default": setDefaultField(),
+			"private_key": {
+				Type:        framework.TypeString,
+				Description: `Private half of the SSH key that will be used to sign certificates.`,
+				Required:    true,
+			},
+			"public_key": {
+				Type:        framework.TypeString,
+				Description: `Public half of the SSH key that will be used to sign certificates.`,
+				Required:    true,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathIssuersImport,
+		},
+
+		HelpSynopsis:    pathIssuersImportHelpSyn,
+		HelpDescription: pathIssuersImportHelpDesc,
+	}
+}
+
+func pathIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex(issuerRefParam),
+		Fields: map[string]*framework.FieldSchema{
+			issuerRefParam: {
+				Type:        framework.TypeString,
+				Description: `Reference to an existing issuer: either its ID, its name, or "default" for the mount's default issuer.`,
+			},
+			"issuer_name": issuerNameField(),
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathIssuerRead,
+			logical.UpdateOperation: b.pathIssuerWrite,
+			logical.DeleteOperation: b.pathIssuerDelete,
+		},
+
+		HelpSynopsis:    pathIssuerHelpSyn,
+		HelpDescription: pathIssuerHelpDesc,
+	}
+}
+
+func pathConfigIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/issuers",
+		Fields: map[string]*framework.FieldSchema{
+			defaultRef: {
+				Type:        framework.TypeString,
+				Description: `Reference (ID or name) to the issuer to use as the default for signing.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigIssuersRead,
+			logical.UpdateOperation: b.pathConfigIssuersWrite,
+		},
+
+		HelpSynopsis:    pathConfigIssuersHelpSyn,
+		HelpDescription: pathConfigIssuersHelpDesc,
+	}
+}
+
+func (b *backend) pathIssuersList(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	ids, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	keyInfo := map[string]interface{}{}
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			continue
+		}
+
+		keys = append(keys, issuer.ID)
+		keyInfo[issuer.ID] = map[string]interface{}{
+			"issuer_name": issuer.Name,
+			"is_default":  issuer.ID == config.DefaultIssuerID,
+		}
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func (b *backend) pathIssuersGenerate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("issuer_name").(string)
+	if err := validateIssuerName(name); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	publicKey, privateKey, err := generateSSHKeyPair(b.Backend.GetRandomReader(), data.Get("key_type").(string), data.Get("key_bits").(int))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return b.storeNewIssuer(ctx, req, name, publicKey, privateKey, data.Get("set_default").(bool))
+}
+
+func (b *backend) pathIssuersImport(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("issuer_name").(string)
+	if err := validateIssuerName(name); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	publicKey := data.Get("public_key").(string)
+	privateKey := data.Get("private_key").(string)
+	if publicKey == "" {
+		return logical.ErrorResponse("missing public_key"), nil
+	}
+	if privateKey == "" {
+		return logical.ErrorResponse("missing private_key"), nil
+	}
+
+	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Unable to parse private_key as an SSH private key: %v", err)), nil
+	}
+
+	parsedPublicKey, err := parsePublicSSHKey(publicKey)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("Unable to parse public_key as an SSH public key: %v", err)), nil
+	}
+
+	if string(signer.PublicKey().Marshal()) != string(parsedPublicKey.Marshal()) {
+		return logical.ErrorResponse("public_key does not match private_key"), nil
+	}
+
+	return b.storeNewIssuer(ctx, req, name, publicKey, privateKey, data.Get("set_default").(bool))
+}
+
+func (b *backend) storeNewIssuer(ctx context.Context, req *logical.Request, name, publicKey, privateKey string, setDefault bool) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		existing, err := resolveIssuerReference(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != "" {
+			return logical.ErrorResponse(fmt.Sprintf("an issuer with the name %q already exists", name)), nil
+		}
+	}
+
+	issuer, err := createIssuer(ctx, req.Storage, name, publicKey, privateKey, setDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.issuerResponse(ctx, req.Storage, issuer)
+}
+
+func (b *backend) issuerResponse(ctx context.Context, s logical.Storage, issuer *issuerEntry) (*logical.Response, error) {
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id":   issuer.ID,
+			"issuer_name": issuer.Name,
+			"public_key":  issuer.PublicKey,
+			"is_default":  issuer.ID == config.DefaultIssuerID,
+		},
+	}, nil
+}
+
+func (b *backend) pathIssuerRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ref := data.Get(issuerRefParam).(string)
+	id, err := resolveIssuerReference(ctx, req.Storage, ref)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return logical.ErrorResponse(fmt.Sprintf("unable to find SSH issuer for reference: %v", ref)), nil
+	}
+
+	issuer, err := fetchIssuerByID(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+
+	return b.issuerResponse(ctx, req.Storage, issuer)
+}
+
+func (b *backend) pathIssuerWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	ref := data.Get(issuerRefParam).(string)
+	id, err := resolveIssuerReference(ctx, req.Storage, ref)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return logical.ErrorResponse(fmt.Sprintf("unable to find SSH issuer for reference: %v", ref)), nil
+	}
+
+	issuer, err := fetchIssuerByID(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unable to find SSH issuer for reference: %v", ref)), nil
+	}
+
+	if nameRaw, ok := data.GetOk("issuer_name"); ok {
+		name := nameRaw.(string)
+		if err := validateIssuerName(name); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		if name != "" && name != issuer.Name {
+			existing, err := resolveIssuerReference(ctx, req.Storage, name)
+			if err != nil {
+				return nil, err
+			}
+			if existing != "" {
+				return logical.ErrorResponse(fmt.Sprintf("an issuer with the name %q already exists", name)), nil
+			}
+		}
+
+		issuer.Name = name
+		if err := writeIssuer(ctx, req.Storage, issuer); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.issuerResponse(ctx, req.Storage, issuer)
+}
+
+func (b *backend) pathIssuerDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	id, err := resolveIssuerReference(ctx, req.Storage, data.Get(issuerRefParam).(string))
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, nil
+	}
+
+	return nil, deleteIssuer(ctx, req.Storage, id)
+}
+
+func (b *backend) pathConfigIssuersRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			defaultRef: config.DefaultIssuerID,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigIssuersWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	ref := data.Get(defaultRef).(string)
+	if ref == "" || ref == defaultRef {
+		return logical.ErrorResponse("invalid issuer specification; must be non-empty and can't be 'default'"), nil
+	}
+
+	id, err := resolveIssuerReference(ctx, req.Storage, ref)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return logical.ErrorResponse(fmt.Sprintf("unable to find SSH issuer for reference: %v", ref)), nil
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	config.DefaultIssuerID = id
+	if err := setIssuersConfig(ctx, req.Storage, config); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			defaultRef: id,
+		},
+	}, nil
+}
+
+const pathListIssuersHelpSyn = `List the SSH CA issuers configured on this mount.`
+
+const pathListIssuersHelpDesc = `
+This endpoint lists the IDs of all issuers on this mount, along with their
+names and whether each is the current default issuer.
+`
+
+const pathIssuersGenerateHelpSyn = `Generate a new SSH CA key pair as an issuer.`
+
+const pathIssuersGenerateHelpDesc = `
+This endpoint generates a new SSH CA key pair and stores it as a new issuer.
+The public key of every issuer on the mount is served from the public_key
+endpoint, allowing a new CA to be distributed to hosts before roles are moved
+over to it.
+
+For security reasons, the private key cannot be retrieved later.
+`
+
+const pathIssuersImportHelpSyn = `Import an existing SSH CA key pair as an issuer.`
+
+const pathIssuersImportHelpDesc = `
+This endpoint stores an existing SSH CA key pair as a new issuer. The fields
+must be in the standard private and public SSH format, and the public key must
+correspond to the private key.
+
+For security reasons, the private key cannot be retrieved later.
+`
+
+const pathIssuerHelpSyn = `Manage a single SSH CA issuer.`
+
+const pathIssuerHelpDesc = `
+Read operations return the issuer's ID, name and public key. Write operations
+allow renaming the issuer. Delete operations remove the issuer and its key
+pair; roles still referencing it will fail to sign until updated.
+`
+
+const pathConfigIssuersHelpSyn = `Read and set the default SSH CA issuer.`
+
+const pathConfigIssuersHelpDesc = `
+The default issuer signs certificates for roles which do not set issuer_ref,
+and is the issuer managed by the legacy config/ca endpoint.
+`
@@ -0,0 +1,274 @@
+package ssh
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSH_IssuerRotation(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Data:      data,
+			Storage:   config.StorageView,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: %v %v: err: %v, resp: %v", op, path, err, resp)
+		}
+		return resp
+	}
+
+	// The first issuer becomes the default automatically.
+	resp := request(logical.UpdateOperation, "issuers/import", map[string]interface{}{
+		"issuer_name": "old",
+		"public_key":  testCAPublicKey,
+		"private_key": testCAPrivateKey,
+	})
+	oldID := resp.Data["issuer_id"].(string)
+	if !resp.Data["is_default"].(bool) {
+		t.Fatalf("expected first issuer to be the default: %v", resp.Data)
+	}
+
+	resp = request(logical.UpdateOperation, "issuers/generate", map[string]interface{}{
+		"issuer_name": "new",
+		"key_type":    "ed25519",
+	})
+	newID := resp.Data["issuer_id"].(string)
+	newPublicKey := resp.Data["public_key"].(string)
+	if resp.Data["is_default"].(bool) {
+		t.Fatalf("expected second issuer not to be the default: %v", resp.Data)
+	}
+
+	// Duplicate and reserved names are rejected.
+	for _, name := range []string{"new", "default"} {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "issuers/generate",
+			Data:      map[string]interface{}{"issuer_name": name},
+			Storage:   config.StorageView,
+		})
+		if err != nil || resp == nil || !resp.IsError() {
+			t.Fatalf("expected error creating issuer named %q: err: %v, resp: %v", name, err, resp)
+		}
+	}
+
+	resp = request(logical.ListOperation, "issuers", nil)
+	if keys := resp.Data["keys"].([]string); len(keys) != 2 {
+		t.Fatalf("expected two issuers, got %v", keys)
+	}
+
+	// Both public keys are trusted, with the default first.
+	resp = request(logical.ReadOperation, "public_key", nil)
+	lines := strings.Split(strings.TrimSpace(string(resp.Data[logical.HTTPRawBody].([]byte))), "\n")
+	if len(lines) != 2 || lines[0] != strings.TrimSpace(testCAPublicKey) || lines[1] != strings.TrimSpace(newPublicKey) {
+		t.Fatalf("unexpected public keys: %v", lines)
+	}
+
+	request(logical.UpdateOperation, "roles/default-issuer", map[string]interface{}{
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"key_type":                "ca",
+	})
+	request(logical.UpdateOperation, "roles/pinned-issuer", map[string]interface{}{
+		"allow_user_certificates": true,
+		"allowed_users":           "*",
+		"key_type":                "ca",
+		"issuer_ref":              "new",
+	})
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/missing-issuer",
+		Data: map[string]interface{}{
+			"allow_user_certificates": true,
+			"key_type":                "ca",
+			"issuer_ref":              "missing",
+		},
+		Storage: config.StorageView,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for unknown issuer_ref: err: %v, resp: %v", err, resp)
+	}
+
+	signedBy := func(role string) string {
+		t.Helper()
+		resp := request(logical.UpdateOperation, "sign/"+role, map[string]interface{}{
+			"public_key": testCAPublicKeyEd25519,
+		})
+		parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(parsed.(*ssh.Certificate).SignatureKey.Marshal())
+	}
+	wireKey := func(publicKey string) string {
+		t.Helper()
+		parsed, err := parsePublicSSHKey(publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(parsed.Marshal())
+	}
+
+	if signedBy("default-issuer") != wireKey(testCAPublicKey) {
+		t.Fatal("expected default role to be signed by old issuer")
+	}
+	if signedBy("pinned-issuer") != wireKey(newPublicKey) {
+		t.Fatal("expected pinned role to be signed by new issuer")
+	}
+
+	// Switching the default moves roles without an explicit issuer_ref.
+	resp = request(logical.UpdateOperation, "config/issuers", map[string]interface{}{
+		"default": "new",
+	})
+	if resp.Data["default"] != newID {
+		t.Fatalf("expected default to be %v, got %v", newID, resp.Data)
+	}
+	if signedBy("default-issuer") != wireKey(newPublicKey) {
+		t.Fatal("expected default role to be signed by new issuer")
+	}
+
+	// Retiring the old issuer removes it from the trusted keys.
+	request(logical.DeleteOperation, "issuer/"+oldID, nil)
+	resp = request(logical.ReadOperation, "public_key", nil)
+	if got := strings.TrimSpace(string(resp.Data[logical.HTTPRawBody].([]byte))); got != strings.TrimSpace(newPublicKey) {
+		t.Fatalf("unexpected public keys after deletion: %v", got)
+	}
+
+	// Deleting config/ca removes every remaining issuer.
+	request(logical.UpdateOperation, "issuers/generate", map[string]interface{}{
+		"issuer_name": "other",
+		"key_type":    "ed25519",
+	})
+	request(logical.DeleteOperation, "config/ca", nil)
+	resp = request(logical.ListOperation, "issuers", nil)
+	if keys, _ := resp.Data["keys"].([]string); len(keys) != 0 {
+		t.Fatalf("expected no issuers after deleting config/ca, got %v", resp.Data["keys"])
+	}
+}
+
+func TestSSH_IssuerConcurrentCreate(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "issuers/generate",
+				Data:      map[string]interface{}{"issuer_name": "dup", "key_type": "ed25519"},
+				Storage:   config.StorageView,
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Only one of the concurrent writes may claim the name.
+	ids, err := listIssuers(context.Background(), config.StorageView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 {
+		t.Fatalf("expected a single issuer, got %d", len(ids))
+	}
+	issuersConfig, err := getIssuersConfig(context.Background(), config.StorageView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issuersConfig.DefaultIssuerID != ids[0] {
+		t.Fatalf("expected default issuer %v, got %v", ids[0], issuersConfig.DefaultIssuerID)
+	}
+}
+
+func TestSSH_IssuerLegacyMigration(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	// Store a CA the way older versions of config/ca did.
+	for path, key := range map[string]string{
+		caPublicKeyStoragePath:  testCAPublicKey,
+		caPrivateKeyStoragePath: testCAPrivateKey,
+	} {
+		entry, err := logical.StorageEntryJSON(path, &keyStorageEntry{Key: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := config.StorageView.Put(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reads work prior to migration.
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "config/ca",
+		Storage:   config.StorageView,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+	if resp.Data["public_key"] != testCAPublicKey {
+		t.Fatalf("unexpected public key: %v", resp.Data["public_key"])
+	}
+
+	// Any write to the issuers migrates the legacy key pair to the default.
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "issuers/generate",
+		Data:      map[string]interface{}{"key_type": "ed25519"},
+		Storage:   config.StorageView,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+	if resp.Data["is_default"].(bool) {
+		t.Fatalf("expected the migrated issuer to remain the default")
+	}
+
+	issuer, err := fetchIssuerByRef(context.Background(), config.StorageView, defaultRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issuer == nil || issuer.ID == "" || issuer.PublicKey != testCAPublicKey {
+		t.Fatalf("expected legacy key pair to be migrated to the default issuer, got %#v", issuer)
+	}
+
+	entry, err := config.StorageView.Get(context.Background(), caPrivateKeyStoragePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != nil {
+		t.Fatal("expected legacy private key entry to be removed")
+	}
+}
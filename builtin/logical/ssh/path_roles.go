@@ -68,6 +68,7 @@ type sshRole struct {
 	AlgorithmSigner            string            `mapstructure:"algorithm_signer" json:"algorithm_signer"`
 	Version                    int               `mapstructure:"role_version" json:"role_version"`
 	NotBeforeDuration          time.Duration     `mapstructure:"not_before_duration" json:"not_before_duration"`
+	IssuerRef                  string            `mapstructure:"issuer_ref" json:"issuer_ref"`
 }
 
 func pathListRoles(b *backend) *framework.Path {
@@ -394,6 +395,17 @@ func pathRoles(b *backend) *framework.Path {
 					Value: 30,
 				},
 			},
+			issuerRefParam: {
+				Type:    framework.TypeString,
+				Default: defaultRef,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				Reference (ID or name) to the issuer used to sign certificates for this role. Defaults
+				to "default", which always uses the mount's current default issuer.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Issuer Reference",
+				},
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -550,6 +562,18 @@ func (b *backend) pathRoleWrite(ctx context.Context, req *logical.Request, d *fr
 		if errorResponse != nil {
 			return errorResponse, nil
 		}
+
+		// Only validate explicit references; the default issuer may be
+		// configured after the role is written.
+		if role.IssuerRef != defaultRef {
+			issuerID, err := resolveIssuerReference(ctx, req.Storage, role.IssuerRef)
+			if err != nil {
+				return nil, err
+			}
+			if issuerID == "" {
+				return logical.ErrorResponse(fmt.Sprintf("unable to find SSH issuer for reference: %v", role.IssuerRef)), nil
+			}
+		}
 		roleEntry = *role
 	} else {
 		return logical.ErrorResponse("invalid key type"), nil
@@ -589,6 +613,11 @@ func (b *backend) createCARole(allowedUsers, defaultUser, signer string, data *f
 		AlgorithmSigner:           signer,
 		Version:                   roleEntryVersion,
 		NotBeforeDuration:         time.Duration(data.Get("not_before_duration").(int)) * time.Second,
+		IssuerRef:                 data.Get(issuerRefParam).(string),
+	}
+
+	if role.IssuerRef == "" {
+		role.IssuerRef = defaultRef
 	}
 
 	if !role.AllowUserCertificates && !role.AllowHostCertificates {
@@ -677,17 +706,17 @@ func (b *backend) checkUpgrade(ctx context.Context, s logical.Storage, n string,
 		// signing key type as we want to make ssh-rsa an explicitly notated
 		// algorithm choice.
 		var publicKey ssh.PublicKey
-		publicKeyEntry, err := caKey(ctx, s, caPublicKey)
+		issuer, err := fetchIssuerByRef(ctx, s, result.IssuerRef)
 		if err != nil {
 			b.Logger().Debug(fmt.Sprintf("failed to load public key entry while attempting to migrate: %v", err))
 			goto SKIPVERSION2
 		}
-		if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+		if issuer == nil || issuer.PublicKey == "" {
 			b.Logger().Debug(fmt.Sprintf("got empty public key entry while attempting to migrate"))
 			goto SKIPVERSION2
 		}
 
-		publicKey, err = parsePublicSSHKey(publicKeyEntry.Key)
+		publicKey, err = parsePublicSSHKey(issuer.PublicKey)
 		if err == nil {
 			// Move an empty signing algorithm to an explicit ssh-rsa (SHA-1)
 			// if this key is of type RSA. This isn't a secure default but
@@ -782,6 +811,7 @@ func (b *backend) parseRole(role *sshRole) (map[string]interface{}, error) {
 			"allowed_user_key_lengths":    role.AllowedUserKeyTypesLengths,
 			"algorithm_signer":            role.AlgorithmSigner,
 			"not_before_duration":         int64(role.NotBeforeDuration.Seconds()),
+			"issuer_ref":                  role.IssuerRef,
 		}
 	case KeyTypeDynamic:
 		result = map[string]interface{}{
@@ -49,6 +49,8 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 			b.pathWrappingKey(),
 			b.pathImport(),
 			b.pathImportVersion(),
+			b.pathCreateCsr(),
+			b.pathSetCertificate(),
 			b.pathKeys(),
 			b.pathListKeys(),
 			b.pathExportKeys(),
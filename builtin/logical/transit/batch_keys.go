@@ -0,0 +1,140 @@
+package transit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// batchKeys holds the read-locked keys used by a sign, verify or hmac
+// request. Batch items may name a key other than the one in the request
+// path; as ACLs are only evaluated against the path, such a key must list
+// the path key in its allowed_batch_keys configuration to be usable.
+type batchKeys struct {
+	pathKey  string
+	policies map[string]*keysutil.Policy
+}
+
+// lockBatchKeys fetches and read-locks the path key and every key named by
+// the batch items. Keys are locked in sorted order so that concurrent
+// requests over overlapping sets of keys cannot deadlock when caching is
+// disabled. Keys which do not exist are reported per item by get.
+func (b *backend) lockBatchKeys(ctx context.Context, s logical.Storage, pathKey string, itemKeys []string) (*batchKeys, error) {
+	names := strutil.RemoveDuplicates(append([]string{pathKey}, itemKeys...), false)
+	sort.Strings(names)
+
+	bk := &batchKeys{
+		pathKey:  pathKey,
+		policies: make(map[string]*keysutil.Policy, len(names)),
+	}
+
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+
+		p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+			Storage: s,
+			Name:    name,
+		}, b.GetRandomReader())
+		if err != nil {
+			bk.unlock()
+			return nil, err
+		}
+		if p == nil {
+			continue
+		}
+		if !b.System().CachingDisabled() {
+			p.Lock(false)
+		}
+
+		bk.policies[name] = p
+	}
+
+	return bk, nil
+}
+
+// get returns the key to use for a batch item, falling back to the path key
+// when the item does not name one.
+func (bk *batchKeys) get(name string) (*keysutil.Policy, error) {
+	if name == "" {
+		name = bk.pathKey
+	}
+
+	p, ok := bk.policies[name]
+	if !ok {
+		return nil, errutil.UserError{Err: "encryption key not found"}
+	}
+
+	if name != bk.pathKey && !strutil.StrListContains(p.AllowedBatchKeys, bk.pathKey) {
+		return nil, errutil.UserError{Err: fmt.Sprintf("key %q does not allow batch use through key %q", name, bk.pathKey)}
+	}
+
+	return p, nil
+}
+
+func (bk *batchKeys) unlock() {
+	for _, p := range bk.policies {
+		p.Unlock()
+	}
+}
+
+// batchItemKeys returns the key names referenced by the given batch items.
+func batchItemKeys[T ~map[string]string](items []T) []string {
+	var names []string
+	for _, item := range items {
+		if name := item["name"]; name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// respondWithBatchStatus sets the status code of a batch response based on
+// the errors of its items when the request sets batch_status_codes, in the
+// same way as the encrypt endpoint: user errors return a 400 and take
+// precedence over internal errors, which return a 500. Otherwise the
+// response keeps its 200, with the errors reported per item.
+func respondWithBatchStatus(resp *logical.Response, req *logical.Request, d *framework.FieldData, itemErrs []error) (*logical.Response, error) {
+	if !d.Get("batch_status_codes").(bool) {
+		return resp, nil
+	}
+
+	userErrorInBatch := false
+	internalErrorInBatch := false
+	for _, err := range itemErrs {
+		switch {
+		case err == nil:
+		case isUserError(err):
+			userErrorInBatch = true
+		default:
+			internalErrorInBatch = true
+		}
+	}
+
+	switch {
+	case userErrorInBatch:
+		return logical.RespondWithStatusCode(resp, req, http.StatusBadRequest)
+	case internalErrorInBatch:
+		return logical.RespondWithStatusCode(resp, req, http.StatusInternalServerError)
+	}
+
+	return resp, nil
+}
+
+func isUserError(err error) bool {
+	if errors.Is(err, logical.ErrInvalidRequest) {
+		return true
+	}
+
+	var userErr errutil.UserError
+	return errors.As(err, &userErr)
+}
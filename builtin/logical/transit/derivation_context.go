@@ -0,0 +1,131 @@
+package transit
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/identitytpl"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// contextPolicy enforces the context_template and context_required_prefix
+// settings of derived keys on the key derivation contexts supplied with a
+// request. The identity of the requesting entity is looked up at most once
+// per request, on first use.
+type contextPolicy struct {
+	sys logical.SystemView
+	req *logical.Request
+
+	loaded bool
+	entity *logical.Entity
+	groups []*logical.Group
+}
+
+func (b *backend) newContextPolicy(req *logical.Request) *contextPolicy {
+	return &contextPolicy{
+		sys: b.System(),
+		req: req,
+	}
+}
+
+// apply checks the given context against the key's context policy and
+// returns the context to derive the key with. When the key has a context
+// template and no context was supplied, the rendered template is used.
+func (c *contextPolicy) apply(p *keysutil.Policy, context []byte) ([]byte, error) {
+	if !p.Derived || (p.ContextTemplate == "" && p.ContextRequiredPrefix == "") {
+		return context, nil
+	}
+
+	if p.ContextTemplate != "" {
+		expected, err := c.render(p.ContextTemplate)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(context) == 0:
+			context = []byte(expected)
+		case !bytes.Equal(context, []byte(expected)):
+			return nil, errutil.UserError{Err: "context does not match the key's context template"}
+		}
+	}
+
+	if p.ContextRequiredPrefix != "" {
+		prefix, err := c.render(p.ContextRequiredPrefix)
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.HasPrefix(context, []byte(prefix)) {
+			return nil, errutil.UserError{Err: "context does not start with the key's required prefix"}
+		}
+	}
+
+	return context, nil
+}
+
+func (c *contextPolicy) render(tpl string) (string, error) {
+	if !c.loaded {
+		if c.req.EntityID != "" {
+			entity, err := c.sys.EntityInfo(c.req.EntityID)
+			if err != nil {
+				return "", errutil.InternalError{Err: fmt.Sprintf("failed to look up entity: %v", err)}
+			}
+			groups, err := c.sys.GroupsForEntity(c.req.EntityID)
+			if err != nil {
+				return "", errutil.InternalError{Err: fmt.Sprintf("failed to look up groups for entity: %v", err)}
+			}
+			c.entity = entity
+			c.groups = groups
+		}
+		c.loaded = true
+	}
+
+	input := identitytpl.PopulateStringInput{
+		Mode:   identitytpl.ACLTemplating,
+		String: tpl,
+		Entity: c.entity,
+		Groups: c.groups,
+	}
+	if c.entity != nil {
+		input.NamespaceID = c.entity.NamespaceID
+	}
+
+	_, rendered, err := identitytpl.PopulateString(input)
+	if err != nil {
+		return "", errutil.UserError{Err: fmt.Sprintf("unable to render the key's context policy for this request: %v", err)}
+	}
+
+	return rendered, nil
+}
+
+// validateContextPolicy checks the context_template and
+// context_required_prefix settings of a key, returning an error response if
+// they are invalid.
+func validateContextPolicy(derived bool, contextTemplate, contextRequiredPrefix string) *logical.Response {
+	if contextTemplate == "" && contextRequiredPrefix == "" {
+		return nil
+	}
+
+	if !derived {
+		return logical.ErrorResponse("context_template and context_required_prefix require derivation to be enabled")
+	}
+
+	for field, tpl := range map[string]string{
+		"context_template":        contextTemplate,
+		"context_required_prefix": contextRequiredPrefix,
+	} {
+		_, _, err := identitytpl.PopulateString(identitytpl.PopulateStringInput{
+			Mode:              identitytpl.ACLTemplating,
+			String:            tpl,
+			ValidityCheckOnly: true,
+		})
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid %s: %v", field, err))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+type entitySystemView struct {
+	*logical.StaticSystemView
+	entities map[string]*logical.Entity
+}
+
+func (e entitySystemView) EntityInfo(entityID string) (*logical.Entity, error) {
+	return e.entities[entityID], nil
+}
+
+func TestTransit_DerivationContextPolicy(t *testing.T) {
+	sysView := entitySystemView{
+		StaticSystemView: logical.TestSystemView(),
+		entities: map[string]*logical.Entity{
+			"entity-a": {ID: "entity-a", Metadata: map[string]string{"tenant": "a"}},
+			"entity-b": {ID: "entity-b", Metadata: map[string]string{"tenant": "b"}},
+		},
+	}
+	storage := &logical.InmemStorage{}
+	conf := &logical.BackendConfig{
+		StorageView: storage,
+		System:      sysView,
+	}
+	b, err := Backend(context.Background(), conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Backend.Setup(context.Background(), conf); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(entityID, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+			EntityID:  entityID,
+		})
+	}
+	mustSucceed := func(resp *logical.Response, err error) *logical.Response {
+		t.Helper()
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: err: %v, resp: %v", err, resp)
+		}
+		return resp
+	}
+	mustFail := func(contains string) func(*logical.Response, error) {
+		return func(resp *logical.Response, err error) {
+			t.Helper()
+			if err == nil && (resp == nil || !resp.IsError()) {
+				t.Fatalf("expected error, got resp: %v", resp)
+			}
+			if resp != nil && resp.IsError() && !strings.Contains(resp.Error().Error(), contains) {
+				t.Fatalf("expected error containing %q, got %v", contains, resp.Error())
+			}
+		}
+	}
+	b64 := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	plaintext := b64("the quick brown fox")
+
+	// Context policies require derived keys.
+	mustFail("require derivation")(request("", "keys/plain", map[string]interface{}{
+		"context_template": "{{identity.entity.id}}",
+	}))
+
+	mustSucceed(request("", "keys/per-entity", map[string]interface{}{
+		"derived":          true,
+		"context_template": "{{identity.entity.id}}",
+	}))
+
+	// The rendered template is used when no context is given.
+	resp := mustSucceed(request("entity-a", "encrypt/per-entity", map[string]interface{}{
+		"plaintext": plaintext,
+	}))
+	ciphertext := resp.Data["ciphertext"].(string)
+
+	resp = mustSucceed(request("entity-a", "decrypt/per-entity", map[string]interface{}{
+		"ciphertext": ciphertext,
+		"context":    b64("entity-a"),
+	}))
+	if resp.Data["plaintext"] != plaintext {
+		t.Fatalf("unexpected plaintext: %v", resp.Data["plaintext"])
+	}
+
+	// Another entity can neither claim the first entity's context nor
+	// decrypt with its own.
+	mustFail("does not match")(request("entity-b", "decrypt/per-entity", map[string]interface{}{
+		"ciphertext": ciphertext,
+		"context":    b64("entity-a"),
+	}))
+	mustFail("")(request("entity-b", "decrypt/per-entity", map[string]interface{}{
+		"ciphertext": ciphertext,
+	}))
+
+	// Requests without an entity cannot render the template.
+	mustFail("unable to render")(request("", "encrypt/per-entity", map[string]interface{}{
+		"plaintext": plaintext,
+	}))
+
+	// A tenant prefix still allows per-document contexts.
+	mustSucceed(request("", "keys/per-tenant", map[string]interface{}{
+		"derived":                 true,
+		"context_required_prefix": "tenant-{{identity.entity.metadata.tenant}}/",
+	}))
+	resp = mustSucceed(request("entity-a", "encrypt/per-tenant", map[string]interface{}{
+		"plaintext": plaintext,
+		"context":   b64("tenant-a/doc-1"),
+	}))
+	ciphertext = resp.Data["ciphertext"].(string)
+
+	mustFail("required prefix")(request("entity-b", "decrypt/per-tenant", map[string]interface{}{
+		"ciphertext": ciphertext,
+		"context":    b64("tenant-a/doc-1"),
+	}))
+	mustFail("required prefix")(request("entity-a", "datakey/plaintext/per-tenant", map[string]interface{}{
+		"context": b64("tenant-b/doc-1"),
+	}))
+
+	// The policy can be changed and removed through the key config.
+	mustSucceed(request("", "keys/per-tenant/config", map[string]interface{}{
+		"context_required_prefix": "",
+	}))
+	mustSucceed(request("entity-b", "decrypt/per-tenant", map[string]interface{}{
+		"ciphertext": ciphertext,
+		"context":    b64("tenant-a/doc-1"),
+	}))
+	mustFail("invalid context_template")(request("", "keys/per-tenant/config", map[string]interface{}{
+		"context_template": "{{identity.entity.id",
+	}))
+}
@@ -2,9 +2,14 @@ package transit
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 
+	"github.com/hashicorp/vault/helper/pgpkeys"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/shamir"
 )
 
 func (b *backend) pathBackup() *framework.Path {
@@ -15,10 +20,29 @@ func (b *backend) pathBackup() *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Name of the key",
 			},
+			"secret_shares": {
+				Type: framework.TypeInt,
+				Description: `If set, the backup is split into this many
+shares using Shamir's secret sharing, each
+encrypted with the corresponding key in
+pgp_keys.`,
+			},
+			"secret_threshold": {
+				Type: framework.TypeInt,
+				Description: `Number of shares required to restore the
+backup. Required when secret_shares is set.`,
+			},
+			"pgp_keys": {
+				Type: framework.TypeCommaStringSlice,
+				Description: `Base64 encoded PGP public keys used to encrypt
+the shares, one per share and in the same order
+as the returned backup_shares.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
-			logical.ReadOperation: b.pathBackupRead,
+			logical.ReadOperation:   b.pathBackupRead,
+			logical.UpdateOperation: b.pathBackupRead,
 		},
 
 		HelpSynopsis:    pathBackupHelpSyn,
@@ -27,19 +51,72 @@ func (b *backend) pathBackup() *framework.Path {
 }
 
 func (b *backend) pathBackupRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	secretShares := d.Get("secret_shares").(int)
+	secretThreshold := d.Get("secret_threshold").(int)
+	pgpKeys := d.Get("pgp_keys").([]string)
+
+	if secretShares != 0 || secretThreshold != 0 || len(pgpKeys) != 0 {
+		switch {
+		case secretShares < 2 || secretShares > 255:
+			return logical.ErrorResponse("secret_shares must be between 2 and 255"), logical.ErrInvalidRequest
+		case secretThreshold < 2 || secretThreshold > secretShares:
+			return logical.ErrorResponse("secret_threshold must be at least 2 and cannot exceed secret_shares"), logical.ErrInvalidRequest
+		case len(pgpKeys) != secretShares:
+			return logical.ErrorResponse("the number of pgp_keys must match secret_shares"), logical.ErrInvalidRequest
+		}
+	}
+
 	backup, err := b.lm.BackupPolicy(ctx, req.Storage, d.Get("name").(string))
 	if err != nil {
 		return nil, err
 	}
 
+	if secretShares == 0 {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"backup": backup,
+			},
+		}, nil
+	}
+
+	shares, err := shamir.Split([]byte(backup), secretShares, secretThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split backup: %w", err)
+	}
+
+	// As with unseal keys, shares are hex encoded prior to encryption so
+	// that holders can decrypt them to a printable value.
+	hexEncodedShares := make([][]byte, len(shares))
+	for i := range shares {
+		hexEncodedShares[i] = []byte(hex.EncodeToString(shares[i]))
+	}
+
+	fingerprints, encryptedShares, err := pgpkeys.EncryptShares(hexEncodedShares, pgpKeys)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to encrypt shares: %v", err)), logical.ErrInvalidRequest
+	}
+
+	backupShares := make([]string, len(encryptedShares))
+	for i := range encryptedShares {
+		backupShares[i] = base64.StdEncoding.EncodeToString(encryptedShares[i])
+	}
+
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"backup": backup,
+			"backup_shares":    backupShares,
+			"pgp_fingerprints": fingerprints,
+			"secret_threshold": secretThreshold,
 		},
 	}, nil
 }
 
 const (
 	pathBackupHelpSyn  = `Backup the named key`
-	pathBackupHelpDesc = `This path is used to backup the named key.`
+	pathBackupHelpDesc = `This path is used to backup the named key.
+
+If secret_shares, secret_threshold and pgp_keys are given, the backup is not
+returned as a single value. Instead, it is split using Shamir's secret sharing
+and each hex encoded share is encrypted with the corresponding PGP key. Any
+secret_threshold of the decrypted shares can be passed to the restore endpoint
+as backup_shares.`
 )
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/hashicorp/vault/helper/pgpkeys"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -249,3 +250,95 @@ func testBackupRestore(t *testing.T, keyType, feature string) {
 	// Ensure that the restored key is functional
 	validationFunc("test1")
 }
+
+func TestTransit_BackupRestoreShares(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Path:      path,
+			Operation: op,
+			Storage:   s,
+			Data:      data,
+		})
+	}
+
+	resp, err := request(logical.UpdateOperation, "keys/test", map[string]interface{}{
+		"allow_plaintext_backup": true,
+		"exportable":             true,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("resp: %#v\nerr: %v", resp, err)
+	}
+
+	resp, err = request(logical.UpdateOperation, "encrypt/test", map[string]interface{}{
+		"plaintext": "dGhlIHF1aWNrIGJyb3duIGZveA==",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("resp: %#v\nerr: %v", resp, err)
+	}
+	ciphertext := resp.Data["ciphertext"]
+
+	// Invalid split parameters are rejected.
+	resp, err = request(logical.UpdateOperation, "backup/test", map[string]interface{}{
+		"secret_shares":    3,
+		"secret_threshold": 2,
+		"pgp_keys":         []string{pgpkeys.TestPubKey1},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error for mismatched pgp_keys, got resp: %#v", resp)
+	}
+
+	resp, err = request(logical.UpdateOperation, "backup/test", map[string]interface{}{
+		"secret_shares":    3,
+		"secret_threshold": 2,
+		"pgp_keys":         []string{pgpkeys.TestPubKey1, pgpkeys.TestPubKey2, pgpkeys.TestPubKey3},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("resp: %#v\nerr: %v", resp, err)
+	}
+	if _, ok := resp.Data["backup"]; ok {
+		t.Fatal("expected the unsplit backup not to be returned")
+	}
+
+	encryptedShares := resp.Data["backup_shares"].([]string)
+	if len(encryptedShares) != 3 || len(resp.Data["pgp_fingerprints"].([]string)) != 3 {
+		t.Fatalf("unexpected response: %#v", resp.Data)
+	}
+
+	privKeys := []string{pgpkeys.TestPrivKey1, pgpkeys.TestPrivKey2, pgpkeys.TestPrivKey3}
+	shares := make([]string, len(encryptedShares))
+	for i, encryptedShare := range encryptedShares {
+		share, err := pgpkeys.DecryptBytes(encryptedShare, privKeys[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		shares[i] = share.String()
+	}
+
+	// A single share is not enough to restore.
+	resp, err = request(logical.UpdateOperation, "restore/single", map[string]interface{}{
+		"backup_shares": shares[:1],
+	})
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error restoring from a single share, got resp: %#v", resp)
+	}
+
+	// Any threshold of shares restores a working key.
+	resp, err = request(logical.UpdateOperation, "restore/restored", map[string]interface{}{
+		"backup_shares": []string{shares[2], shares[0]},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("resp: %#v\nerr: %v", resp, err)
+	}
+
+	resp, err = request(logical.UpdateOperation, "decrypt/restored", map[string]interface{}{
+		"ciphertext": ciphertext,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("resp: %#v\nerr: %v", resp, err)
+	}
+	if resp.Data["plaintext"] != "dGhlIHF1aWNrIGJyb3duIGZveA==" {
+		t.Fatalf("unexpected plaintext: %v", resp.Data["plaintext"])
+	}
+}
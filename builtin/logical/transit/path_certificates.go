@@ -0,0 +1,290 @@
+package transit
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathCreateCsr() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/csr",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+			"version": {
+				Type: framework.TypeInt,
+				Description: `Version of the key to create the CSR for.
+Defaults to the latest version.`,
+			},
+			"common_name": {
+				Type:        framework.TypeString,
+				Description: "Common name of the CSR subject.",
+			},
+			"organization": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "O (Organization) values of the CSR subject.",
+			},
+			"ou": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "OU (OrganizationalUnit) values of the CSR subject.",
+			},
+			"country": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "C (Country) values of the CSR subject.",
+			},
+			"province": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "ST (Province) values of the CSR subject.",
+			},
+			"locality": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "L (Locality) values of the CSR subject.",
+			},
+			"street_address": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Street address values of the CSR subject.",
+			},
+			"postal_code": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Postal code values of the CSR subject.",
+			},
+			"serial_number": {
+				Type:        framework.TypeString,
+				Description: "Serial number attribute of the CSR subject.",
+			},
+			"alt_names": {
+				Type: framework.TypeCommaStringSlice,
+				Description: `DNS names and email addresses to request
+as Subject Alternative Names.`,
+			},
+			"ip_sans": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "IP addresses to request as Subject Alternative Names.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCreateCsrWrite,
+		},
+
+		HelpSynopsis:    pathCreateCsrHelpSyn,
+		HelpDescription: pathCreateCsrHelpDesc,
+	}
+}
+
+func (b *backend) pathSetCertificate() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/set-certificate",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+			"version": {
+				Type: framework.TypeInt,
+				Description: `Version of the key the certificate chain
+was issued for. Defaults to the latest version.`,
+			},
+			"certificate_chain": {
+				Type: framework.TypeString,
+				Description: `PEM encoded certificate chain, starting with
+the leaf certificate for the key and followed
+by its issuers.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathSetCertificateWrite,
+		},
+
+		HelpSynopsis:    pathSetCertificateHelpSyn,
+		HelpDescription: pathSetCertificateHelpDesc,
+	}
+}
+
+func (b *backend) pathCreateCsrWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	template := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:         d.Get("common_name").(string),
+			Organization:       d.Get("organization").([]string),
+			OrganizationalUnit: d.Get("ou").([]string),
+			Country:            d.Get("country").([]string),
+			Province:           d.Get("province").([]string),
+			Locality:           d.Get("locality").([]string),
+			StreetAddress:      d.Get("street_address").([]string),
+			PostalCode:         d.Get("postal_code").([]string),
+			SerialNumber:       d.Get("serial_number").(string),
+		},
+	}
+
+	for _, altName := range d.Get("alt_names").([]string) {
+		if strings.Contains(altName, "@") {
+			template.EmailAddresses = append(template.EmailAddresses, altName)
+		} else {
+			template.DNSNames = append(template.DNSNames, altName)
+		}
+	}
+
+	for _, ipSAN := range d.Get("ip_sans").([]string) {
+		ip := net.ParseIP(ipSAN)
+		if ip == nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid IP address %q in ip_sans", ipSAN)), logical.ErrInvalidRequest
+		}
+		template.IPAddresses = append(template.IPAddresses, ip)
+	}
+
+	csr, err := p.CreateCsr(d.Get("version").(int), template)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+
+	pemCsr := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: csr,
+	})
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"name": p.Name,
+			"type": p.Type.String(),
+			"csr":  strings.TrimSpace(string(pemCsr)),
+		},
+	}, nil
+}
+
+func (b *backend) pathSetCertificateWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	chainPEM := d.Get("certificate_chain").(string)
+	if chainPEM == "" {
+		return logical.ErrorResponse("missing certificate_chain"), logical.ErrInvalidRequest
+	}
+
+	chain, err := parseCertificateChain(chainPEM)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(true)
+	}
+	defer p.Unlock()
+
+	err = p.ValidateAndPersistCertificateChain(ctx, d.Get("version").(int), chain, req.Storage)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		default:
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func parseCertificateChain(chainPEM string) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+
+	rest := []byte(chainPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block of type %q in certificate_chain", block.Type)
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate in certificate_chain: %w", err)
+		}
+		chain = append(chain, cert)
+	}
+
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no PEM encoded certificates found in certificate_chain")
+	}
+	if len(strings.TrimSpace(string(rest))) != 0 {
+		return nil, fmt.Errorf("trailing data after the last certificate in certificate_chain")
+	}
+
+	return chain, nil
+}
+
+// encodeCertificateChain returns the stored DER chain of a key version as
+// concatenated PEM blocks, or an empty string if no chain is set.
+func encodeCertificateChain(derChain [][]byte) string {
+	var out strings.Builder
+	for _, der := range derChain {
+		out.Write(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: der,
+		}))
+	}
+	return strings.TrimSpace(out.String())
+}
+
+const pathCreateCsrHelpSyn = `Create a CSR for an asymmetric key`
+
+const pathCreateCsrHelpDesc = `
+This path is used to create a PKCS#10 certificate signing request for
+a version of the named asymmetric key, signed by that key. The CSR can
+be submitted to a CA (such as a PKI mount) and the resulting chain
+stored with the key via the set-certificate endpoint.
+`
+
+const pathSetCertificateHelpSyn = `Set the certificate chain of an asymmetric key`
+
+const pathSetCertificateHelpDesc = `
+This path is used to associate a certificate chain with a version of
+the named asymmetric key. The leaf certificate must certify the public
+key of that version. The chain is returned when reading the key and can
+be exported with the certificate-chain export type.
+`
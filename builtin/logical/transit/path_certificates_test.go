@@ -0,0 +1,164 @@
+package transit
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_CsrAndCertificateChain(t *testing.T) {
+	for _, keyType := range []string{"ecdsa-p256", "ed25519", "rsa-2048"} {
+		t.Run(keyType, func(t *testing.T) {
+			testCsrAndCertificateChain(t, keyType)
+		})
+	}
+}
+
+func testCsrAndCertificateChain(t *testing.T, keyType string) {
+	b, storage := createBackendWithSysView(t)
+
+	req := &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo",
+		Data: map[string]interface{}{
+			"type": keyType,
+		},
+	}
+	if _, err := b.HandleRequest(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	req.Path = "keys/foo/csr"
+	req.Data = map[string]interface{}{
+		"common_name":  "signer.example.com",
+		"organization": "Example",
+		"alt_names":    "signer.example.com,ops@example.com",
+		"ip_sans":      "127.0.0.1",
+	}
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+
+	block, _ := pem.Decode([]byte(resp.Data["csr"].(string)))
+	if block == nil {
+		t.Fatal("failed to decode CSR")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		t.Fatalf("bad CSR signature: %v", err)
+	}
+	if csr.Subject.CommonName != "signer.example.com" || len(csr.DNSNames) != 1 || len(csr.EmailAddresses) != 1 || len(csr.IPAddresses) != 1 {
+		t.Fatalf("unexpected CSR contents: %#v", csr)
+	}
+
+	// Issue a certificate for the CSR from a throwaway CA.
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, caCert, csr.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+
+	// A chain whose leaf does not match the key is rejected.
+	req.Path = "keys/foo/set-certificate"
+	req.Data = map[string]interface{}{
+		"certificate_chain": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})),
+	}
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error setting a mismatched chain: err: %v, resp: %v", err, resp)
+	}
+
+	req.Data = map[string]interface{}{
+		"certificate_chain": chain,
+	}
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+
+	req.Operation = logical.ReadOperation
+	req.Path = "keys/foo"
+	req.Data = nil
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil || resp == nil {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+	keys := resp.Data["keys"].(map[string]map[string]interface{})
+	if keys["1"]["certificate_chain"] != strings.TrimSpace(chain) {
+		t.Fatalf("unexpected certificate chain on key read: %v", keys["1"]["certificate_chain"])
+	}
+
+	// The chain is exportable even though the key itself is not.
+	req.Path = "export/certificate-chain/foo/1"
+	resp, err = b.HandleRequest(context.Background(), req)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+	if resp.Data["keys"].(map[string]string)["1"] != strings.TrimSpace(chain) {
+		t.Fatalf("unexpected exported certificate chain: %v", resp.Data["keys"])
+	}
+}
+
+func TestTransit_CsrRequiresAsymmetricKey(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	req := &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/foo",
+	}
+	if _, err := b.HandleRequest(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	req.Path = "keys/foo/csr"
+	resp, err := b.HandleRequest(context.Background(), req)
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error creating a CSR for a symmetric key: err: %v, resp: %v", err, resp)
+	}
+}
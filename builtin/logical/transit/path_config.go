@@ -7,6 +7,7 @@ import (
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -55,6 +56,30 @@ the latest version of the key is allowed.`,
 being automatically rotated. A value of 0
 disables automatic rotation for the key.`,
 			},
+
+			"context_template": {
+				Type: framework.TypeString,
+				Description: `Identity template which the key derivation
+context of every request must match once
+rendered for the requesting entity. Only valid
+for derived keys; set to an empty value to remove.`,
+			},
+
+			"context_required_prefix": {
+				Type: framework.TypeString,
+				Description: `Prefix, which may contain identity templates,
+that the key derivation context of every request
+must start with. Only valid for derived keys; set
+to an empty value to remove.`,
+			},
+
+			"allowed_batch_keys": {
+				Type: framework.TypeCommaStringSlice,
+				Description: `Names of other keys whose sign, verify and
+hmac endpoints may use this key for items of
+batch_input. Set to an empty value to disallow
+all other keys.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -92,6 +117,9 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 	originalDeletionAllowed := p.DeletionAllowed
 	originalExportable := p.Exportable
 	originalAllowPlaintextBackup := p.AllowPlaintextBackup
+	originalAllowedBatchKeys := p.AllowedBatchKeys
+	originalContextTemplate := p.ContextTemplate
+	originalContextRequiredPrefix := p.ContextRequiredPrefix
 
 	defer func() {
 		if retErr != nil || (resp != nil && resp.IsError()) {
@@ -100,6 +128,9 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 			p.DeletionAllowed = originalDeletionAllowed
 			p.Exportable = originalExportable
 			p.AllowPlaintextBackup = originalAllowPlaintextBackup
+			p.AllowedBatchKeys = originalAllowedBatchKeys
+			p.ContextTemplate = originalContextTemplate
+			p.ContextRequiredPrefix = originalContextRequiredPrefix
 		}
 	}()
 
@@ -210,6 +241,34 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 		}
 	}
 
+	allowedBatchKeysRaw, ok := d.GetOk("allowed_batch_keys")
+	if ok {
+		allowedBatchKeys := strutil.RemoveDuplicates(allowedBatchKeysRaw.([]string), false)
+		if strutil.StrListContains(allowedBatchKeys, name) {
+			return logical.ErrorResponse("allowed_batch_keys cannot contain the key itself"), nil
+		}
+		if !strutil.EquivalentSlices(allowedBatchKeys, p.AllowedBatchKeys) {
+			p.AllowedBatchKeys = allowedBatchKeys
+			persistNeeded = true
+		}
+	}
+
+	contextTemplateRaw, ok := d.GetOk("context_template")
+	if ok && contextTemplateRaw.(string) != p.ContextTemplate {
+		p.ContextTemplate = contextTemplateRaw.(string)
+		persistNeeded = true
+	}
+
+	contextRequiredPrefixRaw, ok := d.GetOk("context_required_prefix")
+	if ok && contextRequiredPrefixRaw.(string) != p.ContextRequiredPrefix {
+		p.ContextRequiredPrefix = contextRequiredPrefixRaw.(string)
+		persistNeeded = true
+	}
+
+	if errResp := validateContextPolicy(p.Derived, p.ContextTemplate, p.ContextRequiredPrefix); errResp != nil {
+		return errResp, nil
+	}
+
 	if !persistNeeded {
 		return nil, nil
 	}
@@ -142,12 +142,25 @@ func (b *backend) pathDecryptWrite(ctx context.Context, req *logical.Request, d
 		p.Lock(false)
 	}
 
+	contextPolicy := b.newContextPolicy(req)
 	for i, item := range batchInputItems {
 		if batchResponseItems[i].Error != "" {
 			continue
 		}
 
-		plaintext, err := p.Decrypt(item.DecodedContext, item.DecodedNonce, item.Ciphertext)
+		context, err := contextPolicy.apply(p, item.DecodedContext)
+		if err != nil {
+			switch err.(type) {
+			case errutil.InternalError:
+				internalErrorInBatch = true
+			default:
+				userErrorInBatch = true
+			}
+			batchResponseItems[i].Error = err.Error()
+			continue
+		}
+
+		plaintext, err := p.Decrypt(context, item.DecodedNonce, item.Ciphertext)
 		if err != nil {
 			switch err.(type) {
 			case errutil.InternalError:
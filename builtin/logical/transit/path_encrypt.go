@@ -375,6 +375,7 @@ func (b *backend) pathEncryptWrite(ctx context.Context, req *logical.Request, d
 	// item fails, respectively mark the error in the response
 	// collection and continue to process other items.
 	warnAboutNonceUsage := false
+	contextPolicy := b.newContextPolicy(req)
 	for i, item := range batchInputItems {
 		if batchResponseItems[i].Error != "" {
 			continue
@@ -384,7 +385,19 @@ func (b *backend) pathEncryptWrite(ctx context.Context, req *logical.Request, d
 			warnAboutNonceUsage = true
 		}
 
-		ciphertext, err := p.Encrypt(item.KeyVersion, item.DecodedContext, item.DecodedNonce, item.Plaintext)
+		context, err := contextPolicy.apply(p, item.DecodedContext)
+		if err != nil {
+			switch err.(type) {
+			case errutil.InternalError:
+				internalErrorInBatch = true
+			default:
+				userErrorInBatch = true
+			}
+			batchResponseItems[i].Error = err.Error()
+			continue
+		}
+
+		ciphertext, err := p.Encrypt(item.KeyVersion, context, item.DecodedNonce, item.Plaintext)
 		if err != nil {
 			switch err.(type) {
 			case errutil.InternalError:
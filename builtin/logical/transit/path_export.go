@@ -22,6 +22,7 @@ const (
 	exportTypeEncryptionKey = "encryption-key"
 	exportTypeSigningKey    = "signing-key"
 	exportTypeHMACKey       = "hmac-key"
+	exportTypeCertChain     = "certificate-chain"
 )
 
 func (b *backend) pathExportKeys() *framework.Path {
@@ -30,7 +31,7 @@ func (b *backend) pathExportKeys() *framework.Path {
 		Fields: map[string]*framework.FieldSchema{
 			"type": {
 				Type:        framework.TypeString,
-				Description: "Type of key to export (encryption-key, signing-key, hmac-key)",
+				Description: "Type of key to export (encryption-key, signing-key, hmac-key, certificate-chain)",
 			},
 			"name": {
 				Type:        framework.TypeString,
@@ -60,6 +61,7 @@ func (b *backend) pathPolicyExportRead(ctx context.Context, req *logical.Request
 	case exportTypeEncryptionKey:
 	case exportTypeSigningKey:
 	case exportTypeHMACKey:
+	case exportTypeCertChain:
 	default:
 		return logical.ErrorResponse(fmt.Sprintf("invalid export type: %s", exportType)), logical.ErrInvalidRequest
 	}
@@ -79,7 +81,9 @@ func (b *backend) pathPolicyExportRead(ctx context.Context, req *logical.Request
 	}
 	defer p.Unlock()
 
-	if !p.Exportable {
+	// Certificate chains only contain public material, so they can be
+	// exported even when the key itself is not exportable.
+	if !p.Exportable && exportType != exportTypeCertChain {
 		return logical.ErrorResponse("key is not exportable"), nil
 	}
 
@@ -88,7 +92,7 @@ func (b *backend) pathPolicyExportRead(ctx context.Context, req *logical.Request
 		if !p.Type.EncryptionSupported() {
 			return logical.ErrorResponse("encryption not supported for the key"), logical.ErrInvalidRequest
 		}
-	case exportTypeSigningKey:
+	case exportTypeSigningKey, exportTypeCertChain:
 		if !p.Type.SigningSupported() {
 			return logical.ErrorResponse("signing not supported for the key"), logical.ErrInvalidRequest
 		}
@@ -98,6 +102,10 @@ func (b *backend) pathPolicyExportRead(ctx context.Context, req *logical.Request
 	switch version {
 	case "":
 		for k, v := range p.Keys {
+			if exportType == exportTypeCertChain && len(v.CertificateChain) == 0 {
+				continue
+			}
+
 			exportKey, err := getExportKey(p, &v, exportType)
 			if err != nil {
 				return nil, err
@@ -124,6 +132,9 @@ func (b *backend) pathPolicyExportRead(ctx context.Context, req *logical.Request
 		if !ok {
 			return logical.ErrorResponse("version does not exist or cannot be found"), logical.ErrInvalidRequest
 		}
+		if exportType == exportTypeCertChain && len(key.CertificateChain) == 0 {
+			return logical.ErrorResponse("no certificate chain has been set for the version"), logical.ErrInvalidRequest
+		}
 
 		exportKey, err := getExportKey(p, &key, exportType)
 		if err != nil {
@@ -153,6 +164,9 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 	case exportTypeHMACKey:
 		return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.HMACKey)), nil
 
+	case exportTypeCertChain:
+		return encodeCertificateChain(key.CertificateChain), nil
+
 	case exportTypeEncryptionKey:
 		switch policy.Type {
 		case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305:
@@ -235,5 +249,6 @@ const pathExportHelpSyn = `Export named encryption or signing key`
 
 const pathExportHelpDesc = `
 This path is used to export the named keys that are configured as
-exportable.
+exportable. Certificate chains set on asymmetric keys can be exported
+regardless of whether the key is exportable.
 `
@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mitchellh/mapstructure"
@@ -26,6 +27,9 @@ type batchResponseHMACItem struct {
 	// Valid indicates whether signature matches the signature derived from the input string
 	Valid bool `json:"valid,omitempty" mapstructure:"valid"`
 
+	// The key version the HMAC was generated or verified with
+	KeyVersion int `json:"key_version,omitempty" mapstructure:"key_version"`
+
 	// Error, if set represents a failure encountered while encrypting a
 	// corresponding batch request item
 	Error string `json:"error,omitempty" mapstructure:"error"`
@@ -79,6 +83,14 @@ Defaults to "sha2-256".`,
 Must be 0 (for latest) or a value greater than or equal
 to the min_encryption_version configured on the key.`,
 			},
+
+			"batch_status_codes": {
+				Type: framework.TypeBool,
+				Description: `If true, batch requests with failed items return a 400 when
+an item is invalid, or else a 500, in the same way as the encrypt
+endpoint. By default they return a 200, with the errors in the
+results of the failed items.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -99,46 +111,8 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 		algorithm = d.Get("algorithm").(string)
 	}
 
-	// Get the policy
-	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
-		Storage: req.Storage,
-		Name:    name,
-	}, b.GetRandomReader())
-	if err != nil {
-		return nil, err
-	}
-	if p == nil {
-		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
-	}
-	if !b.System().CachingDisabled() {
-		p.Lock(false)
-	}
-
-	switch {
-	case ver == 0:
-		// Allowed, will use latest; set explicitly here to ensure the string
-		// is generated properly
-		ver = p.LatestVersion
-	case ver == p.LatestVersion:
-		// Allowed
-	case p.MinEncryptionVersion > 0 && ver < p.MinEncryptionVersion:
-		p.Unlock()
-		return logical.ErrorResponse("cannot generate HMAC: version is too old (disallowed by policy)"), logical.ErrInvalidRequest
-	}
-
-	key, err := p.HMACKey(ver)
-	if err != nil {
-		p.Unlock()
-		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
-	}
-	if key == nil {
-		p.Unlock()
-		return nil, fmt.Errorf("HMAC key value could not be computed")
-	}
-
 	hashAlgorithm, ok := keysutil.HashTypeMap[algorithm]
 	if !ok {
-		p.Unlock()
 		return logical.ErrorResponse("unsupported algorithm %q", hashAlgorithm), nil
 	}
 
@@ -147,20 +121,38 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 	batchInputRaw := d.Raw["batch_input"]
 	var batchInputItems []batchRequestHMACItem
 	if batchInputRaw != nil {
-		err = mapstructure.Decode(batchInputRaw, &batchInputItems)
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
 		if err != nil {
-			p.Unlock()
 			return nil, fmt.Errorf("failed to parse batch input: %w", err)
 		}
 
 		if len(batchInputItems) == 0 {
-			p.Unlock()
 			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
 		}
-	} else {
+	}
+
+	// Get the policies
+	keys, err := b.lockBatchKeys(ctx, req.Storage, name, batchItemKeys(batchInputItems))
+	if err != nil {
+		return nil, err
+	}
+	defer keys.unlock()
+
+	p, err := keys.get("")
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	if batchInputRaw == nil {
+		if _, _, err := hmacKeyForGeneration(p, ver); err != nil {
+			if isUserError(err) {
+				return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+			}
+			return nil, err
+		}
+
 		valueRaw, ok := d.GetOk("input")
 		if !ok {
-			p.Unlock()
 			return logical.ErrorResponse("missing input for HMAC"), logical.ErrInvalidRequest
 		}
 
@@ -173,6 +165,34 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 	response := make([]batchResponseHMACItem, len(batchInputItems))
 
 	for i, item := range batchInputItems {
+		p, err := keys.get(item["name"])
+		if err != nil {
+			response[i].Error = err.Error()
+			response[i].err = logical.ErrInvalidRequest
+			continue
+		}
+
+		itemVer := ver
+		if rawVer, ok := item["key_version"]; ok && rawVer != "" {
+			itemVer, err = strconv.Atoi(rawVer)
+			if err != nil {
+				response[i].Error = "invalid key_version"
+				response[i].err = logical.ErrInvalidRequest
+				continue
+			}
+		}
+
+		key, itemVer, err := hmacKeyForGeneration(p, itemVer)
+		if err != nil {
+			if isUserError(err) {
+				response[i].Error = err.Error()
+				response[i].err = logical.ErrInvalidRequest
+			} else {
+				response[i].err = err
+			}
+			continue
+		}
+
 		rawInput, ok := item["input"]
 		if !ok {
 			response[i].Error = "missing input for HMAC"
@@ -192,19 +212,14 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 		retBytes := hf.Sum(nil)
 
 		retStr := base64.StdEncoding.EncodeToString(retBytes)
-		retStr = fmt.Sprintf("vault:v%s:%s", strconv.Itoa(ver), retStr)
+		retStr = fmt.Sprintf("vault:v%s:%s", strconv.Itoa(itemVer), retStr)
 		response[i].HMAC = retStr
+		response[i].KeyVersion = itemVer
 	}
 
-	p.Unlock()
-
 	// Generate the response
 	resp := &logical.Response{}
-	if batchInputRaw != nil {
-		resp.Data = map[string]interface{}{
-			"batch_results": response,
-		}
-	} else {
+	if batchInputRaw == nil {
 		if response[0].Error != "" || response[0].err != nil {
 			if response[0].Error != "" {
 				return logical.ErrorResponse(response[0].Error), response[0].err
@@ -215,36 +230,56 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 		resp.Data = map[string]interface{}{
 			"hmac": response[0].HMAC,
 		}
+		return resp, nil
+	}
+
+	resp.Data = map[string]interface{}{
+		"batch_results": response,
 	}
 
-	return resp, nil
+	itemErrs := make([]error, len(response))
+	for i := range response {
+		itemErrs[i] = response[i].err
+	}
+
+	return respondWithBatchStatus(resp, req, d, itemErrs)
 }
 
-func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	name := d.Get("name").(string)
-	algorithm := d.Get("urlalgorithm").(string)
-	if algorithm == "" {
-		algorithm = d.Get("algorithm").(string)
+// hmacKeyForGeneration returns the HMAC key of the given version of the
+// policy, along with the resolved version, enforcing the policy's minimum
+// encryption version.
+func hmacKeyForGeneration(p *keysutil.Policy, ver int) ([]byte, int, error) {
+	switch {
+	case ver == 0:
+		// Allowed, will use latest; set explicitly here to ensure the string
+		// is generated properly
+		ver = p.LatestVersion
+	case ver == p.LatestVersion:
+		// Allowed
+	case p.MinEncryptionVersion > 0 && ver < p.MinEncryptionVersion:
+		return nil, 0, errutil.UserError{Err: "cannot generate HMAC: version is too old (disallowed by policy)"}
 	}
 
-	// Get the policy
-	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
-		Storage: req.Storage,
-		Name:    name,
-	}, b.GetRandomReader())
+	key, err := p.HMACKey(ver)
 	if err != nil {
-		return nil, err
+		return nil, 0, errutil.UserError{Err: err.Error()}
 	}
-	if p == nil {
-		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
+	if key == nil {
+		return nil, 0, fmt.Errorf("HMAC key value could not be computed")
 	}
-	if !b.System().CachingDisabled() {
-		p.Lock(false)
+
+	return key, ver, nil
+}
+
+func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	algorithm := d.Get("urlalgorithm").(string)
+	if algorithm == "" {
+		algorithm = d.Get("algorithm").(string)
 	}
 
 	hashAlgorithm, ok := keysutil.HashTypeMap[algorithm]
 	if !ok {
-		p.Unlock()
 		return logical.ErrorResponse("unsupported algorithm %q", hashAlgorithm), nil
 	}
 
@@ -255,12 +290,10 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 	if batchInputRaw != nil {
 		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
 		if err != nil {
-			p.Unlock()
 			return nil, fmt.Errorf("failed to parse batch input: %w", err)
 		}
 
 		if len(batchInputItems) == 0 {
-			p.Unlock()
 			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
 		}
 	} else {
@@ -275,9 +308,27 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 		}
 	}
 
+	// Get the policies
+	keys, err := b.lockBatchKeys(ctx, req.Storage, name, batchItemKeys(batchInputItems))
+	if err != nil {
+		return nil, err
+	}
+	defer keys.unlock()
+
+	if _, err := keys.get(""); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
 	response := make([]batchResponseHMACItem, len(batchInputItems))
 
 	for i, item := range batchInputItems {
+		p, err := keys.get(item["name"])
+		if err != nil {
+			response[i].Error = err.Error()
+			response[i].err = logical.ErrInvalidRequest
+			continue
+		}
+
 		rawInput, ok := item["input"]
 		if !ok {
 			response[i].Error = "missing input"
@@ -355,17 +406,12 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 		hf.Write(input)
 		retBytes := hf.Sum(nil)
 		response[i].Valid = hmac.Equal(retBytes, verBytes)
+		response[i].KeyVersion = ver
 	}
 
-	p.Unlock()
-
 	// Generate the response
 	resp := &logical.Response{}
-	if batchInputRaw != nil {
-		resp.Data = map[string]interface{}{
-			"batch_results": response,
-		}
-	} else {
+	if batchInputRaw == nil {
 		if response[0].Error != "" || response[0].err != nil {
 			if response[0].Error != "" {
 				return logical.ErrorResponse(response[0].Error), response[0].err
@@ -376,13 +422,27 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 		resp.Data = map[string]interface{}{
 			"valid": response[0].Valid,
 		}
+		return resp, nil
+	}
+
+	resp.Data = map[string]interface{}{
+		"batch_results": response,
 	}
 
-	return resp, nil
+	itemErrs := make([]error, len(response))
+	for i := range response {
+		itemErrs[i] = response[i].err
+	}
+
+	return respondWithBatchStatus(resp, req, d, itemErrs)
 }
 
 const pathHMACHelpSyn = `Generate an HMAC for input data using the named key`
 
 const pathHMACHelpDesc = `
 Generates an HMAC sum of the given algorithm and key against the given input data.
+
+Items of batch_input may set "name" and "key_version" to use another key or key
+version; other keys must list the named key in their allowed_batch_keys
+configuration.
 `
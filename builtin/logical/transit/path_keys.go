@@ -103,6 +103,25 @@ being automatically rotated. A value of 0
 (default) disables automatic rotation for the
 key.`,
 			},
+
+			"context_template": {
+				Type: framework.TypeString,
+				Description: `Identity template, such as
+"{{identity.entity.id}}", which the key
+derivation context of every request must
+match once rendered for the requesting
+entity. If a request omits the context, the
+rendered template is used. Only valid for
+derived keys.`,
+			},
+
+			"context_required_prefix": {
+				Type: framework.TypeString,
+				Description: `Prefix, which may contain identity
+templates, that the key derivation context of
+every request must start with. Only valid for
+derived keys.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -142,15 +161,23 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 		return logical.ErrorResponse("convergent encryption requires derivation to be enabled"), nil
 	}
 
+	contextTemplate := d.Get("context_template").(string)
+	contextRequiredPrefix := d.Get("context_required_prefix").(string)
+	if resp := validateContextPolicy(derived, contextTemplate, contextRequiredPrefix); resp != nil {
+		return resp, nil
+	}
+
 	polReq := keysutil.PolicyRequest{
-		Upsert:               true,
-		Storage:              req.Storage,
-		Name:                 name,
-		Derived:              derived,
-		Convergent:           convergent,
-		Exportable:           exportable,
-		AllowPlaintextBackup: allowPlaintextBackup,
-		AutoRotatePeriod:     autoRotatePeriod,
+		Upsert:                true,
+		Storage:               req.Storage,
+		Name:                  name,
+		Derived:               derived,
+		Convergent:            convergent,
+		Exportable:            exportable,
+		AllowPlaintextBackup:  allowPlaintextBackup,
+		AutoRotatePeriod:      autoRotatePeriod,
+		ContextTemplate:       contextTemplate,
+		ContextRequiredPrefix: contextRequiredPrefix,
 	}
 	switch keyType {
 	case "aes128-gcm96":
@@ -201,6 +228,8 @@ type asymKey struct {
 	Name         string    `json:"name" structs:"name" mapstructure:"name"`
 	PublicKey    string    `json:"public_key" structs:"public_key" mapstructure:"public_key"`
 	CreationTime time.Time `json:"creation_time" structs:"creation_time" mapstructure:"creation_time"`
+	// PEM encoded certificate chain, if one has been set for the version
+	CertificateChain string `json:"certificate_chain" structs:"certificate_chain" mapstructure:"certificate_chain"`
 }
 
 func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
@@ -247,6 +276,17 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 		resp.Data["imported_key_allow_rotation"] = p.AllowImportedKeyRotation
 	}
 
+	if p.ContextTemplate != "" {
+		resp.Data["context_template"] = p.ContextTemplate
+	}
+	if p.ContextRequiredPrefix != "" {
+		resp.Data["context_required_prefix"] = p.ContextRequiredPrefix
+	}
+
+	if len(p.AllowedBatchKeys) > 0 {
+		resp.Data["allowed_batch_keys"] = p.AllowedBatchKeys
+	}
+
 	if p.BackupInfo != nil {
 		resp.Data["backup_info"] = map[string]interface{}{
 			"time":    p.BackupInfo.Time,
@@ -295,8 +335,9 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 		retKeys := map[string]map[string]interface{}{}
 		for k, v := range p.Keys {
 			key := asymKey{
-				PublicKey:    v.FormattedPublicKey,
-				CreationTime: v.CreationTime,
+				PublicKey:        v.FormattedPublicKey,
+				CreationTime:     v.CreationTime,
+				CertificateChain: encodeCertificateChain(v.CertificateChain),
 			}
 			if key.CreationTime.IsZero() {
 				key.CreationTime = time.Unix(v.DeprecatedCreationTime, 0)
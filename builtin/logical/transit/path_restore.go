@@ -2,11 +2,15 @@ package transit
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/shamir"
 )
 
 func (b *backend) pathRestore() *framework.Path {
@@ -17,6 +21,10 @@ func (b *backend) pathRestore() *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Backed up key data to be restored. This should be the output from the 'backup/' endpoint.",
 			},
+			"backup_shares": {
+				Type:        framework.TypeStringSlice,
+				Description: "Hex encoded shares of a split backup, as decrypted from the 'backup_shares' output of the 'backup/' endpoint. At least the threshold number of shares must be given. Cannot be used with 'backup'.",
+			},
 			"name": {
 				Type:        framework.TypeString,
 				Description: "If set, this will be the name of the restored key.",
@@ -39,8 +47,18 @@ func (b *backend) pathRestore() *framework.Path {
 
 func (b *backend) pathRestoreUpdate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	backupB64 := d.Get("backup").(string)
+	backupShares := d.Get("backup_shares").([]string)
 	force := d.Get("force").(bool)
-	if backupB64 == "" {
+	switch {
+	case backupB64 != "" && len(backupShares) != 0:
+		return logical.ErrorResponse("only one of 'backup' or 'backup_shares' may be supplied"), nil
+	case len(backupShares) != 0:
+		combined, err := combineBackupShares(backupShares)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		backupB64 = combined
+	case backupB64 == "":
 		return logical.ErrorResponse("'backup' must be supplied"), nil
 	}
 
@@ -54,6 +72,36 @@ func (b *backend) pathRestoreUpdate(ctx context.Context, req *logical.Request, d
 	return nil, b.lm.RestorePolicy(ctx, req.Storage, keyName, backupB64, force)
 }
 
+// combineBackupShares reassembles a backup split by the backup endpoint from
+// its hex encoded shares.
+func combineBackupShares(hexShares []string) (string, error) {
+	if len(hexShares) < 2 {
+		return "", errors.New("at least two 'backup_shares' must be supplied")
+	}
+
+	shares := make([][]byte, len(hexShares))
+	for i, hexShare := range hexShares {
+		share, err := hex.DecodeString(strings.TrimSpace(hexShare))
+		if err != nil {
+			return "", fmt.Errorf("share %d is not a valid hex string", i)
+		}
+		shares[i] = share
+	}
+
+	backup, err := shamir.Combine(shares)
+	if err != nil {
+		return "", fmt.Errorf("failed to combine backup shares: %w", err)
+	}
+
+	// With fewer shares than the threshold, Combine succeeds but yields
+	// garbage; catch that here rather than in the restore itself.
+	if _, err := base64.StdEncoding.DecodeString(string(backup)); err != nil {
+		return "", errors.New("backup shares did not combine to a valid backup; check that enough shares of the same backup were supplied")
+	}
+
+	return string(backup), nil
+}
+
 const (
 	pathRestoreHelpSyn  = `Restore the named key`
 	pathRestoreHelpDesc = `This path is used to restore the named key.`
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"strconv"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/errutil"
@@ -47,6 +48,9 @@ type batchResponseVerifyItem struct {
 	// Valid indicates whether signature matches the signature derived from the input string
 	Valid bool `json:"valid" mapstructure:"valid"`
 
+	// The key version the signature was made with
+	KeyVersion int `json:"key_version,omitempty" mapstructure:"key_version"`
+
 	// Error, if set represents a failure encountered while encrypting a
 	// corresponding batch request item
 	Error string `json:"error,omitempty" mapstructure:"error"`
@@ -78,6 +82,23 @@ func (b *backend) pathSign() *framework.Path {
 derivation is enabled; currently only available with ed25519 keys.`,
 			},
 
+			"signature_context": {
+				Type: framework.TypeString,
+				Description: `Base64 encoded context for Ed25519ctx signatures. Only valid
+for ed25519 keys; when set, signatures are bound to the context
+as described in RFC 8032 and must be verified with the same
+context. Batch items may override it with their own
+signature_context.`,
+			},
+
+			"batch_status_codes": {
+				Type: framework.TypeBool,
+				Description: `If true, batch requests with failed items return a 400 when
+an item is invalid, or else a 500, in the same way as the encrypt
+endpoint. By default they return a 200, with the errors in the
+results of the failed items.`,
+			},
+
 			"hash_algorithm": {
 				Type:    framework.TypeString,
 				Default: "sha2-256",
@@ -157,6 +178,23 @@ func (b *backend) pathVerify() *framework.Path {
 derivation is enabled; currently only available with ed25519 keys.`,
 			},
 
+			"signature_context": {
+				Type: framework.TypeString,
+				Description: `Base64 encoded context for Ed25519ctx signatures. Only valid
+for ed25519 keys; when set, signatures are bound to the context
+as described in RFC 8032 and must be verified with the same
+context. Batch items may override it with their own
+signature_context.`,
+			},
+
+			"batch_status_codes": {
+				Type: framework.TypeBool,
+				Description: `If true, batch requests with failed items return a 400 when
+an item is invalid, or else a 500, in the same way as the encrypt
+endpoint. By default they return a 200, with the errors in the
+results of the failed items.`,
+			},
+
 			"signature": {
 				Type:        framework.TypeString,
 				Description: "The signature, including vault header/key version",
@@ -253,37 +291,15 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 	prehashed := d.Get("prehashed").(bool)
 	sigAlgorithm := d.Get("signature_algorithm").(string)
 
-	// Get the policy
-	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
-		Storage: req.Storage,
-		Name:    name,
-	}, b.GetRandomReader())
-	if err != nil {
-		return nil, err
-	}
-	if p == nil {
-		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
-	}
-	if !b.System().CachingDisabled() {
-		p.Lock(false)
-	}
-
-	if !p.Type.SigningSupported() {
-		p.Unlock()
-		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support signing", p.Type)), logical.ErrInvalidRequest
-	}
-
 	batchInputRaw := d.Raw["batch_input"]
 	var batchInputItems []batchRequestSignItem
 	if batchInputRaw != nil {
-		err = mapstructure.Decode(batchInputRaw, &batchInputItems)
+		err := mapstructure.Decode(batchInputRaw, &batchInputItems)
 		if err != nil {
-			p.Unlock()
 			return nil, fmt.Errorf("failed to parse batch input: %w", err)
 		}
 
 		if len(batchInputItems) == 0 {
-			p.Unlock()
 			return logical.ErrorResponse("missing batch input to process"), logical.ErrInvalidRequest
 		}
 	} else {
@@ -295,9 +311,48 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 		}
 	}
 
+	// Get the policies
+	keys, err := b.lockBatchKeys(ctx, req.Storage, name, batchItemKeys(batchInputItems))
+	if err != nil {
+		return nil, err
+	}
+	defer keys.unlock()
+
+	p, err := keys.get("")
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	if !p.Type.SigningSupported() {
+		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support signing", p.Type)), logical.ErrInvalidRequest
+	}
+
 	response := make([]batchResponseSignItem, len(batchInputItems))
+	contextPolicy := b.newContextPolicy(req)
 
 	for i, item := range batchInputItems {
+		p, err := keys.get(item["name"])
+		if err != nil {
+			response[i].Error = err.Error()
+			response[i].err = logical.ErrInvalidRequest
+			continue
+		}
+
+		if !p.Type.SigningSupported() {
+			response[i].Error = fmt.Sprintf("key type %v does not support signing", p.Type)
+			response[i].err = logical.ErrInvalidRequest
+			continue
+		}
+
+		itemVer := ver
+		if rawVer, ok := item["key_version"]; ok && rawVer != "" {
+			itemVer, err = strconv.Atoi(rawVer)
+			if err != nil {
+				response[i].Error = "invalid key_version"
+				response[i].err = logical.ErrInvalidRequest
+				continue
+			}
+		}
 
 		rawInput, ok := item["input"]
 		if !ok {
@@ -330,7 +385,29 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 			}
 		}
 
-		sig, err := p.Sign(ver, context, input, hashAlgorithm, sigAlgorithm, marshaling)
+		context, err = contextPolicy.apply(p, context)
+		if err != nil {
+			response[i].Error = err.Error()
+			response[i].err = err
+			if isUserError(err) {
+				response[i].err = logical.ErrInvalidRequest
+			}
+			continue
+		}
+
+		signatureContext, err := signatureContextForItem(d, item)
+		if err != nil {
+			response[i].Error = err.Error()
+			response[i].err = logical.ErrInvalidRequest
+			continue
+		}
+
+		sig, err := p.SignWithOptions(itemVer, context, input, &keysutil.SigningOptions{
+			HashAlgorithm:    hashAlgorithm,
+			Marshaling:       marshaling,
+			SigAlgorithm:     sigAlgorithm,
+			SignatureContext: signatureContext,
+		})
 		if err != nil {
 			if batchInputRaw != nil {
 				response[i].Error = err.Error()
@@ -339,7 +416,7 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 		} else if sig == nil {
 			response[i].err = fmt.Errorf("signature could not be computed")
 		} else {
-			keyVersion := ver
+			keyVersion := itemVer
 			if keyVersion == 0 {
 				keyVersion = p.LatestVersion
 			}
@@ -352,13 +429,8 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 
 	// Generate the response
 	resp := &logical.Response{}
-	if batchInputRaw != nil {
-		resp.Data = map[string]interface{}{
-			"batch_results": response,
-		}
-	} else {
+	if batchInputRaw == nil {
 		if response[0].Error != "" || response[0].err != nil {
-			p.Unlock()
 			if response[0].Error != "" {
 				return logical.ErrorResponse(response[0].Error), response[0].err
 			}
@@ -374,10 +446,39 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 		if len(response[0].PublicKey) > 0 {
 			resp.Data["public_key"] = response[0].PublicKey
 		}
+
+		return resp, nil
 	}
 
-	p.Unlock()
-	return resp, nil
+	resp.Data = map[string]interface{}{
+		"batch_results": response,
+	}
+
+	itemErrs := make([]error, len(response))
+	for i := range response {
+		itemErrs[i] = response[i].err
+	}
+
+	return respondWithBatchStatus(resp, req, d, itemErrs)
+}
+
+// signatureContextForItem returns the decoded Ed25519ctx signature context
+// for a batch item, falling back to the request's signature_context.
+func signatureContextForItem(d *framework.FieldData, item map[string]string) ([]byte, error) {
+	raw, ok := item["signature_context"]
+	if !ok {
+		raw = d.Get("signature_context").(string)
+	}
+	if raw == "" {
+		return nil, nil
+	}
+
+	signatureContext, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to base64-decode signature_context")
+	}
+
+	return signatureContext, nil
 }
 
 func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
@@ -471,29 +572,38 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 	prehashed := d.Get("prehashed").(bool)
 	sigAlgorithm := d.Get("signature_algorithm").(string)
 
-	// Get the policy
-	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
-		Storage: req.Storage,
-		Name:    name,
-	}, b.GetRandomReader())
+	// Get the policies
+	keys, err := b.lockBatchKeys(ctx, req.Storage, name, batchItemKeys(batchInputItems))
 	if err != nil {
 		return nil, err
 	}
-	if p == nil {
-		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
-	}
-	if !b.System().CachingDisabled() {
-		p.Lock(false)
+	defer keys.unlock()
+
+	p, err := keys.get("")
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
 	if !p.Type.SigningSupported() {
-		p.Unlock()
 		return logical.ErrorResponse(fmt.Sprintf("key type %v does not support verification", p.Type)), logical.ErrInvalidRequest
 	}
 
 	response := make([]batchResponseVerifyItem, len(batchInputItems))
+	contextPolicy := b.newContextPolicy(req)
 
 	for i, item := range batchInputItems {
+		p, err := keys.get(item["name"])
+		if err != nil {
+			response[i].Error = err.Error()
+			response[i].err = logical.ErrInvalidRequest
+			continue
+		}
+
+		if !p.Type.SigningSupported() {
+			response[i].Error = fmt.Sprintf("key type %v does not support verification", p.Type)
+			response[i].err = logical.ErrInvalidRequest
+			continue
+		}
 
 		rawInput, ok := item["input"]
 		if !ok {
@@ -533,7 +643,29 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 			}
 		}
 
-		valid, err := p.VerifySignature(context, input, hashAlgorithm, sigAlgorithm, marshaling, sig)
+		context, err = contextPolicy.apply(p, context)
+		if err != nil {
+			response[i].Error = err.Error()
+			response[i].err = err
+			if isUserError(err) {
+				response[i].err = logical.ErrInvalidRequest
+			}
+			continue
+		}
+
+		signatureContext, err := signatureContextForItem(d, item)
+		if err != nil {
+			response[i].Error = err.Error()
+			response[i].err = logical.ErrInvalidRequest
+			continue
+		}
+
+		valid, err := p.VerifySignatureWithOptions(context, input, sig, &keysutil.SigningOptions{
+			HashAlgorithm:    hashAlgorithm,
+			Marshaling:       marshaling,
+			SigAlgorithm:     sigAlgorithm,
+			SignatureContext: signatureContext,
+		})
 		if err != nil {
 			switch err.(type) {
 			case errutil.UserError:
@@ -545,20 +677,17 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 				}
 				response[i].err = err
 			}
-		} else {
-			response[i].Valid = valid
+			continue
 		}
+
+		response[i].Valid = valid
+		response[i].KeyVersion, _ = p.SignatureKeyVersion(sig)
 	}
 
 	// Generate the response
 	resp := &logical.Response{}
-	if batchInputRaw != nil {
-		resp.Data = map[string]interface{}{
-			"batch_results": response,
-		}
-	} else {
+	if batchInputRaw == nil {
 		if response[0].Error != "" || response[0].err != nil {
-			p.Unlock()
 			if response[0].Error != "" {
 				return logical.ErrorResponse(response[0].Error), response[0].err
 			}
@@ -567,19 +696,41 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 		resp.Data = map[string]interface{}{
 			"valid": response[0].Valid,
 		}
+		if response[0].KeyVersion != 0 {
+			resp.Data["key_version"] = response[0].KeyVersion
+		}
+		return resp, nil
+	}
+
+	resp.Data = map[string]interface{}{
+		"batch_results": response,
 	}
 
-	p.Unlock()
-	return resp, nil
+	itemErrs := make([]error, len(response))
+	for i := range response {
+		itemErrs[i] = response[i].err
+	}
+
+	return respondWithBatchStatus(resp, req, d, itemErrs)
 }
 
 const pathSignHelpSyn = `Generate a signature for input data using the named key`
 
 const pathSignHelpDesc = `
 Generates a signature of the input data using the named key and the given hash algorithm.
+
+Items of batch_input may set "name" and "key_version" to sign with another key
+or key version. A key other than the named one may only be used if it lists
+the named key in its allowed_batch_keys configuration. Errors are reported per
+item; if any item fails, the batch is returned with a 400 (or 500 for internal
+errors) status code.
 `
 const pathVerifyHelpSyn = `Verify a signature or HMAC for input data created using the named key`
 
 const pathVerifyHelpDesc = `
 Verifies a signature or HMAC of the input data using the named key and the given hash algorithm.
+
+As with signing, items of batch_input may set "name" to verify with another key
+which allows batch use through the named key. The key version each signature
+or HMAC was made with is returned as key_version.
 `
@@ -4,12 +4,14 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"testing"
 
 	"golang.org/x/crypto/ed25519"
 
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mitchellh/mapstructure"
@@ -700,3 +702,233 @@ func TestTransit_SignVerify_ED25519(t *testing.T) {
 	outcome[1].valid = false
 	verifyRequest(req, false, outcome, "bar", goodsig, true)
 }
+
+func TestTransit_SignVerify_MultipleKeys(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+		})
+	}
+
+	for _, name := range []string{"primary", "secondary", "other"} {
+		if resp, err := request("keys/"+name, map[string]interface{}{"type": "ecdsa-p256"}); err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: err: %v, resp: %v", err, resp)
+		}
+	}
+	if resp, err := request("keys/secondary/rotate", nil); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+
+	// Only secondary allows use through primary.
+	resp, err := request("keys/secondary/config", map[string]interface{}{
+		"allowed_batch_keys": "primary",
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+
+	input := "dGhlIHF1aWNrIGJyb3duIGZveA=="
+	resp, err = request("sign/primary", map[string]interface{}{
+		"batch_status_codes": true,
+		"batch_input": []batchRequestSignItem{
+			{"input": input},
+			{"input": input, "name": "secondary", "key_version": "1"},
+			{"input": input, "name": "other"},
+			{"input": input, "name": "missing"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var signResults []batchResponseSignItem
+	if status := decodeBatchResults(t, resp, &signResults); status != http.StatusBadRequest {
+		t.Fatalf("expected status code %d, got %d", http.StatusBadRequest, status)
+	}
+	if signResults[0].Error != "" || signResults[0].KeyVersion != 1 {
+		t.Fatalf("unexpected result for path key: %#v", signResults[0])
+	}
+	if signResults[1].Error != "" || signResults[1].KeyVersion != 1 || !strings.HasPrefix(signResults[1].Signature, "vault:v1:") {
+		t.Fatalf("unexpected result for allowed key: %#v", signResults[1])
+	}
+	if !strings.Contains(signResults[2].Error, "does not allow batch use") {
+		t.Fatalf("expected disallowed key to fail: %#v", signResults[2])
+	}
+	if signResults[3].Error != "encryption key not found" {
+		t.Fatalf("expected missing key to fail: %#v", signResults[3])
+	}
+
+	// Both signatures of the dual-signed payload verify with their key
+	// versions, and with no failing items the batch succeeds.
+	resp, err = request("verify/primary", map[string]interface{}{
+		"batch_input": []batchRequestVerifyItem{
+			{"input": input, "signature": signResults[0].Signature},
+			{"input": input, "signature": signResults[1].Signature, "name": "secondary"},
+			{"input": input, "signature": signResults[1].Signature},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var verifyResults []batchResponseVerifyItem
+	if status := decodeBatchResults(t, resp, &verifyResults); status != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, status)
+	}
+	if !verifyResults[0].Valid || !verifyResults[1].Valid || verifyResults[1].KeyVersion != 1 {
+		t.Fatalf("expected signatures to verify: %#v", verifyResults)
+	}
+	if verifyResults[2].Valid {
+		t.Fatalf("expected signature of another key not to verify: %#v", verifyResults[2])
+	}
+
+	// HMACs follow the same rules. Without batch_status_codes, failed items
+	// do not change the status code.
+	resp, err = request("hmac/primary", map[string]interface{}{
+		"batch_input": []batchRequestHMACItem{
+			{"input": input, "name": "secondary"},
+			{"input": input, "name": "other"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hmacResults []batchResponseHMACItem
+	if status := decodeBatchResults(t, resp, &hmacResults); status != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d", http.StatusOK, status)
+	}
+	if hmacResults[0].Error != "" || hmacResults[0].KeyVersion != 2 || !strings.HasPrefix(hmacResults[0].HMAC, "vault:v2:") {
+		t.Fatalf("unexpected result for allowed key: %#v", hmacResults[0])
+	}
+	if hmacResults[1].Error == "" {
+		t.Fatalf("expected disallowed key to fail: %#v", hmacResults[1])
+	}
+
+	// A key cannot allow itself.
+	resp, err = request("keys/secondary/config", map[string]interface{}{
+		"allowed_batch_keys": "secondary",
+	})
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error, got resp: %v", resp)
+	}
+}
+
+func TestTransit_SignVerify_ED25519Context(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+		})
+	}
+
+	if resp, err := request("keys/ctx", map[string]interface{}{"type": "ed25519"}); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+
+	input := "dGhlIHF1aWNrIGJyb3duIGZveA=="
+	signatureContext := base64.StdEncoding.EncodeToString([]byte("document-pipeline"))
+
+	resp, err := request("sign/ctx", map[string]interface{}{
+		"input":             input,
+		"signature_context": signatureContext,
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+	sig := resp.Data["signature"].(string)
+
+	for _, tc := range []struct {
+		name             string
+		signatureContext string
+		valid            bool
+	}{
+		{"matching context", signatureContext, true},
+		{"wrong context", base64.StdEncoding.EncodeToString([]byte("other")), false},
+		{"missing context", "", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := map[string]interface{}{
+				"input":     input,
+				"signature": sig,
+			}
+			if tc.signatureContext != "" {
+				data["signature_context"] = tc.signatureContext
+			}
+			resp, err := request("verify/ctx", data)
+			if err != nil || resp == nil || resp.IsError() {
+				t.Fatalf("bad: err: %v, resp: %v", err, resp)
+			}
+			if resp.Data["valid"].(bool) != tc.valid {
+				t.Fatalf("expected valid=%v, got %v", tc.valid, resp.Data["valid"])
+			}
+		})
+	}
+
+	// Per-item contexts override the request's.
+	resp, err = request("verify/ctx", map[string]interface{}{
+		"signature_context": signatureContext,
+		"batch_input": []batchRequestVerifyItem{
+			{"input": input, "signature": sig},
+			{"input": input, "signature": sig, "signature_context": ""},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var verifyResults []batchResponseVerifyItem
+	decodeBatchResults(t, resp, &verifyResults)
+	if !verifyResults[0].Valid || verifyResults[1].Valid {
+		t.Fatalf("unexpected results: %#v", verifyResults)
+	}
+
+	// Signature contexts are only valid for ed25519 keys.
+	if resp, err := request("keys/ecdsa", map[string]interface{}{"type": "ecdsa-p256"}); err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+	resp, err = request("sign/ecdsa", map[string]interface{}{
+		"input":             input,
+		"signature_context": signatureContext,
+	})
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatalf("expected error, got resp: %v", resp)
+	}
+}
+
+// decodeBatchResults decodes the batch_results of a batch response into out,
+// unwrapping responses which carry an HTTP status code because some items
+// failed, and returns the status code of the response.
+func decodeBatchResults(t *testing.T, resp *logical.Response, out interface{}) int {
+	t.Helper()
+
+	gotStatus := http.StatusOK
+	if rawRespBody, ok := resp.Data[logical.HTTPRawBody]; ok {
+		httpResp := &logical.HTTPResponse{}
+		if err := jsonutil.DecodeJSON([]byte(rawRespBody.(string)), httpResp); err != nil {
+			t.Fatalf("failed to unmarshal nested response: err:%v, resp:%#v", err, resp)
+		}
+		gotStatus = resp.Data[logical.HTTPStatusCode].(int)
+		resp = logical.HTTPResponseToLogicalResponse(httpResp)
+	}
+
+	// Round trip through JSON so that results are decoded the same way
+	// whether or not they were wrapped.
+	raw, err := jsonutil.EncodeJSON(resp.Data["batch_results"])
+	if err != nil {
+		t.Fatalf("problem encoding response items: err:%v, resp:%#v", err, resp)
+	}
+	if err := jsonutil.DecodeJSON(raw, out); err != nil {
+		t.Fatalf("problem decoding response items: err:%v, resp:%#v", err, resp)
+	}
+
+	return gotStatus
+}
@@ -1,8 +1,10 @@
 package command
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -39,6 +41,7 @@ import (
 	"github.com/hashicorp/vault/command/agent/sink"
 	"github.com/hashicorp/vault/command/agent/sink/file"
 	"github.com/hashicorp/vault/command/agent/sink/inmem"
+	"github.com/hashicorp/vault/command/agent/sink/socket"
 	"github.com/hashicorp/vault/command/agent/template"
 	"github.com/hashicorp/vault/command/agent/winsvc"
 	"github.com/hashicorp/vault/helper/metricsutil"
@@ -74,6 +77,11 @@ type AgentCommand struct {
 	// Telemetry object
 	metricsHelper *metricsutil.MetricsHelper
 
+	// authHandler is set once auto-auth has been configured, and is used by
+	// the health endpoint to report the state of the auto-auth token.
+	authHandler     *auth.AuthHandler
+	authHandlerLock sync.RWMutex
+
 	cleanupGuard sync.Once
 
 	startedCh chan (struct{}) // for tests
@@ -404,6 +412,25 @@ func (c *AgentCommand) Run(args []string) int {
 				}
 				config.Sink = s
 				sinks = append(sinks, config)
+			case "socket":
+				config := &sink.SinkConfig{
+					Logger:    c.logger.Named("sink.socket"),
+					Config:    sc.Config,
+					Client:    sinkClient,
+					WrapTTL:   sc.WrapTTL,
+					DHType:    sc.DHType,
+					DeriveKey: sc.DeriveKey,
+					DHPath:    sc.DHPath,
+					AAD:       sc.AAD,
+				}
+				s, err := socket.NewSocketSink(config)
+				if err != nil {
+					c.UI.Error(fmt.Errorf("Error creating socket sink: %w", err).Error())
+					return 1
+				}
+				defer s.(io.Closer).Close()
+				config.Sink = s
+				sinks = append(sinks, config)
 			default:
 				c.UI.Error(fmt.Sprintf("Unknown sink type %q", sc.Type))
 				return 1
@@ -535,10 +562,12 @@ func (c *AgentCommand) Run(args []string) int {
 		// Create the lease cache proxier and set its underlying proxier to
 		// the API proxier.
 		leaseCache, err = cache.NewLeaseCache(&cache.LeaseCacheConfig{
-			Client:      proxyClient,
-			BaseContext: ctx,
-			Proxier:     apiProxy,
-			Logger:      cacheLogger.Named("leasecache"),
+			Client:                      proxyClient,
+			BaseContext:                 ctx,
+			Proxier:                     apiProxy,
+			Logger:                      cacheLogger.Named("leasecache"),
+			CacheStaticSecrets:          config.Cache.CacheStaticSecrets,
+			StaticSecretRefreshInterval: config.Cache.StaticSecretRefreshInterval,
 		})
 		if err != nil {
 			c.UI.Error(fmt.Sprintf("Error creating lease cache: %v", err))
@@ -554,13 +583,29 @@ func (c *AgentCommand) Run(args []string) int {
 
 			// Set AAD based on key protection type
 			var aad string
+			// persistToken is used to call transit when the encryption key is
+			// protected by a transit key. It is obtained by logging in with
+			// the auto-auth method, as the auth handler is not running yet.
+			var persistToken string
 			switch config.Cache.Persist.Type {
-			case "kubernetes":
+			case agentConfig.PersistTypeKubernetes:
 				aad, err = getServiceAccountJWT(config.Cache.Persist.ServiceAccountTokenFile)
 				if err != nil {
 					c.UI.Error(fmt.Sprintf("failed to read service account token from %s: %s", config.Cache.Persist.ServiceAccountTokenFile, err))
 					return 1
 				}
+			case agentConfig.PersistTypeTransit:
+				authClient, err := c.client.CloneWithHeaders()
+				if err != nil {
+					c.UI.Error(fmt.Sprintf("Error cloning client for persistent cache authentication: %v", err))
+					return 1
+				}
+				persistToken, err = auth.Authenticate(ctx, authClient, method)
+				if err != nil {
+					c.UI.Error(fmt.Sprintf("failed to authenticate for persistent cache transit key: %s", err))
+					return 1
+				}
+			case agentConfig.PersistTypeFile, agentConfig.PersistTypePassphrase:
 			default:
 				c.UI.Error(fmt.Sprintf("persistent key protection type %q not supported", config.Cache.Persist.Type))
 				return 1
@@ -594,7 +639,7 @@ func (c *AgentCommand) Run(args []string) int {
 					c.UI.Warn(fmt.Sprintf("Failed to close persistent cache file after getting retrieval token: %s", err))
 				}
 
-				km, err := keymanager.NewPassthroughKeyManager(ctx, token)
+				km, err := c.newPersistKeyManager(ctx, config.Cache.Persist, token, persistToken)
 				if err != nil {
 					c.UI.Error(fmt.Sprintf("failed to configure persistence encryption for cache: %s", err))
 					return 1
@@ -658,7 +703,7 @@ func (c *AgentCommand) Run(args []string) int {
 					}
 				}
 			} else {
-				km, err := keymanager.NewPassthroughKeyManager(ctx, nil)
+				km, err := c.newPersistKeyManager(ctx, config.Cache.Persist, nil, persistToken)
 				if err != nil {
 					c.UI.Error(fmt.Sprintf("failed to configure persistence encryption for cache: %s", err))
 					return 1
@@ -675,20 +720,46 @@ func (c *AgentCommand) Run(args []string) int {
 				}
 				cacheLogger.Info("configured persistent storage", "path", config.Cache.Persist.Path)
 
-				// Stash the key material in bolt
+				// Stash the key material in bolt, unless the key is sourced
+				// from elsewhere
 				token, err := km.RetrievalToken(ctx)
 				if err != nil {
 					c.UI.Error(fmt.Sprintf("Error getting persistent key: %s", err))
 					return 1
 				}
-				if err := ps.StoreRetrievalToken(token); err != nil {
-					c.UI.Error(fmt.Sprintf("Error setting key in persistent cache: %v", err))
-					return 1
+				if len(token) > 0 {
+					if err := ps.StoreRetrievalToken(token); err != nil {
+						c.UI.Error(fmt.Sprintf("Error setting key in persistent cache: %v", err))
+						return 1
+					}
 				}
 
 				defer ps.Close()
 				leaseCache.SetPersistentStorage(ps)
 			}
+
+			// Hand the token used for transit over to the auth handler, unless
+			// the previous auto-auth token was restored, in which case it is
+			// no longer needed.
+			if persistToken != "" {
+				if previousToken == "" {
+					previousToken = persistToken
+				} else {
+					// The auth method was handed the token about to be
+					// revoked, so give it the restored token instead
+					if amt, ok := method.(auth.AuthMethodWithToken); ok {
+						amt.TokenUpdated(previousToken)
+					}
+					revokeClient, err := c.client.CloneWithHeaders()
+					if err == nil {
+						revokeClient.SetToken(persistToken)
+						err = revokeClient.Auth().Token().RevokeSelfWithContext(ctx, "")
+					}
+					if err != nil {
+						cacheLogger.Warn("failed to revoke token used for persistent cache transit key", "error", err)
+					}
+				}
+			}
 		}
 
 		var inmemSink sink.Sink
@@ -709,8 +780,26 @@ func (c *AgentCommand) Run(args []string) int {
 
 		proxyVaultToken := !config.Cache.ForceAutoAuthToken
 
+		// Coalesce identical concurrent reads in front of the lease cache if
+		// enabled.
+		var proxier cache.Proxier = leaseCache
+		if config.Cache.CoalesceRequests {
+			proxier, err = cache.NewCoalescingProxier(&cache.CoalescingProxierConfig{
+				Proxier: leaseCache,
+				Logger:  cacheLogger.Named("coalesce"),
+			})
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error creating coalescing proxier: %v", err))
+				return 1
+			}
+		}
+
 		// Create the request handler
-		cacheHandler := cache.Handler(ctx, cacheLogger, leaseCache, inmemSink, proxyVaultToken)
+		cacheHandler := cache.Handler(ctx, cacheLogger, proxier, inmemSink, proxyVaultToken)
+
+		// Listeners with the cache_only role only proxy requests with the
+		// client's own token.
+		clientTokenHandler := cache.Handler(ctx, cacheLogger, proxier, nil, true)
 
 		var listeners []net.Listener
 
@@ -739,18 +828,34 @@ func (c *AgentCommand) Run(args []string) int {
 			// Parse 'require_request_header' listener config option, and wrap
 			// the request handler if necessary
 			muxHandler := cacheHandler
+			if lnConfig.Role == agentConfig.ListenerRoleCacheOnly {
+				muxHandler = clientTokenHandler
+			}
+			muxHandler = cache.RestrictPaths(cacheLogger, muxHandler, lnConfig.AllowedPaths, lnConfig.DeniedPaths)
 			if lnConfig.RequireRequestHeader {
 				muxHandler = verifyRequestHeader(muxHandler)
 			}
 
-			// Create a muxer and add paths relevant for the lease cache layer
+			// Create a muxer and add paths relevant for the listener's role
 			mux := http.NewServeMux()
 			quitEnabled := lnConfig.AgentAPI != nil && lnConfig.AgentAPI.EnableQuit
 
-			mux.Handle(consts.AgentPathCacheClear, leaseCache.HandleCacheClear(ctx))
-			mux.Handle(consts.AgentPathQuit, c.handleQuit(quitEnabled))
-			mux.Handle(consts.AgentPathMetrics, c.handleMetrics())
-			mux.Handle("/", muxHandler)
+			switch lnConfig.Role {
+			case agentConfig.ListenerRoleMetricsOnly:
+				mux.Handle(consts.AgentPathMetrics, c.handleMetrics())
+				mux.Handle(consts.AgentPathHealth, c.handleHealth(config.AutoAuth != nil))
+				mux.Handle("/", handleForbidden(lnConfig.Role))
+			case agentConfig.ListenerRoleCacheOnly:
+				mux.Handle(consts.AgentPathCacheClear, leaseCache.HandleCacheClear(ctx))
+				mux.Handle("/agent/", handleForbidden(lnConfig.Role))
+				mux.Handle("/", muxHandler)
+			default:
+				mux.Handle(consts.AgentPathCacheClear, leaseCache.HandleCacheClear(ctx))
+				mux.Handle(consts.AgentPathQuit, c.handleQuit(quitEnabled))
+				mux.Handle(consts.AgentPathMetrics, c.handleMetrics())
+				mux.Handle(consts.AgentPathHealth, c.handleHealth(config.AutoAuth != nil))
+				mux.Handle("/", muxHandler)
+			}
 
 			scheme := "https://"
 			if tlsConf == nil {
@@ -840,7 +945,7 @@ func (c *AgentCommand) Run(args []string) int {
 			ahClient.SetDisableKeepAlives(true)
 		}
 
-		ah := auth.NewAuthHandler(&auth.AuthHandlerConfig{
+		ahConfig := &auth.AuthHandlerConfig{
 			Logger:                       c.logger.Named("auth.handler"),
 			Client:                       ahClient,
 			WrapTTL:                      config.AutoAuth.Method.WrapTTL,
@@ -849,7 +954,18 @@ func (c *AgentCommand) Run(args []string) int {
 			EnableReauthOnNewCredentials: config.AutoAuth.EnableReauthOnNewCredentials,
 			EnableTemplateTokenCh:        enableTokenCh,
 			Token:                        previousToken,
-		})
+			OnAuthFailure:                config.AutoAuth.Method.OnAuthFailure,
+			OnAuthSuccess:                config.AutoAuth.Method.OnAuthSuccess,
+		}
+		if retry := config.AutoAuth.Method.Retry; retry != nil {
+			ahConfig.MaxAttempts = retry.MaxAttempts
+			ahConfig.Jitter = retry.Jitter
+			ahConfig.ExitOnFailure = retry.ExitOnFailure
+		}
+		ah := auth.NewAuthHandler(ahConfig)
+		c.authHandlerLock.Lock()
+		c.authHandler = ah
+		c.authHandlerLock.Unlock()
 
 		ss := sink.NewSinkServer(&sink.SinkServerConfig{
 			Logger:        c.logger.Named("sink.server"),
@@ -977,6 +1093,16 @@ func verifyRequestHeader(handler http.Handler) http.Handler {
 	})
 }
 
+// handleForbidden rejects requests for paths that are not served by
+// listeners with the given role.
+func handleForbidden(role string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logical.RespondError(w,
+			http.StatusForbidden,
+			fmt.Errorf("path %q is not served by listeners with role %q", r.URL.Path, role))
+	})
+}
+
 func (c *AgentCommand) notifySystemd(status string) {
 	sent, err := systemd.SdNotify(false, status)
 	if err != nil {
@@ -1069,6 +1195,35 @@ func (c *AgentCommand) removePidFile(pidPath string) error {
 	return os.Remove(pidPath)
 }
 
+// newPersistKeyManager returns the key manager protecting the encryption key
+// of the persistent cache. The retrieval token is the one stored in an
+// existing cache file, or nil for a new one.
+func (c *AgentCommand) newPersistKeyManager(ctx context.Context, persist *agentConfig.Persist, retrievalToken []byte, transitToken string) (keymanager.KeyManager, error) {
+	switch persist.Type {
+	case agentConfig.PersistTypeFile:
+		return keymanager.NewFileKeyManager(ctx, persist.KeyFile)
+	case agentConfig.PersistTypePassphrase:
+		passphrase, err := ioutil.ReadFile(persist.PassphraseFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+		return keymanager.NewPassphraseKeyManager(ctx, bytes.TrimRight(passphrase, "\r\n"), retrievalToken)
+	case agentConfig.PersistTypeTransit:
+		client, err := c.client.CloneWithHeaders()
+		if err != nil {
+			return nil, err
+		}
+		client.SetToken(transitToken)
+		return keymanager.NewTransitKeyManager(ctx, &keymanager.TransitKeyManagerConfig{
+			Client:    client,
+			MountPath: persist.TransitMount,
+			KeyName:   persist.TransitKey,
+		}, retrievalToken)
+	default:
+		return keymanager.NewPassthroughKeyManager(ctx, retrievalToken)
+	}
+}
+
 // GetServiceAccountJWT reads the service account jwt from `tokenFile`. Default is
 // the default service account file path in kubernetes.
 func getServiceAccountJWT(tokenFile string) (string, error) {
@@ -1118,6 +1273,50 @@ func (c *AgentCommand) handleMetrics() http.Handler {
 	})
 }
 
+// handleHealth reports whether the agent holds a valid auto-auth token. It
+// responds with a 503 if auto-auth is configured but no token is held, or the
+// token has expired.
+func (c *AgentCommand) handleHealth(autoAuthEnabled bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		default:
+			logical.RespondError(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		resp := map[string]interface{}{
+			"auto_auth_enabled": autoAuthEnabled,
+		}
+		status := http.StatusOK
+
+		if autoAuthEnabled {
+			c.authHandlerLock.RLock()
+			ah := c.authHandler
+			c.authHandlerLock.RUnlock()
+
+			var valid bool
+			var expireAt time.Time
+			if ah != nil {
+				valid, expireAt = ah.TokenState()
+			}
+			resp["token_valid"] = valid
+			if !expireAt.IsZero() {
+				resp["token_expire_time"] = expireAt.UTC().Format(time.RFC3339)
+			}
+			if !valid {
+				status = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(resp)
+		}
+	})
+}
+
 func (c *AgentCommand) handleQuit(enabled bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !enabled {
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math/rand"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -17,6 +19,10 @@ import (
 const (
 	defaultMinBackoff = 1 * time.Second
 	defaultMaxBackoff = 5 * time.Minute
+
+	// defaultBackoffJitter is the default fraction of each backoff that may
+	// be randomly trimmed off.
+	defaultBackoffJitter = 0.25
 )
 
 // AuthMethod is the interface that auto-auth methods implement for the agent
@@ -37,6 +43,14 @@ type AuthMethodWithClient interface {
 	AuthClient(client *api.Client) (*api.Client, error)
 }
 
+// AuthMethodWithToken is an extended interface for auth methods that need the
+// token from the most recent successful authentication, such as to renew
+// their own credentials. It is not called when the token is wrapped.
+type AuthMethodWithToken interface {
+	AuthMethod
+	TokenUpdated(token string)
+}
+
 type AuthConfig struct {
 	Logger    hclog.Logger
 	MountPath string
@@ -58,6 +72,23 @@ type AuthHandler struct {
 	minBackoff                   time.Duration
 	enableReauthOnNewCredentials bool
 	enableTemplateTokenCh        bool
+
+	// jitter, maxAttempts and exitOnFailure configure retrying failed
+	// authentication attempts, and onAuthFailure and onAuthSuccess the
+	// commands run on failure and success. failedAttempts counts the
+	// consecutive failed attempts.
+	jitter         float64
+	maxAttempts    int
+	exitOnFailure  bool
+	onAuthFailure  []string
+	onAuthSuccess  []string
+	failedAttempts int
+
+	// tokenLock protects the state of the most recently obtained token,
+	// reported by TokenState.
+	tokenLock     sync.RWMutex
+	tokenValid    bool
+	tokenExpireAt time.Time
 }
 
 type AuthHandlerConfig struct {
@@ -69,6 +100,23 @@ type AuthHandlerConfig struct {
 	Token                        string
 	EnableReauthOnNewCredentials bool
 	EnableTemplateTokenCh        bool
+
+	// Jitter is the fraction of each backoff that may be randomly trimmed
+	// off. If nil, the default of 0.25 is used.
+	Jitter *float64
+
+	// MaxAttempts is the number of consecutive failed authentication
+	// attempts after which auto-auth is considered failed: OnAuthFailure is
+	// run and, if ExitOnFailure is set, the handler returns an error. Zero
+	// means every failed attempt.
+	MaxAttempts   int
+	ExitOnFailure bool
+
+	// OnAuthFailure and OnAuthSuccess are commands, with their arguments,
+	// run when auto-auth is considered failed and after each successful
+	// authentication.
+	OnAuthFailure []string
+	OnAuthSuccess []string
 }
 
 func NewAuthHandler(conf *AuthHandlerConfig) *AuthHandler {
@@ -86,6 +134,14 @@ func NewAuthHandler(conf *AuthHandlerConfig) *AuthHandler {
 		maxBackoff:                   conf.MaxBackoff,
 		enableReauthOnNewCredentials: conf.EnableReauthOnNewCredentials,
 		enableTemplateTokenCh:        conf.EnableTemplateTokenCh,
+		jitter:                       defaultBackoffJitter,
+		maxAttempts:                  conf.MaxAttempts,
+		exitOnFailure:                conf.ExitOnFailure,
+		onAuthFailure:                conf.OnAuthFailure,
+		onAuthSuccess:                conf.OnAuthSuccess,
+	}
+	if conf.Jitter != nil {
+		ah.jitter = *conf.Jitter
 	}
 
 	return ah
@@ -117,6 +173,11 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		return errors.New("auth handler: min_backoff cannot be greater than max_backoff")
 	}
 
+	if ah.jitter < 0 || ah.jitter >= 1 {
+		return errors.New("auth handler: jitter must be at least 0 and less than 1")
+	}
+	backoff.jitter = ah.jitter
+
 	ah.logger.Info("starting auth handler")
 	defer func() {
 		am.Shutdown()
@@ -167,8 +228,9 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 			clientToUse, err = am.(AuthMethodWithClient).AuthClient(ah.client)
 			if err != nil {
 				ah.logger.Error("error creating client for authentication call", "error", err, "backoff", backoff)
-				backoffOrQuit(ctx, backoff)
-				metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+				if err := ah.authFailed(ctx, backoff, err); err != nil {
+					return err
+				}
 				continue
 			}
 		default:
@@ -190,8 +252,9 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 			secret, err = clientToUse.Auth().Token().LookupSelfWithContext(ctx)
 			if err != nil {
 				ah.logger.Error("could not look up token", "err", err, "backoff", backoff)
-				backoffOrQuit(ctx, backoff)
-				metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+				if err := ah.authFailed(ctx, backoff, err); err != nil {
+					return err
+				}
 				continue
 			}
 
@@ -207,8 +270,9 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 			path, header, data, err = am.Authenticate(ctx, ah.client)
 			if err != nil {
 				ah.logger.Error("error getting path or data from method", "error", err, "backoff", backoff)
-				backoffOrQuit(ctx, backoff)
-				metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+				if err := ah.authFailed(ctx, backoff, err); err != nil {
+					return err
+				}
 				continue
 			}
 		}
@@ -217,8 +281,9 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 			wrapClient, err := clientToUse.Clone()
 			if err != nil {
 				ah.logger.Error("error creating client for wrapped call", "error", err, "backoff", backoff)
-				backoffOrQuit(ctx, backoff)
-				metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+				if err := ah.authFailed(ctx, backoff, err); err != nil {
+					return err
+				}
 				continue
 			}
 			wrapClient.SetWrappingLookupFunc(func(string, string) string {
@@ -239,8 +304,9 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 			// Check errors/sanity
 			if err != nil {
 				ah.logger.Error("error authenticating", "error", err, "backoff", backoff)
-				backoffOrQuit(ctx, backoff)
-				metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+				if err := ah.authFailed(ctx, backoff, err); err != nil {
+					return err
+				}
 				continue
 			}
 		}
@@ -249,23 +315,27 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		case ah.wrapTTL > 0:
 			if secret.WrapInfo == nil {
 				ah.logger.Error("authentication returned nil wrap info", "backoff", backoff)
-				backoffOrQuit(ctx, backoff)
-				metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+				if err := ah.authFailed(ctx, backoff, errors.New("authentication returned nil wrap info")); err != nil {
+					return err
+				}
 				continue
 			}
 			if secret.WrapInfo.Token == "" {
 				ah.logger.Error("authentication returned empty wrapped client token", "backoff", backoff)
-				backoffOrQuit(ctx, backoff)
-				metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+				if err := ah.authFailed(ctx, backoff, errors.New("authentication returned empty wrapped client token")); err != nil {
+					return err
+				}
 				continue
 			}
 			wrappedResp, err := jsonutil.EncodeJSON(secret.WrapInfo)
 			if err != nil {
 				ah.logger.Error("failed to encode wrapinfo", "error", err, "backoff", backoff)
-				backoffOrQuit(ctx, backoff)
-				metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+				if err := ah.authFailed(ctx, backoff, err); err != nil {
+					return err
+				}
 				continue
 			}
+			ah.setTokenState(true, secret.WrapInfo.TTL)
 			ah.logger.Info("authentication successful, sending wrapped token to sinks and pausing")
 			ah.OutputCh <- string(wrappedResp)
 			if ah.enableTemplateTokenCh {
@@ -273,6 +343,7 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 			}
 
 			am.CredSuccess()
+			ah.authSucceeded(ctx)
 			backoff.reset()
 
 			select {
@@ -288,16 +359,23 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		default:
 			if secret == nil || secret.Auth == nil {
 				ah.logger.Error("authentication returned nil auth info", "backoff", backoff)
-				backoffOrQuit(ctx, backoff)
-				metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+				if err := ah.authFailed(ctx, backoff, errors.New("authentication returned nil auth info")); err != nil {
+					return err
+				}
 				continue
 			}
 			if secret.Auth.ClientToken == "" {
 				ah.logger.Error("authentication returned empty client token", "backoff", backoff)
-				backoffOrQuit(ctx, backoff)
-				metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+				if err := ah.authFailed(ctx, backoff, errors.New("authentication returned empty client token")); err != nil {
+					return err
+				}
 				continue
 			}
+			if amt, ok := am.(AuthMethodWithToken); ok {
+				amt.TokenUpdated(secret.Auth.ClientToken)
+			}
+
+			ah.setTokenState(true, secret.Auth.LeaseDuration)
 			ah.logger.Info("authentication successful, sending token to sinks")
 			ah.OutputCh <- secret.Auth.ClientToken
 			if ah.enableTemplateTokenCh {
@@ -305,6 +383,7 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 			}
 
 			am.CredSuccess()
+			ah.authSucceeded(ctx)
 			backoff.reset()
 		}
 
@@ -317,8 +396,9 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		})
 		if err != nil {
 			ah.logger.Error("error creating lifetime watcher, backing off and retrying", "error", err, "backoff", backoff)
-			backoffOrQuit(ctx, backoff)
-			metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+			if err := ah.authFailed(ctx, backoff, err); err != nil {
+				return err
+			}
 			continue
 		}
 
@@ -337,15 +417,19 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 
 			case err := <-watcher.DoneCh():
 				ah.logger.Info("lifetime watcher done channel triggered")
+				ah.setTokenState(false, 0)
 				if err != nil {
 					metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
 					ah.logger.Error("error renewing token", "error", err)
 				}
 				break LifetimeWatcherLoop
 
-			case <-watcher.RenewCh():
+			case renewal := <-watcher.RenewCh():
 				metrics.IncrCounter([]string{"agent", "auth", "success"}, 1)
 				ah.logger.Info("renewed auth token")
+				if renewal != nil && renewal.Secret != nil && renewal.Secret.Auth != nil {
+					ah.setTokenState(true, renewal.Secret.Auth.LeaseDuration)
+				}
 
 			case <-credCh:
 				ah.logger.Info("auth method found new credentials, re-authenticating")
@@ -355,11 +439,120 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 	}
 }
 
+// authFailed records a failed authentication attempt and backs off. Once
+// max_attempts consecutive attempts have failed, the failure hook is run and,
+// with exit_on_failure, an error is returned for the handler to exit with.
+func (ah *AuthHandler) authFailed(ctx context.Context, backoff *agentBackoff, cause error) error {
+	metrics.IncrCounter([]string{"agent", "auth", "failure"}, 1)
+
+	ah.failedAttempts++
+	if ah.maxAttempts == 0 || ah.failedAttempts%ah.maxAttempts == 0 {
+		ah.runHook(ctx, "on_auth_failure", ah.onAuthFailure, cause)
+
+		if ah.exitOnFailure {
+			return fmt.Errorf("auth handler: authentication failed after %d attempts: %w", ah.failedAttempts, cause)
+		}
+	}
+
+	backoffOrQuit(ctx, backoff)
+	return nil
+}
+
+// authSucceeded resets the failed attempts and runs the success hook.
+func (ah *AuthHandler) authSucceeded(ctx context.Context) {
+	ah.failedAttempts = 0
+	ah.runHook(ctx, "on_auth_success", ah.onAuthSuccess, nil)
+}
+
+// setTokenState records whether the handler holds a valid token, and its TTL
+// in seconds. A TTL of zero means the token does not expire.
+func (ah *AuthHandler) setTokenState(valid bool, ttl int) {
+	ah.tokenLock.Lock()
+	defer ah.tokenLock.Unlock()
+
+	ah.tokenValid = valid
+	ah.tokenExpireAt = time.Time{}
+	if valid && ttl > 0 {
+		ah.tokenExpireAt = time.Now().Add(time.Duration(ttl) * time.Second)
+	}
+
+	if valid {
+		metrics.SetGauge([]string{"agent", "auth", "token_valid"}, 1)
+	} else {
+		metrics.SetGauge([]string{"agent", "auth", "token_valid"}, 0)
+	}
+}
+
+// TokenState reports whether the handler holds a token that has not expired,
+// and when it expires. A zero expiry time means the token does not expire.
+func (ah *AuthHandler) TokenState() (bool, time.Time) {
+	ah.tokenLock.RLock()
+	defer ah.tokenLock.RUnlock()
+
+	if !ah.tokenValid {
+		return false, time.Time{}
+	}
+	if !ah.tokenExpireAt.IsZero() && time.Now().After(ah.tokenExpireAt) {
+		return false, ah.tokenExpireAt
+	}
+	return true, ah.tokenExpireAt
+}
+
+// Authenticate logs in once with the auth method, without retrying or
+// starting renewal. It is used where a token is needed before the auth
+// handler runs, and the returned token can be given to the auth handler as
+// its preloaded token.
+func Authenticate(ctx context.Context, client *api.Client, am AuthMethod) (string, error) {
+	clientToUse := client
+	if amc, ok := am.(AuthMethodWithClient); ok {
+		var err error
+		clientToUse, err = amc.AuthClient(client)
+		if err != nil {
+			return "", fmt.Errorf("error creating client for authentication call: %w", err)
+		}
+	}
+
+	clientToUse, err := clientToUse.Clone()
+	if err != nil {
+		return "", err
+	}
+	clientToUse.SetMaxRetries(0)
+
+	path, header, data, err := am.Authenticate(ctx, client)
+	if err != nil {
+		return "", fmt.Errorf("error getting path or data from method: %w", err)
+	}
+	for key, values := range header {
+		for _, value := range values {
+			clientToUse.AddHeader(key, value)
+		}
+	}
+
+	secret, err := clientToUse.Logical().WriteWithContext(ctx, path, data)
+	if err != nil {
+		return "", fmt.Errorf("error authenticating: %w", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", errors.New("authentication returned no client token")
+	}
+
+	if amt, ok := am.(AuthMethodWithToken); ok {
+		amt.TokenUpdated(secret.Auth.ClientToken)
+	}
+	am.CredSuccess()
+
+	return secret.Auth.ClientToken, nil
+}
+
 // agentBackoff tracks exponential backoff state.
 type agentBackoff struct {
 	min     time.Duration
 	max     time.Duration
 	current time.Duration
+
+	// jitter is the fraction of each backoff that may be randomly trimmed
+	// off.
+	jitter float64
 }
 
 func newAgentBackoff(min, max time.Duration) *agentBackoff {
@@ -375,6 +568,7 @@ func newAgentBackoff(min, max time.Duration) *agentBackoff {
 		current: min,
 		max:     max,
 		min:     min,
+		jitter:  defaultBackoffJitter,
 	}
 }
 
@@ -387,9 +581,11 @@ func (b *agentBackoff) next() {
 		maxBackoff = b.max
 	}
 
-	// Trim a random amount (0-25%) off the doubled duration
-	trim := rand.Int63n(int64(maxBackoff) / 4)
-	b.current = maxBackoff - time.Duration(trim)
+	// Trim a random amount (0-25% by default) off the doubled duration
+	b.current = maxBackoff
+	if maxTrim := int64(float64(maxBackoff) * b.jitter); maxTrim > 0 {
+		b.current -= time.Duration(rand.Int63n(maxTrim))
+	}
 }
 
 func (b *agentBackoff) reset() {
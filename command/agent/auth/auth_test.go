@@ -2,7 +2,12 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"io/ioutil"
 	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -73,11 +78,22 @@ func TestAuthHandler(t *testing.T) {
 
 	ctx, cancelFunc := context.WithCancel(context.Background())
 
+	var onAuthSuccess []string
+	hookOutput := filepath.Join(t.TempDir(), "successes")
+	if _, err := exec.LookPath("sh"); err == nil {
+		onAuthSuccess = []string{"sh", "-c", "echo success >> " + hookOutput}
+	}
+
 	ah := NewAuthHandler(&AuthHandlerConfig{
-		Logger: logger.Named("auth.handler"),
-		Client: client,
+		Logger:        logger.Named("auth.handler"),
+		Client:        client,
+		OnAuthSuccess: onAuthSuccess,
 	})
 
+	if valid, _ := ah.TokenState(); valid {
+		t.Fatal("expected no valid token before authenticating")
+	}
+
 	am := newUserpassTestMethod(t, client)
 	errCh := make(chan error)
 	go func() {
@@ -95,7 +111,14 @@ consumption:
 				t.Fatal(err)
 			}
 			break consumption
-		case <-ah.OutputCh:
+		case token := <-ah.OutputCh:
+			if token == "" {
+				continue
+			}
+			valid, expireAt := ah.TokenState()
+			if !valid || expireAt.IsZero() || expireAt.Before(time.Now()) {
+				t.Fatalf("expected a valid expiring token, got valid=%t expireAt=%s", valid, expireAt)
+			}
 		case <-ah.TemplateTokenCh:
 		// Nothing
 		case <-time.After(stopTime.Sub(time.Now())):
@@ -105,6 +128,73 @@ consumption:
 			}
 		}
 	}
+
+	if onAuthSuccess != nil {
+		out, err := ioutil.ReadFile(hookOutput)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(out), "success\n") {
+			t.Fatalf("unexpected hook output %q", out)
+		}
+	}
+}
+
+type failingTestMethod struct{}
+
+func (f *failingTestMethod) Authenticate(context.Context, *api.Client) (string, http.Header, map[string]interface{}, error) {
+	return "", nil, nil, errors.New("no credentials")
+}
+
+func (f *failingTestMethod) NewCreds() chan struct{} {
+	return nil
+}
+
+func (f *failingTestMethod) CredSuccess() {
+}
+
+func (f *failingTestMethod) Shutdown() {
+}
+
+func TestAuthHandler_ExitOnFailure(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is required to run the hook")
+	}
+
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hookOutput := filepath.Join(t.TempDir(), "failures")
+	jitter := 0.0
+	ah := NewAuthHandler(&AuthHandlerConfig{
+		Logger:        logging.NewVaultLogger(hclog.Trace),
+		Client:        client,
+		MinBackoff:    10 * time.Millisecond,
+		MaxBackoff:    20 * time.Millisecond,
+		Jitter:        &jitter,
+		MaxAttempts:   3,
+		ExitOnFailure: true,
+		OnAuthFailure: []string{"sh", "-c", `echo "$VAULT_AGENT_AUTH_FAILED_ATTEMPTS $VAULT_AGENT_AUTH_ERROR" >> ` + hookOutput},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = ah.Run(ctx, &failingTestMethod{})
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("expected the handler to give up after 3 attempts, got: %v", err)
+	}
+
+	// The hook runs once the attempts are exhausted, not for every failure.
+	out, err := ioutil.ReadFile(hookOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "3 no credentials\n" {
+		t.Fatalf("unexpected hook output %q", out)
+	}
 }
 
 func TestAgentBackoff(t *testing.T) {
@@ -142,6 +232,16 @@ func TestAgentBackoff(t *testing.T) {
 	if backoff.current != defaultMinBackoff {
 		t.Fatalf("expected 1s backoff after reset, got: %v", backoff.current)
 	}
+
+	// Test that without jitter the backoff doubles exactly
+	backoff.jitter = 0
+	for i := 0; i < 5; i++ {
+		old := backoff.current
+		backoff.next()
+		if backoff.current != 2*old {
+			t.Fatalf("expected backoff of %v without jitter, got: %v", 2*old, backoff)
+		}
+	}
 }
 
 func TestAgentMinBackoffCustom(t *testing.T) {
@@ -194,3 +294,39 @@ func TestAgentMinBackoffCustom(t *testing.T) {
 		}
 	}
 }
+
+func TestAuthenticate(t *testing.T) {
+	logger := logging.NewVaultLogger(hclog.Trace)
+	coreConfig := &vault.CoreConfig{
+		Logger: logger,
+		CredentialBackends: map[string]logical.Factory{
+			"userpass": userpass.Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	client := cluster.Cores[0].Client
+
+	token, err := Authenticate(context.Background(), client, newUserpassTestMethod(t, client))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenClient, err := client.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenClient.SetToken(token)
+	secret, err := tokenClient.Auth().Token().LookupSelf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret.Data["path"] != "auth/userpass/login/foo" {
+		t.Fatalf("unexpected token path: %v", secret.Data["path"])
+	}
+}
@@ -23,9 +23,15 @@ type certMethod struct {
 
 	// Client is the cached client to use if cert info was provided.
 	client *api.Client
+
+	// renewal is set if the method renews its own certificate from PKI.
+	renewal *certRenewal
 }
 
-var _ auth.AuthMethodWithClient = &certMethod{}
+var (
+	_ auth.AuthMethodWithClient = &certMethod{}
+	_ auth.AuthMethodWithToken  = &certMethod{}
+)
 
 func NewCertAuthMethod(conf *auth.AuthConfig) (auth.AuthMethod, error) {
 	if conf == nil {
@@ -73,6 +79,17 @@ func NewCertAuthMethod(conf *auth.AuthConfig) (auth.AuthMethod, error) {
 				return nil, errors.New("could not convert 'cert_key' config value to string")
 			}
 		}
+
+		renewal, err := parseRenewalConfig(conf)
+		if err != nil {
+			return nil, err
+		}
+		if renewal != nil {
+			if c.clientCert == "" || c.clientKey == "" {
+				return nil, errors.New("'client_cert' and 'client_key' are required to renew the certificate from PKI")
+			}
+			c.renewal = renewal
+		}
 	}
 
 	return c, nil
@@ -91,12 +108,34 @@ func (c *certMethod) Authenticate(_ context.Context, client *api.Client) (string
 }
 
 func (c *certMethod) NewCreds() chan struct{} {
-	return nil
+	if c.renewal == nil {
+		return nil
+	}
+	return c.renewal.credsCh
 }
 
 func (c *certMethod) CredSuccess() {}
 
-func (c *certMethod) Shutdown() {}
+func (c *certMethod) Shutdown() {
+	if c.renewal != nil {
+		c.renewal.cancel()
+	}
+}
+
+// TokenUpdated records the token used to renew the certificate, starting
+// renewal once the first token is available.
+func (c *certMethod) TokenUpdated(token string) {
+	if c.renewal == nil {
+		return
+	}
+	c.renewal.l.Lock()
+	c.renewal.token = token
+	c.renewal.l.Unlock()
+
+	c.renewal.startOnce.Do(func() {
+		go c.runRenewal()
+	})
+}
 
 // AuthClient uses the existing client's address and returns a new client with
 // the auto-auth method's certificate information if that's provided in its
@@ -106,6 +145,11 @@ func (c *certMethod) AuthClient(client *api.Client) (*api.Client, error) {
 
 	clientToAuth := client
 
+	if c.renewal != nil {
+		c.renewal.l.Lock()
+		defer c.renewal.l.Unlock()
+	}
+
 	if c.caCert != "" || (c.clientKey != "" && c.clientCert != "") {
 		// Return cached client if present
 		if c.client != nil {
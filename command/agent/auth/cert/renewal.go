@@ -0,0 +1,351 @@
+package cert
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/vault/command/agent/auth"
+)
+
+const (
+	defaultPKIMount = "pki"
+
+	// defaultRenewRetryInterval is how long to wait before retrying a failed
+	// renewal.
+	defaultRenewRetryInterval = 30 * time.Second
+)
+
+// certRenewal holds the configuration and state for renewing the client
+// certificate from a PKI issue endpoint using the auto-auth token.
+type certRenewal struct {
+	pkiMount    string
+	pkiRole     string
+	commonName  string
+	altNames    string
+	ipSANs      string
+	ttl         string
+	renewBefore time.Duration
+
+	retryInterval time.Duration
+
+	// l protects token and the certMethod's cached client, which is reset
+	// whenever the certificate files are rewritten.
+	l     sync.Mutex
+	token string
+
+	startOnce sync.Once
+	credsCh   chan struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func parseRenewalConfig(conf *auth.AuthConfig) (*certRenewal, error) {
+	roleRaw, ok := conf.Config["pki_role"]
+	if !ok {
+		for _, key := range []string{"pki_mount", "common_name", "alt_names", "ip_sans", "ttl", "renew_before"} {
+			if _, ok := conf.Config[key]; ok {
+				return nil, fmt.Errorf("'%s' requires 'pki_role' to be set", key)
+			}
+		}
+		return nil, nil
+	}
+
+	if conf.WrapTTL > 0 {
+		return nil, errors.New("renewing the certificate from PKI cannot be used with a wrapped auto-auth token")
+	}
+
+	r := &certRenewal{
+		pkiMount:      defaultPKIMount,
+		retryInterval: defaultRenewRetryInterval,
+		credsCh:       make(chan struct{}, 1),
+	}
+
+	var err error
+	if r.pkiRole, err = parseutil.ParseString(roleRaw); err != nil || r.pkiRole == "" {
+		return nil, errors.New("could not convert 'pki_role' config value to string")
+	}
+
+	for key, dst := range map[string]*string{
+		"pki_mount":   &r.pkiMount,
+		"common_name": &r.commonName,
+		"alt_names":   &r.altNames,
+		"ip_sans":     &r.ipSANs,
+		"ttl":         &r.ttl,
+	} {
+		raw, ok := conf.Config[key]
+		if !ok {
+			continue
+		}
+		if *dst, err = parseutil.ParseString(raw); err != nil {
+			return nil, fmt.Errorf("could not convert '%s' config value to string", key)
+		}
+	}
+	r.pkiMount = strings.Trim(r.pkiMount, "/")
+	if r.pkiMount == "" {
+		return nil, errors.New("'pki_mount' cannot be empty")
+	}
+
+	if raw, ok := conf.Config["renew_before"]; ok {
+		if r.renewBefore, err = parseutil.ParseDurationSecond(raw); err != nil {
+			return nil, fmt.Errorf("could not parse 'renew_before': %w", err)
+		}
+		if r.renewBefore <= 0 {
+			return nil, errors.New("'renew_before' must be positive")
+		}
+	}
+
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	return r, nil
+}
+
+// runRenewal renews the client certificate before it expires until the
+// method is shut down. After each renewal the auth handler is notified of the
+// new credentials so that it re-authenticates with the new certificate.
+func (c *certMethod) runRenewal() {
+	r := c.renewal
+	for {
+		wait, err := c.timeUntilRenewal()
+		if err != nil {
+			c.logger.Error("error reading client certificate, retrying", "error", err, "backoff", r.retryInterval)
+			wait = r.retryInterval
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if err != nil {
+			continue
+		}
+
+		if err := c.renewCertificate(r.ctx); err != nil {
+			c.logger.Error("error renewing client certificate, retrying", "error", err, "backoff", r.retryInterval)
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-time.After(r.retryInterval):
+			}
+			continue
+		}
+
+		c.logger.Info("renewed client certificate, re-authenticating")
+		select {
+		case r.credsCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// timeUntilRenewal returns how long until the current certificate is due for
+// renewal: renew_before ahead of its expiry, or once two thirds of its
+// validity have passed.
+func (c *certMethod) timeUntilRenewal() (time.Duration, error) {
+	cert, err := readCertificate(c.clientCert)
+	if err != nil {
+		return 0, err
+	}
+
+	renewBefore := c.renewal.renewBefore
+	if renewBefore == 0 {
+		renewBefore = cert.NotAfter.Sub(cert.NotBefore) / 3
+	}
+
+	wait := time.Until(cert.NotAfter.Add(-renewBefore))
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, nil
+}
+
+func readCertificate(path string) (*x509.Certificate, error) {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("no PEM encoded certificate found in %s", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// renewCertificate issues a new certificate with the auto-auth token and
+// atomically replaces the certificate and key files with it.
+func (c *certMethod) renewCertificate(ctx context.Context) error {
+	r := c.renewal
+
+	r.l.Lock()
+	token := r.token
+	client := c.client
+	r.l.Unlock()
+	if token == "" || client == nil {
+		return errors.New("no auto-auth token available yet")
+	}
+
+	commonName := r.commonName
+	if commonName == "" {
+		cert, err := readCertificate(c.clientCert)
+		if err != nil {
+			return err
+		}
+		commonName = cert.Subject.CommonName
+	}
+
+	client, err := client.Clone()
+	if err != nil {
+		return err
+	}
+	client.SetToken(token)
+
+	data := map[string]interface{}{
+		"common_name": commonName,
+	}
+	if r.altNames != "" {
+		data["alt_names"] = r.altNames
+	}
+	if r.ipSANs != "" {
+		data["ip_sans"] = r.ipSANs
+	}
+	if r.ttl != "" {
+		data["ttl"] = r.ttl
+	}
+
+	secret, err := client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/issue/%s", r.pkiMount, r.pkiRole), data)
+	if err != nil {
+		return fmt.Errorf("error issuing certificate: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return errors.New("empty response issuing certificate")
+	}
+
+	certPEM, _ := secret.Data["certificate"].(string)
+	keyPEM, _ := secret.Data["private_key"].(string)
+	if certPEM == "" || keyPEM == "" {
+		return errors.New("certificate or private key missing from issue response")
+	}
+
+	// Include the chain so that the certificate verifies against a cert auth
+	// role trusting any CA in it.
+	chain := []string{strings.TrimSpace(certPEM)}
+	if caChain, ok := secret.Data["ca_chain"].([]interface{}); ok && len(caChain) > 0 {
+		for _, ca := range caChain {
+			if s, ok := ca.(string); ok && s != "" {
+				chain = append(chain, strings.TrimSpace(s))
+			}
+		}
+	} else if issuingCA, ok := secret.Data["issuing_ca"].(string); ok && issuingCA != "" {
+		chain = append(chain, strings.TrimSpace(issuingCA))
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if err := replaceKeyPair(c.clientKey, c.clientCert, []byte(strings.TrimSpace(keyPEM)+"\n"), []byte(strings.Join(chain, "\n")+"\n")); err != nil {
+		return err
+	}
+
+	// Have AuthClient load the new certificate.
+	c.client = nil
+
+	return nil
+}
+
+// replaceKeyPair replaces the key and certificate files together. Both are
+// fully written to temporary files before either is renamed into place, and
+// the previous key is put back if the certificate cannot be, so that the
+// files never hold a key and a certificate that do not match.
+func replaceKeyPair(keyPath, certPath string, keyPEM, certPEM []byte) error {
+	keyTmp, err := writeTempFile(keyPath, keyPEM, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(keyTmp)
+
+	certTmp, err := writeTempFile(certPath, certPEM, 0o644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(certTmp)
+
+	oldKey, err := ioutil.ReadFile(keyPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Rename(keyTmp, keyPath); err != nil {
+		return fmt.Errorf("error renaming %s to %s: %w", keyTmp, keyPath, err)
+	}
+	if err := os.Rename(certTmp, certPath); err != nil {
+		err = fmt.Errorf("error renaming %s to %s: %w", certTmp, certPath, err)
+		if oldKey != nil {
+			if restoreErr := writeFileAtomic(keyPath, oldKey, 0o600); restoreErr != nil {
+				return fmt.Errorf("%v; additionally failed to restore previous key: %w", err, restoreErr)
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// writeFileAtomic writes the data to a temporary file next to path and
+// renames it into place, keeping the mode of an existing file.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmpPath, err := writeTempFile(path, data, mode)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("error renaming %s to %s: %w", tmpPath, path, err)
+	}
+	return nil
+}
+
+// writeTempFile writes and syncs the data to a temporary file next to path,
+// with the mode of an existing file at path, and returns its name.
+func writeTempFile(path string, data []byte, mode os.FileMode) (string, error) {
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp.")
+	if err != nil {
+		return "", fmt.Errorf("error creating temp file for %s: %w", path, err)
+	}
+
+	if err := tmpFile.Chmod(mode); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("error setting mode on %s: %w", tmpFile.Name(), err)
+	}
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("error writing to %s: %w", tmpFile.Name(), err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("error syncing %s: %w", tmpFile.Name(), err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("error closing %s: %w", tmpFile.Name(), err)
+	}
+
+	return tmpFile.Name(), nil
+}
@@ -0,0 +1,245 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/auth"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+)
+
+// testCertificate returns a PEM encoded self-signed certificate and key
+// valid between the given times.
+func testCertificate(t *testing.T, commonName string, notBefore, notAfter time.Time) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+}
+
+func TestCertAuthMethod_Renewal(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "agent.crt")
+	keyPath := filepath.Join(dir, "agent.key")
+
+	// The bootstrap certificate is past two thirds of its validity, so it is
+	// renewed as soon as a token is available.
+	certPEM, keyPEM := testCertificate(t, "agent.example.com", time.Now().Add(-time.Hour), time.Now().Add(time.Minute))
+	if err := ioutil.WriteFile(certPath, []byte(certPEM), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyPath, []byte(keyPEM), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	var issueReq map[string]interface{}
+	issued := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/pki-int/issue/agent" || r.Header.Get(consts.AuthHeaderName) != "token1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&issueReq); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		newCert, newKey := testCertificate(t, "agent.example.com", time.Now(), time.Now().Add(time.Hour))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"certificate": newCert,
+				"private_key": newKey,
+			},
+		})
+		select {
+		case issued <- struct{}{}:
+		default:
+		}
+	}))
+	defer server.Close()
+
+	method, err := NewCertAuthMethod(&auth.AuthConfig{
+		Logger:    hclog.NewNullLogger(),
+		MountPath: "auth/cert",
+		Config: map[string]interface{}{
+			"client_cert": certPath,
+			"client_key":  keyPath,
+			"pki_mount":   "pki-int",
+			"pki_role":    "agent",
+			"ttl":         "1h",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer method.Shutdown()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authClient, err := method.(auth.AuthMethodWithClient).AuthClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	method.(auth.AuthMethodWithToken).TokenUpdated("token1")
+
+	select {
+	case <-method.NewCreds():
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the certificate to be renewed")
+	}
+	<-issued
+
+	if issueReq["common_name"] != "agent.example.com" || issueReq["ttl"] != "1h" {
+		t.Fatalf("unexpected issue request: %v", issueReq)
+	}
+
+	cert, err := readCertificate(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(cert.NotAfter) < 30*time.Minute {
+		t.Fatalf("expected the certificate to be replaced, expires at %s", cert.NotAfter)
+	}
+
+	// The existing file modes are kept.
+	fi, err := os.Stat(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o640 {
+		t.Fatalf("unexpected key file mode %v", fi.Mode().Perm())
+	}
+
+	// The new certificate is used for the next authentication.
+	newAuthClient, err := method.(auth.AuthMethodWithClient).AuthClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newAuthClient == authClient {
+		t.Fatal("expected a new client after renewal")
+	}
+
+	// The renewed certificate is not due yet, so no further renewal happens.
+	select {
+	case <-method.NewCreds():
+		t.Fatal("unexpected renewal of the new certificate")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestCertAuthMethod_RenewalConfig(t *testing.T) {
+	for name, config := range map[string]map[string]interface{}{
+		"no role":         {"client_cert": "a", "client_key": "b", "pki_mount": "pki"},
+		"no cert":         {"pki_role": "agent"},
+		"bad renewal":     {"client_cert": "a", "client_key": "b", "pki_role": "agent", "renew_before": "-1h"},
+		"empty pki mount": {"client_cert": "a", "client_key": "b", "pki_role": "agent", "pki_mount": "/"},
+	} {
+		_, err := NewCertAuthMethod(&auth.AuthConfig{
+			Logger:    hclog.NewNullLogger(),
+			MountPath: "auth/cert",
+			Config:    config,
+		})
+		if err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+
+	_, err := NewCertAuthMethod(&auth.AuthConfig{
+		Logger:    hclog.NewNullLogger(),
+		MountPath: "auth/cert",
+		WrapTTL:   time.Minute,
+		Config:    map[string]interface{}{"client_cert": "a", "client_key": "b", "pki_role": "agent"},
+	})
+	if err == nil {
+		t.Fatal("expected error renewing with a wrapped token")
+	}
+}
+
+func TestCertAuthMethod_ReplaceKeyPair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cert-renewal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyPath := filepath.Join(dir, "client.key")
+	certPath := filepath.Join(dir, "client.crt")
+	if err := ioutil.WriteFile(keyPath, []byte("old key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certPath, []byte("old cert"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := replaceKeyPair(keyPath, certPath, []byte("new key"), []byte("new cert")); err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{keyPath: "new key", certPath: "new cert"} {
+		if data, err := ioutil.ReadFile(path); err != nil || string(data) != expected {
+			t.Fatalf("expected %q in %s, got %q: %v", expected, path, data, err)
+		}
+	}
+
+	// The previous key is restored when the certificate cannot be replaced
+	if err := os.Remove(certPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(certPath, "busy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceKeyPair(keyPath, certPath, []byte("newer key"), []byte("newer cert")); err == nil {
+		t.Fatal("expected error replacing the certificate")
+	}
+	if data, err := ioutil.ReadFile(keyPath); err != nil || string(data) != "new key" {
+		t.Fatalf("expected the previous key to be restored, got %q: %v", data, err)
+	}
+
+	// No temporary files are left behind
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected only the key and certificate, got %d files", len(files))
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+// hookTimeout bounds how long an auth hook may run.
+const hookTimeout = 30 * time.Second
+
+// runHook runs an on_auth_failure or on_auth_success command, if configured,
+// and waits for it to finish. The number of consecutive failed attempts and
+// the last error are passed to it in the environment.
+func (ah *AuthHandler) runHook(ctx context.Context, name string, command []string, cause error) {
+	if len(command) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Env = append(os.Environ(), "VAULT_AGENT_AUTH_FAILED_ATTEMPTS="+strconv.Itoa(ah.failedAttempts))
+	if cause != nil {
+		cmd.Env = append(cmd.Env, "VAULT_AGENT_AUTH_ERROR="+cause.Error())
+	}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		ah.logger.Error("error running auth hook", "hook", name, "error", err, "output", string(out))
+		return
+	}
+	ah.logger.Debug("ran auth hook", "hook", name)
+}
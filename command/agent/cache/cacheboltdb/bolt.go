@@ -45,6 +45,9 @@ const (
 	// allowing us to correctly attach child contexts to their parent's context.
 	LeaseType = "lease"
 
+	// StaticSecretType - Bucket/type for cached static (non-leased) secrets
+	StaticSecretType = "static-secret"
+
 	// lookupType - v2 Bucket/type to map from a memcachedb index ID to an
 	// auto-incrementing BoltDB key. Facilitates deletes from the lease
 	// bucket using an ID instead of the auto-incrementing BoltDB key.
@@ -153,8 +156,10 @@ func createV1BoltSchema(tx *bolt.Tx) error {
 }
 
 func createV2BoltSchema(tx *bolt.Tx) error {
-	// Create the buckets for tokens and leases.
-	for _, bucket := range []string{TokenType, LeaseType, lookupType} {
+	// Create the buckets for tokens, leases and static secrets. The static
+	// secret bucket is created on open for existing v2 files as well, so it
+	// does not require a schema migration.
+	for _, bucket := range []string{TokenType, LeaseType, lookupType, StaticSecretType} {
 		if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
 			return fmt.Errorf("failed to create %s bucket: %w", bucket, err)
 		}
@@ -226,7 +231,7 @@ func autoIncrementedLeaseKey(tx *bolt.Tx, id string) ([]byte, error) {
 	return key, nil
 }
 
-// Set an index (token or lease) in bolt storage
+// Set an index (token, lease or static secret) in bolt storage
 func (b *BoltStorage) Set(ctx context.Context, id string, plaintext []byte, indexType string) error {
 	blob, err := b.wrapper.Encrypt(ctx, plaintext, wrapping.WithAad([]byte(b.aad)))
 	if err != nil {
@@ -256,6 +261,8 @@ func (b *BoltStorage) Set(ctx context.Context, id string, plaintext []byte, inde
 			if err := meta.Put([]byte(AutoAuthToken), protoBlob); err != nil {
 				return fmt.Errorf("failed to set latest auto-auth token: %w", err)
 			}
+		case StaticSecretType:
+			key = []byte(id)
 		default:
 			return fmt.Errorf("called Set for unsupported type %q", indexType)
 		}
@@ -267,7 +274,7 @@ func (b *BoltStorage) Set(ctx context.Context, id string, plaintext []byte, inde
 	})
 }
 
-// Delete an index (token or lease) by key from bolt storage
+// Delete an index (token, lease or static secret) by key from bolt storage
 func (b *BoltStorage) Delete(id string, indexType string) error {
 	return b.db.Update(func(tx *bolt.Tx) error {
 		key := []byte(id)
@@ -404,11 +411,11 @@ func (b *BoltStorage) Close() error {
 	return b.db.Close()
 }
 
-// Clear the boltdb by deleting all the token and lease buckets and recreating
-// the schema/layout
+// Clear the boltdb by deleting all the token, lease and static secret buckets
+// and recreating the schema/layout
 func (b *BoltStorage) Clear() error {
 	return b.db.Update(func(tx *bolt.Tx) error {
-		for _, name := range []string{TokenType, LeaseType, lookupType} {
+		for _, name := range []string{TokenType, LeaseType, lookupType, StaticSecretType} {
 			b.logger.Trace("deleting bolt bucket", "name", name)
 			if err := tx.DeleteBucket([]byte(name)); err != nil {
 				return err
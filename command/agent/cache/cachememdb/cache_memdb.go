@@ -115,6 +115,16 @@ func newDB() (*memdb.MemDB, error) {
 							Field: "Lease",
 						},
 					},
+					// This index enables fetching all the entries in cache of
+					// a given type.
+					IndexNameType: {
+						Name:         IndexNameType,
+						Unique:       false,
+						AllowMissing: true,
+						Indexer: &memdb.StringFieldIndex{
+							Field: "Type",
+						},
+					},
 				},
 			},
 		},
@@ -63,10 +63,21 @@ type Index struct {
 	// RequestHeader is the header used in the request
 	RequestHeader http.Header
 
+	// RequestQuery is the encoded query string of the request. It is only
+	// recorded for static secrets, which are refreshed by replaying the
+	// request.
+	RequestQuery string
+
+	// Tokens is the set of tokens that Vault has allowed to read the static
+	// secret held by this index. Cached static secrets are only served to
+	// these tokens.
+	// Required: false, Unique: false
+	Tokens map[string]struct{}
+
 	// LastRenewed is the timestamp of last renewal
 	LastRenewed time.Time
 
-	// Type is the index type (token, auth-lease, secret-lease)
+	// Type is the index type (token, lease, static-secret)
 	Type string
 }
 
@@ -93,6 +104,9 @@ const (
 
 	// IndexNameLeaseToken is the token that created the lease.
 	IndexNameLeaseToken = "lease_token"
+
+	// IndexNameType is the type of the index.
+	IndexNameType = "type"
 )
 
 func validIndexName(indexName string) bool {
@@ -104,6 +118,7 @@ func validIndexName(indexName string) bool {
 	case "token_accessor":
 	case "token_parent":
 	case "lease_token":
+	case "type":
 	default:
 		return false
 	}
@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/armon/go-metrics"
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/cryptoutil"
+)
+
+// coalesceHeaders are the request headers, besides the token, that can change
+// Vault's response to a read and so must match for requests to be coalesced.
+var coalesceHeaders = []string{
+	consts.NamespaceHeaderName,
+	vaulthttp.WrapFormatHeaderName,
+	vaulthttp.MFAHeaderName,
+	vaulthttp.PolicyOverrideHeaderName,
+	vaulthttp.VaultIndexHeaderName,
+	vaulthttp.VaultForwardHeaderName,
+	vaulthttp.VaultInconsistentHeaderName,
+}
+
+// CoalescingProxier is an implementation of the proxier interface that
+// coalesces identical concurrent GET requests, made with the same token, path,
+// parameters and relevant headers, into a single request to the underlying
+// proxier whose response is returned to all of them.
+type CoalescingProxier struct {
+	proxier Proxier
+	logger  hclog.Logger
+
+	l        sync.Mutex
+	inflight map[string]*coalescedRequest
+}
+
+var _ Proxier = &CoalescingProxier{}
+
+type CoalescingProxierConfig struct {
+	Proxier Proxier
+	Logger  hclog.Logger
+}
+
+// coalescedRequest is a request in flight to the underlying proxier. The
+// response and error are set before done is closed.
+type coalescedRequest struct {
+	done chan struct{}
+	resp *SendResponse
+	err  error
+
+	// canceled is set if the request failed because the context of the
+	// request that made it was canceled, in which case the waiting requests
+	// are sent on their own.
+	canceled bool
+}
+
+func NewCoalescingProxier(conf *CoalescingProxierConfig) (*CoalescingProxier, error) {
+	if conf == nil {
+		return nil, errors.New("nil configuration provided")
+	}
+
+	if conf.Proxier == nil || conf.Logger == nil {
+		return nil, fmt.Errorf("missing configuration required params: %v", conf)
+	}
+
+	return &CoalescingProxier{
+		proxier:  conf.Proxier,
+		logger:   conf.Logger,
+		inflight: make(map[string]*coalescedRequest),
+	}, nil
+}
+
+// Send passes the request to the underlying proxier, unless an identical GET
+// request is already in flight, in which case it waits for and returns a copy
+// of that request's response. Requests for a response-wrapped secret are never
+// coalesced, as a wrapping token can only be unwrapped once.
+func (p *CoalescingProxier) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	if req.Request.Method != http.MethodGet || req.Request.Header.Get(vaulthttp.WrapTTLHeaderName) != "" {
+		return p.proxier.Send(ctx, req)
+	}
+
+	key := coalesceKey(req)
+
+	p.l.Lock()
+	inflight, found := p.inflight[key]
+	if !found {
+		inflight = &coalescedRequest{
+			done: make(chan struct{}),
+		}
+		p.inflight[key] = inflight
+	}
+	p.l.Unlock()
+
+	if found {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-inflight.done:
+		}
+
+		if inflight.canceled {
+			return p.proxier.Send(ctx, req)
+		}
+
+		p.logger.Debug("returning coalesced response", "path", req.Request.URL.Path)
+		metrics.IncrCounter([]string{"agent", "cache", "coalesced"}, 1)
+		return inflight.resp.copy(), inflight.err
+	}
+
+	resp, err := p.proxier.Send(ctx, req)
+
+	p.l.Lock()
+	delete(p.inflight, key)
+	p.l.Unlock()
+
+	inflight.resp = resp
+	inflight.err = err
+	inflight.canceled = err != nil && ctx.Err() != nil
+	if resp != nil && resp.Response != nil && resp.ResponseBody == nil && resp.Response.Body != nil {
+		// Read the body so that it can be copied for the waiting requests.
+		body, readErr := ioutil.ReadAll(resp.Response.Body)
+		resp.Response.Body.Close()
+		resp.Response.Body = ioutil.NopCloser(bytes.NewReader(body))
+		resp.ResponseBody = body
+		if readErr != nil {
+			inflight.canceled = true
+		}
+	}
+	close(inflight.done)
+
+	// The response is shared with the waiting requests, so return a copy
+	// for this request to use.
+	return resp.copy(), err
+}
+
+// coalesceKey returns the key identifying identical requests.
+func coalesceKey(req *SendRequest) string {
+	var b bytes.Buffer
+	b.WriteString(req.Request.Method)
+	b.WriteByte(0)
+	b.WriteString(req.Request.URL.RequestURI())
+	b.WriteByte(0)
+	b.WriteString(req.Token)
+	for _, h := range coalesceHeaders {
+		b.WriteByte(0)
+		b.WriteString(h)
+		for _, v := range req.Request.Header.Values(h) {
+			b.WriteByte(0)
+			b.WriteString(v)
+		}
+	}
+
+	return hex.EncodeToString(cryptoutil.Blake2b256Hash(b.String()))
+}
+
+// copy returns a copy of the response that can be read and modified
+// independently of the original.
+func (r *SendResponse) copy() *SendResponse {
+	if r == nil {
+		return nil
+	}
+
+	c := &SendResponse{
+		ResponseBody: r.ResponseBody,
+	}
+	if r.CacheMeta != nil {
+		meta := *r.CacheMeta
+		c.CacheMeta = &meta
+	}
+	if r.Response != nil {
+		c.Response = &api.Response{}
+		if r.Response.Response != nil {
+			httpResp := *r.Response.Response
+			httpResp.Header = r.Response.Header.Clone()
+			httpResp.Body = ioutil.NopCloser(bytes.NewReader(r.ResponseBody))
+			c.Response.Response = &httpResp
+		}
+	}
+	return c
+}
@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"go.uber.org/atomic"
+)
+
+// mockBlockingProxier is a mock implementation of the Proxier interface that
+// blocks each request until released, returning a unique response for each.
+type mockBlockingProxier struct {
+	sent    *atomic.Int64
+	started chan struct{}
+	release chan struct{}
+}
+
+func newMockBlockingProxier() *mockBlockingProxier {
+	return &mockBlockingProxier{
+		sent:    atomic.NewInt64(0),
+		started: make(chan struct{}, 100),
+		release: make(chan struct{}),
+	}
+}
+
+func (p *mockBlockingProxier) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	n := p.sent.Inc()
+	p.started <- struct{}{}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-p.release:
+	}
+
+	return newTestSendResponse(http.StatusOK, fmt.Sprintf(`{"data": {"request": %d}}`, n)), nil
+}
+
+func testCoalescingProxier(t *testing.T, proxier Proxier) *CoalescingProxier {
+	t.Helper()
+
+	p, err := NewCoalescingProxier(&CoalescingProxierConfig{
+		Proxier: proxier,
+		Logger:  logging.NewVaultLogger(hclog.Trace).Named("coalesce"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func testSendRequest(method, path, token string) *SendRequest {
+	return &SendRequest{
+		Token:   token,
+		Request: httptest.NewRequest(method, path, nil),
+	}
+}
+
+func TestCoalescingProxier_Send(t *testing.T) {
+	mock := newMockBlockingProxier()
+	p := testCoalescingProxier(t, mock)
+
+	const waiters = 10
+	var wg sync.WaitGroup
+	bodies := make(chan string, waiters+1)
+	send := func() {
+		defer wg.Done()
+		resp, err := p.Send(context.Background(), testSendRequest(http.MethodGet, "/v1/database/creds/role?x=1", "token"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		body, err := ioutil.ReadAll(resp.Response.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		bodies <- string(body)
+	}
+
+	wg.Add(1)
+	go send()
+	<-mock.started
+
+	wg.Add(waiters)
+	for i := 0; i < waiters; i++ {
+		go send()
+	}
+
+	// Give the identical requests time to start waiting on the first one.
+	time.Sleep(100 * time.Millisecond)
+	close(mock.release)
+	wg.Wait()
+	close(bodies)
+
+	if n := mock.sent.Load(); n != 1 {
+		t.Fatalf("expected a single upstream request, got %d", n)
+	}
+	count := 0
+	for body := range bodies {
+		count++
+		if body != `{"data": {"request": 1}}` {
+			t.Fatalf("unexpected response body %q", body)
+		}
+	}
+	if count != waiters+1 {
+		t.Fatalf("expected %d responses, got %d", waiters+1, count)
+	}
+}
+
+func TestCoalescingProxier_NotCoalesced(t *testing.T) {
+	testCases := map[string][2]*SendRequest{
+		"different tokens": {
+			testSendRequest(http.MethodGet, "/v1/database/creds/role", "token1"),
+			testSendRequest(http.MethodGet, "/v1/database/creds/role", "token2"),
+		},
+		"different parameters": {
+			testSendRequest(http.MethodGet, "/v1/secret/foo?version=1", "token"),
+			testSendRequest(http.MethodGet, "/v1/secret/foo?version=2", "token"),
+		},
+		"different namespaces": {
+			func() *SendRequest {
+				req := testSendRequest(http.MethodGet, "/v1/secret/foo", "token")
+				req.Request.Header.Set("X-Vault-Namespace", "ns1")
+				return req
+			}(),
+			testSendRequest(http.MethodGet, "/v1/secret/foo", "token"),
+		},
+		"response wrapped": {
+			func() *SendRequest {
+				req := testSendRequest(http.MethodGet, "/v1/secret/foo", "token")
+				req.Request.Header.Set("X-Vault-Wrap-TTL", "5m")
+				return req
+			}(),
+			func() *SendRequest {
+				req := testSendRequest(http.MethodGet, "/v1/secret/foo", "token")
+				req.Request.Header.Set("X-Vault-Wrap-TTL", "5m")
+				return req
+			}(),
+		},
+		"writes": {
+			testSendRequest(http.MethodPost, "/v1/database/creds/role", "token"),
+			testSendRequest(http.MethodPost, "/v1/database/creds/role", "token"),
+		},
+	}
+
+	for name, reqs := range testCases {
+		t.Run(name, func(t *testing.T) {
+			mock := newMockBlockingProxier()
+			p := testCoalescingProxier(t, mock)
+
+			var wg sync.WaitGroup
+			for _, req := range reqs {
+				wg.Add(1)
+				go func(req *SendRequest) {
+					defer wg.Done()
+					if _, err := p.Send(context.Background(), req); err != nil {
+						t.Error(err)
+					}
+				}(req)
+				<-mock.started
+			}
+			close(mock.release)
+			wg.Wait()
+
+			if n := mock.sent.Load(); n != 2 {
+				t.Fatalf("expected 2 upstream requests, got %d", n)
+			}
+		})
+	}
+}
+
+func TestCoalescingProxier_Canceled(t *testing.T) {
+	mock := newMockBlockingProxier()
+	p := testCoalescingProxier(t, mock)
+
+	// The first request is canceled while the second waits on it, so the
+	// second is sent on its own.
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := p.Send(ctx, testSendRequest(http.MethodGet, "/v1/secret/foo", "token"))
+		firstErr <- err
+	}()
+	<-mock.started
+
+	secondErr := make(chan error, 1)
+	go func() {
+		_, err := p.Send(context.Background(), testSendRequest(http.MethodGet, "/v1/secret/foo", "token"))
+		secondErr <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Fatalf("expected the first request to be canceled, got %v", err)
+	}
+
+	<-mock.started
+	close(mock.release)
+	if err := <-secondErr; err != nil {
+		t.Fatal(err)
+	}
+	if n := mock.sent.Load(); n != 2 {
+		t.Fatalf("expected 2 upstream requests, got %d", n)
+	}
+}
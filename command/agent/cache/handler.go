@@ -10,6 +10,8 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -93,6 +95,62 @@ func Handler(ctx context.Context, logger hclog.Logger, proxier Proxier, inmemSin
 	})
 }
 
+// RestrictPaths wraps an http.Handler inside a Handler that rejects requests
+// for paths not matching allowedPaths, if any are given, or matching
+// deniedPaths with a 403 before they are proxied. Paths are relative to /v1/
+// and are prefixed with the namespace from the request header, if any. A
+// trailing "*" matches any path with the given prefix. Denied paths take
+// precedence over allowed paths.
+func RestrictPaths(logger hclog.Logger, handler http.Handler, allowedPaths, deniedPaths []string) http.Handler {
+	if len(allowedPaths) == 0 && len(deniedPaths) == 0 {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqPath := restrictedRequestPath(r)
+		if matchesPaths(deniedPaths, reqPath) || (len(allowedPaths) > 0 && !matchesPaths(allowedPaths, reqPath)) {
+			logger.Warn("rejecting request for restricted path", "method", r.Method, "path", reqPath)
+			metrics.IncrCounter([]string{"agent", "proxy", "denied"}, 1)
+			logical.RespondError(w, http.StatusForbidden, fmt.Errorf("path %q is not allowed on this listener", reqPath))
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// restrictedRequestPath returns the cleaned request path relative to /v1/,
+// including the namespace from the request header.
+func restrictedRequestPath(r *http.Request) string {
+	reqPath := path.Clean("/" + r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") && reqPath != "/" {
+		reqPath += "/"
+	}
+	reqPath = strings.TrimPrefix(reqPath, "/")
+	reqPath = strings.TrimPrefix(reqPath, "v1/")
+
+	if ns := strings.Trim(r.Header.Get(consts.NamespaceHeaderName), "/"); ns != "" {
+		reqPath = ns + "/" + reqPath
+	}
+	return reqPath
+}
+
+func matchesPaths(patterns []string, reqPath string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimLeft(pattern, "/")
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(reqPath, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+			continue
+		}
+		if reqPath == pattern {
+			return true
+		}
+	}
+	return false
+}
+
 // setHeaders is a helper that sets the header values based on SendResponse. It
 // copies over the headers from the original response and also includes any
 // cache-related headers.
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+)
+
+func TestRestrictPaths(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	logger := logging.NewVaultLogger(hclog.Trace)
+
+	handler := RestrictPaths(logger, next,
+		[]string{"secret/data/app/*", "/sys/health", "ns1/auth/token/lookup-self"},
+		[]string{"secret/data/app/admin*"})
+
+	tests := []struct {
+		path      string
+		namespace string
+		expected  int
+	}{
+		{"/v1/secret/data/app/config", "", http.StatusNoContent},
+		{"/v1/secret/metadata/app/config", "", http.StatusForbidden},
+		{"/v1/secret/data/app/admin", "", http.StatusForbidden},
+		{"/v1/secret/data/app/admin/users", "", http.StatusForbidden},
+		{"/v1/sys/health", "", http.StatusNoContent},
+		{"/v1/sys/health/", "", http.StatusForbidden},
+		{"/v1/sys/health/../seal", "", http.StatusForbidden},
+		{"/v1/secret/data/app/../../../sys/seal", "", http.StatusForbidden},
+		{"/v1/auth/token/lookup-self", "", http.StatusForbidden},
+		{"/v1/auth/token/lookup-self", "ns1/", http.StatusNoContent},
+		{"/v1/ns1/auth/token/lookup-self", "", http.StatusNoContent},
+		{"/v1/sys/health", "ns1", http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1"+tc.path, nil)
+		if tc.namespace != "" {
+			req.Header.Set(consts.NamespaceHeaderName, tc.namespace)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tc.expected {
+			t.Fatalf("%s (namespace %q): expected status %d, got %d", tc.path, tc.namespace, tc.expected, rr.Code)
+		}
+	}
+
+	// Without any paths configured, the handler is returned unchanged.
+	rr := httptest.NewRecorder()
+	RestrictPaths(logger, next, nil, nil).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://127.0.0.1/v1/sys/seal", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+}
@@ -0,0 +1,77 @@
+package keymanager
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	wrapping "github.com/hashicorp/go-kms-wrapping/v2"
+	"github.com/hashicorp/go-kms-wrapping/wrappers/aead/v2"
+)
+
+var _ KeyManager = (*FileKeyManager)(nil)
+
+// FileKeyManager uses a key read from a local file, such as one provisioned
+// by configuration management, as the encryption key. Since the key is read
+// from the file each time, no retrieval token is stored with the cache.
+type FileKeyManager struct {
+	wrapper *aead.Wrapper
+}
+
+// NewFileKeyManager returns a new instance of the file key manager. The file
+// must contain a 32 byte key, either as raw bytes or encoded as base64 or
+// hex.
+func NewFileKeyManager(ctx context.Context, path string) (*FileKeyManager, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	key, err := parseFileKey(contents)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key in %s: %w", path, err)
+	}
+
+	wrapper, err := newAEADWrapper(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileKeyManager{
+		wrapper: wrapper,
+	}, nil
+}
+
+func parseFileKey(contents []byte) ([]byte, error) {
+	if len(contents) == 32 {
+		return contents, nil
+	}
+
+	encoded := strings.TrimSpace(string(contents))
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == 32 {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("key should be 32 bytes, either raw or base64 or hex encoded")
+}
+
+// Wrapper returns the manager's wrapper for key operations.
+func (w *FileKeyManager) Wrapper() wrapping.Wrapper {
+	return w.wrapper
+}
+
+// RetrievalToken returns nil, as the key is sourced from the key file rather
+// than from the cache.
+func (w *FileKeyManager) RetrievalToken(ctx context.Context) ([]byte, error) {
+	if w.wrapper == nil {
+		return nil, fmt.Errorf("unable to get wrapper for token retrieval")
+	}
+
+	return nil, nil
+}
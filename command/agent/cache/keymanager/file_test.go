@@ -0,0 +1,54 @@
+package keymanager
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyManager_FileKeyManager(t *testing.T) {
+	key := []byte("e679e2f3d8d0e489d408bc617c6890d6")
+
+	tests := []struct {
+		name     string
+		contents []byte
+		wantErr  bool
+	}{
+		{"raw key", key, false},
+		{"base64 key", []byte(base64.StdEncoding.EncodeToString(key) + "\n"), false},
+		{"hex key", []byte(hex.EncodeToString(key)), false},
+		{"invalid key length", []byte("foobar"), true},
+	}
+
+	ctx := context.Background()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "key")
+			require.NoError(t, os.WriteFile(path, tc.contents, 0o600))
+
+			m, err := NewFileKeyManager(ctx, path)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			keyBytes, err := m.wrapper.KeyBytes(ctx)
+			require.NoError(t, err)
+			require.Equal(t, key, keyBytes)
+
+			// The key must never be stored alongside the cache
+			token, err := m.RetrievalToken(ctx)
+			require.NoError(t, err)
+			require.Empty(t, token)
+		})
+	}
+
+	_, err := NewFileKeyManager(ctx, filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
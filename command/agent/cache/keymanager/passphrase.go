@@ -0,0 +1,78 @@
+package keymanager
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	wrapping "github.com/hashicorp/go-kms-wrapping/v2"
+	"github.com/hashicorp/go-kms-wrapping/wrappers/aead/v2"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	passphraseSaltSize = 16
+
+	// Argon2id parameters, following the recommendations of RFC 9106 for
+	// memory constrained environments.
+	passphraseArgon2Time    = 3
+	passphraseArgon2Memory  = 64 * 1024
+	passphraseArgon2Threads = 4
+)
+
+var _ KeyManager = (*PassphraseKeyManager)(nil)
+
+// PassphraseKeyManager derives the encryption key from a passphrase using
+// Argon2id. The random salt is the retrieval token, so that the same key can
+// be derived again on restart.
+type PassphraseKeyManager struct {
+	wrapper *aead.Wrapper
+	salt    []byte
+}
+
+// NewPassphraseKeyManager returns a new instance of the passphrase key
+// manager. If a salt is provided the key is derived using it, otherwise a new
+// salt is generated.
+func NewPassphraseKeyManager(ctx context.Context, passphrase, salt []byte) (*PassphraseKeyManager, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+
+	switch len(salt) {
+	case 0:
+		salt = make([]byte, passphraseSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+	case passphraseSaltSize:
+	default:
+		return nil, fmt.Errorf("invalid salt size, should be %d, got %d", passphraseSaltSize, len(salt))
+	}
+
+	key := argon2.IDKey(passphrase, salt, passphraseArgon2Time, passphraseArgon2Memory, passphraseArgon2Threads, 32)
+
+	wrapper, err := newAEADWrapper(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PassphraseKeyManager{
+		wrapper: wrapper,
+		salt:    salt,
+	}, nil
+}
+
+// Wrapper returns the manager's wrapper for key operations.
+func (w *PassphraseKeyManager) Wrapper() wrapping.Wrapper {
+	return w.wrapper
+}
+
+// RetrievalToken returns the salt used to derive the key from the passphrase.
+func (w *PassphraseKeyManager) RetrievalToken(ctx context.Context) ([]byte, error) {
+	if w.wrapper == nil {
+		return nil, fmt.Errorf("unable to get wrapper for token retrieval")
+	}
+
+	return w.salt, nil
+}
@@ -0,0 +1,39 @@
+package keymanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyManager_PassphraseKeyManager(t *testing.T) {
+	ctx := context.Background()
+
+	m, err := NewPassphraseKeyManager(ctx, []byte("correct horse"), nil)
+	require.NoError(t, err)
+	salt, err := m.RetrievalToken(ctx)
+	require.NoError(t, err)
+	require.Len(t, salt, passphraseSaltSize)
+	key, err := m.wrapper.KeyBytes(ctx)
+	require.NoError(t, err)
+
+	// The same passphrase and salt derive the same key
+	restored, err := NewPassphraseKeyManager(ctx, []byte("correct horse"), salt)
+	require.NoError(t, err)
+	restoredKey, err := restored.wrapper.KeyBytes(ctx)
+	require.NoError(t, err)
+	require.Equal(t, key, restoredKey)
+
+	// A different passphrase does not
+	other, err := NewPassphraseKeyManager(ctx, []byte("battery staple"), salt)
+	require.NoError(t, err)
+	otherKey, err := other.wrapper.KeyBytes(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, key, otherKey)
+
+	_, err = NewPassphraseKeyManager(ctx, nil, nil)
+	require.Error(t, err)
+	_, err = NewPassphraseKeyManager(ctx, []byte("correct horse"), []byte("short"))
+	require.Error(t, err)
+}
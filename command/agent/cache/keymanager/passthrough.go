@@ -34,13 +34,8 @@ func NewPassthroughKeyManager(ctx context.Context, key []byte) (*PassthroughKeyM
 		return nil, fmt.Errorf("invalid key size, should be 32, got %d", len(key))
 	}
 
-	wrapper := aead.NewWrapper()
-
-	if _, err := wrapper.SetConfig(ctx, wrapping.WithConfigMap(map[string]string{"key_id": KeyID})); err != nil {
-		return nil, err
-	}
-
-	if err := wrapper.SetAesGcmKeyBytes(rootKey); err != nil {
+	wrapper, err := newAEADWrapper(ctx, rootKey)
+	if err != nil {
 		return nil, err
 	}
 
@@ -66,3 +61,18 @@ func (w *PassthroughKeyManager) RetrievalToken(ctx context.Context) ([]byte, err
 
 	return w.wrapper.KeyBytes(ctx)
 }
+
+// newAEADWrapper returns an AES-GCM wrapper using the given key.
+func newAEADWrapper(ctx context.Context, key []byte) (*aead.Wrapper, error) {
+	wrapper := aead.NewWrapper()
+
+	if _, err := wrapper.SetConfig(ctx, wrapping.WithConfigMap(map[string]string{"key_id": KeyID})); err != nil {
+		return nil, err
+	}
+
+	if err := wrapper.SetAesGcmKeyBytes(key); err != nil {
+		return nil, err
+	}
+
+	return wrapper, nil
+}
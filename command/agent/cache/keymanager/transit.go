@@ -0,0 +1,117 @@
+package keymanager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	wrapping "github.com/hashicorp/go-kms-wrapping/v2"
+	"github.com/hashicorp/go-kms-wrapping/wrappers/aead/v2"
+	"github.com/hashicorp/vault/api"
+)
+
+var _ KeyManager = (*TransitKeyManager)(nil)
+
+// TransitKeyManager protects a randomly generated encryption key with a
+// Vault transit key. The transit ciphertext of the key is the retrieval token,
+// and it can only be decrypted by a token allowed to use the transit key.
+type TransitKeyManager struct {
+	wrapper    *aead.Wrapper
+	ciphertext string
+}
+
+// TransitKeyManagerConfig is the configuration of a TransitKeyManager.
+type TransitKeyManagerConfig struct {
+	// Client is used to call transit and must have a token set
+	Client *api.Client
+
+	// MountPath is the path of the transit mount, defaulting to "transit"
+	MountPath string
+
+	// KeyName is the name of the transit key
+	KeyName string
+}
+
+// NewTransitKeyManager returns a new instance of the transit key manager. If a
+// retrieval token is provided, it is decrypted with transit to get the
+// encryption key, otherwise a new key is generated and encrypted.
+func NewTransitKeyManager(ctx context.Context, conf *TransitKeyManagerConfig, retrievalToken []byte) (*TransitKeyManager, error) {
+	if conf == nil || conf.Client == nil {
+		return nil, errors.New("nil API client")
+	}
+	if conf.KeyName == "" {
+		return nil, errors.New("transit key name not provided")
+	}
+
+	mountPath := strings.Trim(conf.MountPath, "/")
+	if mountPath == "" {
+		mountPath = "transit"
+	}
+
+	var key []byte
+	ciphertext := string(retrievalToken)
+	if ciphertext == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+
+		secret, err := conf.Client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/encrypt/%s", mountPath, conf.KeyName), map[string]interface{}{
+			"plaintext": base64.StdEncoding.EncodeToString(key),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt key with transit: %w", err)
+		}
+		if secret == nil || secret.Data == nil {
+			return nil, errors.New("empty response encrypting key with transit")
+		}
+		ciphertext, _ = secret.Data["ciphertext"].(string)
+		if ciphertext == "" {
+			return nil, errors.New("transit did not return a ciphertext")
+		}
+	} else {
+		secret, err := conf.Client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/decrypt/%s", mountPath, conf.KeyName), map[string]interface{}{
+			"ciphertext": ciphertext,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key with transit: %w", err)
+		}
+		if secret == nil || secret.Data == nil {
+			return nil, errors.New("empty response decrypting key with transit")
+		}
+		plaintext, _ := secret.Data["plaintext"].(string)
+		if key, err = base64.StdEncoding.DecodeString(plaintext); err != nil {
+			return nil, fmt.Errorf("failed to decode key decrypted with transit: %w", err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("invalid key size, should be 32, got %d", len(key))
+		}
+	}
+
+	wrapper, err := newAEADWrapper(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransitKeyManager{
+		wrapper:    wrapper,
+		ciphertext: ciphertext,
+	}, nil
+}
+
+// Wrapper returns the manager's wrapper for key operations.
+func (w *TransitKeyManager) Wrapper() wrapping.Wrapper {
+	return w.wrapper
+}
+
+// RetrievalToken returns the transit ciphertext of the encryption key.
+func (w *TransitKeyManager) RetrievalToken(ctx context.Context) ([]byte, error) {
+	if w.wrapper == nil {
+		return nil, fmt.Errorf("unable to get wrapper for token retrieval")
+	}
+
+	return []byte(w.ciphertext), nil
+}
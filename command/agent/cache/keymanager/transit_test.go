@@ -0,0 +1,86 @@
+package keymanager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTransitServer returns a server emulating the transit encrypt and
+// decrypt endpoints of the "agent" key on the "transit-agent" mount, by
+// prefixing plaintexts.
+func newTestTransitServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var data map[string]string
+		switch r.URL.Path {
+		case "/v1/transit-agent/encrypt/agent":
+			data = map[string]string{"ciphertext": "vault:v1:" + req["plaintext"]}
+		case "/v1/transit-agent/decrypt/agent":
+			if !strings.HasPrefix(req["ciphertext"], "vault:v1:") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			data = map[string]string{"plaintext": strings.TrimPrefix(req["ciphertext"], "vault:v1:")}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestKeyManager_TransitKeyManager(t *testing.T) {
+	server := newTestTransitServer(t)
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	client, err := api.NewClient(config)
+	require.NoError(t, err)
+	client.SetToken("test-token")
+
+	ctx := context.Background()
+	conf := &TransitKeyManagerConfig{
+		Client:    client,
+		MountPath: "transit-agent/",
+		KeyName:   "agent",
+	}
+
+	m, err := NewTransitKeyManager(ctx, conf, nil)
+	require.NoError(t, err)
+	token, err := m.RetrievalToken(ctx)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(token), "vault:v1:"))
+	key, err := m.wrapper.KeyBytes(ctx)
+	require.NoError(t, err)
+
+	restored, err := NewTransitKeyManager(ctx, conf, token)
+	require.NoError(t, err)
+	restoredKey, err := restored.wrapper.KeyBytes(ctx)
+	require.NoError(t, err)
+	require.Equal(t, key, restoredKey)
+
+	_, err = NewTransitKeyManager(ctx, conf, []byte("not-a-ciphertext"))
+	require.Error(t, err)
+
+	_, err = NewTransitKeyManager(ctx, &TransitKeyManagerConfig{Client: client}, nil)
+	require.Error(t, err)
+}
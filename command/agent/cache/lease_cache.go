@@ -95,6 +95,18 @@ type LeaseCache struct {
 	// shuttingDown is used to determine if cache needs to be evicted or not
 	// when the context is cancelled
 	shuttingDown atomic.Bool
+
+	// cacheStaticSecrets enables caching of static (non-leased) KV secrets
+	cacheStaticSecrets bool
+
+	// staticSecretRefreshInterval is how often cached static secrets are
+	// re-read from Vault
+	staticSecretRefreshInterval time.Duration
+
+	// staticSecretMounts holds the mounts, by namespace, that static secret
+	// requests were made against
+	staticSecretMounts     map[string][]*staticSecretMount
+	staticSecretMountsLock sync.RWMutex
 }
 
 // LeaseCacheConfig is the configuration for initializing a new
@@ -105,6 +117,12 @@ type LeaseCacheConfig struct {
 	Proxier     Proxier
 	Logger      hclog.Logger
 	Storage     *cacheboltdb.BoltStorage
+
+	// CacheStaticSecrets enables caching of KV secrets, which are not
+	// leased. StaticSecretRefreshInterval defaults to
+	// DefaultStaticSecretRefreshInterval.
+	CacheStaticSecrets          bool
+	StaticSecretRefreshInterval time.Duration
 }
 
 type inflightRequest struct {
@@ -146,16 +164,24 @@ func NewLeaseCache(conf *LeaseCacheConfig) (*LeaseCache, error) {
 	// Create a base context for the lease cache layer
 	baseCtxInfo := cachememdb.NewContextInfo(conf.BaseContext)
 
+	staticSecretRefreshInterval := conf.StaticSecretRefreshInterval
+	if staticSecretRefreshInterval == 0 {
+		staticSecretRefreshInterval = DefaultStaticSecretRefreshInterval
+	}
+
 	return &LeaseCache{
-		client:        conf.Client,
-		proxier:       conf.Proxier,
-		logger:        conf.Logger,
-		db:            db,
-		baseCtxInfo:   baseCtxInfo,
-		l:             &sync.RWMutex{},
-		idLocks:       locksutil.CreateLocks(),
-		inflightCache: gocache.New(gocache.NoExpiration, gocache.NoExpiration),
-		ps:            conf.Storage,
+		client:                      conf.Client,
+		proxier:                     conf.Proxier,
+		logger:                      conf.Logger,
+		db:                          db,
+		baseCtxInfo:                 baseCtxInfo,
+		l:                           &sync.RWMutex{},
+		idLocks:                     locksutil.CreateLocks(),
+		inflightCache:               gocache.New(gocache.NoExpiration, gocache.NoExpiration),
+		ps:                          conf.Storage,
+		cacheStaticSecrets:          conf.CacheStaticSecrets,
+		staticSecretRefreshInterval: staticSecretRefreshInterval,
+		staticSecretMounts:          make(map[string][]*staticSecretMount),
 	}, nil
 }
 
@@ -182,6 +208,11 @@ func (c *LeaseCache) checkCacheForRequest(id string) (*SendResponse, error) {
 		return nil, nil
 	}
 
+	return c.sendResponseFromIndex(index)
+}
+
+// sendResponseFromIndex deserializes the response held by a cached index.
+func (c *LeaseCache) sendResponseFromIndex(index *cachememdb.Index) (*SendResponse, error) {
 	// Cached request is found, deserialize the response
 	reader := bufio.NewReader(bytes.NewReader(index.Response))
 	resp, err := http.ReadResponse(reader, nil)
@@ -274,6 +305,20 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 		return cachedResp, nil
 	}
 
+	// Static secrets are cached independently of the token, so look them up
+	// separately
+	staticSecretID := c.staticSecretRequestID(req)
+	if staticSecretID != "" {
+		cachedResp, err := c.checkStaticSecretCache(staticSecretID, req.Token)
+		if err != nil {
+			return nil, err
+		}
+		if cachedResp != nil {
+			c.logger.Debug("returning cached static secret", "path", req.Request.URL.Path)
+			return cachedResp, nil
+		}
+	}
+
 	c.logger.Debug("forwarding request", "method", req.Request.Method, "path", req.Request.URL.Path)
 
 	// Pass the request down and get a response
@@ -282,6 +327,15 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 		return resp, err
 	}
 
+	// Evict the static secrets changed by a successful write. The write has
+	// already been made, so failing to do so doesn't fail the request;
+	// remaining entries are corrected on their next refresh.
+	if c.cacheStaticSecrets && isWriteRequest(req) && resp.Response.StatusCode < 300 {
+		if err := c.invalidateStaticSecrets(ctx, req); err != nil {
+			c.logger.Warn("failed to invalidate cached static secrets", "method", req.Request.Method, "path", req.Request.URL.Path, "error", err)
+		}
+	}
+
 	// If this is a non-2xx or if the returned response does not contain JSON payload,
 	// we skip caching
 	if resp.Response.StatusCode >= 300 || resp.Response.Header.Get("Content-Type") != "application/json" {
@@ -327,6 +381,11 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 		return resp, nil
 	}
 
+	// Static secrets carry neither a lease nor a token
+	if staticSecretID != "" && secret.LeaseID == "" && secret.Auth == nil && secret.WrapInfo == nil {
+		return c.cacheStaticSecret(ctx, req, resp, staticSecretID)
+	}
+
 	// Short-circuit if the secret is not renewable
 	tokenRenewable, err := secret.TokenIsRenewable()
 	if err != nil {
@@ -644,6 +703,10 @@ func (c *LeaseCache) handleCacheClear(ctx context.Context, in *cacheClearInput)
 			return errors.New("token not provided")
 		}
 
+		if err := c.removeStaticSecretToken(ctx, in.Token); err != nil {
+			return err
+		}
+
 		// Get the context for the given token and cancel its context
 		index, err := c.db.Get(cachememdb.IndexNameToken, in.Token)
 		if err != nil {
@@ -676,6 +739,10 @@ func (c *LeaseCache) handleCacheClear(ctx context.Context, in *cacheClearInput)
 
 		index.RenewCtxInfo.CancelFunc()
 
+		if err := c.removeStaticSecretToken(ctx, index.Token); err != nil {
+			return err
+		}
+
 	case "lease":
 		if in.Lease == "" {
 			return errors.New("lease not provided")
@@ -713,6 +780,7 @@ func (c *LeaseCache) handleCacheClear(ctx context.Context, in *cacheClearInput)
 		if err := c.Flush(); err != nil {
 			return err
 		}
+		c.resetStaticSecretMounts()
 
 	default:
 		return errInvalidType
@@ -808,6 +876,10 @@ func (c *LeaseCache) handleRevocationRequest(ctx context.Context, req *SendReque
 			return false, fmt.Errorf("expected token in the request body to be string")
 		}
 
+		if err := c.removeStaticSecretToken(ctx, token); err != nil {
+			return false, err
+		}
+
 		// Kill the lifetime watchers of all the leases attached to the revoked
 		// token
 		indexes, err := c.db.GetByPrefix(cachememdb.IndexNameLeaseToken, token)
@@ -1021,6 +1093,31 @@ func (c *LeaseCache) Restore(ctx context.Context, storage *cacheboltdb.BoltStora
 		}
 	}
 
+	// Finally restore static secrets, which are refreshed right away if
+	// their refresh interval has passed while the agent was down
+	if c.cacheStaticSecrets {
+		staticSecrets, err := storage.GetByType(ctx, cacheboltdb.StaticSecretType)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		} else {
+			for _, staticSecret := range staticSecrets {
+				newIndex, err := cachememdb.Deserialize(staticSecret)
+				if err != nil {
+					errs = multierror.Append(errs, err)
+					continue
+				}
+
+				c.setStaticSecretCtx(newIndex)
+				if err := c.db.Set(newIndex); err != nil {
+					errs = multierror.Append(errs, err)
+					continue
+				}
+				go c.refreshStaticSecret(newIndex.RenewCtxInfo.Ctx, newIndex)
+				c.logger.Trace("restored static secret", "id", newIndex.ID, "path", newIndex.RequestPath)
+			}
+		}
+	}
+
 	return errs.ErrorOrNil()
 }
 
@@ -0,0 +1,608 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/cache/cacheboltdb"
+	"github.com/hashicorp/vault/command/agent/cache/cachememdb"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/cryptoutil"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+)
+
+const (
+	// DefaultStaticSecretRefreshInterval is how often cached static secrets
+	// are re-read from Vault if no interval is configured.
+	DefaultStaticSecretRefreshInterval = 5 * time.Minute
+
+	vaultPathInternalUIMounts = "/v1/sys/internal/ui/mounts/"
+)
+
+// staticSecretMount holds what the agent knows about the mount a static
+// secret request was made against, as returned by the sys/internal/ui/mounts
+// preflight endpoint.
+type staticSecretMount struct {
+	// path is the mount path relative to the request namespace, including
+	// the trailing slash.
+	path string
+
+	// cacheable is true for KV mounts that have not set force_no_cache.
+	cacheable bool
+
+	// version is the KV version of the mount.
+	version int
+}
+
+// staticSecretRequestID returns the ID of the static secret index for the
+// request, or an empty string if the request is not a candidate for static
+// secret caching. Unlike computeIndexID, the ID does not include the token:
+// all tokens that are allowed to read a static secret share the same index.
+func (c *LeaseCache) staticSecretRequestID(req *SendRequest) string {
+	if !c.cacheStaticSecrets || req.Token == "" {
+		return ""
+	}
+
+	switch req.Request.Method {
+	case http.MethodGet, "LIST":
+	default:
+		return ""
+	}
+
+	// Wrapped responses are single use, so they are never served from the
+	// cache.
+	if req.Request.Header.Get(vaulthttp.WrapTTLHeaderName) != "" {
+		return ""
+	}
+
+	input := requestNamespace(req) + "\x00" + req.Request.URL.Path + "?" + staticSecretQuery(req)
+	return hex.EncodeToString(cryptoutil.Blake2b256Hash(input))
+}
+
+// staticSecretQuery returns the encoded query of a read request, treating a
+// LIST request the same as a GET request with list=true.
+func staticSecretQuery(req *SendRequest) string {
+	query := req.Request.URL.Query()
+	if req.Request.Method == "LIST" {
+		query.Set("list", "true")
+	}
+	return query.Encode()
+}
+
+// requestNamespace returns the namespace of the request header, defaulting to
+// "root/" since go-memdb skips over indexes that contain empty values.
+func requestNamespace(req *SendRequest) string {
+	namespace := req.Request.Header.Get(consts.NamespaceHeaderName)
+	if namespace == "" {
+		namespace = "root/"
+	}
+	return namespace
+}
+
+// checkStaticSecretCache returns the cached static secret for the given index
+// ID if the token has previously been allowed to read it.
+func (c *LeaseCache) checkStaticSecretCache(id, token string) (*SendResponse, error) {
+	index, err := c.db.Get(cachememdb.IndexNameID, id)
+	if err != nil {
+		return nil, err
+	}
+	if index == nil || index.Type != cacheboltdb.StaticSecretType {
+		return nil, nil
+	}
+
+	if _, ok := index.Tokens[token]; !ok {
+		return nil, nil
+	}
+
+	return c.sendResponseFromIndex(index)
+}
+
+// cacheStaticSecret stores the response to a static secret read, or adds the
+// request's token to the set of tokens allowed to read an already cached
+// secret. Responses for mounts that are not KV mounts are passed through.
+func (c *LeaseCache) cacheStaticSecret(ctx context.Context, req *SendRequest, resp *SendResponse, id string) (*SendResponse, error) {
+	mount, err := c.staticSecretMount(ctx, req)
+	if err != nil {
+		c.logger.Warn("unable to determine mount of static secret; not caching", "path", req.Request.URL.Path, "error", err)
+		return resp, nil
+	}
+	if !mount.cacheable {
+		c.logger.Debug("pass-through response; static secret not in a cacheable KV mount", "method", req.Request.Method, "path", req.Request.URL.Path)
+		return resp, nil
+	}
+
+	respBytes, err := serializeSendResponse(resp)
+	if err != nil {
+		c.logger.Error("failed to serialize response", "error", err)
+		return nil, err
+	}
+
+	idLock := locksutil.LockForKey(c.idLocks, id)
+	idLock.Lock()
+	defer idLock.Unlock()
+
+	existing, err := c.db.Get(cachememdb.IndexNameID, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		// Indexes are shared with concurrent readers, so update a copy
+		// rather than the cached index.
+		updated := *existing
+		updated.Tokens = copyTokens(existing.Tokens)
+		updated.Tokens[req.Token] = struct{}{}
+		updated.Response = respBytes
+		updated.LastRenewed = time.Now().UTC()
+
+		c.logger.Debug("updating static secret in the cache", "method", req.Request.Method, "path", req.Request.URL.Path)
+		if err := c.Set(ctx, &updated); err != nil {
+			c.logger.Error("failed to cache the static secret", "error", err)
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	// The token is tracked in Tokens, so it is not stored with the headers
+	// that are used to refresh the secret.
+	header := req.Request.Header.Clone()
+	header.Del(consts.AuthHeaderName)
+	header.Del("Authorization")
+
+	index := &cachememdb.Index{
+		ID:            id,
+		Namespace:     requestNamespace(req),
+		RequestPath:   req.Request.URL.Path,
+		RequestMethod: http.MethodGet,
+		RequestQuery:  staticSecretQuery(req),
+		RequestHeader: header,
+		Tokens:        map[string]struct{}{req.Token: {}},
+		Response:      respBytes,
+		LastRenewed:   time.Now().UTC(),
+		Type:          cacheboltdb.StaticSecretType,
+	}
+	c.setStaticSecretCtx(index)
+
+	c.logger.Debug("storing static secret into the cache", "method", req.Request.Method, "path", req.Request.URL.Path)
+	if err := c.Set(ctx, index); err != nil {
+		c.logger.Error("failed to cache the static secret", "error", err)
+		return nil, err
+	}
+
+	go c.refreshStaticSecret(index.RenewCtxInfo.Ctx, index)
+
+	return resp, nil
+}
+
+// setStaticSecretCtx derives the context of a static secret index from the
+// lease cache's base context. Cancelling it evicts the index.
+func (c *LeaseCache) setStaticSecretCtx(index *cachememdb.Index) {
+	ctxInfo := c.createCtxInfo(nil)
+	index.RenewCtxInfo = &cachememdb.ContextInfo{
+		Ctx:        context.WithValue(ctxInfo.Ctx, contextIndexID, index.ID),
+		CancelFunc: ctxInfo.CancelFunc,
+		DoneCh:     ctxInfo.DoneCh,
+	}
+}
+
+// refreshStaticSecret periodically re-reads a cached static secret with each
+// of the tokens allowed to read it, until the index's context is cancelled
+// or no token is able to read the secret any more.
+func (c *LeaseCache) refreshStaticSecret(ctx context.Context, index *cachememdb.Index) {
+	defer func() {
+		if c.shuttingDown.Load() {
+			c.logger.Trace("not evicting static secret from cache during shutdown", "id", index.ID, "path", index.RequestPath)
+			return
+		}
+		if err := c.evictStaticSecret(index); err != nil {
+			c.logger.Error("failed to evict static secret", "id", index.ID, "error", err)
+		}
+	}()
+
+	for {
+		current, err := c.db.Get(cachememdb.IndexNameID, index.ID)
+		if err != nil || current == nil || current.RenewCtxInfo != index.RenewCtxInfo {
+			return
+		}
+
+		timer := time.NewTimer(c.staticSecretRefreshInterval - time.Since(current.LastRenewed))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			c.logger.Debug("context cancelled; stopping static secret refresh", "path", index.RequestPath)
+			return
+		case <-index.RenewCtxInfo.DoneCh:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !c.updateStaticSecret(ctx, current) {
+			return
+		}
+	}
+}
+
+// updateStaticSecret re-reads the static secret held by the index with each
+// of its tokens. Tokens that are now denied are dropped from the index, and
+// the cached response is replaced with the latest one. It returns false if
+// the index should be evicted.
+func (c *LeaseCache) updateStaticSecret(ctx context.Context, index *cachememdb.Index) bool {
+	tokens := make([]string, 0, len(index.Tokens))
+	for token := range index.Tokens {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+
+	var respBytes []byte
+	allowed := make(map[string]struct{}, len(tokens))
+	for _, token := range tokens {
+		resp, err := c.proxier.Send(ctx, &SendRequest{
+			Token: token,
+			Request: &http.Request{
+				Method: index.RequestMethod,
+				URL: &url.URL{
+					Path:     index.RequestPath,
+					RawQuery: index.RequestQuery,
+				},
+				Header: index.RequestHeader.Clone(),
+			},
+		})
+
+		var status int
+		if resp != nil && resp.Response != nil {
+			status = resp.Response.StatusCode
+		}
+
+		switch {
+		case status == http.StatusForbidden || status == http.StatusUnauthorized:
+			c.logger.Debug("token no longer allowed to read static secret", "path", index.RequestPath)
+		case status == http.StatusNotFound:
+			c.logger.Debug("static secret no longer exists; evicting from cache", "path", index.RequestPath)
+			return false
+		case err != nil || status >= 300:
+			// Keep the token and the current response on transient errors,
+			// they are retried on the next refresh.
+			c.logger.Warn("failed to refresh static secret", "path", index.RequestPath, "status", status, "error", err)
+			allowed[token] = struct{}{}
+		default:
+			allowed[token] = struct{}{}
+			if respBytes == nil {
+				if respBytes, err = serializeSendResponse(resp); err != nil {
+					c.logger.Warn("failed to serialize refreshed static secret", "path", index.RequestPath, "error", err)
+				}
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return false
+	}
+
+	idLock := locksutil.LockForKey(c.idLocks, index.ID)
+	idLock.Lock()
+	defer idLock.Unlock()
+
+	current, err := c.db.Get(cachememdb.IndexNameID, index.ID)
+	if err != nil || current == nil || current.RenewCtxInfo != index.RenewCtxInfo {
+		return false
+	}
+
+	// Tokens added or removed while the secret was being refreshed are
+	// carried over as they are.
+	updated := *current
+	updated.Tokens = make(map[string]struct{}, len(current.Tokens))
+	for token := range current.Tokens {
+		if _, refreshed := index.Tokens[token]; !refreshed {
+			updated.Tokens[token] = struct{}{}
+			continue
+		}
+		if _, ok := allowed[token]; ok {
+			updated.Tokens[token] = struct{}{}
+		}
+	}
+	if len(updated.Tokens) == 0 {
+		c.logger.Debug("no tokens left that can read static secret; evicting from cache", "path", index.RequestPath)
+		return false
+	}
+	if respBytes != nil {
+		updated.Response = respBytes
+	}
+	updated.LastRenewed = time.Now().UTC()
+
+	if err := c.Set(ctx, &updated); err != nil {
+		c.logger.Error("failed to update refreshed static secret", "id", index.ID, "error", err)
+		return false
+	}
+	c.logger.Debug("static secret refreshed", "path", index.RequestPath)
+
+	return true
+}
+
+// evictStaticSecret evicts the index and stops its refresh, unless the index
+// has been replaced by a newer one in the meantime.
+func (c *LeaseCache) evictStaticSecret(index *cachememdb.Index) error {
+	idLock := locksutil.LockForKey(c.idLocks, index.ID)
+	idLock.Lock()
+	defer idLock.Unlock()
+
+	index.RenewCtxInfo.CancelFunc()
+
+	current, err := c.db.Get(cachememdb.IndexNameID, index.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.RenewCtxInfo != index.RenewCtxInfo {
+		return nil
+	}
+
+	c.logger.Debug("evicting static secret from cache", "id", index.ID, "path", index.RequestPath)
+	return c.Evict(current)
+}
+
+// invalidateStaticSecrets evicts the cached static secrets that may have been
+// changed by a successful write proxied by the agent. On KV v2 mounts, a
+// write to any of data/, metadata/, delete/, undelete/ or destroy/ for a key
+// evicts all cached reads of that key, along with the cached lists of its
+// parent directories. Writes elsewhere in the mount, such as to config,
+// evict every cached secret of the mount.
+func (c *LeaseCache) invalidateStaticSecrets(ctx context.Context, req *SendRequest) error {
+	namespace := requestNamespace(req)
+
+	// Avoid looking up the mount of every write when nothing is cached for
+	// the namespace.
+	cached, err := c.db.GetByPrefix(cachememdb.IndexNameRequestPath, namespace, "/v1/")
+	if err != nil {
+		return err
+	}
+	if !containsStaticSecrets(cached) {
+		return nil
+	}
+
+	mount, err := c.staticSecretMount(ctx, req)
+	if err != nil {
+		return err
+	}
+	if !mount.cacheable {
+		return nil
+	}
+
+	writtenKey, isKey := kvSecretKey(mount, strings.TrimPrefix(req.Request.URL.Path, "/v1/"))
+
+	indexes, err := c.db.GetByPrefix(cachememdb.IndexNameRequestPath, namespace, "/v1/"+mount.path)
+	if err != nil {
+		return err
+	}
+	for _, index := range indexes {
+		if index.Type != cacheboltdb.StaticSecretType {
+			continue
+		}
+
+		if isKey {
+			key, ok := kvSecretKey(mount, strings.TrimPrefix(index.RequestPath, "/v1/"))
+			if ok && key != writtenKey && !(isListQuery(index.RequestQuery) && isParentKey(key, writtenKey)) {
+				continue
+			}
+		}
+
+		c.logger.Debug("invalidating static secret after write", "path", index.RequestPath, "write_path", req.Request.URL.Path)
+		if err := c.evictStaticSecret(index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeStaticSecretToken removes a revoked token from every cached static
+// secret, evicting secrets that no remaining token can read.
+func (c *LeaseCache) removeStaticSecretToken(ctx context.Context, token string) error {
+	indexes, err := c.db.GetByPrefix(cachememdb.IndexNameType, cacheboltdb.StaticSecretType)
+	if err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		if _, ok := index.Tokens[token]; !ok {
+			continue
+		}
+
+		if len(index.Tokens) == 1 {
+			if err := c.evictStaticSecret(index); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := c.updateStaticSecretTokens(ctx, index, token); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *LeaseCache) updateStaticSecretTokens(ctx context.Context, index *cachememdb.Index, removedToken string) error {
+	idLock := locksutil.LockForKey(c.idLocks, index.ID)
+	idLock.Lock()
+	defer idLock.Unlock()
+
+	current, err := c.db.Get(cachememdb.IndexNameID, index.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return nil
+	}
+
+	updated := *current
+	updated.Tokens = copyTokens(current.Tokens)
+	delete(updated.Tokens, removedToken)
+
+	return c.Set(ctx, &updated)
+}
+
+// staticSecretMount returns the mount the request was made against, looking
+// it up with the sys/internal/ui/mounts preflight endpoint using the
+// request's token if it is not known yet.
+func (c *LeaseCache) staticSecretMount(ctx context.Context, req *SendRequest) (*staticSecretMount, error) {
+	namespace := requestNamespace(req)
+	path := strings.TrimPrefix(req.Request.URL.Path, "/v1/")
+
+	c.staticSecretMountsLock.RLock()
+	for _, mount := range c.staticSecretMounts[namespace] {
+		if strings.HasPrefix(path, mount.path) {
+			c.staticSecretMountsLock.RUnlock()
+			return mount, nil
+		}
+	}
+	c.staticSecretMountsLock.RUnlock()
+
+	header := http.Header{}
+	if ns := req.Request.Header.Get(consts.NamespaceHeaderName); ns != "" {
+		header.Set(consts.NamespaceHeaderName, ns)
+	}
+
+	resp, err := c.proxier.Send(ctx, &SendRequest{
+		Token: req.Token,
+		Request: &http.Request{
+			Method: http.MethodGet,
+			URL: &url.URL{
+				Path: vaultPathInternalUIMounts + path,
+			},
+			Header: header,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mount preflight request failed: %w", err)
+	}
+	if resp.Response.StatusCode >= 300 {
+		return nil, fmt.Errorf("mount preflight request returned %d", resp.Response.StatusCode)
+	}
+
+	secret, err := api.ParseSecret(bytes.NewReader(resp.ResponseBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mount preflight response: %w", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, fmt.Errorf("empty mount preflight response")
+	}
+
+	mountPath, _ := secret.Data["path"].(string)
+	if mountPath == "" || !strings.HasPrefix(path, strings.TrimSuffix(mountPath, "/")) {
+		return nil, fmt.Errorf("unexpected mount path %q in mount preflight response", mountPath)
+	}
+	if !strings.HasSuffix(mountPath, "/") {
+		mountPath += "/"
+	}
+
+	mount := &staticSecretMount{
+		path:    mountPath,
+		version: 1,
+	}
+	if mountType, _ := secret.Data["type"].(string); mountType == "kv" || mountType == "generic" {
+		mount.cacheable = true
+	}
+	if options, ok := secret.Data["options"].(map[string]interface{}); ok && options["version"] == "2" {
+		mount.version = 2
+	}
+	if config, ok := secret.Data["config"].(map[string]interface{}); ok && config["force_no_cache"] == true {
+		mount.cacheable = false
+	}
+
+	c.staticSecretMountsLock.Lock()
+	defer c.staticSecretMountsLock.Unlock()
+	for _, existing := range c.staticSecretMounts[namespace] {
+		if existing.path == mount.path {
+			return existing, nil
+		}
+	}
+	c.staticSecretMounts[namespace] = append(c.staticSecretMounts[namespace], mount)
+
+	return mount, nil
+}
+
+// resetStaticSecretMounts forgets all known mounts, so that they are looked
+// up again on the next static secret request.
+func (c *LeaseCache) resetStaticSecretMounts() {
+	c.staticSecretMountsLock.Lock()
+	defer c.staticSecretMountsLock.Unlock()
+	c.staticSecretMounts = make(map[string][]*staticSecretMount)
+}
+
+// kvSecretKey returns the key within the KV mount that a path refers to. It
+// returns false for KV v2 paths that do not refer to a key, such as config.
+func kvSecretKey(mount *staticSecretMount, path string) (string, bool) {
+	rest := strings.TrimPrefix(path, mount.path)
+	if mount.version < 2 {
+		return rest, true
+	}
+
+	op, key, _ := strings.Cut(rest, "/")
+	switch op {
+	case "data", "metadata", "delete", "undelete", "destroy", "subkeys":
+		return key, true
+	}
+	return "", false
+}
+
+// isParentKey returns true if dir is a directory containing key.
+func isParentKey(dir, key string) bool {
+	dir = strings.TrimSuffix(dir, "/")
+	return dir == "" || strings.HasPrefix(key, dir+"/")
+}
+
+func isListQuery(rawQuery string) bool {
+	query, err := url.ParseQuery(rawQuery)
+	return err == nil && query.Get("list") == "true"
+}
+
+func isWriteRequest(req *SendRequest) bool {
+	switch req.Request.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func containsStaticSecrets(indexes []*cachememdb.Index) bool {
+	for _, index := range indexes {
+		if index.Type == cacheboltdb.StaticSecretType {
+			return true
+		}
+	}
+	return false
+}
+
+func copyTokens(tokens map[string]struct{}) map[string]struct{} {
+	copied := make(map[string]struct{}, len(tokens)+1)
+	for token := range tokens {
+		copied[token] = struct{}{}
+	}
+	return copied
+}
+
+// serializeSendResponse serializes the response for storage in an index and
+// resets its body for upper layers to read.
+func serializeSendResponse(resp *SendResponse) ([]byte, error) {
+	var respBytes bytes.Buffer
+	if err := resp.Response.Write(&respBytes); err != nil {
+		return nil, err
+	}
+
+	if resp.Response.Body != nil {
+		resp.Response.Body.Close()
+	}
+	resp.Response.Body = ioutil.NopCloser(bytes.NewReader(resp.ResponseBody))
+
+	return respBytes.Bytes(), nil
+}
@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/cache/cacheboltdb"
+	"github.com/hashicorp/vault/command/agent/cache/cachememdb"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewStaticSecretLeaseCache(t *testing.T, proxier Proxier, refreshInterval time.Duration, storage *cacheboltdb.BoltStorage) *LeaseCache {
+	t.Helper()
+
+	client, err := api.NewClient(api.DefaultConfig())
+	require.NoError(t, err)
+
+	lc, err := NewLeaseCache(&LeaseCacheConfig{
+		Client:                      client,
+		BaseContext:                 context.Background(),
+		Proxier:                     proxier,
+		Logger:                      logging.NewVaultLogger(hclog.Trace).Named("cache.leasecache"),
+		Storage:                     storage,
+		CacheStaticSecrets:          true,
+		StaticSecretRefreshInterval: refreshInterval,
+	})
+	require.NoError(t, err)
+	return lc
+}
+
+// sendStaticSecretRequest sends a request through the lease cache and returns
+// the response body, the status code and whether it was served from the
+// cache.
+func sendStaticSecretRequest(t *testing.T, lc *LeaseCache, token, method, path, body string) (string, int, bool) {
+	t.Helper()
+
+	req := &SendRequest{
+		Token:       token,
+		Request:     httptest.NewRequest(method, "http://example.com/v1/"+path, strings.NewReader(body)),
+		RequestBody: []byte(body),
+	}
+	resp, err := lc.Send(context.Background(), req)
+	if resp == nil {
+		require.NoError(t, err)
+	}
+
+	var respBody []byte
+	if resp.Response.Body != nil {
+		respBody, err = ioutil.ReadAll(resp.Response.Body)
+		require.NoError(t, err)
+	}
+	return string(respBody), resp.Response.StatusCode, resp.CacheMeta != nil && resp.CacheMeta.Hit
+}
+
+func TestLeaseCache_StaticSecrets(t *testing.T) {
+	proxier := newMockKVProxier("token1", "token2")
+	proxier.setSecret("app/config", "one")
+	lc := testNewStaticSecretLeaseCache(t, proxier, time.Hour, nil)
+
+	// The first read is proxied and cached, the second is served from the
+	// cache.
+	body, _, hit := sendStaticSecretRequest(t, lc, "token1", http.MethodGet, "secret/data/app/config", "")
+	require.Contains(t, body, `"one"`)
+	require.False(t, hit)
+	body, _, hit = sendStaticSecretRequest(t, lc, "token1", http.MethodGet, "secret/data/app/config", "")
+	require.Contains(t, body, `"one"`)
+	require.True(t, hit)
+	require.Equal(t, 1, proxier.requestCount(http.MethodGet, "secret/data/app/config"))
+
+	// Another token has to be allowed by Vault before it is served from the
+	// cache.
+	_, _, hit = sendStaticSecretRequest(t, lc, "token2", http.MethodGet, "secret/data/app/config", "")
+	require.False(t, hit)
+	_, _, hit = sendStaticSecretRequest(t, lc, "token2", http.MethodGet, "secret/data/app/config", "")
+	require.True(t, hit)
+	require.Equal(t, 2, proxier.requestCount(http.MethodGet, "secret/data/app/config"))
+
+	// A token that is denied is never served from the cache.
+	_, status, hit := sendStaticSecretRequest(t, lc, "token3", http.MethodGet, "secret/data/app/config", "")
+	require.Equal(t, http.StatusForbidden, status)
+	require.False(t, hit)
+	require.Equal(t, 3, proxier.requestCount(http.MethodGet, "secret/data/app/config"))
+
+	indexes, err := lc.db.GetByPrefix(cachememdb.IndexNameType, cacheboltdb.StaticSecretType)
+	require.NoError(t, err)
+	require.Len(t, indexes, 1)
+	require.Equal(t, map[string]struct{}{"token1": {}, "token2": {}}, indexes[0].Tokens)
+
+	// Lists are cached as well.
+	body, _, hit = sendStaticSecretRequest(t, lc, "token1", "LIST", "secret/metadata/app/", "")
+	require.Contains(t, body, `"config"`)
+	require.False(t, hit)
+	_, _, hit = sendStaticSecretRequest(t, lc, "token1", http.MethodGet, "secret/metadata/app/?list=true", "")
+	require.True(t, hit)
+
+	// A write through the agent invalidates the cached reads of the key and
+	// the lists of its parent directories.
+	_, status, _ = sendStaticSecretRequest(t, lc, "token1", http.MethodPost, "secret/data/app/other", `{"data": {"value": "other"}}`)
+	require.Equal(t, http.StatusNoContent, status)
+	_, _, hit = sendStaticSecretRequest(t, lc, "token1", http.MethodGet, "secret/data/app/config", "")
+	require.True(t, hit)
+	body, _, hit = sendStaticSecretRequest(t, lc, "token1", "LIST", "secret/metadata/app/", "")
+	require.Contains(t, body, `"other"`)
+	require.False(t, hit)
+
+	_, status, _ = sendStaticSecretRequest(t, lc, "token1", http.MethodPost, "secret/data/app/config", `{"data": {"value": "two"}}`)
+	require.Equal(t, http.StatusNoContent, status)
+	body, _, hit = sendStaticSecretRequest(t, lc, "token1", http.MethodGet, "secret/data/app/config", "")
+	require.Contains(t, body, `"two"`)
+	require.False(t, hit)
+
+	// Revoking a token through the agent removes it from the cached secrets.
+	require.NoError(t, lc.handleCacheClear(context.Background(), &cacheClearInput{
+		Type:  "token",
+		Token: "token1",
+	}))
+	index, err := lc.db.Get(cachememdb.IndexNameID, indexes[0].ID)
+	require.NoError(t, err)
+	require.Nil(t, index)
+}
+
+func TestLeaseCache_StaticSecrets_Disabled(t *testing.T) {
+	proxier := newMockKVProxier("token1")
+	proxier.setSecret("app/config", "one")
+
+	client, err := api.NewClient(api.DefaultConfig())
+	require.NoError(t, err)
+	lc, err := NewLeaseCache(&LeaseCacheConfig{
+		Client:      client,
+		BaseContext: context.Background(),
+		Proxier:     proxier,
+		Logger:      logging.NewVaultLogger(hclog.Trace).Named("cache.leasecache"),
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, _, hit := sendStaticSecretRequest(t, lc, "token1", http.MethodGet, "secret/data/app/config", "")
+		require.False(t, hit)
+	}
+	require.Equal(t, 2, proxier.requestCount(http.MethodGet, "secret/data/app/config"))
+}
+
+func TestLeaseCache_StaticSecrets_NonKVMount(t *testing.T) {
+	proxier := newMockKVProxier("token1")
+	lc := testNewStaticSecretLeaseCache(t, proxier, time.Hour, nil)
+
+	for i := 0; i < 2; i++ {
+		_, _, hit := sendStaticSecretRequest(t, lc, "token1", "LIST", "sys/policies/acl", "")
+		require.False(t, hit)
+	}
+	require.Equal(t, 2, proxier.requestCount("LIST", "sys/policies/acl"))
+}
+
+func TestLeaseCache_StaticSecrets_Refresh(t *testing.T) {
+	proxier := newMockKVProxier("token1", "token2")
+	proxier.setSecret("app/config", "one")
+	lc := testNewStaticSecretLeaseCache(t, proxier, 50*time.Millisecond, nil)
+
+	sendStaticSecretRequest(t, lc, "token1", http.MethodGet, "secret/data/app/config", "")
+	sendStaticSecretRequest(t, lc, "token2", http.MethodGet, "secret/data/app/config", "")
+
+	// Changes made outside of the agent and tokens losing access are picked
+	// up on refresh.
+	proxier.setSecret("app/config", "changed")
+	proxier.setTokenAllowed("token2", false)
+
+	require.Eventually(t, func() bool {
+		indexes, err := lc.db.GetByPrefix(cachememdb.IndexNameType, cacheboltdb.StaticSecretType)
+		if err != nil || len(indexes) != 1 || len(indexes[0].Tokens) != 1 {
+			return false
+		}
+		body, _, hit := sendStaticSecretRequest(t, lc, "token1", http.MethodGet, "secret/data/app/config", "")
+		return hit && strings.Contains(body, `"changed"`)
+	}, 5*time.Second, 20*time.Millisecond)
+
+	_, _, hit := sendStaticSecretRequest(t, lc, "token2", http.MethodGet, "secret/data/app/config", "")
+	require.False(t, hit)
+
+	// Once no token is allowed to read the secret, it is evicted.
+	proxier.setTokenAllowed("token1", false)
+	require.Eventually(t, func() bool {
+		indexes, err := lc.db.GetByPrefix(cachememdb.IndexNameType, cacheboltdb.StaticSecretType)
+		return err == nil && len(indexes) == 0
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestLeaseCache_StaticSecrets_PersistAndRestore(t *testing.T) {
+	tempDir, boltStorage := setupBoltStorage(t)
+	defer os.RemoveAll(tempDir)
+	defer boltStorage.Close()
+
+	proxier := newMockKVProxier("token1")
+	proxier.setSecret("app/config", "one")
+	lc := testNewStaticSecretLeaseCache(t, proxier, time.Hour, boltStorage)
+
+	_, _, hit := sendStaticSecretRequest(t, lc, "token1", http.MethodGet, "secret/data/app/config", "")
+	require.False(t, hit)
+
+	restoredProxier := newMockKVProxier("token1")
+	restored := testNewStaticSecretLeaseCache(t, restoredProxier, time.Hour, boltStorage)
+	require.NoError(t, restored.Restore(context.Background(), boltStorage))
+
+	body, _, hit := sendStaticSecretRequest(t, restored, "token1", http.MethodGet, "secret/data/app/config", "")
+	require.True(t, hit)
+	require.Contains(t, body, `"one"`)
+	require.Equal(t, 0, restoredProxier.requestCount(http.MethodGet, "secret/data/app/config"))
+
+	// Writes after a restore still invalidate the restored secrets.
+	sendStaticSecretRequest(t, restored, "token1", http.MethodPost, "secret/data/app/config", `{"data": {"value": "two"}}`)
+	body, _, hit = sendStaticSecretRequest(t, restored, "token1", http.MethodGet, "secret/data/app/config", "")
+	require.False(t, hit)
+	require.Contains(t, body, `"two"`)
+}
@@ -7,7 +7,9 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/vault/api"
@@ -105,3 +107,108 @@ func (p *mockDelayProxier) Send(ctx context.Context, req *SendRequest) (*SendRes
 
 	return newTestSendResponse(http.StatusOK, `{"value": "output"}`), nil
 }
+
+// mockKVProxier is a mock implementation of the Proxier interface that
+// emulates a KV v2 mount at "secret/", for testing static secret caching. It
+// answers mount preflight requests, reads and lists for the tokens in
+// allowedTokens, and stores the body of writes.
+type mockKVProxier struct {
+	l             sync.Mutex
+	secrets       map[string]string
+	allowedTokens map[string]bool
+	requests      []string
+}
+
+func newMockKVProxier(tokens ...string) *mockKVProxier {
+	p := &mockKVProxier{
+		secrets:       make(map[string]string),
+		allowedTokens: make(map[string]bool),
+	}
+	for _, token := range tokens {
+		p.allowedTokens[token] = true
+	}
+	return p
+}
+
+func (p *mockKVProxier) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	path := strings.TrimPrefix(req.Request.URL.Path, "/v1/")
+	p.requests = append(p.requests, req.Request.Method+" "+path)
+
+	if !p.allowedTokens[req.Token] {
+		return newTestSendResponse(http.StatusForbidden, `{"errors": ["permission denied"]}`), fmt.Errorf("permission denied")
+	}
+
+	if strings.HasPrefix(path, "sys/internal/ui/mounts/") {
+		mount := strings.SplitN(strings.TrimPrefix(path, "sys/internal/ui/mounts/"), "/", 2)[0]
+		if mount == "secret" {
+			return newTestSendResponse(http.StatusOK, `{"data": {"path": "secret/", "type": "kv", "options": {"version": "2"}}}`), nil
+		}
+		return newTestSendResponse(http.StatusOK, `{"data": {"path": "`+mount+`/", "type": "system"}}`), nil
+	}
+
+	key := strings.TrimPrefix(strings.TrimPrefix(path, "secret/data/"), "secret/metadata/")
+	switch req.Request.Method {
+	case http.MethodGet, "LIST":
+		if req.Request.Method == "LIST" || req.Request.URL.Query().Get("list") == "true" {
+			var keys []string
+			for k := range p.secrets {
+				if strings.HasPrefix(k, key) {
+					keys = append(keys, `"`+strings.TrimPrefix(k, key)+`"`)
+				}
+			}
+			sort.Strings(keys)
+			return newTestSendResponse(http.StatusOK, `{"data": {"keys": [`+strings.Join(keys, ",")+`]}}`), nil
+		}
+		value, ok := p.secrets[key]
+		if !ok {
+			return newTestSendResponse(http.StatusNotFound, `{"errors": []}`), fmt.Errorf("not found")
+		}
+		return newTestSendResponse(http.StatusOK, `{"data": {"data": {"value": "`+value+`"}, "metadata": {}}}`), nil
+	case http.MethodPost, http.MethodPut:
+		var body struct {
+			Data map[string]string `json:"data"`
+		}
+		if err := json.Unmarshal(req.RequestBody, &body); err != nil {
+			return newTestSendResponse(http.StatusBadRequest, `{"errors": ["invalid body"]}`), err
+		}
+		p.secrets[key] = body.Data["value"]
+		return newTestSendResponse(http.StatusNoContent, ""), nil
+	case http.MethodDelete:
+		delete(p.secrets, key)
+		return newTestSendResponse(http.StatusNoContent, ""), nil
+	}
+
+	return newTestSendResponse(http.StatusMethodNotAllowed, `{"errors": []}`), fmt.Errorf("unsupported method")
+}
+
+// setSecret sets the value of a secret without going through the cache.
+func (p *mockKVProxier) setSecret(key, value string) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	p.secrets[key] = value
+}
+
+// setTokenAllowed allows or denies a token without going through the cache.
+func (p *mockKVProxier) setTokenAllowed(token string, allowed bool) {
+	p.l.Lock()
+	defer p.l.Unlock()
+	p.allowedTokens[token] = allowed
+}
+
+// requestCount returns the number of requests made for the given path,
+// ignoring mount preflight requests.
+func (p *mockKVProxier) requestCount(method, path string) int {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	var count int
+	for _, r := range p.requests {
+		if r == method+" "+path {
+			count++
+		}
+	}
+	return count
+}
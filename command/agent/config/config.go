@@ -91,24 +91,66 @@ type transportDialer interface {
 
 // Cache contains any configuration needed for Cache mode
 type Cache struct {
-	UseAutoAuthTokenRaw interface{}     `hcl:"use_auto_auth_token"`
-	UseAutoAuthToken    bool            `hcl:"-"`
-	ForceAutoAuthToken  bool            `hcl:"-"`
-	EnforceConsistency  string          `hcl:"enforce_consistency"`
-	WhenInconsistent    string          `hcl:"when_inconsistent"`
-	Persist             *Persist        `hcl:"persist"`
-	InProcDialer        transportDialer `hcl:"-"`
+	UseAutoAuthTokenRaw            interface{}     `hcl:"use_auto_auth_token"`
+	UseAutoAuthToken               bool            `hcl:"-"`
+	ForceAutoAuthToken             bool            `hcl:"-"`
+	EnforceConsistency             string          `hcl:"enforce_consistency"`
+	WhenInconsistent               string          `hcl:"when_inconsistent"`
+	CacheStaticSecretsRaw          interface{}     `hcl:"cache_static_secrets"`
+	CacheStaticSecrets             bool            `hcl:"-"`
+	StaticSecretRefreshIntervalRaw interface{}     `hcl:"static_secret_refresh_interval"`
+	StaticSecretRefreshInterval    time.Duration   `hcl:"-"`
+	CoalesceRequestsRaw            interface{}     `hcl:"coalesce_requests"`
+	CoalesceRequests               bool            `hcl:"-"`
+	Persist                        *Persist        `hcl:"persist"`
+	InProcDialer                   transportDialer `hcl:"-"`
 }
 
-// Persist contains configuration needed for persistent caching
+// Persist contains configuration needed for persistent caching. Type selects
+// how the encryption key of the cache file is protected.
 type Persist struct {
 	Type                    string
 	Path                    string `hcl:"path"`
 	KeepAfterImport         bool   `hcl:"keep_after_import"`
 	ExitOnErr               bool   `hcl:"exit_on_err"`
 	ServiceAccountTokenFile string `hcl:"service_account_token_file"`
+
+	// KeyFile holds the encryption key for the "file" type
+	KeyFile string `hcl:"key_file"`
+
+	// TransitMount and TransitKey name the transit key used with the
+	// auto-auth token to protect the encryption key for the "transit" type
+	TransitMount string `hcl:"transit_mount"`
+	TransitKey   string `hcl:"transit_key"`
+
+	// PassphraseFile holds the passphrase the encryption key is derived
+	// from for the "passphrase" type
+	PassphraseFile string `hcl:"passphrase_file"`
 }
 
+const (
+	PersistTypeKubernetes = "kubernetes"
+	PersistTypeFile       = "file"
+	PersistTypeTransit    = "transit"
+	PersistTypePassphrase = "passphrase"
+)
+
+// Listener roles restrict what an agent listener serves. A listener without a
+// role serves everything, as with ListenerRoleDefault.
+const (
+	// ListenerRoleDefault proxies requests, using the auto-auth token if
+	// configured, and serves all of the agent API.
+	ListenerRoleDefault = "default"
+
+	// ListenerRoleCacheOnly proxies requests through the cache, but only with
+	// the token supplied by the client, and only serves the cache clear
+	// endpoint of the agent API.
+	ListenerRoleCacheOnly = "cache_only"
+
+	// ListenerRoleMetricsOnly only serves the metrics endpoint.
+	ListenerRoleMetricsOnly = "metrics_only"
+)
+
 // AutoAuth is the configured authentication method and sinks
 type AutoAuth struct {
 	Method *Method `hcl:"-"`
@@ -130,9 +172,23 @@ type Method struct {
 	MaxBackoffRaw interface{}   `hcl:"max_backoff"`
 	MaxBackoff    time.Duration `hcl:"-"`
 	Namespace     string        `hcl:"namespace"`
+	Retry         *MethodRetry  `hcl:"-"`
+	OnAuthFailure []string      `hcl:"on_auth_failure"`
+	OnAuthSuccess []string      `hcl:"on_auth_success"`
 	Config        map[string]interface{}
 }
 
+// MethodRetry configures how auto-auth retries failed authentication
+// attempts. MaxAttempts is the number of consecutive failed attempts after
+// which auto-auth is considered failed, running on_auth_failure and exiting
+// if ExitOnFailure is set; zero means every failed attempt. Jitter is the
+// fraction of each backoff that may be randomly trimmed off.
+type MethodRetry struct {
+	MaxAttempts   int      `hcl:"max_attempts"`
+	Jitter        *float64 `hcl:"jitter"`
+	ExitOnFailure bool     `hcl:"exit_on_failure"`
+}
+
 // Sink defines a location to write the authenticated token
 type Sink struct {
 	Type       string
@@ -151,6 +207,11 @@ type TemplateConfig struct {
 	ExitOnRetryFailure       bool          `hcl:"exit_on_retry_failure"`
 	StaticSecretRenderIntRaw interface{}   `hcl:"static_secret_render_interval"`
 	StaticSecretRenderInt    time.Duration `hcl:"-"`
+
+	// LeaseRenewalThreshold is the fraction of the lifetime of issued
+	// certificates and other non-renewable secrets after which templates
+	// using them are rendered again. Zero uses consul-template's default.
+	LeaseRenewalThreshold float64 `hcl:"lease_renewal_threshold"`
 }
 
 func NewConfig() *Config {
@@ -203,8 +264,12 @@ func LoadConfig(path string) (*Config, error) {
 	}
 
 	// Pruning custom headers for Agent for now
-	for _, ln := range sharedConfig.Listeners {
+	for i, ln := range sharedConfig.Listeners {
 		ln.CustomResponseHeaders = nil
+
+		if err := validateListenerRole(ln); err != nil {
+			return nil, fmt.Errorf("error parsing listener %d: %w", i, err)
+		}
 	}
 
 	result.SharedConfig = sharedConfig
@@ -243,6 +308,15 @@ func LoadConfig(path string) (*Config, error) {
 				return nil, fmt.Errorf("cache.use_auto_auth_token is true and auto_auth uses wrapping")
 			}
 		}
+
+		if result.Cache.Persist != nil && result.Cache.Persist.Type == PersistTypeTransit {
+			if result.AutoAuth == nil {
+				return nil, fmt.Errorf("cache.persist type \"transit\" requires auto_auth to be configured")
+			}
+			if result.AutoAuth.Method.WrapTTL > 0 {
+				return nil, fmt.Errorf("cache.persist type \"transit\" cannot be used when auto_auth uses wrapping")
+			}
+		}
 	}
 
 	if result.AutoAuth != nil {
@@ -425,6 +499,33 @@ func parseCache(result *Config, list *ast.ObjectList) error {
 			}
 		}
 	}
+
+	if c.CacheStaticSecretsRaw != nil {
+		if c.CacheStaticSecrets, err = parseutil.ParseBool(c.CacheStaticSecretsRaw); err != nil {
+			return fmt.Errorf("error parsing 'cache_static_secrets': %w", err)
+		}
+		c.CacheStaticSecretsRaw = nil
+	}
+
+	if c.StaticSecretRefreshIntervalRaw != nil {
+		if !c.CacheStaticSecrets {
+			return errors.New("'static_secret_refresh_interval' requires 'cache_static_secrets' to be enabled")
+		}
+		if c.StaticSecretRefreshInterval, err = parseutil.ParseDurationSecond(c.StaticSecretRefreshIntervalRaw); err != nil {
+			return fmt.Errorf("error parsing 'static_secret_refresh_interval': %w", err)
+		}
+		if c.StaticSecretRefreshInterval <= 0 {
+			return errors.New("'static_secret_refresh_interval' must be a positive duration")
+		}
+		c.StaticSecretRefreshIntervalRaw = nil
+	}
+
+	if c.CoalesceRequestsRaw != nil {
+		if c.CoalesceRequests, err = parseutil.ParseBool(c.CoalesceRequestsRaw); err != nil {
+			return fmt.Errorf("error parsing 'coalesce_requests': %w", err)
+		}
+		c.CoalesceRequestsRaw = nil
+	}
 	result.Cache = &c
 
 	subs, ok := item.Val.(*ast.ObjectType)
@@ -468,6 +569,27 @@ func parsePersist(result *Config, list *ast.ObjectList) error {
 		}
 	}
 
+	switch p.Type {
+	case PersistTypeKubernetes:
+	case PersistTypeFile:
+		if p.KeyFile == "" {
+			return errors.New("key_file must be specified for persist type \"file\"")
+		}
+	case PersistTypeTransit:
+		if p.TransitKey == "" {
+			return errors.New("transit_key must be specified for persist type \"transit\"")
+		}
+		if p.TransitMount == "" {
+			p.TransitMount = "transit"
+		}
+	case PersistTypePassphrase:
+		if p.PassphraseFile == "" {
+			return errors.New("passphrase_file must be specified for persist type \"passphrase\"")
+		}
+	default:
+		return fmt.Errorf("persistent key protection type %q not supported", p.Type)
+	}
+
 	result.Cache.Persist = &p
 
 	return nil
@@ -521,6 +643,12 @@ func parseAutoAuth(result *Config, list *ast.ObjectList) error {
 		}
 	}
 
+	// The cert method re-authenticates with its renewed certificate by
+	// signalling new credentials, which is ignored unless enabled.
+	if _, ok := result.AutoAuth.Method.Config["pki_role"]; ok && result.AutoAuth.Method.Type == "cert" && !a.EnableReauthOnNewCredentials {
+		return fmt.Errorf("error parsing auto_auth: renewing the cert method's certificate from PKI requires 'enable_reauth_on_new_credentials'")
+	}
+
 	if result.AutoAuth.Method.MaxBackoffRaw != nil {
 		var err error
 		if result.AutoAuth.Method.MaxBackoff, err = parseutil.ParseDurationSecond(result.AutoAuth.Method.MaxBackoffRaw); err != nil {
@@ -583,10 +711,64 @@ func parseMethod(result *Config, list *ast.ObjectList) error {
 	// Canonicalize namespace path if provided
 	m.Namespace = namespace.Canonicalize(m.Namespace)
 
+	if ot, ok := item.Val.(*ast.ObjectType); ok {
+		if err := parseMethodRetry(&m, ot.List); err != nil {
+			return fmt.Errorf("error parsing 'retry': %w", err)
+		}
+	}
+
 	result.AutoAuth.Method = &m
 	return nil
 }
 
+func validateListenerRole(ln *configutil.Listener) error {
+	switch ln.Role {
+	case "", ListenerRoleDefault, ListenerRoleCacheOnly:
+	case ListenerRoleMetricsOnly:
+		if len(ln.AllowedPaths) > 0 || len(ln.DeniedPaths) > 0 {
+			return fmt.Errorf("'allowed_paths' and 'denied_paths' cannot be used with role %q", ln.Role)
+		}
+	default:
+		return fmt.Errorf("invalid value for 'role': %q", ln.Role)
+	}
+
+	for _, p := range append(ln.AllowedPaths, ln.DeniedPaths...) {
+		if strings.TrimLeft(p, "/") == "" {
+			return errors.New("'allowed_paths' and 'denied_paths' cannot contain empty paths")
+		}
+	}
+
+	return nil
+}
+
+func parseMethodRetry(m *Method, list *ast.ObjectList) error {
+	name := "retry"
+
+	retryList := list.Filter(name)
+	if len(retryList.Items) == 0 {
+		return nil
+	}
+
+	if len(retryList.Items) > 1 {
+		return fmt.Errorf("at most one %q block is allowed", name)
+	}
+
+	var r MethodRetry
+	if err := hcl.DecodeObject(&r, retryList.Items[0].Val); err != nil {
+		return err
+	}
+
+	if r.MaxAttempts < 0 {
+		return errors.New("max_attempts cannot be negative")
+	}
+	if r.Jitter != nil && (*r.Jitter < 0 || *r.Jitter >= 1) {
+		return errors.New("jitter must be at least 0 and less than 1")
+	}
+
+	m.Retry = &r
+	return nil
+}
+
 func parseSinks(result *Config, list *ast.ObjectList) error {
 	name := "sink"
 
@@ -682,6 +864,10 @@ func parseTemplateConfig(result *Config, list *ast.ObjectList) error {
 		result.TemplateConfig.StaticSecretRenderIntRaw = nil
 	}
 
+	if t := result.TemplateConfig.LeaseRenewalThreshold; t < 0 || t > 1 {
+		return fmt.Errorf("lease_renewal_threshold must be between 0 and 1, got %v", t)
+	}
+
 	return nil
 }
 
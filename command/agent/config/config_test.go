@@ -333,6 +333,61 @@ func TestLoadConfigFile_Method_InitialBackoff(t *testing.T) {
 	}
 }
 
+func TestLoadConfigFile_Method_Retry(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-method-retry.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	jitter := 0.5
+	expected := &Config{
+		SharedConfig: &configutil.SharedConfig{
+			PidFile: "./pidfile",
+		},
+		AutoAuth: &AutoAuth{
+			Method: &Method{
+				Type:      "aws",
+				MountPath: "auth/aws",
+				Retry: &MethodRetry{
+					MaxAttempts:   5,
+					Jitter:        &jitter,
+					ExitOnFailure: true,
+				},
+				OnAuthFailure: []string{"/usr/local/bin/mark-unhealthy", "vault-agent"},
+				OnAuthSuccess: []string{"/usr/local/bin/mark-healthy", "vault-agent"},
+				Config: map[string]interface{}{
+					"role": "foobar",
+				},
+			},
+			Sinks: []*Sink{
+				{
+					Type: "file",
+					Config: map[string]interface{}{
+						"path": "/tmp/file-foo",
+					},
+				},
+			},
+		},
+		Vault: &Vault{
+			Retry: &Retry{
+				NumRetries: 12,
+			},
+		},
+	}
+
+	config.Prune()
+	if diff := deep.Equal(config, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoadConfigFile_Bad_Method_Retry_Jitter(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/bad-config-method-retry-jitter.hcl")
+	if err == nil {
+		t.Fatal("LoadConfig should return an error for a jitter of 1")
+	}
+}
+
 func TestLoadConfigFile_AgentCache_NoAutoAuth(t *testing.T) {
 	config, err := LoadConfig("./test-fixtures/config-cache-no-auto_auth.hcl")
 	if err != nil {
@@ -364,6 +419,139 @@ func TestLoadConfigFile_AgentCache_NoAutoAuth(t *testing.T) {
 	}
 }
 
+func TestLoadConfigFile_AgentCache_StaticSecrets(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-cache-static-secrets.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		Cache: &Cache{
+			CacheStaticSecrets:          true,
+			StaticSecretRefreshInterval: 10 * time.Minute,
+		},
+		SharedConfig: &configutil.SharedConfig{
+			PidFile: "./pidfile",
+			Listeners: []*configutil.Listener{
+				{
+					Type:       "tcp",
+					Address:    "127.0.0.1:8300",
+					TLSDisable: true,
+				},
+			},
+		},
+		Vault: &Vault{
+			Retry: &Retry{
+				NumRetries: 12,
+			},
+		},
+	}
+
+	config.Prune()
+	if diff := deep.Equal(config, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoadConfigFile_AgentCache_CoalesceRequests(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-cache-coalesce-requests.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		Cache: &Cache{
+			CoalesceRequests: true,
+		},
+		SharedConfig: &configutil.SharedConfig{
+			PidFile: "./pidfile",
+			Listeners: []*configutil.Listener{
+				{
+					Type:       "tcp",
+					Address:    "127.0.0.1:8300",
+					TLSDisable: true,
+				},
+			},
+		},
+		Vault: &Vault{
+			Retry: &Retry{
+				NumRetries: 12,
+			},
+		},
+	}
+
+	config.Prune()
+	if diff := deep.Equal(config, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoadConfigFile_Bad_AgentCache_StaticSecretsInterval(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/bad-config-cache-static-secrets-interval.hcl")
+	if err == nil {
+		t.Fatal("LoadConfig should return an error when static_secret_refresh_interval is set without cache_static_secrets")
+	}
+}
+
+func TestLoadConfigFile_AgentCache_ListenerRoles(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-cache-listener-roles.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := &Config{
+		Cache: &Cache{},
+		SharedConfig: &configutil.SharedConfig{
+			PidFile: "./pidfile",
+			Listeners: []*configutil.Listener{
+				{
+					Type:         "tcp",
+					Address:      "127.0.0.1:8300",
+					TLSDisable:   true,
+					Role:         ListenerRoleCacheOnly,
+					AllowedPaths: []string{"secret/data/app/*"},
+					DeniedPaths:  []string{"secret/data/app/admin"},
+				},
+				{
+					Type:       "tcp",
+					Address:    "127.0.0.1:8301",
+					TLSDisable: true,
+					Role:       ListenerRoleMetricsOnly,
+				},
+			},
+		},
+		Vault: &Vault{
+			Retry: &Retry{
+				NumRetries: 12,
+			},
+		},
+	}
+
+	config.Prune()
+	if diff := deep.Equal(config, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoadConfigFile_Bad_TemplateConfig_LeaseRenewalThreshold(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/bad-config-template_config-lease-renewal-threshold.hcl")
+	if err == nil {
+		t.Fatal("LoadConfig should return an error for a lease_renewal_threshold above 1")
+	}
+}
+
+func TestLoadConfigFile_Bad_AgentCache_ListenerRole(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/bad-config-cache-listener-role.hcl")
+	if err == nil {
+		t.Fatal("LoadConfig should return an error for an unknown listener role")
+	}
+
+	_, err = LoadConfig("./test-fixtures/bad-config-cache-listener-role-metrics-paths.hcl")
+	if err == nil {
+		t.Fatal("LoadConfig should return an error when a metrics_only listener sets allowed_paths")
+	}
+}
+
 func TestLoadConfigFile_Bad_AgentCache_InconsisentAutoAuth(t *testing.T) {
 	_, err := LoadConfig("./test-fixtures/bad-config-cache-inconsistent-auto_auth.hcl")
 	if err == nil {
@@ -392,6 +580,13 @@ func TestLoadConfigFile_Bad_AutoAuth_Wrapped_Multiple_Sinks(t *testing.T) {
 	}
 }
 
+func TestLoadConfigFile_Bad_AutoAuth_CertRenewal_NoReauth(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/bad-config-auto_auth-cert-renewal-no-reauth.hcl")
+	if err == nil {
+		t.Fatal("LoadConfig should return an error when the cert method renews from PKI without enable_reauth_on_new_credentials")
+	}
+}
+
 func TestLoadConfigFile_Bad_AutoAuth_Nosinks_Nocache_Notemplates(t *testing.T) {
 	_, err := LoadConfig("./test-fixtures/bad-config-auto_auth-nosinks-nocache-notemplates.hcl")
 	if err == nil {
@@ -646,6 +841,46 @@ func TestLoadConfigFile_AgentCache_PersistMissingType(t *testing.T) {
 	}
 }
 
+func TestLoadConfigFile_AgentCache_PersistKeyProtection(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-cache-persist-file.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected := &Persist{
+		Type:            PersistTypeFile,
+		Path:            "/vault/agent-cache/",
+		KeepAfterImport: true,
+		KeyFile:         "/etc/vault-agent/cache.key",
+	}
+	if diff := deep.Equal(config.Cache.Persist, expected); diff != nil {
+		t.Fatal(diff)
+	}
+
+	config, err = LoadConfig("./test-fixtures/config-cache-persist-transit.hcl")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	expected = &Persist{
+		Type:            PersistTypeTransit,
+		Path:            "/vault/agent-cache/",
+		KeepAfterImport: true,
+		TransitMount:    "transit",
+		TransitKey:      "agent-cache",
+	}
+	if diff := deep.Equal(config.Cache.Persist, expected); diff != nil {
+		t.Fatal(diff)
+	}
+
+	for _, fixture := range []string{
+		"bad-config-cache-persist-file-no-key.hcl",
+		"bad-config-cache-persist-transit-no-auto_auth.hcl",
+	} {
+		if _, err := LoadConfig("./test-fixtures/" + fixture); err == nil {
+			t.Fatalf("expected error loading %s", fixture)
+		}
+	}
+}
+
 func TestLoadConfigFile_TemplateConfig(t *testing.T) {
 	testCases := map[string]struct {
 		fixturePath            string
@@ -656,6 +891,7 @@ func TestLoadConfigFile_TemplateConfig(t *testing.T) {
 			TemplateConfig{
 				ExitOnRetryFailure:    true,
 				StaticSecretRenderInt: 1 * time.Minute,
+				LeaseRenewalThreshold: 0.75,
 			},
 		},
 		"empty": {
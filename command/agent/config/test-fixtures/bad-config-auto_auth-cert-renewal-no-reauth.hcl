@@ -0,0 +1,17 @@
+pid_file = "./pidfile"
+
+auto_auth {
+  method "cert" {
+    config = {
+      client_cert = "/tmp/agent.crt"
+      client_key = "/tmp/agent.key"
+      pki_role = "agent"
+    }
+  }
+
+  sink "file" {
+    config = {
+      path = "/tmp/file-foo"
+    }
+  }
+}
@@ -0,0 +1,13 @@
+pid_file = "./pidfile"
+
+cache {
+    persist "transit" {
+        path = "/vault/agent-cache/"
+        transit_key = "agent-cache"
+    }
+}
+
+listener "tcp" {
+    address = "127.0.0.1:8300"
+    tls_disable = true
+}
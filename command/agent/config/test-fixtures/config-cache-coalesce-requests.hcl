@@ -0,0 +1,10 @@
+pid_file = "./pidfile"
+
+cache {
+	coalesce_requests = true
+}
+
+listener "tcp" {
+    address = "127.0.0.1:8300"
+    tls_disable = true
+}
@@ -0,0 +1,18 @@
+pid_file = "./pidfile"
+
+cache {
+}
+
+listener "tcp" {
+    address = "127.0.0.1:8300"
+    tls_disable = true
+    role = "cache_only"
+    allowed_paths = ["secret/data/app/*"]
+    denied_paths = ["secret/data/app/admin"]
+}
+
+listener "tcp" {
+    address = "127.0.0.1:8301"
+    tls_disable = true
+    role = "metrics_only"
+}
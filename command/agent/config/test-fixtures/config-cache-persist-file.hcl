@@ -0,0 +1,14 @@
+pid_file = "./pidfile"
+
+cache {
+    persist "file" {
+        path = "/vault/agent-cache/"
+        key_file = "/etc/vault-agent/cache.key"
+        keep_after_import = true
+    }
+}
+
+listener "tcp" {
+    address = "127.0.0.1:8300"
+    tls_disable = true
+}
@@ -0,0 +1,24 @@
+pid_file = "./pidfile"
+
+auto_auth {
+    method "approle" {
+        config = {
+            role_id_file_path = "/etc/vault-agent/role-id"
+            secret_id_file_path = "/etc/vault-agent/secret-id"
+        }
+    }
+}
+
+cache {
+    use_auto_auth_token = true
+    persist "transit" {
+        path = "/vault/agent-cache/"
+        transit_key = "agent-cache"
+        keep_after_import = true
+    }
+}
+
+listener "tcp" {
+    address = "127.0.0.1:8300"
+    tls_disable = true
+}
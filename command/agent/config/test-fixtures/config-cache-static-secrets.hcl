@@ -0,0 +1,11 @@
+pid_file = "./pidfile"
+
+cache {
+	cache_static_secrets = true
+	static_secret_refresh_interval = "10m"
+}
+
+listener "tcp" {
+    address = "127.0.0.1:8300"
+    tls_disable = true
+}
@@ -0,0 +1,24 @@
+pid_file = "./pidfile"
+
+auto_auth {
+	method {
+		type = "aws"
+		config = {
+			role = "foobar"
+		}
+		retry {
+			max_attempts = 5
+			jitter = 0.5
+			exit_on_failure = true
+		}
+		on_auth_failure = ["/usr/local/bin/mark-unhealthy", "vault-agent"]
+		on_auth_success = ["/usr/local/bin/mark-healthy", "vault-agent"]
+	}
+
+	sink {
+		type = "file"
+		config = {
+			path = "/tmp/file-foo"
+		}
+	}
+}
@@ -8,6 +8,7 @@ vault {
 template_config {
   exit_on_retry_failure = true
   static_secret_render_interval = 60
+  lease_renewal_threshold = 0.75
 }
 
 template {
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package socket
+
+import (
+	"errors"
+	"net"
+)
+
+const peerCredentialsSupported = false
+
+func peerCredentials(*net.UnixConn) (uint32, uint32, error) {
+	return 0, 0, errors.New("peer credentials are not supported on this platform")
+}
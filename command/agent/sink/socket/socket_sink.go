@@ -0,0 +1,256 @@
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/sdk/helper/parseutil"
+	"go.uber.org/atomic"
+)
+
+const (
+	// defaultMode only allows the user running the agent to connect unless
+	// overridden, since peer credentials are only checked when allow-lists
+	// are configured.
+	defaultMode = 0o600
+
+	writeTimeout = 10 * time.Second
+)
+
+// socketSink is a Sink implementation that serves the token to local
+// clients connecting to a Unix domain socket. Each connection receives the
+// current token, after which it is closed. The token is never written to
+// disk.
+type socketSink struct {
+	path        string
+	mode        os.FileMode
+	allowedUIDs map[uint32]struct{}
+	allowedGIDs map[uint32]struct{}
+	logger      hclog.Logger
+
+	token    *atomic.String
+	listener *net.UnixListener
+
+	closeOnce sync.Once
+	doneCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// NewSocketSink creates a new socket sink with the given configuration and
+// starts listening on the configured path.
+func NewSocketSink(conf *sink.SinkConfig) (sink.Sink, error) {
+	if conf.Logger == nil {
+		return nil, errors.New("nil logger provided")
+	}
+
+	conf.Logger.Info("creating socket sink")
+
+	s := &socketSink{
+		logger: conf.Logger,
+		mode:   defaultMode,
+		token:  atomic.NewString(""),
+		doneCh: make(chan struct{}),
+	}
+
+	pathRaw, ok := conf.Config["path"]
+	if !ok {
+		return nil, errors.New("'path' not specified for socket sink")
+	}
+	path, ok := pathRaw.(string)
+	if !ok || path == "" {
+		return nil, errors.New("could not parse 'path' as string")
+	}
+	s.path = path
+
+	if modeRaw, ok := conf.Config["mode"]; ok {
+		mode, typeOK := modeRaw.(int)
+		if !typeOK {
+			return nil, errors.New("could not parse 'mode' as integer")
+		}
+		if os.FileMode(mode)&^os.ModePerm != 0 {
+			return nil, errors.New("socket mode may only contain permission bits")
+		}
+		s.mode = os.FileMode(mode)
+	}
+
+	var err error
+	if s.allowedUIDs, err = parseIDs(conf.Config, "allowed_uids"); err != nil {
+		return nil, err
+	}
+	if s.allowedGIDs, err = parseIDs(conf.Config, "allowed_gids"); err != nil {
+		return nil, err
+	}
+	if (len(s.allowedUIDs) > 0 || len(s.allowedGIDs) > 0) && !peerCredentialsSupported {
+		return nil, errors.New("'allowed_uids' and 'allowed_gids' are not supported on this platform")
+	}
+
+	if err := s.listen(); err != nil {
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	s.logger.Info("socket sink configured", "path", s.path, "mode", s.mode)
+
+	return s, nil
+}
+
+// parseIDs parses an optional list of numeric user or group IDs.
+func parseIDs(config map[string]interface{}, key string) (map[uint32]struct{}, error) {
+	raw, ok := config[key]
+	if !ok {
+		return nil, nil
+	}
+
+	var items []interface{}
+	switch v := raw.(type) {
+	case []interface{}:
+		items = v
+	case []int:
+		for _, i := range v {
+			items = append(items, i)
+		}
+	default:
+		items = []interface{}{v}
+	}
+
+	ids := make(map[uint32]struct{}, len(items))
+	for _, item := range items {
+		id, err := parseutil.ParseInt(item)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse '%s': %w", key, err)
+		}
+		if id < 0 || id > int64(^uint32(0)) {
+			return nil, fmt.Errorf("invalid id %d in '%s'", id, key)
+		}
+		ids[uint32(id)] = struct{}{}
+	}
+	return ids, nil
+}
+
+func (s *socketSink) listen() error {
+	// Remove a socket left behind by a previous run, but refuse to replace
+	// anything else.
+	if fi, err := os.Lstat(s.path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s exists and is not a socket", s.path)
+		}
+		if err := os.Remove(s.path); err != nil {
+			return fmt.Errorf("error removing stale socket %s: %w", s.path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error stat-ing socket path %s: %w", s.path, err)
+	}
+
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: s.path, Net: "unix"})
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", s.path, err)
+	}
+	ln.SetUnlinkOnClose(true)
+
+	if err := os.Chmod(s.path, s.mode); err != nil {
+		ln.Close()
+		return fmt.Errorf("error setting mode on %s: %w", s.path, err)
+	}
+
+	s.listener = ln
+	return nil
+}
+
+func (s *socketSink) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.AcceptUnix()
+		if err != nil {
+			select {
+			case <-s.doneCh:
+				return
+			default:
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			s.logger.Error("error accepting connection", "path", s.path, "error", err)
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConn(conn)
+		}()
+	}
+}
+
+func (s *socketSink) handleConn(conn *net.UnixConn) {
+	defer conn.Close()
+
+	if len(s.allowedUIDs) > 0 || len(s.allowedGIDs) > 0 {
+		uid, gid, err := peerCredentials(conn)
+		if err != nil {
+			s.logger.Error("error reading peer credentials, closing connection", "error", err)
+			return
+		}
+		if !s.allowed(uid, gid) {
+			s.logger.Warn("rejecting connection from peer not in allow-lists", "uid", uid, "gid", gid)
+			return
+		}
+	}
+
+	// Clients connecting before the first token has been written get an
+	// empty response and are expected to retry.
+	token := s.token.Load()
+	if token == "" {
+		return
+	}
+
+	if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+		s.logger.Error("error setting write deadline", "error", err)
+		return
+	}
+	if _, err := conn.Write([]byte(token)); err != nil {
+		s.logger.Error("error writing token to connection", "error", err)
+	}
+}
+
+func (s *socketSink) allowed(uid, gid uint32) bool {
+	if _, ok := s.allowedUIDs[uid]; ok {
+		return true
+	}
+	_, ok := s.allowedGIDs[gid]
+	return ok
+}
+
+// WriteToken implements the Sink interface and stores the token that is
+// served to subsequent connections.
+func (s *socketSink) WriteToken(token string) error {
+	s.token.Store(token)
+	s.logger.Info("token updated", "path", s.path)
+	return nil
+}
+
+// Token implements the SinkReader interface.
+func (s *socketSink) Token() string {
+	return s.token.Load()
+}
+
+// Close stops accepting connections, removes the socket and waits for
+// in-flight connections to finish.
+func (s *socketSink) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.doneCh)
+		err = s.listener.Close()
+		s.wg.Wait()
+	})
+	return err
+}
@@ -0,0 +1,175 @@
+package socket
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+)
+
+func testSocketSink(t *testing.T, config map[string]interface{}) (*sink.SinkConfig, string) {
+	t.Helper()
+
+	// Unix socket paths are limited in length, so avoid t.TempDir which
+	// embeds the test name.
+	tmpDir, err := ioutil.TempDir("", "vault-agent-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmpDir) })
+
+	path := filepath.Join(tmpDir, "agent.sock")
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+	config["path"] = path
+
+	conf := &sink.SinkConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace).Named("sink.socket"),
+		Config: config,
+	}
+	s, err := NewSocketSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.(io.Closer).Close() })
+	conf.Sink = s
+
+	return conf, path
+}
+
+func readSocket(t *testing.T, path string) string {
+	t.Helper()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	b, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSocketSink(t *testing.T) {
+	conf, path := testSocketSink(t, nil)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != defaultMode {
+		t.Fatalf("unexpected socket mode %v", fi.Mode().Perm())
+	}
+
+	if token := readSocket(t, path); token != "" {
+		t.Fatalf("expected no token before the first write, got %q", token)
+	}
+
+	if err := conf.WriteToken("token1"); err != nil {
+		t.Fatal(err)
+	}
+	if token := readSocket(t, path); token != "token1" {
+		t.Fatalf("expected token1, got %q", token)
+	}
+
+	if err := conf.WriteToken("token2"); err != nil {
+		t.Fatal(err)
+	}
+	if token := readSocket(t, path); token != "token2" {
+		t.Fatalf("expected token2, got %q", token)
+	}
+
+	if err := conf.Sink.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected socket to be removed on close, got %v", err)
+	}
+}
+
+func TestSocketSink_AllowLists(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are only supported on linux")
+	}
+
+	uid, gid := os.Getuid(), os.Getgid()
+
+	// The peer matches neither list.
+	conf, path := testSocketSink(t, map[string]interface{}{
+		"allowed_uids": []interface{}{uid + 1},
+		"allowed_gids": []interface{}{gid + 1},
+	})
+	if err := conf.WriteToken("token"); err != nil {
+		t.Fatal(err)
+	}
+	if token := readSocket(t, path); token != "" {
+		t.Fatalf("expected connection to be rejected, got %q", token)
+	}
+
+	// Matching either the uid or gid list is sufficient.
+	for _, config := range []map[string]interface{}{
+		{"allowed_uids": []interface{}{uid + 1, uid}},
+		{"allowed_gids": gid},
+	} {
+		conf, path := testSocketSink(t, config)
+		if err := conf.WriteToken("token"); err != nil {
+			t.Fatal(err)
+		}
+		if token := readSocket(t, path); token != "token" {
+			t.Fatalf("expected token with config %v, got %q", config, token)
+		}
+	}
+}
+
+func TestSocketSink_ExistingPath(t *testing.T) {
+	conf, path := testSocketSink(t, nil)
+
+	// A stale socket from a previous run is replaced.
+	ln, err := net.Listen("unix", path+".stale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	conf.Config["path"] = path + ".stale"
+	s, err := NewSocketSink(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.(io.Closer).Close()
+
+	// Anything else is left alone.
+	if err := ioutil.WriteFile(path+".file", []byte("data"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	conf.Config["path"] = path + ".file"
+	if _, err := NewSocketSink(conf); err == nil {
+		t.Fatal("expected error for a path that is not a socket")
+	}
+}
+
+func TestSocketSink_BadConfig(t *testing.T) {
+	logger := logging.NewVaultLogger(hclog.Trace)
+	for name, config := range map[string]map[string]interface{}{
+		"no path":     {},
+		"bad mode":    {"path": "/tmp/agent.sock", "mode": "0600"},
+		"bad uid":     {"path": "/tmp/agent.sock", "allowed_uids": []interface{}{"root"}},
+		"negative":    {"path": "/tmp/agent.sock", "allowed_gids": []interface{}{-1}},
+		"dir in mode": {"path": "/tmp/agent.sock", "mode": int(os.ModeDir | 0o600)},
+	} {
+		if _, err := NewSocketSink(&sink.SinkConfig{Logger: logger, Config: config}); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package template
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"time"
+
+	ctconfig "github.com/hashicorp/consul-template/config"
+)
+
+// errNoCertificate is returned by certificateRenewalTime for files without a
+// certificate, such as a rendered private key.
+var errNoCertificate = errors.New("no PEM encoded certificate found")
+
+// issueCertRegex matches template functions issuing certificates from a PKI
+// secrets engine.
+var issueCertRegex = regexp.MustCompile(`\bpkiCert\b|\bsecret\s+"[^"]*/issue/`)
+
+// issuesCertificate returns whether the template issues a certificate when
+// rendered.
+func issuesCertificate(tmpl *ctconfig.TemplateConfig) bool {
+	var contents []byte
+	switch {
+	case tmpl.Contents != nil && *tmpl.Contents != "":
+		contents = []byte(*tmpl.Contents)
+	case tmpl.Source != nil && *tmpl.Source != "":
+		var err error
+		if contents, err = ioutil.ReadFile(*tmpl.Source); err != nil {
+			return false
+		}
+	}
+	return issueCertRegex.Match(contents)
+}
+
+// certificateRenewalTime returns when the first certificate in the PEM file at
+// path is due for renewal, once the given fraction of its lifetime has passed.
+func certificateRenewalTime(path string, threshold float64) (time.Time, error) {
+	rest, err := ioutil.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return time.Time{}, errNoCertificate
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return time.Time{}, err
+		}
+		lifetime := cert.NotAfter.Sub(cert.NotBefore)
+		return cert.NotBefore.Add(time.Duration(float64(lifetime) * threshold)), nil
+	}
+}
+
+// activeTemplates returns the templates the runner should render. Templates
+// issuing certificates are left out while the certificates rendered at their
+// destinations are not yet due for renewal, so that restarting the agent or
+// the runner does not issue new certificates. The returned time is when the
+// certificates left out are due, and is zero if none are.
+//
+// Certificate templates are only left out together, as the certificate and
+// private key issued by a single call are often rendered to separate files.
+// Destinations holding no certificate, such as those of private keys, must
+// exist but do not affect when the certificates are due.
+func (ts *Server) activeTemplates(templates []*ctconfig.TemplateConfig) ([]*ctconfig.TemplateConfig, time.Time) {
+	threshold := ctconfig.DefaultLeaseRenewalThreshold
+	if tc := ts.config.AgentConfig.TemplateConfig; tc != nil && tc.LeaseRenewalThreshold != 0 {
+		threshold = tc.LeaseRenewalThreshold
+	}
+
+	var active, certTemplates []*ctconfig.TemplateConfig
+	for _, tmpl := range templates {
+		if issuesCertificate(tmpl) {
+			certTemplates = append(certTemplates, tmpl)
+		} else {
+			active = append(active, tmpl)
+		}
+	}
+	if len(certTemplates) == 0 {
+		return templates, time.Time{}
+	}
+
+	now := time.Now()
+	var renewAt time.Time
+	for _, tmpl := range certTemplates {
+		if tmpl.Destination == nil {
+			return templates, time.Time{}
+		}
+		t, err := certificateRenewalTime(*tmpl.Destination, threshold)
+		switch {
+		case err == errNoCertificate:
+			continue
+		case err != nil || !now.Before(t):
+			return templates, time.Time{}
+		}
+		if renewAt.IsZero() || t.Before(renewAt) {
+			renewAt = t
+		}
+	}
+	if renewAt.IsZero() {
+		return templates, time.Time{}
+	}
+
+	ts.logger.Info("rendered certificates are still valid, not issuing new ones until renewal",
+		"templates", len(certTemplates), "renew_at", renewAt.Format(time.RFC3339))
+	return active, renewAt
+}
@@ -0,0 +1,212 @@
+package template
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ctconfig "github.com/hashicorp/consul-template/config"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/hashicorp/vault/sdk/helper/pointerutil"
+)
+
+// testCertificatePEM returns a PEM encoded self-signed certificate valid
+// between the given times.
+func testCertificatePEM(t *testing.T, notBefore, notAfter time.Time) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "web.example.com"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))
+}
+
+func TestCertificateRenewalTime(t *testing.T) {
+	dir := t.TempDir()
+	notBefore := time.Now().Add(-time.Hour).Truncate(time.Second)
+	notAfter := notBefore.Add(10 * time.Hour)
+
+	// The certificate may follow other PEM blocks, such as the private key.
+	path := filepath.Join(dir, "bundle.pem")
+	bundle := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte("key")})) +
+		testCertificatePEM(t, notBefore, notAfter)
+	if err := os.WriteFile(path, []byte(bundle), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	renewAt, err := certificateRenewalTime(path, 0.75)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := notBefore.Add(450 * time.Minute); !renewAt.Equal(expected) {
+		t.Fatalf("expected renewal at %s, got %s", expected, renewAt)
+	}
+
+	keyPath := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte("key")}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := certificateRenewalTime(keyPath, 0.75); err != errNoCertificate {
+		t.Fatalf("expected errNoCertificate, got %v", err)
+	}
+
+	if _, err := certificateRenewalTime(filepath.Join(dir, "missing.pem"), 0.75); err == nil {
+		t.Fatal("expected error for a missing file")
+	}
+}
+
+func TestActiveTemplates(t *testing.T) {
+	dir := t.TempDir()
+
+	validCert := filepath.Join(dir, "valid.pem")
+	if err := os.WriteFile(validCert, []byte(testCertificatePEM(t, time.Now().Add(-time.Hour), time.Now().Add(9*time.Hour))), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	dueCert := filepath.Join(dir, "due.pem")
+	if err := os.WriteFile(dueCert, []byte(testCertificatePEM(t, time.Now().Add(-9*time.Hour), time.Now().Add(time.Hour))), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(key, []byte("key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	newTemplate := func(contents, destination string) *ctconfig.TemplateConfig {
+		return &ctconfig.TemplateConfig{
+			Contents:    pointerutil.StringPtr(contents),
+			Destination: pointerutil.StringPtr(destination),
+		}
+	}
+	kv := newTemplate(`{{ with secret "kv/app" }}{{ .Data.password }}{{ end }}`, filepath.Join(dir, "kv.txt"))
+	cert := newTemplate(`{{ with secret "pki/issue/web" "common_name=web.example.com" }}{{ .Data.certificate }}{{ end }}`, validCert)
+	certKey := newTemplate(`{{ with secret "pki/issue/web" "common_name=web.example.com" }}{{ .Data.private_key }}{{ end }}`, key)
+	dueCertTmpl := newTemplate(`{{ with pkiCert "pki/issue/api" "common_name=api.example.com" }}{{ .Cert }}{{ end }}`, dueCert)
+	missing := newTemplate(`{{ with secret "pki/issue/web" "common_name=web.example.com" }}{{ .Data.ca_chain }}{{ end }}`, filepath.Join(dir, "missing.pem"))
+
+	ts := NewServer(&ServerConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		AgentConfig: &config.Config{
+			TemplateConfig: &config.TemplateConfig{LeaseRenewalThreshold: 0.5},
+		},
+	})
+
+	testCases := map[string]struct {
+		templates []*ctconfig.TemplateConfig
+		active    int
+		deferred  bool
+	}{
+		"no certificates":     {[]*ctconfig.TemplateConfig{kv}, 1, false},
+		"valid certificate":   {[]*ctconfig.TemplateConfig{kv, cert, certKey}, 1, true},
+		"due certificate":     {[]*ctconfig.TemplateConfig{kv, cert, dueCertTmpl}, 3, false},
+		"missing destination": {[]*ctconfig.TemplateConfig{cert, missing}, 2, false},
+		"only a private key":  {[]*ctconfig.TemplateConfig{certKey}, 1, false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			active, renewAt := ts.activeTemplates(tc.templates)
+			if len(active) != tc.active {
+				t.Fatalf("expected %d active templates, got %d", tc.active, len(active))
+			}
+			if renewAt.IsZero() == tc.deferred {
+				t.Fatalf("unexpected renewal time %s", renewAt)
+			}
+			if tc.deferred {
+				// Half of the ten hour lifetime of the valid certificate.
+				if until := time.Until(renewAt); until < 3*time.Hour || until > 4*time.Hour {
+					t.Fatalf("unexpected renewal time %s", renewAt)
+				}
+			}
+		})
+	}
+}
+
+// TestServerRun_CertificateNotReissued tests that restarting the template
+// server does not issue a new certificate while the rendered one is valid.
+func TestServerRun_CertificateNotReissued(t *testing.T) {
+	var issued int32
+	pki := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/pki/issue/web" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		atomic.AddInt32(&issued, 1)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"certificate": testCertificatePEM(t, time.Now(), time.Now().Add(time.Hour)),
+				"expiration":  time.Now().Add(time.Hour).Unix(),
+			},
+		})
+	}))
+	defer pki.Close()
+
+	destination := filepath.Join(t.TempDir(), "web.pem")
+	templates := []*ctconfig.TemplateConfig{
+		{
+			Contents:    pointerutil.StringPtr(`{{ with secret "pki/issue/web" "common_name=web.example.com" }}{{ .Data.certificate }}{{ end }}`),
+			Destination: pointerutil.StringPtr(destination),
+		},
+	}
+
+	run := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+		defer cancel()
+
+		server := NewServer(&ServerConfig{
+			Logger: logging.NewVaultLogger(hclog.Trace),
+			AgentConfig: &config.Config{
+				Vault: &config.Vault{
+					Address: pki.URL,
+				},
+			},
+			LogLevel:      hclog.Trace,
+			LogWriter:     hclog.DefaultOutput,
+			ExitAfterAuth: true,
+		})
+
+		tokenCh := make(chan string, 1)
+		tokenCh <- "test"
+		if err := server.Run(ctx, tokenCh, templates); err != nil {
+			t.Fatal(err)
+		}
+		if ctx.Err() != nil {
+			t.Fatal("timeout reached before templates were rendered")
+		}
+	}
+
+	run()
+	if _, err := certificateRenewalTime(destination, 0.9); err != nil {
+		t.Fatalf("expected a rendered certificate: %v", err)
+	}
+
+	run()
+	if n := atomic.LoadInt32(&issued); n != 1 {
+		t.Fatalf("expected a single certificate to be issued, got %d", n)
+	}
+}
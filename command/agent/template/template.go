@@ -11,7 +11,9 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
+	"github.com/armon/go-metrics"
 	"go.uber.org/atomic"
 
 	ctconfig "github.com/hashicorp/consul-template/config"
@@ -60,6 +62,10 @@ type Server struct {
 	// from the runner in the event we're using exit after auth.
 	lookupMap map[string][]*ctconfig.TemplateConfig
 
+	// lastRendered holds the last time each template, by consul-template ID,
+	// was written to disk, to count renders.
+	lastRendered map[string]time.Time
+
 	DoneCh  chan struct{}
 	stopped *atomic.Bool
 
@@ -73,6 +79,7 @@ func NewServer(conf *ServerConfig) *Server {
 		DoneCh:        make(chan struct{}),
 		stopped:       atomic.NewBool(false),
 		runnerStarted: atomic.NewBool(false),
+		lastRendered:  make(map[string]time.Time),
 
 		logger:        conf.Logger,
 		config:        conf,
@@ -112,33 +119,16 @@ func (ts *Server) Run(ctx context.Context, incoming chan string, templates []*ct
 		return fmt.Errorf("template server failed to runner generate config: %w", runnerConfigErr)
 	}
 
-	var err error
-	ts.runner, err = manager.NewRunner(runnerConfig, false)
+	renewAt, err := ts.newRunner(runnerConfig)
 	if err != nil {
 		return fmt.Errorf("template server failed to create: %w", err)
 	}
-
-	// Build the lookup map using the id mapping from the Template runner. This is
-	// used to check the template rendering against the expected templates. This
-	// returns a map with a generated ID and a slice of templates for that id. The
-	// slice is determined by the source or contents of the template, so if a
-	// configuration has multiple templates specified, but are the same source /
-	// contents, they will be identified by the same key.
-	idMap := ts.runner.TemplateConfigMapping()
-	lookupMap := make(map[string][]*ctconfig.TemplateConfig, len(idMap))
-	for id, ctmpls := range idMap {
-		for _, ctmpl := range ctmpls {
-			tl := lookupMap[id]
-			tl = append(tl, ctmpl)
-			lookupMap[id] = tl
-		}
-	}
-	ts.lookupMap = lookupMap
+	renewCh := renewalTimer(renewAt)
 
 	for {
 		select {
 		case <-ctx.Done():
-			ts.runner.Stop()
+			ts.stopRunner()
 			return nil
 
 		case token := <-incoming:
@@ -153,7 +143,7 @@ func (ts *Server) Run(ctx context.Context, incoming chan string, templates []*ct
 					continue
 				}
 
-				ts.runner.Stop()
+				ts.stopRunner()
 				*latestToken = token
 				ctv := ctconfig.Config{
 					Vault: &ctconfig.VaultConfig{
@@ -163,16 +153,46 @@ func (ts *Server) Run(ctx context.Context, incoming chan string, templates []*ct
 
 				runnerConfig = runnerConfig.Merge(&ctv)
 				var runnerErr error
-				ts.runner, runnerErr = manager.NewRunner(runnerConfig, false)
+				renewAt, runnerErr = ts.newRunner(runnerConfig)
 				if runnerErr != nil {
 					ts.logger.Error("template server failed with new Vault token", "error", runnerErr)
 					continue
 				}
+				renewCh = renewalTimer(renewAt)
 				ts.runnerStarted.CAS(false, true)
+				if ts.runner == nil {
+					// Every template is waiting for its certificate to be due
+					// for renewal, so there is nothing to render now.
+					if ts.exitAfterAuth {
+						return nil
+					}
+					continue
+				}
+				go ts.runner.Start()
+			}
+
+		case <-renewCh:
+			// The rendered certificates are due for renewal, so restart the
+			// runner including their templates.
+			renewCh = nil
+			if !ts.runnerStarted.Load() {
+				// Without a token yet, the templates are checked again once
+				// one is received.
+				continue
+			}
+
+			ts.logger.Info("rendered certificates are due for renewal")
+			ts.stopRunner()
+			if renewAt, err = ts.newRunner(runnerConfig); err != nil {
+				return fmt.Errorf("template server failed to create: %w", err)
+			}
+			renewCh = renewalTimer(renewAt)
+			if ts.runner != nil {
 				go ts.runner.Start()
 			}
 
-		case err := <-ts.runner.ErrCh:
+		case err := <-ts.errCh():
+			metrics.IncrCounter([]string{"agent", "template", "error"}, 1)
 			ts.logger.Error("template server error", "error", err.Error())
 			ts.runner.StopImmediately()
 
@@ -182,16 +202,25 @@ func (ts *Server) Run(ctx context.Context, incoming chan string, templates []*ct
 				return fmt.Errorf("template server: %w", err)
 			}
 
-			ts.runner, err = manager.NewRunner(runnerConfig, false)
-			if err != nil {
+			if renewAt, err = ts.newRunner(runnerConfig); err != nil {
 				return fmt.Errorf("template server failed to create: %w", err)
 			}
-			go ts.runner.Start()
+			renewCh = renewalTimer(renewAt)
+			if ts.runner != nil {
+				go ts.runner.Start()
+			}
 
-		case <-ts.runner.TemplateRenderedCh():
+		case <-ts.templateRenderedCh():
 			// A template has been rendered, figure out what to do
 			events := ts.runner.RenderEvents()
 
+			for id, event := range events {
+				if event.LastDidRender.After(ts.lastRendered[id]) {
+					ts.lastRendered[id] = event.LastDidRender
+					metrics.IncrCounter([]string{"agent", "template", "rendered"}, 1)
+				}
+			}
+
 			// events are keyed by template ID, and can be matched up to the id's from
 			// the lookupMap
 			if len(events) < len(ts.lookupMap) {
@@ -219,6 +248,82 @@ func (ts *Server) Run(ctx context.Context, incoming chan string, templates []*ct
 	}
 }
 
+// newRunner creates a runner for the templates in the runner configuration,
+// leaving out those whose rendered certificates are not yet due for renewal.
+// It returns when the templates left out are due. The runner is nil if every
+// template was left out.
+func (ts *Server) newRunner(runnerConfig *ctconfig.Config) (time.Time, error) {
+	ts.runner = nil
+	ts.lookupMap = nil
+
+	active, renewAt := ts.activeTemplates(*runnerConfig.Templates)
+	if len(active) == 0 {
+		return renewAt, nil
+	}
+
+	conf := runnerConfig.Copy()
+	activeConfigs := ctconfig.TemplateConfigs(active)
+	conf.Templates = activeConfigs.Copy()
+
+	runner, err := manager.NewRunner(conf, false)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Build the lookup map using the id mapping from the Template runner. This is
+	// used to check the template rendering against the expected templates. This
+	// returns a map with a generated ID and a slice of templates for that id. The
+	// slice is determined by the source or contents of the template, so if a
+	// configuration has multiple templates specified, but are the same source /
+	// contents, they will be identified by the same key.
+	idMap := runner.TemplateConfigMapping()
+	lookupMap := make(map[string][]*ctconfig.TemplateConfig, len(idMap))
+	for id, ctmpls := range idMap {
+		for _, ctmpl := range ctmpls {
+			tl := lookupMap[id]
+			tl = append(tl, ctmpl)
+			lookupMap[id] = tl
+		}
+	}
+
+	ts.runner = runner
+	ts.lookupMap = lookupMap
+	return renewAt, nil
+}
+
+// stopRunner stops the runner, if there is one.
+func (ts *Server) stopRunner() {
+	if ts.runner != nil {
+		ts.runner.Stop()
+	}
+}
+
+// errCh returns the runner's error channel, or nil if there is no runner.
+func (ts *Server) errCh() <-chan error {
+	if ts.runner == nil {
+		return nil
+	}
+	return ts.runner.ErrCh
+}
+
+// templateRenderedCh returns the runner's render notification channel, or nil
+// if there is no runner.
+func (ts *Server) templateRenderedCh() <-chan struct{} {
+	if ts.runner == nil {
+		return nil
+	}
+	return ts.runner.TemplateRenderedCh()
+}
+
+// renewalTimer returns a channel receiving once renewAt is reached, or nil if
+// it is zero.
+func renewalTimer(renewAt time.Time) <-chan time.Time {
+	if renewAt.IsZero() {
+		return nil
+	}
+	return time.After(time.Until(renewAt))
+}
+
 func (ts *Server) Stop() {
 	if ts.stopped.CAS(false, true) {
 		close(ts.DoneCh)
@@ -245,6 +350,10 @@ func newRunnerConfig(sc *ServerConfig, templates ctconfig.TemplateConfigs) (*ctc
 		conf.Vault.DefaultLeaseDuration = &sc.AgentConfig.TemplateConfig.StaticSecretRenderInt
 	}
 
+	if sc.AgentConfig.TemplateConfig != nil && sc.AgentConfig.TemplateConfig.LeaseRenewalThreshold != 0 {
+		conf.Vault.LeaseRenewalThreshold = &sc.AgentConfig.TemplateConfig.LeaseRenewalThreshold
+	}
+
 	if sc.AgentConfig.DisableIdleConnsTemplating {
 		idleConns := -1
 		conf.Vault.Transport.MaxIdleConns = &idleConns
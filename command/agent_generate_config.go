@@ -0,0 +1,463 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*AgentGenerateConfigCommand)(nil)
+	_ cli.CommandAutocomplete = (*AgentGenerateConfigCommand)(nil)
+)
+
+const (
+	generateConfigTypeTemplate    = "template"
+	generateConfigTypeEnvTemplate = "env-template"
+
+	generateConfigDefaultFile    = "agent.hcl"
+	generateConfigDefaultEnvFile = "secrets.env"
+)
+
+var envVarNameRegex = regexp.MustCompile(`[^A-Z0-9_]`)
+
+type AgentGenerateConfigCommand struct {
+	*BaseCommand
+
+	flagType       string
+	flagPaths      []string
+	flagEnvFile    string
+	flagAuthMethod string
+}
+
+func (c *AgentGenerateConfigCommand) Synopsis() string {
+	return "Generate a Vault Agent configuration from existing secrets"
+}
+
+func (c *AgentGenerateConfigCommand) Help() string {
+	helpText := `
+Usage: vault agent generate-config [options] [CONFIG_FILE]
+
+  Generates a Vault Agent configuration file with templates for the secrets
+  in the given key-value paths, read with the current token. The version of
+  each key-value secrets engine is detected automatically. The configuration
+  is written to CONFIG_FILE, "agent.hcl" by default, which must not exist.
+  The auto_auth stanza uses the auth method the current token was issued by,
+  or the one given with -auth-method, with placeholder values to fill in.
+  The configuration and the rendered secrets are only readable by their
+  owner.
+
+  Generate a template stanza for every secret under "secret/app/", each
+  rendering the secret as JSON:
+
+      $ vault agent generate-config -type=template -path="secret/app/*"
+
+  Generate a template rendering an environment file exporting every key of
+  the given secrets, each value quoted so that the file can safely be sourced
+  by a shell:
+
+      $ vault agent generate-config -type=env-template \
+          -path="secret/app/config" -path="secret/db/*" agent.hcl
+
+  Generate an auto_auth stanza for AppRole rather than for the auth method
+  of the current token:
+
+      $ vault agent generate-config -type=template -auth-method=approle \
+          -path="secret/app/*"
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *AgentGenerateConfigCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "type",
+		Target:     &c.flagType,
+		Completion: complete.PredictSet(generateConfigTypeTemplate, generateConfigTypeEnvTemplate),
+		Usage: "Type of configuration to generate. \"template\" renders each " +
+			"secret to its own file as JSON, \"env-template\" renders every key " +
+			"of the secrets to a single environment file.",
+	})
+
+	f.StringSliceVar(&StringSliceVar{
+		Name:       "path",
+		Target:     &c.flagPaths,
+		Completion: c.PredictVaultFolders(),
+		Usage: "Path to a key-value secret. A path ending in \"/*\" includes all " +
+			"secrets under it, recursively. This can be specified multiple times.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "env-file",
+		Target:  &c.flagEnvFile,
+		Default: generateConfigDefaultEnvFile,
+		Usage:   "Destination of the environment file rendered with -type=env-template.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "auth-method",
+		Target:     &c.flagAuthMethod,
+		Completion: complete.PredictSet(generateConfigAuthMethods()...),
+		Usage: "Type of the auth method of the generated auto_auth stanza. By " +
+			"default, the auth method the current token was issued by is used.",
+	})
+
+	return set
+}
+
+func (c *AgentGenerateConfigCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*.hcl")
+}
+
+func (c *AgentGenerateConfigCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *AgentGenerateConfigCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	configPath := generateConfigDefaultFile
+	args = f.Args()
+	switch len(args) {
+	case 0:
+	case 1:
+		configPath = strings.TrimSpace(args[0])
+	default:
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0 or 1, got %d)", len(args)))
+		return 1
+	}
+
+	switch c.flagType {
+	case generateConfigTypeTemplate, generateConfigTypeEnvTemplate:
+	case "":
+		c.UI.Error("-type is required")
+		return 1
+	default:
+		c.UI.Error(fmt.Sprintf("Unsupported -type %q", c.flagType))
+		return 1
+	}
+
+	if len(c.flagPaths) == 0 {
+		c.UI.Error("At least one -path is required")
+		return 1
+	}
+
+	if _, err := os.Stat(configPath); err == nil {
+		c.UI.Error(fmt.Sprintf("Configuration file %q already exists", configPath))
+		return 1
+	} else if !os.IsNotExist(err) {
+		c.UI.Error(fmt.Sprintf("Error checking configuration file %q: %s", configPath, err))
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	authMethod, mountPath, err := c.authMethod(client)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	var secrets []*generateConfigSecret
+	for _, p := range c.flagPaths {
+		found, err := c.findSecrets(client, p)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error reading secrets at %s: %s", p, err))
+			return 2
+		}
+		secrets = append(secrets, found...)
+	}
+	if len(secrets) == 0 {
+		c.UI.Error("No secrets found at the given paths")
+		return 2
+	}
+
+	config := generateAgentConfig(client.Address(), authMethod, mountPath, c.flagType, c.flagEnvFile, secrets)
+	if err := writeNewFile(configPath, []byte(config), 0o600); err != nil {
+		c.UI.Error(fmt.Sprintf("Error writing configuration file %q: %s", configPath, err))
+		return 2
+	}
+
+	c.UI.Output(fmt.Sprintf("Generated configuration for %d secret(s) in %s", len(secrets), configPath))
+	return 0
+}
+
+// authMethod returns the type and mount path of the auth method of the
+// auto_auth stanza: the one given with -auth-method, or else the one the
+// current token was issued by.
+func (c *AgentGenerateConfigCommand) authMethod(client *api.Client) (string, string, error) {
+	if c.flagAuthMethod != "" {
+		if _, ok := generateConfigAuthMethodConfigs[c.flagAuthMethod]; !ok {
+			return "", "", fmt.Errorf("Unsupported -auth-method %q", c.flagAuthMethod)
+		}
+		return c.flagAuthMethod, "auth/" + c.flagAuthMethod, nil
+	}
+
+	secret, err := client.Auth().Token().LookupSelf()
+	if err != nil {
+		return "", "", fmt.Errorf("Error looking up the current token: %w", err)
+	}
+	var tokenPath string
+	if secret != nil {
+		tokenPath, _ = secret.Data["path"].(string)
+	}
+
+	// The token was created by logging in at auth/<mount>/login
+	mount, _, found := strings.Cut(strings.TrimPrefix(tokenPath, "auth/"), "/login")
+	if !found || mount == "" || mount == "token" || !strings.HasPrefix(tokenPath, "auth/") {
+		return "", "", errors.New("The current token was not issued by an auth method the agent " +
+			"can use, choose one with -auth-method")
+	}
+
+	// Without permission to list the auth mounts, assume the mount is named
+	// after its type
+	methodType := mount
+	if auths, err := client.Sys().ListAuth(); err == nil {
+		if m, ok := auths[mount+"/"]; ok {
+			methodType = m.Type
+		}
+	}
+	if _, ok := generateConfigAuthMethodConfigs[methodType]; !ok {
+		return "", "", fmt.Errorf("The current token was issued by the auth method at %q, "+
+			"of type %q, which the agent cannot use, choose one with -auth-method", "auth/"+mount, methodType)
+	}
+
+	return methodType, "auth/" + mount, nil
+}
+
+// writeNewFile writes the data to a file that must not exist yet.
+func writeNewFile(path string, data []byte, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// generateConfigSecret is a key-value secret included in the generated
+// configuration.
+type generateConfigSecret struct {
+	// path is the secret's path as given to the kv commands, and dataPath
+	// the API path it is read from.
+	path     string
+	dataPath string
+
+	// relPath is the path relative to the mount.
+	relPath string
+
+	v2     bool
+	fields []string
+}
+
+// findSecrets returns the secrets at the given path, walking it recursively
+// if it ends in "/*".
+func (c *AgentGenerateConfigCommand) findSecrets(client *api.Client, p string) ([]*generateConfigSecret, error) {
+	recursive := strings.HasSuffix(p, "/*")
+	p = sanitizePath(strings.TrimSuffix(p, "*"))
+
+	mountPath, v2, err := isKVv2(p+"/", client)
+	if err != nil {
+		return nil, err
+	}
+
+	if !recursive {
+		secret, err := c.readSecret(client, p, mountPath, v2)
+		if err != nil {
+			return nil, err
+		}
+		if secret == nil {
+			return nil, fmt.Errorf("no secret found at %s", p)
+		}
+		return []*generateConfigSecret{secret}, nil
+	}
+
+	var secrets []*generateConfigSecret
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		listPath := dir
+		if v2 {
+			listPath = addPrefixToKVPath(dir, mountPath, "metadata")
+		}
+		resp, err := client.Logical().List(listPath)
+		if err != nil {
+			return err
+		}
+		keys, _ := extractListData(resp)
+
+		for _, k := range keys {
+			key, ok := k.(string)
+			if !ok {
+				continue
+			}
+			if strings.HasSuffix(key, "/") {
+				if err := walk(dir + key); err != nil {
+					return err
+				}
+				continue
+			}
+
+			secret, err := c.readSecret(client, strings.TrimSuffix(dir+key, "/"), mountPath, v2)
+			if err != nil {
+				return err
+			}
+			if secret != nil {
+				secrets = append(secrets, secret)
+			}
+		}
+		return nil
+	}
+	if err := walk(p + "/"); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+// readSecret reads a secret to find its keys. Deleted or destroyed versions
+// are skipped by returning nil.
+func (c *AgentGenerateConfigCommand) readSecret(client *api.Client, p, mountPath string, v2 bool) (*generateConfigSecret, error) {
+	s := &generateConfigSecret{
+		path:     p,
+		dataPath: p,
+		relPath:  strings.TrimPrefix(p, mountPath),
+		v2:       v2,
+	}
+	if v2 {
+		s.dataPath = addPrefixToKVPath(p, mountPath, "data")
+	}
+
+	resp, err := kvReadRequest(client, s.dataPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || resp.Data == nil {
+		return nil, nil
+	}
+
+	data := resp.Data
+	if v2 {
+		data, _ = resp.Data["data"].(map[string]interface{})
+		if data == nil {
+			return nil, nil
+		}
+	}
+	for k := range data {
+		s.fields = append(s.fields, k)
+	}
+	sort.Strings(s.fields)
+
+	return s, nil
+}
+
+// envVarName returns the environment variable name for a key of the secret,
+// derived from the secret's path relative to its mount.
+func (s *generateConfigSecret) envVarName(field string) string {
+	name := strings.ToUpper(strings.Trim(s.relPath, "/") + "_" + field)
+	return envVarNameRegex.ReplaceAllString(name, "_")
+}
+
+// dataExpr returns the template expression for the secret's data.
+func (s *generateConfigSecret) dataExpr() string {
+	if s.v2 {
+		return ".Data.data"
+	}
+	return ".Data"
+}
+
+// shellQuotePipeline is appended to a template expression to render its
+// value as a single-quoted shell word: every single quote in it ends the
+// quoted string, is added as a double-quoted character, and starts a new
+// quoted string.
+const shellQuotePipeline = "| sprig_toString | sprig_replace \"'\" `'\"'\"'`"
+
+// generateConfigAuthMethodConfigs holds the placeholder configuration of the
+// auth methods supported by the agent.
+var generateConfigAuthMethodConfigs = map[string][][2]string{
+	"alicloud":   {{"role", "<role>"}, {"region", "<region>"}},
+	"approle":    {{"role_id_file_path", "/path/to/role-id"}, {"secret_id_file_path", "/path/to/secret-id"}},
+	"aws":        {{"type", "iam"}, {"role", "<role>"}},
+	"azure":      {{"role", "<role>"}, {"resource", "https://management.azure.com/"}},
+	"cert":       {{"name", "<certificate role>"}},
+	"cf":         {{"role", "<role>"}},
+	"gcp":        {{"type", "gce"}, {"role", "<role>"}},
+	"jwt":        {{"role", "<role>"}, {"path", "/path/to/jwt"}},
+	"kerberos":   {{"username", "<username>"}, {"service", "<service principal>"}, {"realm", "<realm>"}, {"keytab_path", "/path/to/keytab"}, {"krb5conf_path", "/etc/krb5.conf"}},
+	"kubernetes": {{"role", "<role>"}},
+}
+
+func generateConfigAuthMethods() []string {
+	methods := make([]string, 0, len(generateConfigAuthMethodConfigs))
+	for m := range generateConfigAuthMethodConfigs {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+func generateAgentConfig(address, authMethod, mountPath, configType, envFile string, secrets []*generateConfigSecret) string {
+	var b strings.Builder
+
+	b.WriteString(`# Generated by "vault agent generate-config", review before use.
+#
+# Replace the placeholder values of the auto_auth stanza with those of the
+# auth method the agent should use to fetch these secrets.
+
+`)
+	fmt.Fprintf(&b, "vault {\n  address = %s\n}\n\n", strconv.Quote(address))
+
+	fmt.Fprintf(&b, "auto_auth {\n  method {\n    type       = %s\n    mount_path = %s\n    config = {\n", strconv.Quote(authMethod), strconv.Quote(mountPath))
+	for _, kv := range generateConfigAuthMethodConfigs[authMethod] {
+		fmt.Fprintf(&b, "      %s = %s\n", kv[0], strconv.Quote(kv[1]))
+	}
+	b.WriteString("    }\n  }\n\n")
+	b.WriteString("  sink \"file\" {\n    config = {\n      path = \"/path/to/vault-token\"\n    }\n  }\n}\n\n")
+
+	b.WriteString("template_config {\n  exit_on_retry_failure = true\n}\n")
+
+	switch configType {
+	case generateConfigTypeTemplate:
+		for _, s := range secrets {
+			fmt.Fprintf(&b, "\ntemplate {\n  destination = %s\n  perms       = \"0600\"\n  contents    = <<EOT\n", strconv.Quote(s.path+".json"))
+			fmt.Fprintf(&b, "{{ with secret %s }}{{ %s | toJSONPretty }}{{ end }}\n", strconv.Quote(s.dataPath), s.dataExpr())
+			b.WriteString("EOT\n}\n")
+		}
+
+	case generateConfigTypeEnvTemplate:
+		fmt.Fprintf(&b, "\ntemplate {\n  destination = %s\n  perms       = \"0600\"\n  contents    = <<EOT\n", strconv.Quote(envFile))
+		for _, s := range secrets {
+			for _, field := range s.fields {
+				fmt.Fprintf(&b, "export %s='{{ with secret %s }}{{ index %s %s %s }}{{ end }}'\n",
+					s.envVarName(field), strconv.Quote(s.dataPath), s.dataExpr(), strconv.Quote(field), shellQuotePipeline)
+			}
+		}
+		b.WriteString("EOT\n}\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,336 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	dep "github.com/hashicorp/consul-template/dependency"
+	ctemplate "github.com/hashicorp/consul-template/template"
+	"github.com/hashicorp/vault/api"
+	agentConfig "github.com/hashicorp/vault/command/agent/config"
+	"github.com/mitchellh/cli"
+)
+
+func testAgentGenerateConfigCommand(tb testing.TB) (*cli.MockUi, *AgentGenerateConfigCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &AgentGenerateConfigCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+	}
+}
+
+func TestAgentGenerateConfigCommand_Validations(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		args []string
+		out  string
+	}{
+		{"no_type", []string{"-path=secret/foo"}, "-type is required"},
+		{"bad_type", []string{"-type=exec", "-path=secret/foo"}, "Unsupported -type"},
+		{"no_path", []string{"-type=template"}, "At least one -path is required"},
+		{"too_many_args", []string{"-type=template", "-path=secret/foo", "a.hcl", "b.hcl"}, "Too many arguments"},
+		{"bad_auth_method", []string{"-type=template", "-path=secret/foo", "-auth-method=userpass", filepath.Join(os.TempDir(), "vault-generate-config-missing.hcl")}, "Unsupported -auth-method"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ui, cmd := testAgentGenerateConfigCommand(t)
+			if code := cmd.Run(tc.args); code != 1 {
+				t.Fatalf("expected exit code 1, got %d", code)
+			}
+			if combined := ui.OutputWriter.String() + ui.ErrorWriter.String(); !strings.Contains(combined, tc.out) {
+				t.Fatalf("expected %q to contain %q", combined, tc.out)
+			}
+		})
+	}
+}
+
+func TestAgentGenerateConfigCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	client, closer := testVaultServer(t)
+	defer closer()
+
+	if err := client.Sys().Mount("kvv1/", &api.MountInput{Type: "kv"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Sys().Mount("kvv2/", &api.MountInput{Type: "kv-v2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Logical().Write("kvv1/app/config", map[string]interface{}{
+		"user": "admin",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes to a new KV v2 mount fail until it has been upgraded.
+	var err error
+	for deadline := time.Now().Add(20 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if _, err = client.Logical().Write("kvv2/data/app/db", map[string]interface{}{
+			"data": map[string]interface{}{"password": "secret", "db-host": "localhost"},
+		}); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("kvv2/data/app/nested/api", map[string]interface{}{
+		"data": map[string]interface{}{"key": "value"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("env-template", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "agent.hcl")
+
+		ui, cmd := testAgentGenerateConfigCommand(t)
+		cmd.client = client
+		code := cmd.Run([]string{
+			"-type=env-template",
+			"-auth-method=approle",
+			"-path=kvv1/app/config",
+			"-path=kvv2/app/*",
+			configPath,
+		})
+		if code != 0 {
+			t.Fatalf("expected exit code 0, got %d: %s", code, ui.ErrorWriter.String())
+		}
+
+		config, err := agentConfig.LoadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.AutoAuth == nil || config.AutoAuth.Method.Type != "approle" || config.AutoAuth.Method.MountPath != "auth/approle" {
+			t.Fatalf("expected an approle auto_auth stanza, got %#v", config.AutoAuth)
+		}
+		if len(config.AutoAuth.Sinks) != 1 || config.AutoAuth.Sinks[0].Type != "file" {
+			t.Fatalf("expected a file sink, got %#v", config.AutoAuth.Sinks)
+		}
+		if len(config.Templates) != 1 {
+			t.Fatalf("expected 1 template, got %d", len(config.Templates))
+		}
+		if *config.Templates[0].Destination != generateConfigDefaultEnvFile {
+			t.Fatalf("unexpected destination %q", *config.Templates[0].Destination)
+		}
+
+		contents := *config.Templates[0].Contents
+		for _, expected := range []string{
+			"export APP_CONFIG_USER='{{ with secret \"kvv1/app/config\" }}{{ index .Data \"user\" " + shellQuotePipeline + " }}{{ end }}'",
+			"export APP_DB_DB_HOST='{{ with secret \"kvv2/data/app/db\" }}{{ index .Data.data \"db-host\" " + shellQuotePipeline + " }}{{ end }}'",
+			"export APP_DB_PASSWORD='{{ with secret \"kvv2/data/app/db\" }}{{ index .Data.data \"password\" " + shellQuotePipeline + " }}{{ end }}'",
+			"export APP_NESTED_API_KEY='{{ with secret \"kvv2/data/app/nested/api\" }}{{ index .Data.data \"key\" " + shellQuotePipeline + " }}{{ end }}'",
+		} {
+			if !strings.Contains(contents, expected) {
+				t.Fatalf("expected template contents to contain %q, got:\n%s", expected, contents)
+			}
+		}
+
+		// An existing configuration file is not overwritten.
+		_, cmd = testAgentGenerateConfigCommand(t)
+		cmd.client = client
+		if code := cmd.Run([]string{"-type=env-template", "-auth-method=approle", "-path=kvv1/app/config", configPath}); code != 1 {
+			t.Fatalf("expected exit code 1, got %d", code)
+		}
+	})
+
+	t.Run("template", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "agent.hcl")
+
+		ui, cmd := testAgentGenerateConfigCommand(t)
+		cmd.client = client
+		code := cmd.Run([]string{
+			"-type=template",
+			"-auth-method=kubernetes",
+			"-path=kvv2/app/*",
+			configPath,
+		})
+		if code != 0 {
+			t.Fatalf("expected exit code 0, got %d: %s", code, ui.ErrorWriter.String())
+		}
+
+		config, err := agentConfig.LoadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(config.Templates) != 2 {
+			t.Fatalf("expected 2 templates, got %d", len(config.Templates))
+		}
+		if *config.Templates[0].Destination != "kvv2/app/db.json" {
+			t.Fatalf("unexpected destination %q", *config.Templates[0].Destination)
+		}
+		expected := `{{ with secret "kvv2/data/app/db" }}{{ .Data.data | toJSONPretty }}{{ end }}`
+		if contents := *config.Templates[0].Contents; !strings.Contains(contents, expected) {
+			t.Fatalf("expected template contents to contain %q, got:\n%s", expected, contents)
+		}
+
+		raw, err := ioutil.ReadFile(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(raw), client.Address()) {
+			t.Fatalf("expected the Vault address in the configuration, got:\n%s", raw)
+		}
+		if config.AutoAuth == nil || config.AutoAuth.Method.Type != "kubernetes" {
+			t.Fatalf("expected a kubernetes auto_auth stanza, got %#v", config.AutoAuth)
+		}
+		if role := config.AutoAuth.Method.Config["role"]; role != "<role>" {
+			t.Fatalf("expected a placeholder role, got %v", role)
+		}
+
+		fi, err := os.Stat(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := fi.Mode().Perm(); perm != 0o600 {
+			t.Fatalf("expected the configuration to be written with mode 0600, got %o", perm)
+		}
+	})
+
+	t.Run("detected auth method", func(t *testing.T) {
+		if err := client.Sys().EnableAuthWithOptions("apps", &api.EnableAuthOptions{Type: "approle"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.Sys().PutPolicy("kvv1-read", `
+path "kvv1/*" { capabilities = ["read", "list"] }
+path "sys/auth" { capabilities = ["read"] }
+`); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Logical().Write("auth/apps/role/app", map[string]interface{}{
+			"token_policies": "kvv1-read",
+		}); err != nil {
+			t.Fatal(err)
+		}
+		roleID, err := client.Logical().Read("auth/apps/role/app/role-id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		secretID, err := client.Logical().Write("auth/apps/role/app/secret-id", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		login, err := client.Logical().Write("auth/apps/login", map[string]interface{}{
+			"role_id":   roleID.Data["role_id"],
+			"secret_id": secretID.Data["secret_id"],
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		appClient, err := client.Clone()
+		if err != nil {
+			t.Fatal(err)
+		}
+		appClient.SetToken(login.Auth.ClientToken)
+
+		configPath := filepath.Join(t.TempDir(), "agent.hcl")
+		ui, cmd := testAgentGenerateConfigCommand(t)
+		cmd.client = appClient
+		if code := cmd.Run([]string{"-type=template", "-path=kvv1/app/config", configPath}); code != 0 {
+			t.Fatalf("expected exit code 0, got %d: %s", code, ui.ErrorWriter.String())
+		}
+
+		config, err := agentConfig.LoadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.AutoAuth == nil || config.AutoAuth.Method.Type != "approle" || config.AutoAuth.Method.MountPath != "auth/apps" {
+			t.Fatalf("expected an approle auto_auth stanza mounted at auth/apps, got %#v", config.AutoAuth)
+		}
+	})
+
+	t.Run("undetected auth method", func(t *testing.T) {
+		ui, cmd := testAgentGenerateConfigCommand(t)
+		cmd.client = client
+		if code := cmd.Run([]string{"-type=template", "-path=kvv1/app/config", filepath.Join(t.TempDir(), "agent.hcl")}); code != 1 {
+			t.Fatalf("expected exit code 1, got %d", code)
+		}
+		if out := ui.ErrorWriter.String(); !strings.Contains(out, "-auth-method") {
+			t.Fatalf("expected the error to suggest -auth-method, got %q", out)
+		}
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		_, cmd := testAgentGenerateConfigCommand(t)
+		cmd.client = client
+		if code := cmd.Run([]string{"-type=template", "-auth-method=approle", "-path=kvv2/missing", filepath.Join(t.TempDir(), "agent.hcl")}); code != 2 {
+			t.Fatalf("expected exit code 2, got %d", code)
+		}
+	})
+}
+
+func TestAgentGenerateConfig_EnvTemplateQuoting(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell available")
+	}
+
+	secrets := []*generateConfigSecret{
+		{path: "kv/app", dataPath: "kv/data/app", relPath: "app", v2: true, fields: []string{"value"}},
+	}
+	configPath := filepath.Join(t.TempDir(), "agent.hcl")
+	if err := ioutil.WriteFile(configPath, []byte(generateAgentConfig("https://127.0.0.1:8200", "approle", "auth/approle", generateConfigTypeEnvTemplate, "secrets.env", secrets)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := agentConfig.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perms := config.Templates[0].Perms; perms == nil || *perms != 0o600 {
+		t.Fatalf("expected the environment file to be rendered with mode 0600, got %v", perms)
+	}
+
+	// Render the template the way the agent does, with a value that would run
+	// commands if it were not quoted
+	dir := t.TempDir()
+	value := "it's \"$(touch " + filepath.Join(dir, "pwned") + ")\" `id` $HOME \\ '"
+	tmpl, err := ctemplate.NewTemplate(&ctemplate.NewTemplateInput{
+		Contents: *config.Templates[0].Contents,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := dep.NewVaultReadQuery("kv/data/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	brain := ctemplate.NewBrain()
+	brain.Remember(d, &dep.Secret{
+		Data: map[string]interface{}{
+			"data": map[string]interface{}{"value": value},
+		},
+	})
+	rendered, err := tmpl.Execute(&ctemplate.ExecuteInput{Brain: brain})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envPath := filepath.Join(dir, "secrets.env")
+	if err := ioutil.WriteFile(envPath, rendered.Output, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("sh", "-c", `. "$1" && printf %s "$APP_VALUE"`, "sh", envPath).Output()
+	if err != nil {
+		t.Fatalf("error sourcing %s: %v", rendered.Output, err)
+	}
+	if string(out) != value {
+		t.Fatalf("expected %q, got %q", value, out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pwned")); err == nil {
+		t.Fatal("sourcing the environment file ran a command from the secret")
+	}
+}
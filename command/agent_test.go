@@ -14,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/go-cleanhttp"
 	hclog "github.com/hashicorp/go-hclog"
 	vaultjwt "github.com/hashicorp/vault-plugin-auth-jwt"
 	logicalKv "github.com/hashicorp/vault-plugin-secrets-kv"
@@ -2096,6 +2097,195 @@ listener "tcp" {
 	})
 }
 
+func TestAgent_Health(t *testing.T) {
+	logger := logging.NewVaultLogger(hclog.Trace)
+	cluster := vault.NewTestCluster(t,
+		&vault.CoreConfig{
+			Logger: logger,
+			CredentialBackends: map[string]logical.Factory{
+				"approle": credAppRole.Factory,
+			},
+		},
+		&vault.TestClusterOptions{
+			HandlerFunc: vaulthttp.Handler,
+		})
+	cluster.Start()
+	defer cluster.Cleanup()
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	serverClient := cluster.Cores[0].Client
+
+	// Unset the environment variable so that agent picks up the right test
+	// cluster address
+	defer os.Setenv(api.EnvVaultAddress, os.Getenv(api.EnvVaultAddress))
+	os.Unsetenv(api.EnvVaultAddress)
+
+	autoAuthConfig, cleanup := prepAgentApproleKV(t, serverClient)
+	defer cleanup()
+
+	listenAddr := generateListenerAddress(t)
+	config := fmt.Sprintf(`
+vault {
+  address = "%s"
+  tls_skip_verify = true
+}
+
+%s
+
+cache {
+  use_auto_auth_token = true
+}
+
+listener "tcp" {
+    address = "%s"
+    tls_disable = true
+}
+`, serverClient.Address(), autoAuthConfig, listenAddr)
+	configPath := makeTempFile(t, "config.hcl", config)
+	defer os.Remove(configPath)
+
+	ui, cmd := testAgentCommand(t, logger)
+	cmd.startedCh = make(chan struct{})
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		code := cmd.Run([]string{"-config", configPath})
+		if code != 0 {
+			t.Errorf("non-zero return code when running agent: %d", code)
+			t.Logf("STDOUT from agent:\n%s", ui.OutputWriter.String())
+			t.Logf("STDERR from agent:\n%s", ui.ErrorWriter.String())
+		}
+		wg.Done()
+	}()
+
+	select {
+	case <-cmd.startedCh:
+	case <-time.After(5 * time.Second):
+		t.Errorf("timeout")
+	}
+
+	defer func() {
+		cmd.ShutdownCh <- struct{}{}
+		wg.Wait()
+	}()
+
+	var health map[string]interface{}
+	require.Eventually(t, func() bool {
+		resp, err := cleanhttp.DefaultClient().Get("http://" + listenAddr + consts.AgentPathHealth)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		health = nil
+		if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+			return false
+		}
+		return resp.StatusCode == http.StatusOK
+	}, 10*time.Second, 100*time.Millisecond)
+
+	require.Equal(t, true, health["auto_auth_enabled"])
+	require.Equal(t, true, health["token_valid"])
+	expireTime, err := time.Parse(time.RFC3339, health["token_expire_time"].(string))
+	require.NoError(t, err)
+	require.True(t, expireTime.After(time.Now()))
+}
+
+func TestAgent_ListenerRoles(t *testing.T) {
+	logger := logging.NewVaultLogger(hclog.Trace)
+	cluster := vault.NewTestCluster(t,
+		&vault.CoreConfig{
+			Logger: logger,
+		},
+		&vault.TestClusterOptions{
+			HandlerFunc: vaulthttp.Handler,
+		})
+	cluster.Start()
+	defer cluster.Cleanup()
+	vault.TestWaitActive(t, cluster.Cores[0].Core)
+	serverClient := cluster.Cores[0].Client
+
+	metricsAddr := generateListenerAddress(t)
+	cacheAddr := generateListenerAddress(t)
+	config := fmt.Sprintf(`
+cache {}
+
+listener "tcp" {
+    address = "%s"
+    tls_disable = true
+    role = "metrics_only"
+}
+
+listener "tcp" {
+    address = "%s"
+    tls_disable = true
+    role = "cache_only"
+    denied_paths = ["sys/*"]
+}
+`, metricsAddr, cacheAddr)
+	configPath := makeTempFile(t, "config.hcl", config)
+	defer os.Remove(configPath)
+
+	ui, cmd := testAgentCommand(t, logger)
+	cmd.client = serverClient
+	cmd.startedCh = make(chan struct{})
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		code := cmd.Run([]string{"-config", configPath})
+		if code != 0 {
+			t.Errorf("non-zero return code when running agent: %d", code)
+			t.Logf("STDOUT from agent:\n%s", ui.OutputWriter.String())
+			t.Logf("STDERR from agent:\n%s", ui.ErrorWriter.String())
+		}
+		wg.Done()
+	}()
+
+	select {
+	case <-cmd.startedCh:
+	case <-time.After(5 * time.Second):
+		t.Errorf("timeout")
+	}
+
+	defer func() {
+		cmd.ShutdownCh <- struct{}{}
+		wg.Wait()
+	}()
+
+	status := func(method, url string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(consts.AuthHeaderName, serverClient.Token())
+		resp, err := cleanhttp.DefaultClient().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		url      string
+		expected int
+	}{
+		{"http://" + metricsAddr + "/agent/v1/metrics", http.StatusOK},
+		{"http://" + metricsAddr + "/agent/v1/health", http.StatusOK},
+		{"http://" + metricsAddr + "/v1/auth/token/lookup-self", http.StatusForbidden},
+		{"http://" + cacheAddr + "/agent/v1/metrics", http.StatusForbidden},
+		{"http://" + cacheAddr + "/agent/v1/quit", http.StatusForbidden},
+		{"http://" + cacheAddr + "/v1/sys/mounts", http.StatusForbidden},
+		{"http://" + cacheAddr + "/v1/auth/token/lookup-self", http.StatusOK},
+	}
+	for _, tc := range tests {
+		if code := status(http.MethodGet, tc.url); code != tc.expected {
+			t.Fatalf("%s: expected status %d, got %d", tc.url, tc.expected, code)
+		}
+	}
+}
+
 func TestAgent_Quit(t *testing.T) {
 	//----------------------------------------------------
 	// Start the server and agent
@@ -131,6 +131,26 @@ func (g *StringGenerator) generate(rng io.Reader) (str string, err error) {
 	return string(candidate), nil
 }
 
+// Check that a string which was not generated, such as a user chosen password,
+// adheres to the rules. The length is treated as a minimum rather than an exact
+// length.
+func (g *StringGenerator) Check(str string) error {
+	candidate := []rune(str)
+	if len(candidate) < g.Length {
+		return fmt.Errorf("must be at least %d characters long", g.Length)
+	}
+
+	for _, rule := range g.Rules {
+		if !rule.Pass(candidate) {
+			if cr, ok := rule.(CharsetRule); ok {
+				return fmt.Errorf("must contain at least %d of the characters %q", cr.MinChars, string(cr.Charset))
+			}
+			return fmt.Errorf("does not pass the %s rule", rule.Type())
+		}
+	}
+	return nil
+}
+
 const (
 	// maxCharsetLen is the maximum length a charset is allowed to be when generating a candidate string.
 	// This is the total number of numbers available for selecting an index out of the charset slice.
@@ -639,6 +639,33 @@ type testNonCharsetRule struct {
 func (tr testNonCharsetRule) Pass([]rune) bool { return true }
 func (tr testNonCharsetRule) Type() string     { return "testNonCharsetRule" }
 
+func TestStringGenerator_Check(t *testing.T) {
+	type testCase struct {
+		value     string
+		expectErr bool
+	}
+
+	tests := map[string]testCase{
+		"passes":                 {"abcDEF123-xyz-ABC-789", false},
+		"longer than the length": {"abcDEF123-xyz-ABC-789-and-then-some", false},
+		"too short":              {"aB1-", true},
+		"missing uppercase":      {"abcdef123-xyz-abc-789", true},
+		"missing symbol":         {"abcDEF123xyzABC789abc", true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := DefaultStringGenerator.Check(test.value)
+			if test.expectErr && err == nil {
+				t.Fatalf("err expected, got nil")
+			}
+			if !test.expectErr && err != nil {
+				t.Fatalf("no error expected, got: %s", err)
+			}
+		})
+	}
+}
+
 func TestGetChars(t *testing.T) {
 	type testCase struct {
 		rules    []Rule
@@ -96,6 +96,12 @@ type Listener struct {
 
 	AgentAPI *AgentAPI `hcl:"agent_api"`
 
+	// Role, AllowedPaths and DeniedPaths restrict what an agent listener
+	// serves.
+	Role         string   `hcl:"role"`
+	AllowedPaths []string `hcl:"allowed_paths"`
+	DeniedPaths  []string `hcl:"denied_paths"`
+
 	Telemetry              ListenerTelemetry              `hcl:"telemetry"`
 	Profiling              ListenerProfiling              `hcl:"profiling"`
 	InFlightRequestLogging ListenerInFlightRequestLogging `hcl:"inflight_requests_logging"`
@@ -10,3 +10,7 @@ const AgentPathMetrics = "/agent/v1/metrics"
 
 // AgentPathQuit is the path that the agent will use to trigger stopping it.
 const AgentPathQuit = "/agent/v1/quit"
+
+// AgentPathHealth is the path that the agent will use to report whether it
+// holds a valid auto-auth token.
+const AgentPathHealth = "/agent/v1/health"
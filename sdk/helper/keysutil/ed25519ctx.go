@@ -0,0 +1,34 @@
+//go:build go1.20
+
+package keysutil
+
+import (
+	stded25519 "crypto/ed25519"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+)
+
+// maxEd25519ContextLength is the maximum context length permitted by RFC 8032
+// for the Ed25519ctx variant.
+const maxEd25519ContextLength = 255
+
+func signEd25519ctx(key stded25519.PrivateKey, input, signatureContext []byte) ([]byte, error) {
+	if len(signatureContext) > maxEd25519ContextLength {
+		return nil, errutil.UserError{Err: "signature context must be at most 255 bytes"}
+	}
+
+	return key.Sign(nil, input, &stded25519.Options{
+		Context: string(signatureContext),
+	})
+}
+
+func verifyEd25519ctx(key stded25519.PublicKey, input, sig, signatureContext []byte) (bool, error) {
+	if len(signatureContext) > maxEd25519ContextLength {
+		return false, errutil.UserError{Err: "signature context must be at most 255 bytes"}
+	}
+
+	err := stded25519.VerifyWithOptions(key, input, sig, &stded25519.Options{
+		Context: string(signatureContext),
+	})
+	return err == nil, nil
+}
@@ -0,0 +1,20 @@
+//go:build !go1.20
+
+package keysutil
+
+import (
+	stded25519 "crypto/ed25519"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+)
+
+// The Ed25519ctx variant is only available in the standard library from Go
+// 1.20 onwards.
+
+func signEd25519ctx(_ stded25519.PrivateKey, _, _ []byte) ([]byte, error) {
+	return nil, errutil.UserError{Err: "signature context is not supported by this build"}
+}
+
+func verifyEd25519ctx(_ stded25519.PublicKey, _, _, _ []byte) (bool, error) {
+	return false, errutil.UserError{Err: "signature context is not supported by this build"}
+}
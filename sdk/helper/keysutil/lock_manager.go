@@ -56,6 +56,12 @@ type PolicyRequest struct {
 
 	// AllowImportedKeyRotation indicates whether an imported key may be rotated by Vault
 	AllowImportedKeyRotation bool
+
+	// The identity template derivation contexts must match
+	ContextTemplate string
+
+	// The identity template derivation contexts must start with
+	ContextRequiredPrefix string
 }
 
 type LockManager struct {
@@ -391,6 +397,8 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest, rand io
 
 		if req.Derived {
 			p.KDF = Kdf_hkdf_sha256
+			p.ContextTemplate = req.ContextTemplate
+			p.ContextRequiredPrefix = req.ContextRequiredPrefix
 			if req.Convergent {
 				p.ConvergentEncryption = true
 				// As of version 3 we store the version within each key, so we
@@ -190,6 +190,10 @@ type KeyEntry struct {
 	// This is deprecated (but still filled) in favor of the value above which
 	// is more precise
 	DeprecatedCreationTime int64 `json:"creation_time"`
+
+	// DER encoded certificate chain, leaf first, issued for the public key
+	// of an asymmetric key version
+	CertificateChain [][]byte `json:"certificate_chain"`
 }
 
 // deprecatedKeyEntryMap is used to allow JSON marshal/unmarshal
@@ -378,6 +382,20 @@ type Policy struct {
 	// rotate. Setting this to zero disables automatic rotation for the key.
 	AutoRotatePeriod time.Duration `json:"auto_rotate_period"`
 
+	// AllowedBatchKeys lists the names of other keys through whose batch
+	// sign, verify and hmac endpoints this key may be used.
+	AllowedBatchKeys []string `json:"allowed_batch_keys"`
+
+	// ContextTemplate, if set on a derived key, is an identity template
+	// which the key derivation context supplied by a caller must match once
+	// rendered for the requesting entity.
+	ContextTemplate string `json:"context_template"`
+
+	// ContextRequiredPrefix, if set on a derived key, is an identity
+	// template which, once rendered for the requesting entity, every key
+	// derivation context must start with.
+	ContextRequiredPrefix string `json:"context_required_prefix"`
+
 	// versionPrefixCache stores caches of version prefix strings and the split
 	// version template.
 	versionPrefixCache sync.Map
@@ -1025,7 +1043,32 @@ func (p *Policy) HMACKey(version int) ([]byte, error) {
 	return keyEntry.HMACKey, nil
 }
 
+// SigningOptions holds the parameters for SignWithOptions and
+// VerifySignatureWithOptions.
+type SigningOptions struct {
+	HashAlgorithm HashType
+	Marshaling    MarshalingType
+	SigAlgorithm  string
+
+	// SignatureContext, when set, selects the Ed25519ctx variant of RFC 8032
+	// for ed25519 keys, binding the signature to the given context. It is
+	// not valid for other key types.
+	SignatureContext []byte
+}
+
 func (p *Policy) Sign(ver int, context, input []byte, hashAlgorithm HashType, sigAlgorithm string, marshaling MarshalingType) (*SigningResult, error) {
+	return p.SignWithOptions(ver, context, input, &SigningOptions{
+		HashAlgorithm: hashAlgorithm,
+		Marshaling:    marshaling,
+		SigAlgorithm:  sigAlgorithm,
+	})
+}
+
+func (p *Policy) SignWithOptions(ver int, context, input []byte, options *SigningOptions) (*SigningResult, error) {
+	hashAlgorithm := options.HashAlgorithm
+	marshaling := options.Marshaling
+	sigAlgorithm := options.SigAlgorithm
+
 	if !p.Type.SigningSupported() {
 		return nil, fmt.Errorf("message signing not supported for key type %v", p.Type)
 	}
@@ -1041,6 +1084,10 @@ func (p *Policy) Sign(ver int, context, input []byte, hashAlgorithm HashType, si
 		return nil, errutil.UserError{Err: "requested version for signing is less than the minimum encryption key version"}
 	}
 
+	if len(options.SignatureContext) != 0 && p.Type != KeyType_ED25519 {
+		return nil, errutil.UserError{Err: "signature context is only supported for ed25519 keys"}
+	}
+
 	var sig []byte
 	var pubKey []byte
 	var err error
@@ -1122,6 +1169,9 @@ func (p *Policy) Sign(ver int, context, input []byte, hashAlgorithm HashType, si
 			var err error
 			key, err = p.GetKey(context, ver, 32)
 			if err != nil {
+				if _, ok := err.(errutil.UserError); ok {
+					return nil, errutil.UserError{Err: fmt.Sprintf("error deriving key: %v", err)}
+				}
 				return nil, errutil.InternalError{Err: fmt.Sprintf("error deriving key: %v", err)}
 			}
 			pubKey = key.Public().(ed25519.PublicKey)
@@ -1129,6 +1179,14 @@ func (p *Policy) Sign(ver int, context, input []byte, hashAlgorithm HashType, si
 			key = ed25519.PrivateKey(keyParams.Key)
 		}
 
+		if len(options.SignatureContext) != 0 {
+			sig, err = signEd25519ctx(key, input, options.SignatureContext)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+
 		// Per docs, do not pre-hash ed25519; it does two passes and performs
 		// its own hashing
 		sig, err = key.Sign(rand.Reader, input, crypto.Hash(0))
@@ -1203,28 +1261,60 @@ func (p *Policy) Sign(ver int, context, input []byte, hashAlgorithm HashType, si
 }
 
 func (p *Policy) VerifySignature(context, input []byte, hashAlgorithm HashType, sigAlgorithm string, marshaling MarshalingType, sig string) (bool, error) {
-	if !p.Type.SigningSupported() {
-		return false, errutil.UserError{Err: fmt.Sprintf("message verification not supported for key type %v", p.Type)}
-	}
+	return p.VerifySignatureWithOptions(context, input, sig, &SigningOptions{
+		HashAlgorithm: hashAlgorithm,
+		Marshaling:    marshaling,
+		SigAlgorithm:  sigAlgorithm,
+	})
+}
+
+// SignatureKeyVersion returns the key version encoded in the prefix of a
+// signature produced by this policy.
+func (p *Policy) SignatureKeyVersion(sig string) (int, error) {
+	ver, _, err := p.splitVersionedSignature(sig)
+	return ver, err
+}
 
+func (p *Policy) splitVersionedSignature(sig string) (int, string, error) {
 	tplParts, err := p.getTemplateParts()
 	if err != nil {
-		return false, err
+		return 0, "", err
 	}
 
 	// Verify the prefix
 	if !strings.HasPrefix(sig, tplParts[0]) {
-		return false, errutil.UserError{Err: "invalid signature: no prefix"}
+		return 0, "", errutil.UserError{Err: "invalid signature: no prefix"}
 	}
 
 	splitVerSig := strings.SplitN(strings.TrimPrefix(sig, tplParts[0]), tplParts[1], 2)
 	if len(splitVerSig) != 2 {
-		return false, errutil.UserError{Err: "invalid signature: wrong number of fields"}
+		return 0, "", errutil.UserError{Err: "invalid signature: wrong number of fields"}
 	}
 
 	ver, err := strconv.Atoi(splitVerSig[0])
 	if err != nil {
-		return false, errutil.UserError{Err: "invalid signature: version number could not be decoded"}
+		return 0, "", errutil.UserError{Err: "invalid signature: version number could not be decoded"}
+	}
+
+	return ver, splitVerSig[1], nil
+}
+
+func (p *Policy) VerifySignatureWithOptions(context, input []byte, sig string, options *SigningOptions) (bool, error) {
+	hashAlgorithm := options.HashAlgorithm
+	marshaling := options.Marshaling
+	sigAlgorithm := options.SigAlgorithm
+
+	if !p.Type.SigningSupported() {
+		return false, errutil.UserError{Err: fmt.Sprintf("message verification not supported for key type %v", p.Type)}
+	}
+
+	if len(options.SignatureContext) != 0 && p.Type != KeyType_ED25519 {
+		return false, errutil.UserError{Err: "signature context is only supported for ed25519 keys"}
+	}
+
+	ver, encodedSig, err := p.splitVersionedSignature(sig)
+	if err != nil {
+		return false, err
 	}
 
 	if ver > p.LatestVersion {
@@ -1238,9 +1328,9 @@ func (p *Policy) VerifySignature(context, input []byte, hashAlgorithm HashType,
 	var sigBytes []byte
 	switch marshaling {
 	case MarshalingTypeASN1:
-		sigBytes, err = base64.StdEncoding.DecodeString(splitVerSig[1])
+		sigBytes, err = base64.StdEncoding.DecodeString(encodedSig)
 	case MarshalingTypeJWS:
-		sigBytes, err = base64.RawURLEncoding.DecodeString(splitVerSig[1])
+		sigBytes, err = base64.RawURLEncoding.DecodeString(encodedSig)
 	default:
 		return false, errutil.UserError{Err: "requested marshaling type is invalid"}
 	}
@@ -1302,12 +1392,19 @@ func (p *Policy) VerifySignature(context, input []byte, hashAlgorithm HashType,
 			var err error
 			key, err = p.GetKey(context, ver, 32)
 			if err != nil {
+				if _, ok := err.(errutil.UserError); ok {
+					return false, errutil.UserError{Err: fmt.Sprintf("error deriving key: %v", err)}
+				}
 				return false, errutil.InternalError{Err: fmt.Sprintf("error deriving key: %v", err)}
 			}
 		} else {
 			key = ed25519.PrivateKey(p.Keys[strconv.Itoa(ver)].Key)
 		}
 
+		if len(options.SignatureContext) != 0 {
+			return verifyEd25519ctx(key.Public().(ed25519.PublicKey), input, sigBytes, options.SignatureContext)
+		}
+
 		return ed25519.Verify(key.Public().(ed25519.PublicKey), input, sigBytes), nil
 
 	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
@@ -1856,3 +1953,132 @@ func (p *Policy) SymmetricDecryptRaw(encKey, ciphertext []byte, opts SymmetricOp
 	}
 	return plain, nil
 }
+
+// signerForVersion returns a crypto.Signer for the given version of an
+// asymmetric, non-derived key, resolving version 0 to the latest version.
+func (p *Policy) signerForVersion(ver int) (crypto.Signer, int, error) {
+	if !p.Type.SigningSupported() {
+		return nil, 0, errutil.UserError{Err: fmt.Sprintf("key type %v does not have a public key", p.Type)}
+	}
+	if p.Derived {
+		return nil, 0, errutil.UserError{Err: "operation is not supported on derived keys"}
+	}
+
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return nil, 0, errutil.UserError{Err: "requested version is negative"}
+	case ver > p.LatestVersion:
+		return nil, 0, errutil.UserError{Err: "requested version is higher than the latest key version"}
+	case ver < p.MinAvailableVersion:
+		return nil, 0, errutil.UserError{Err: "requested version has been archived"}
+	}
+
+	keyEntry, err := p.safeGetKeyEntry(ver)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch p.Type {
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
+		var curve elliptic.Curve
+		switch p.Type {
+		case KeyType_ECDSA_P384:
+			curve = elliptic.P384()
+		case KeyType_ECDSA_P521:
+			curve = elliptic.P521()
+		default:
+			curve = elliptic.P256()
+		}
+
+		return &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: curve,
+				X:     keyEntry.EC_X,
+				Y:     keyEntry.EC_Y,
+			},
+			D: keyEntry.EC_D,
+		}, ver, nil
+
+	case KeyType_ED25519:
+		return ed25519.PrivateKey(keyEntry.Key), ver, nil
+
+	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+		return keyEntry.RSAKey, ver, nil
+
+	default:
+		return nil, 0, fmt.Errorf("unsupported key type %v", p.Type)
+	}
+}
+
+// CreateCsr returns a DER encoded PKCS#10 certificate signing request for
+// the given key version, signed by that key. The subject and extensions of
+// the request are taken from the template.
+func (p *Policy) CreateCsr(ver int, template *x509.CertificateRequest) ([]byte, error) {
+	signer, _, err := p.signerForVersion(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature algorithm is chosen by the x509 package based on the
+	// key type.
+	template.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, template, signer)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("could not create the certificate signing request: %v", err)}
+	}
+
+	return csr, nil
+}
+
+// ValidateAndPersistCertificateChain associates a certificate chain, leaf
+// first, with the given key version after checking that the leaf certifies
+// that version's public key and that each certificate is signed by the next
+// one. The policy is persisted on success.
+func (p *Policy) ValidateAndPersistCertificateChain(ctx context.Context, ver int, chain []*x509.Certificate, storage logical.Storage) error {
+	if len(chain) == 0 {
+		return errutil.UserError{Err: "certificate chain is empty"}
+	}
+
+	signer, ver, err := p.signerForVersion(ver)
+	if err != nil {
+		return err
+	}
+
+	leaf := chain[0]
+	if leaf.IsCA {
+		return errutil.UserError{Err: "the first certificate in the chain must be a leaf certificate, not a CA"}
+	}
+
+	leafKey, ok := leaf.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !leafKey.Equal(signer.Public()) {
+		return errutil.UserError{Err: fmt.Sprintf("the public key of the leaf certificate does not match version %d of the key", ver)}
+	}
+
+	for i := 0; i < len(chain)-1; i++ {
+		if err := chain[i].CheckSignatureFrom(chain[i+1]); err != nil {
+			return errutil.UserError{Err: fmt.Sprintf("certificate %d in the chain is not signed by the certificate following it: %v", i, err)}
+		}
+	}
+
+	derChain := make([][]byte, 0, len(chain))
+	for _, cert := range chain {
+		derChain = append(derChain, cert.Raw)
+	}
+
+	keyVer := strconv.Itoa(ver)
+	keyEntry := p.Keys[keyVer]
+	priorChain := keyEntry.CertificateChain
+	keyEntry.CertificateChain = derChain
+	p.Keys[keyVer] = keyEntry
+
+	if err := p.Persist(ctx, storage); err != nil {
+		keyEntry.CertificateChain = priorChain
+		p.Keys[keyVer] = keyEntry
+		return err
+	}
+
+	return nil
+}
@@ -20,6 +20,10 @@ import (
 	"github.com/hashicorp/go-secure-stdlib/tlsutil"
 )
 
+// defaultNestedGroupFilter finds the groups that a group is a member of, in
+// directories such as OpenLDAP and 389 Directory Server.
+const defaultNestedGroupFilter = "(|(member={{.GroupDN}})(uniqueMember={{.GroupDN}}))"
+
 type Client struct {
 	Logger hclog.Logger
 	LDAP   LDAP
@@ -27,66 +31,71 @@ type Client struct {
 
 func (c *Client) DialLDAP(cfg *ConfigEntry) (Connection, error) {
 	var retErr *multierror.Error
-	var conn Connection
-	urls := strings.Split(cfg.Url, ",")
-	for _, uut := range urls {
-		u, err := url.Parse(uut)
+	for _, uut := range strings.Split(cfg.Url, ",") {
+		conn, err := c.dialURL(cfg, uut)
 		if err != nil {
-			retErr = multierror.Append(retErr, errwrap.Wrapf(fmt.Sprintf("error parsing url %q: {{err}}", uut), err))
+			retErr = multierror.Append(retErr, err)
 			continue
 		}
-		host, port, err := net.SplitHostPort(u.Host)
-		if err != nil {
-			host = u.Host
+		if retErr != nil {
+			if c.Logger.IsDebug() {
+				c.Logger.Debug("errors connecting to some hosts", "error", retErr.Error())
+			}
 		}
+		return conn, nil
+	}
+	return nil, retErr
+}
 
-		var tlsConfig *tls.Config
-		switch u.Scheme {
-		case "ldap":
-			if port == "" {
-				port = "389"
-			}
-			conn, err = c.LDAP.Dial("tcp", net.JoinHostPort(host, port))
-			if err != nil {
-				break
-			}
-			if conn == nil {
-				err = fmt.Errorf("empty connection after dialing")
-				break
-			}
-			if cfg.StartTLS {
-				tlsConfig, err = getTLSConfig(cfg, host)
-				if err != nil {
-					break
-				}
-				err = conn.StartTLS(tlsConfig)
-			}
-		case "ldaps":
-			if port == "" {
-				port = "636"
-			}
+// dialURL connects to the LDAP server at a single URL of the configuration.
+func (c *Client) dialURL(cfg *ConfigEntry, uut string) (Connection, error) {
+	u, err := url.Parse(uut)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("error parsing url %q: {{err}}", uut), err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host = u.Host
+	}
+
+	var conn Connection
+	var tlsConfig *tls.Config
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = "389"
+		}
+		conn, err = c.LDAP.Dial("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			break
+		}
+		if conn == nil {
+			err = fmt.Errorf("empty connection after dialing")
+			break
+		}
+		if cfg.StartTLS {
 			tlsConfig, err = getTLSConfig(cfg, host)
 			if err != nil {
 				break
 			}
-			conn, err = c.LDAP.DialTLS("tcp", net.JoinHostPort(host, port), tlsConfig)
-		default:
-			retErr = multierror.Append(retErr, fmt.Errorf("invalid LDAP scheme in url %q", net.JoinHostPort(host, port)))
-			continue
-		}
-		if err == nil {
-			if retErr != nil {
-				if c.Logger.IsDebug() {
-					c.Logger.Debug("errors connecting to some hosts", "error", retErr.Error())
-				}
+			if err = conn.StartTLS(tlsConfig); err != nil {
+				conn.Close()
 			}
-			retErr = nil
+		}
+	case "ldaps":
+		if port == "" {
+			port = "636"
+		}
+		tlsConfig, err = getTLSConfig(cfg, host)
+		if err != nil {
 			break
 		}
-		retErr = multierror.Append(retErr, errwrap.Wrapf(fmt.Sprintf("error connecting to host %q: {{err}}", uut), err))
+		conn, err = c.LDAP.DialTLS("tcp", net.JoinHostPort(host, port), tlsConfig)
+	default:
+		return nil, fmt.Errorf("invalid LDAP scheme in url %q", net.JoinHostPort(host, port))
 	}
-	if retErr != nil {
-		return nil, retErr
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("error connecting to host %q: {{err}}", uut), err)
 	}
 	if timeout := cfg.RequestTimeout; timeout > 0 {
 		conn.SetTimeout(time.Duration(timeout) * time.Second)
@@ -351,6 +360,83 @@ func (c *Client) performLdapFilterGroupsSearch(cfg *ConfigEntry, conn Connection
 	return result.Entries, nil
 }
 
+// performLdapNestedGroupsSearch returns the groups that the given groups are
+// members of, directly or through other groups, up to the configured depth.
+// Groups already seen are not searched again, so membership cycles end the
+// search.
+func (c *Client) performLdapNestedGroupsSearch(cfg *ConfigEntry, conn Connection, entries []*ldap.Entry) ([]*ldap.Entry, error) {
+	if cfg.GroupDN == "" {
+		return nil, nil
+	}
+
+	filter := cfg.NestedGroupFilter
+	if filter == "" {
+		filter = defaultNestedGroupFilter
+	}
+	t, err := template.New("queryTemplate").Parse(filter)
+	if err != nil {
+		return nil, errwrap.Wrapf("LDAP search failed due to template compilation error: {{err}}", err)
+	}
+
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		seen[strings.ToLower(e.DN)] = true
+	}
+
+	var nested []*ldap.Entry
+	current := entries
+	for depth := 1; depth <= cfg.NestedGroupDepth && len(current) > 0; depth++ {
+		var next []*ldap.Entry
+		for _, e := range current {
+			context := struct {
+				GroupDN string
+			}{
+				ldap.EscapeFilter(e.DN),
+			}
+
+			var renderedQuery bytes.Buffer
+			if err := t.Execute(&renderedQuery, context); err != nil {
+				return nil, errwrap.Wrapf("LDAP search failed due to template parsing error: {{err}}", err)
+			}
+
+			if c.Logger.IsDebug() {
+				c.Logger.Debug("searching nested groups", "groupdn", cfg.GroupDN, "depth", depth, "rendered_query", renderedQuery.String())
+			}
+
+			result, err := conn.Search(&ldap.SearchRequest{
+				BaseDN: cfg.GroupDN,
+				Scope:  ldap.ScopeWholeSubtree,
+				Filter: renderedQuery.String(),
+				Attributes: []string{
+					cfg.GroupAttr,
+				},
+				SizeLimit: math.MaxInt32,
+			})
+			if err != nil {
+				return nil, errwrap.Wrapf("LDAP search failed: {{err}}", err)
+			}
+
+			for _, parent := range result.Entries {
+				key := strings.ToLower(parent.DN)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				next = append(next, parent)
+			}
+		}
+
+		nested = append(nested, next...)
+		current = next
+	}
+
+	if len(current) > 0 && c.Logger.IsDebug() {
+		c.Logger.Debug("nested group search stopped at maximum depth", "nested_group_depth", cfg.NestedGroupDepth)
+	}
+
+	return nested, nil
+}
+
 func sidBytesToString(b []byte) (string, error) {
 	reader := bytes.NewReader(b)
 
@@ -463,6 +549,11 @@ func (c *Client) GetLdapGroups(cfg *ConfigEntry, conn Connection, userDN string,
 		entries, err = c.performLdapTokenGroupsSearch(cfg, conn, userDN)
 	} else {
 		entries, err = c.performLdapFilterGroupsSearch(cfg, conn, userDN, username)
+		if err == nil && cfg.NestedGroupDepth > 0 {
+			var nested []*ldap.Entry
+			nested, err = c.performLdapNestedGroupsSearch(cfg, conn, entries)
+			entries = append(entries, nested...)
+		}
 	}
 	if err != nil {
 		return nil, err
@@ -1,8 +1,11 @@
 package ldaputil
 
 import (
+	"sort"
+	"strings"
 	"testing"
 
+	"github.com/go-ldap/ldap/v3"
 	"github.com/hashicorp/go-hclog"
 )
 
@@ -85,3 +88,80 @@ func TestSIDBytesToString(t *testing.T) {
 		}
 	}
 }
+
+// fakeDirectory answers searches for "(member=<dn>)" with the groups that
+// the entry is a direct member of.
+type fakeDirectory struct {
+	Connection
+
+	memberOf map[string][]string
+	searches int
+}
+
+func (d *fakeDirectory) Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	d.searches++
+	member := strings.TrimSuffix(strings.TrimPrefix(searchRequest.Filter, "(member="), ")")
+
+	result := &ldap.SearchResult{}
+	for _, groupDN := range d.memberOf[member] {
+		cn := strings.TrimPrefix(strings.Split(groupDN, ",")[0], "cn=")
+		result.Entries = append(result.Entries, ldap.NewEntry(groupDN, map[string][]string{
+			"cn": {cn},
+		}))
+	}
+	return result, nil
+}
+
+func TestGetLdapGroups_Nested(t *testing.T) {
+	dir := &fakeDirectory{
+		memberOf: map[string][]string{
+			"cn=alice,ou=users":   {"cn=dev,ou=groups"},
+			"cn=dev,ou=groups":    {"cn=eng,ou=groups", "cn=ops,ou=groups"},
+			"cn=eng,ou=groups":    {"cn=staff,ou=groups"},
+			"cn=ops,ou=groups":    {"cn=dev,ou=groups"},
+			"cn=staff,ou=groups":  {"cn=eng,ou=groups", "cn=all,ou=groups"},
+			"cn=all,ou=groups":    {},
+			"cn=unused,ou=groups": {"cn=all,ou=groups"},
+		},
+	}
+	ldapClient := Client{
+		Logger: hclog.NewNullLogger(),
+		LDAP:   NewLDAP(),
+	}
+	cfg := &ConfigEntry{
+		GroupDN:           "ou=groups",
+		GroupFilter:       "(member={{.UserDN}})",
+		GroupAttr:         "cn",
+		NestedGroupFilter: "(member={{.GroupDN}})",
+	}
+
+	testcases := []struct {
+		depth    int
+		expected []string
+	}{
+		{0, []string{"dev"}},
+		{1, []string{"dev", "eng", "ops"}},
+		{2, []string{"dev", "eng", "ops", "staff"}},
+		{10, []string{"all", "dev", "eng", "ops", "staff"}},
+	}
+	for _, tc := range testcases {
+		cfg.NestedGroupDepth = tc.depth
+		groups, err := ldapClient.GetLdapGroups(cfg, dir, "cn=alice,ou=users", "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(groups)
+		if strings.Join(groups, ",") != strings.Join(tc.expected, ",") {
+			t.Fatalf("depth %d: expected %v, got %v", tc.depth, tc.expected, groups)
+		}
+	}
+
+	// Each group is only searched once despite the membership cycles
+	dir.searches = 0
+	if _, err := ldapClient.GetLdapGroups(cfg, dir, "cn=alice,ou=users", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if dir.searches != 6 {
+		t.Fatalf("expected 6 searches, got %d", dir.searches)
+	}
+}
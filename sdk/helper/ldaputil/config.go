@@ -80,6 +80,25 @@ Default: (|(memberUid={{.Username}})(member={{.UserDN}})(uniqueMember={{.UserDN}
 			},
 		},
 
+		"nested_group_depth": {
+			Type:        framework.TypeInt,
+			Description: "Maximum depth to which groups that the groups of a user are members of are searched for, for directories without transitive membership queries. If zero, only the groups found with groupfilter are used.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Nested Group Depth",
+			},
+		},
+
+		"nested_group_filter": {
+			Type:    framework.TypeString,
+			Default: defaultNestedGroupFilter,
+			Description: `Go template for querying the groups that a group is a member of, used when nested_group_depth is set
+The template can access the following context variables: GroupDN
+Default: ` + defaultNestedGroupFilter,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Nested Group Filter",
+			},
+		},
+
 		"groupattr": {
 			Type:    framework.TypeString,
 			Default: "cn",
@@ -226,6 +245,23 @@ Default: ({{.UserAttr}}={{.Username}})`,
 			Description: "Timeout, in seconds, for the connection when making requests against the server before returning back an error.",
 			Default:     "90s",
 		},
+
+		"server_selection": {
+			Type:        framework.TypeString,
+			Description: `How to choose among the servers given in "url". "failover" uses the first healthy server in the given order, "round_robin" spreads connections across the healthy servers.`,
+			Default:     ServerSelectionFailover,
+		},
+
+		"connection_pool_size": {
+			Type:        framework.TypeInt,
+			Description: "Maximum number of idle connections kept open per server for reuse. If zero, a new connection is made for every request.",
+		},
+
+		"health_check_interval": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Interval at which servers marked unhealthy are checked for recovery and idle connections are probed.",
+			Default:     "30s",
+		},
 	}
 }
 
@@ -294,6 +330,27 @@ func NewConfigEntry(existing *ConfigEntry, d *framework.FieldData) (*ConfigEntry
 		cfg.GroupFilter = groupfilter
 	}
 
+	if _, ok := d.Raw["nested_group_depth"]; ok || !hadExisting {
+		nestedGroupDepth := d.Get("nested_group_depth").(int)
+		if nestedGroupDepth < 0 {
+			return nil, errors.New("nested_group_depth cannot be negative")
+		}
+		cfg.NestedGroupDepth = nestedGroupDepth
+	}
+
+	if _, ok := d.Raw["nested_group_filter"]; ok || !hadExisting {
+		nestedGroupFilter := d.Get("nested_group_filter").(string)
+		if nestedGroupFilter != "" {
+			// Validate the template before proceeding
+			_, err := template.New("queryTemplate").Parse(nestedGroupFilter)
+			if err != nil {
+				return nil, errwrap.Wrapf("invalid nested_group_filter: {{err}}", err)
+			}
+		}
+
+		cfg.NestedGroupFilter = nestedGroupFilter
+	}
+
 	if _, ok := d.Raw["groupattr"]; ok || !hadExisting {
 		cfg.GroupAttr = d.Get("groupattr").(string)
 	}
@@ -392,6 +449,32 @@ func NewConfigEntry(existing *ConfigEntry, d *framework.FieldData) (*ConfigEntry
 		cfg.RequestTimeout = d.Get("request_timeout").(int)
 	}
 
+	if _, ok := d.Raw["server_selection"]; ok || !hadExisting {
+		serverSelection := d.Get("server_selection").(string)
+		switch serverSelection {
+		case ServerSelectionFailover, ServerSelectionRoundRobin:
+		default:
+			return nil, fmt.Errorf("invalid server_selection %q", serverSelection)
+		}
+		cfg.ServerSelection = serverSelection
+	}
+
+	if _, ok := d.Raw["connection_pool_size"]; ok || !hadExisting {
+		connectionPoolSize := d.Get("connection_pool_size").(int)
+		if connectionPoolSize < 0 {
+			return nil, errors.New("connection_pool_size cannot be negative")
+		}
+		cfg.ConnectionPoolSize = connectionPoolSize
+	}
+
+	if _, ok := d.Raw["health_check_interval"]; ok || !hadExisting {
+		healthCheckInterval := d.Get("health_check_interval").(int)
+		if healthCheckInterval <= 0 {
+			return nil, errors.New("health_check_interval must be positive")
+		}
+		cfg.HealthCheckInterval = healthCheckInterval
+	}
+
 	return cfg, nil
 }
 
@@ -401,6 +484,8 @@ type ConfigEntry struct {
 	AnonymousGroupSearch     bool   `json:"anonymous_group_search"`
 	GroupDN                  string `json:"groupdn"`
 	GroupFilter              string `json:"groupfilter"`
+	NestedGroupDepth         int    `json:"nested_group_depth"`
+	NestedGroupFilter        string `json:"nested_group_filter"`
 	GroupAttr                string `json:"groupattr"`
 	UPNDomain                string `json:"upndomain"`
 	UsernameAsAlias          bool   `json:"username_as_alias"`
@@ -418,6 +503,9 @@ type ConfigEntry struct {
 	UseTokenGroups           bool   `json:"use_token_groups"`
 	UsePre111GroupCNBehavior *bool  `json:"use_pre111_group_cn_behavior"`
 	RequestTimeout           int    `json:"request_timeout"`
+	ServerSelection          string `json:"server_selection"`
+	ConnectionPoolSize       int    `json:"connection_pool_size"`
+	HealthCheckInterval      int    `json:"health_check_interval"`
 
 	// These json tags deviate from snake case because there was a past issue
 	// where the tag was being ignored, causing it to be jsonified as "CaseSensitiveNames", etc.
@@ -440,6 +528,8 @@ func (c *ConfigEntry) PasswordlessMap() map[string]interface{} {
 		"userdn":                 c.UserDN,
 		"groupdn":                c.GroupDN,
 		"groupfilter":            c.GroupFilter,
+		"nested_group_depth":     c.NestedGroupDepth,
+		"nested_group_filter":    c.NestedGroupFilter,
 		"groupattr":              c.GroupAttr,
 		"userfilter":             c.UserFilter,
 		"upndomain":              c.UPNDomain,
@@ -456,6 +546,9 @@ func (c *ConfigEntry) PasswordlessMap() map[string]interface{} {
 		"anonymous_group_search": c.AnonymousGroupSearch,
 		"request_timeout":        c.RequestTimeout,
 		"username_as_alias":      c.UsernameAsAlias,
+		"server_selection":       c.ServerSelection,
+		"connection_pool_size":   c.ConnectionPoolSize,
+		"health_check_interval":  c.HealthCheckInterval,
 	}
 	if c.CaseSensitiveNames != nil {
 		m["case_sensitive_names"] = *c.CaseSensitiveNames
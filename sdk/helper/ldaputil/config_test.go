@@ -149,6 +149,8 @@ var jsonConfigDefault = []byte(`
   "anonymous_group_search": false,
   "groupdn": "",
   "groupfilter": "(|(memberUid={{.Username}})(member={{.UserDN}})(uniqueMember={{.UserDN}}))",
+  "nested_group_depth": 0,
+  "nested_group_filter": "(|(member={{.GroupDN}})(uniqueMember={{.GroupDN}}))",
   "groupattr": "cn",
   "upndomain": "",
   "userattr": "cn",
@@ -168,6 +170,9 @@ var jsonConfigDefault = []byte(`
   "use_pre111_group_cn_behavior": null,
   "username_as_alias": false,
   "request_timeout": 90,
+  "server_selection": "failover",
+  "connection_pool_size": 0,
+  "health_check_interval": 30,
   "CaseSensitiveNames": false,
   "ClientTLSCert": "",
   "ClientTLSKey": ""
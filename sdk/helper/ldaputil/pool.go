@@ -0,0 +1,309 @@
+package ldaputil
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	multierror "github.com/hashicorp/go-multierror"
+)
+
+const (
+	// ServerSelectionFailover uses the first healthy server, in the order
+	// given in the configured URLs.
+	ServerSelectionFailover = "failover"
+
+	// ServerSelectionRoundRobin spreads connections across the healthy
+	// servers.
+	ServerSelectionRoundRobin = "round_robin"
+
+	defaultHealthCheckInterval = 30 * time.Second
+)
+
+// Pool hands out connections to the servers of a configuration. It keeps
+// track of which servers are reachable, so that a server that is down does
+// not add a dial timeout to every request, and keeps idle connections open
+// for reuse when the configuration has a connection pool size.
+type Pool struct {
+	client  *Client
+	cfg     *ConfigEntry
+	servers []*poolServer
+
+	// next is the index of the server to start from for round robin
+	// selection.
+	next uint64
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+type poolServer struct {
+	url string
+
+	l       sync.Mutex
+	healthy bool
+	idle    []Connection
+	closed  bool
+}
+
+// pooledConnection is a connection handed out by a pool, which must be
+// returned to it through Put.
+type pooledConnection struct {
+	Connection
+	server *poolServer
+}
+
+// NewPool returns a pool for the servers of the given configuration, and
+// starts checking their health in the background. The pool must be closed
+// once it is no longer used.
+func NewPool(client *Client, cfg *ConfigEntry) *Pool {
+	p := &Pool{
+		client: client,
+		cfg:    cfg,
+		stopCh: make(chan struct{}),
+	}
+	for _, u := range strings.Split(cfg.Url, ",") {
+		p.servers = append(p.servers, &poolServer{
+			url:     u,
+			healthy: true,
+		})
+	}
+
+	interval := time.Duration(cfg.HealthCheckInterval) * time.Second
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	go p.run(interval)
+
+	return p
+}
+
+// Get returns a connection to one of the servers, reusing an idle one if
+// there is any. Servers that are unhealthy are only tried once all healthy
+// ones have failed. The connection must be returned through Put.
+func (p *Pool) Get() (Connection, error) {
+	var retErr *multierror.Error
+	servers := p.order()
+
+	for _, healthy := range []bool{true, false} {
+		for _, s := range servers {
+			if s.isHealthy() != healthy {
+				continue
+			}
+			if conn := s.takeIdle(); conn != nil {
+				return &pooledConnection{Connection: conn, server: s}, nil
+			}
+
+			conn, err := p.client.dialURL(p.cfg, s.url)
+			if err != nil {
+				retErr = multierror.Append(retErr, err)
+				if s.setHealthy(false) {
+					p.client.Logger.Warn("marking LDAP server unhealthy", "url", s.url, "error", err)
+				}
+				continue
+			}
+			if s.setHealthy(true) {
+				p.client.Logger.Info("LDAP server is healthy again", "url", s.url)
+			}
+			if retErr != nil && p.client.Logger.IsDebug() {
+				p.client.Logger.Debug("errors connecting to some hosts", "error", retErr.Error())
+			}
+			return &pooledConnection{Connection: conn, server: s}, nil
+		}
+	}
+	return nil, retErr
+}
+
+// Put returns a connection obtained through Get to the pool. The connection
+// is bound back to the bind DN, or anonymously if there is none, so that it
+// is not reused with the identity of a user who logged in on it. Connections
+// beyond the pool size, or that cannot be reset, are closed.
+func (p *Pool) Put(conn Connection) {
+	pc, ok := conn.(*pooledConnection)
+	if !ok {
+		conn.Close()
+		return
+	}
+	if p.cfg.ConnectionPoolSize <= 0 || isClosing(pc.Connection) {
+		pc.Connection.Close()
+		return
+	}
+
+	var err error
+	if p.cfg.BindDN != "" && p.cfg.BindPassword != "" {
+		err = pc.Connection.Bind(p.cfg.BindDN, p.cfg.BindPassword)
+	} else {
+		err = pc.Connection.UnauthenticatedBind("")
+	}
+	if err != nil {
+		pc.Connection.Close()
+		return
+	}
+
+	s := pc.server
+	s.l.Lock()
+	if s.closed || len(s.idle) >= p.cfg.ConnectionPoolSize {
+		s.l.Unlock()
+		pc.Connection.Close()
+		return
+	}
+	s.idle = append(s.idle, pc.Connection)
+	s.l.Unlock()
+}
+
+// Close stops the health checks and closes all idle connections. Connections
+// returned after the pool is closed are closed as well.
+func (p *Pool) Close() {
+	p.stopOnce.Do(func() {
+		close(p.stopCh)
+	})
+	for _, s := range p.servers {
+		s.l.Lock()
+		s.closed = true
+		idle := s.idle
+		s.idle = nil
+		s.l.Unlock()
+
+		for _, conn := range idle {
+			conn.Close()
+		}
+	}
+}
+
+func (p *Pool) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case <-ticker.C:
+			p.checkHealth()
+		}
+	}
+}
+
+// checkHealth dials the servers marked unhealthy to see whether they have
+// recovered, and probes the idle connections of the healthy ones, closing
+// those that no longer work. A healthy server none of whose idle connections
+// work is marked unhealthy.
+func (p *Pool) checkHealth() {
+	for _, s := range p.servers {
+		s.l.Lock()
+		healthy, idle := s.healthy, s.idle
+		s.idle = nil
+		s.l.Unlock()
+
+		if !healthy {
+			conn, err := p.client.dialURL(p.cfg, s.url)
+			if err != nil {
+				continue
+			}
+			conn.Close()
+			if s.setHealthy(true) {
+				p.client.Logger.Info("LDAP server is healthy again", "url", s.url)
+			}
+			continue
+		}
+
+		var alive []Connection
+		for _, conn := range idle {
+			if err := probe(conn); err != nil {
+				conn.Close()
+				continue
+			}
+			alive = append(alive, conn)
+		}
+		if len(idle) > 0 && len(alive) == 0 {
+			if s.setHealthy(false) {
+				p.client.Logger.Warn("marking LDAP server unhealthy", "url", s.url, "error", "idle connections failed health check")
+			}
+		}
+
+		s.l.Lock()
+		if s.closed {
+			s.l.Unlock()
+			for _, conn := range alive {
+				conn.Close()
+			}
+			continue
+		}
+		s.idle = append(s.idle, alive...)
+		s.l.Unlock()
+	}
+}
+
+// order returns the servers in the order they should be tried.
+func (p *Pool) order() []*poolServer {
+	if p.cfg.ServerSelection != ServerSelectionRoundRobin || len(p.servers) < 2 {
+		return p.servers
+	}
+
+	start := int((atomic.AddUint64(&p.next, 1) - 1) % uint64(len(p.servers)))
+	servers := make([]*poolServer, 0, len(p.servers))
+	servers = append(servers, p.servers[start:]...)
+	return append(servers, p.servers[:start]...)
+}
+
+func (s *poolServer) isHealthy() bool {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.healthy
+}
+
+// setHealthy records the health of the server, returning whether it changed.
+// Marking a server unhealthy closes its idle connections.
+func (s *poolServer) setHealthy(healthy bool) bool {
+	s.l.Lock()
+	changed := s.healthy != healthy
+	s.healthy = healthy
+	var idle []Connection
+	if !healthy {
+		idle = s.idle
+		s.idle = nil
+	}
+	s.l.Unlock()
+
+	for _, conn := range idle {
+		conn.Close()
+	}
+	return changed
+}
+
+// takeIdle removes and returns an idle connection of the server, or nil if
+// there is none.
+func (s *poolServer) takeIdle() Connection {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	for len(s.idle) > 0 {
+		conn := s.idle[len(s.idle)-1]
+		s.idle = s.idle[:len(s.idle)-1]
+		if !isClosing(conn) {
+			return conn
+		}
+		conn.Close()
+	}
+	return nil
+}
+
+// probe checks that a connection still works by reading the root DSE.
+func probe(conn Connection) error {
+	_, err := conn.Search(&ldap.SearchRequest{
+		BaseDN:     "",
+		Scope:      ldap.ScopeBaseObject,
+		Filter:     "(objectClass=*)",
+		Attributes: []string{"1.1"},
+	})
+	return err
+}
+
+// isClosing returns whether the connection is known to have been closed,
+// for instance because the server went away.
+func isClosing(conn Connection) bool {
+	c, ok := conn.(interface{ IsClosing() bool })
+	return ok && c.IsClosing()
+}
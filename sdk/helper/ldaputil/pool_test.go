@@ -0,0 +1,237 @@
+package ldaputil
+
+import (
+	"crypto/tls"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
+)
+
+// fakeLDAP dials fake connections to the hosts that are up.
+type fakeLDAP struct {
+	l     sync.Mutex
+	down  map[string]bool
+	dials map[string]int
+}
+
+func (f *fakeLDAP) Dial(network, addr string) (Connection, error) {
+	f.l.Lock()
+	defer f.l.Unlock()
+
+	if f.dials == nil {
+		f.dials = make(map[string]int)
+	}
+	f.dials[addr]++
+	if f.down[addr] {
+		return nil, errors.New("connection refused")
+	}
+	return &fakeConnection{ldap: f, addr: addr}, nil
+}
+
+func (f *fakeLDAP) DialTLS(network, addr string, config *tls.Config) (Connection, error) {
+	return f.Dial(network, addr)
+}
+
+func (f *fakeLDAP) setDown(addr string, down bool) {
+	f.l.Lock()
+	defer f.l.Unlock()
+	if f.down == nil {
+		f.down = make(map[string]bool)
+	}
+	f.down[addr] = down
+}
+
+func (f *fakeLDAP) dialCount(addr string) int {
+	f.l.Lock()
+	defer f.l.Unlock()
+	return f.dials[addr]
+}
+
+type fakeConnection struct {
+	Connection
+
+	ldap    *fakeLDAP
+	addr    string
+	boundAs string
+	closed  bool
+}
+
+func (c *fakeConnection) Bind(username, password string) error {
+	c.boundAs = username
+	return nil
+}
+
+func (c *fakeConnection) UnauthenticatedBind(username string) error {
+	c.boundAs = username
+	return nil
+}
+
+func (c *fakeConnection) Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.ldap.l.Lock()
+	defer c.ldap.l.Unlock()
+	if c.ldap.down[c.addr] {
+		return nil, errors.New("connection reset")
+	}
+	return &ldap.SearchResult{}, nil
+}
+
+func (c *fakeConnection) SetTimeout(time.Duration) {}
+
+func (c *fakeConnection) Close() {
+	c.closed = true
+}
+
+func testPool(t *testing.T, cfg *ConfigEntry) (*Pool, *fakeLDAP) {
+	t.Helper()
+
+	f := &fakeLDAP{}
+	p := NewPool(&Client{Logger: hclog.NewNullLogger(), LDAP: f}, cfg)
+	t.Cleanup(p.Close)
+	return p, f
+}
+
+func getAddr(t *testing.T, p *Pool) (Connection, string) {
+	t.Helper()
+
+	conn, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, conn.(*pooledConnection).Connection.(*fakeConnection).addr
+}
+
+func TestPool_Failover(t *testing.T) {
+	p, f := testPool(t, &ConfigEntry{
+		Url:             "ldap://ldap1,ldap://ldap2",
+		ServerSelection: ServerSelectionFailover,
+	})
+
+	conn, addr := getAddr(t, p)
+	p.Put(conn)
+	if addr != "ldap1:389" {
+		t.Fatalf("expected first server, got %q", addr)
+	}
+
+	// The first server going down fails over to the second one, and is
+	// only dialed once while it is known to be unhealthy.
+	f.setDown("ldap1:389", true)
+	for i := 0; i < 3; i++ {
+		conn, addr = getAddr(t, p)
+		p.Put(conn)
+		if addr != "ldap2:389" {
+			t.Fatalf("expected second server, got %q", addr)
+		}
+	}
+	if n := f.dialCount("ldap1:389"); n != 2 {
+		t.Fatalf("expected first server to be dialed twice, got %d", n)
+	}
+
+	// Once the health check sees it back, the first server is used again.
+	f.setDown("ldap1:389", false)
+	p.checkHealth()
+	conn, addr = getAddr(t, p)
+	p.Put(conn)
+	if addr != "ldap1:389" {
+		t.Fatalf("expected first server, got %q", addr)
+	}
+
+	// With all servers down, they are all tried.
+	f.setDown("ldap1:389", true)
+	f.setDown("ldap2:389", true)
+	if _, err := p.Get(); err == nil {
+		t.Fatal("expected error")
+	}
+	f.setDown("ldap2:389", false)
+	conn, addr = getAddr(t, p)
+	p.Put(conn)
+	if addr != "ldap2:389" {
+		t.Fatalf("expected second server, got %q", addr)
+	}
+}
+
+func TestPool_RoundRobin(t *testing.T) {
+	p, f := testPool(t, &ConfigEntry{
+		Url:             "ldap://ldap1,ldap://ldap2,ldap://ldap3",
+		ServerSelection: ServerSelectionRoundRobin,
+	})
+
+	var addrs []string
+	for i := 0; i < 4; i++ {
+		conn, addr := getAddr(t, p)
+		p.Put(conn)
+		addrs = append(addrs, addr)
+	}
+	expected := []string{"ldap1:389", "ldap2:389", "ldap3:389", "ldap1:389"}
+	for i := range expected {
+		if addrs[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, addrs)
+		}
+	}
+
+	// Unhealthy servers are skipped.
+	f.setDown("ldap2:389", true)
+	for i := 0; i < 4; i++ {
+		conn, addr := getAddr(t, p)
+		p.Put(conn)
+		if addr == "ldap2:389" {
+			t.Fatal("expected unhealthy server to be skipped")
+		}
+	}
+}
+
+func TestPool_Reuse(t *testing.T) {
+	p, f := testPool(t, &ConfigEntry{
+		Url:                "ldap://ldap1",
+		BindDN:             "cn=vault",
+		BindPassword:       "secret",
+		ConnectionPoolSize: 1,
+	})
+
+	conn1, _ := getAddr(t, p)
+	conn2, _ := getAddr(t, p)
+	if err := conn1.Bind("cn=user", "password"); err != nil {
+		t.Fatal(err)
+	}
+	p.Put(conn1)
+	p.Put(conn2)
+
+	// Only one connection is kept, bound back to the bind DN.
+	fc1 := conn1.(*pooledConnection).Connection.(*fakeConnection)
+	fc2 := conn2.(*pooledConnection).Connection.(*fakeConnection)
+	if fc1.closed || fc1.boundAs != "cn=vault" {
+		t.Fatalf("expected idle connection bound to bind DN, got %#v", fc1)
+	}
+	if !fc2.closed {
+		t.Fatal("expected connection beyond pool size to be closed")
+	}
+
+	conn, _ := getAddr(t, p)
+	if conn.(*pooledConnection).Connection != fc1 {
+		t.Fatal("expected idle connection to be reused")
+	}
+	if n := f.dialCount("ldap1:389"); n != 2 {
+		t.Fatalf("expected 2 dials, got %d", n)
+	}
+	p.Put(conn)
+
+	// Idle connections failing the health check are closed.
+	f.setDown("ldap1:389", true)
+	p.checkHealth()
+	if !fc1.closed {
+		t.Fatal("expected idle connection to be closed")
+	}
+
+	// Connections returned after the pool is closed are closed.
+	f.setDown("ldap1:389", false)
+	p.checkHealth()
+	conn, _ = getAddr(t, p)
+	p.Close()
+	p.Put(conn)
+	if !conn.(*pooledConnection).Connection.(*fakeConnection).closed {
+		t.Fatal("expected connection to be closed")
+	}
+}
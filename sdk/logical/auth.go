@@ -90,6 +90,11 @@ type Auth struct {
 	// The set of CIDRs that this token can be used with
 	BoundCIDRs []*sockaddr.SockAddrMarshaler `json:"bound_cidrs"`
 
+	// BoundCertFingerprint is the fingerprint of the client certificate that
+	// connections must present to use this token, as returned by
+	// CertFingerprint. If empty, the token is not bound to a certificate.
+	BoundCertFingerprint string `json:"bound_cert_fingerprint"`
+
 	// CreationPath is a path that the backend can return to use in the lease.
 	// This is currently only supported for the token store where roles may
 	// change the perceived path of the lease, even though they don't change
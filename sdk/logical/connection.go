@@ -1,7 +1,10 @@
 package logical
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 )
 
 // Connection represents the connection information for a request. This
@@ -16,3 +19,19 @@ type Connection struct {
 	// ConnState is the TLS connection state if applicable.
 	ConnState *tls.ConnectionState `sentinel:""`
 }
+
+// ClientCertFingerprint returns the fingerprint of the client certificate
+// presented on the connection, or an empty string if there is none.
+func (c *Connection) ClientCertFingerprint() string {
+	if c == nil || c.ConnState == nil || len(c.ConnState.PeerCertificates) == 0 {
+		return ""
+	}
+	return CertFingerprint(c.ConnState.PeerCertificates[0])
+}
+
+// CertFingerprint returns the hex encoded SHA-256 fingerprint of the
+// certificate, as used to bind tokens to certificates.
+func CertFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
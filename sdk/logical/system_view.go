@@ -85,6 +85,25 @@ type PasswordPolicy interface {
 	Generate(context.Context, io.Reader) (string, error)
 }
 
+// PasswordPolicyValidator is an optional interface implemented by system views
+// that can validate passwords chosen by users against password policies. It is
+// not available to plugins running in a separate process.
+type PasswordPolicyValidator interface {
+	// ValidatePasswordWithPolicy returns an error describing why the password
+	// does not satisfy the referenced policy, or if the policy does not exist.
+	ValidatePasswordWithPolicy(ctx context.Context, policyName, password string) error
+}
+
+// ExternalGroupManager is an optional interface implemented by system views
+// that can maintain identity groups on behalf of credential backends, so that
+// groups known to an auth method can be used without creating them manually.
+type ExternalGroupManager interface {
+	// EnsureExternalGroups creates an external identity group, with a group
+	// alias on the backend's mount, for each of the given alias names that
+	// does not have one yet.
+	EnsureExternalGroups(ctx context.Context, aliasNames []string) error
+}
+
 type ExtendedSystemView interface {
 	Auditor() Auditor
 	ForwardGenericRequest(context.Context, *Request) (*Response, error)
@@ -163,6 +163,10 @@ type TokenEntry struct {
 	// The set of CIDRs that this token can be used with
 	BoundCIDRs []*sockaddr.SockAddrMarshaler `json:"bound_cidrs" sentinel:""`
 
+	// The fingerprint of the client certificate that this token can be used
+	// with
+	BoundCertFingerprint string `json:"bound_cert_fingerprint" mapstructure:"bound_cert_fingerprint" structs:"bound_cert_fingerprint" sentinel:""`
+
 	// NamespaceID is the identifier of the namespace to which this token is
 	// confined to. Do not return this value over the API when the token is
 	// being looked up.
@@ -359,10 +359,6 @@ func (d dynamicSystemView) PluginEnv(_ context.Context) (*logical.PluginEnvironm
 }
 
 func (d dynamicSystemView) GeneratePasswordFromPolicy(ctx context.Context, policyName string) (password string, err error) {
-	if policyName == "" {
-		return "", fmt.Errorf("missing password policy name")
-	}
-
 	// Ensure there's a timeout on the context of some sort
 	if _, hasTimeout := ctx.Deadline(); !hasTimeout {
 		var cancel func()
@@ -370,21 +366,71 @@ func (d dynamicSystemView) GeneratePasswordFromPolicy(ctx context.Context, polic
 		defer cancel()
 	}
 
+	passPolicy, err := d.passwordPolicy(ctx, policyName)
+	if err != nil {
+		return "", err
+	}
+
+	return passPolicy.Generate(ctx, nil)
+}
+
+var _ logical.PasswordPolicyValidator = dynamicSystemView{}
+
+// ValidatePasswordWithPolicy checks a password chosen by a user against the
+// password policy referenced.
+func (d dynamicSystemView) ValidatePasswordWithPolicy(ctx context.Context, policyName, password string) error {
+	passPolicy, err := d.passwordPolicy(ctx, policyName)
+	if err != nil {
+		return err
+	}
+
+	if err := passPolicy.Check(password); err != nil {
+		return fmt.Errorf("password does not satisfy password policy %q: %w", policyName, err)
+	}
+	return nil
+}
+
+var _ logical.ExternalGroupManager = dynamicSystemView{}
+
+// EnsureExternalGroups creates external identity groups with group aliases on
+// the mount for the alias names that have none yet.
+func (d dynamicSystemView) EnsureExternalGroups(ctx context.Context, aliasNames []string) error {
+	if d.core == nil {
+		return fmt.Errorf("system view core is nil")
+	}
+	if d.core.identityStore == nil {
+		return fmt.Errorf("system view identity store is nil")
+	}
+	if d.mountEntry.Local {
+		return fmt.Errorf("group aliases cannot be created on local mounts")
+	}
+
+	ctx = namespace.ContextWithNamespace(ctx, d.mountEntry.Namespace())
+	return d.core.identityStore.ensureExternalGroups(ctx, d.mountEntry.Accessor, aliasNames)
+}
+
+// passwordPolicy retrieves and parses the password policy referenced from the
+// mount's namespace.
+func (d dynamicSystemView) passwordPolicy(ctx context.Context, policyName string) (*random.StringGenerator, error) {
+	if policyName == "" {
+		return nil, fmt.Errorf("missing password policy name")
+	}
+
 	ctx = namespace.ContextWithNamespace(ctx, d.mountEntry.Namespace())
 
 	policyCfg, err := d.retrievePasswordPolicy(ctx, policyName)
 	if err != nil {
-		return "", fmt.Errorf("failed to retrieve password policy: %w", err)
+		return nil, fmt.Errorf("failed to retrieve password policy: %w", err)
 	}
 
 	if policyCfg == nil {
-		return "", fmt.Errorf("no password policy found")
+		return nil, fmt.Errorf("no password policy found")
 	}
 
 	passPolicy, err := random.ParsePolicy(policyCfg.HCLPolicy)
 	if err != nil {
-		return "", fmt.Errorf("stored password policy is invalid: %w", err)
+		return nil, fmt.Errorf("stored password policy is invalid: %w", err)
 	}
 
-	return passPolicy.Generate(ctx, nil)
+	return &passPolicy, nil
 }
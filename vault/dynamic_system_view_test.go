@@ -292,6 +292,43 @@ func TestDynamicSystemView_GeneratePasswordFromPolicy_failed(t *testing.T) {
 	}
 }
 
+func TestDynamicSystemView_ValidatePasswordWithPolicy(t *testing.T) {
+	policyEntry, err := logical.StorageEntryJSON(getPasswordPolicyKey(testPolicyName), passwordPolicyConfig{
+		HCLPolicy: `
+length = 20
+rule "charset" {
+	charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	min-chars = 1
+}
+rule "charset" {
+	charset = "0123456789"
+	min-chars = 2
+}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	core := &Core{
+		systemBarrierView: NewBarrierView(fakeBarrier{getEntry: policyEntry}, "sys/"),
+	}
+	dsv := TestDynamicSystemView(core, nil)
+	ctx := namespace.RootContext(context.Background())
+
+	if err := dsv.ValidatePasswordWithPolicy(ctx, testPolicyName, "abcdefghijABCDEFGHIJ0123"); err != nil {
+		t.Fatalf("no error expected, got: %s", err)
+	}
+	if err := dsv.ValidatePasswordWithPolicy(ctx, testPolicyName, "abcdefghijABCDEFGHIJ"); err == nil {
+		t.Fatal("expected error for a password without digits")
+	}
+	if err := dsv.ValidatePasswordWithPolicy(ctx, testPolicyName, "aB1"); err == nil {
+		t.Fatal("expected error for a short password")
+	}
+	if err := dsv.ValidatePasswordWithPolicy(ctx, "", "abcdefghijABCDEFGHIJ0123"); err == nil {
+		t.Fatal("expected error without a policy name")
+	}
+}
+
 type runes []rune
 
 func (r runes) Len() int           { return len(r) }
@@ -9,6 +9,7 @@ import (
 	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -264,6 +265,84 @@ func (i *IdentityStore) handleGroupAliasUpdateCommon(ctx context.Context, req *l
 	}, nil
 }
 
+// ensureExternalGroups creates an external group, named after the alias, for
+// each of the alias names that has no group alias on the given mount yet.
+// Names already used by other groups are skipped, as aliasing them could
+// hand their policies to users of the mount.
+func (i *IdentityStore) ensureExternalGroups(ctx context.Context, mountAccessor string, aliasNames []string) error {
+	missing, err := i.missingGroupAliases(mountAccessor, aliasNames)
+	if err != nil {
+		return err
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if i.localNode.ReplicationState().HasState(consts.ReplicationPerformanceSecondary) || i.localNode.HAState() == consts.PerfStandby {
+		return logical.ErrReadOnly
+	}
+
+	ns, err := namespace.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	i.groupLock.Lock()
+	defer i.groupLock.Unlock()
+
+	// Check again now that the lock is held
+	missing, err = i.missingGroupAliases(mountAccessor, missing)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range missing {
+		groupByName, err := i.MemDBGroupByName(ctx, name, false)
+		if err != nil {
+			return err
+		}
+		if groupByName != nil {
+			i.logger.Warn("not creating external group for alias, group name is already in use", "name", name, "mount_accessor", mountAccessor)
+			continue
+		}
+
+		now := ptypes.TimestampNow()
+		group := &identity.Group{
+			Name: name,
+			Type: groupTypeExternal,
+			Alias: &identity.Alias{
+				Name:           name,
+				MountAccessor:  mountAccessor,
+				NamespaceID:    ns.ID,
+				CreationTime:   now,
+				LastUpdateTime: now,
+			},
+		}
+		if err := i.sanitizeAndUpsertGroup(ctx, group, nil, nil); err != nil {
+			return err
+		}
+		i.logger.Info("created external group for alias", "name", name, "mount_accessor", mountAccessor, "group_id", group.ID)
+	}
+
+	return nil
+}
+
+// missingGroupAliases returns the alias names that have no group alias on the
+// given mount.
+func (i *IdentityStore) missingGroupAliases(mountAccessor string, aliasNames []string) ([]string, error) {
+	var missing []string
+	for _, name := range aliasNames {
+		groupAlias, err := i.MemDBAliasByFactors(mountAccessor, name, false, true)
+		if err != nil {
+			return nil, err
+		}
+		if groupAlias == nil {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
 // pathGroupAliasIDRead returns the properties of an alias for a given
 // alias ID
 func (i *IdentityStore) pathGroupAliasIDRead() framework.OperationFunc {
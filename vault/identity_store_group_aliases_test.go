@@ -583,3 +583,60 @@ func TestIdentityStore_GroupAliasesUpdate(t *testing.T) {
 		t.Fatalf("still found alias with old group: %s", pretty.Sprint(resp.Data))
 	}
 }
+
+func TestIdentityStore_EnsureExternalGroups(t *testing.T) {
+	ctx := namespace.RootContext(nil)
+	i, accessor, _ := testIdentityStoreWithGithubAuth(ctx, t)
+
+	// A group whose name is taken is not aliased
+	resp, err := i.HandleRequest(ctx, &logical.Request{
+		Path:      "group",
+		Operation: logical.UpdateOperation,
+		Data: map[string]interface{}{
+			"name":     "admins",
+			"policies": "root-ish",
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v\n", resp, err)
+	}
+
+	if err := i.ensureExternalGroups(ctx, accessor, []string{"devs", "admins"}); err != nil {
+		t.Fatal(err)
+	}
+
+	groupAlias, err := i.MemDBAliasByFactors(accessor, "devs", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupAlias == nil {
+		t.Fatal("expected group alias to be created")
+	}
+	group, err := i.MemDBGroupByAliasID(groupAlias.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group == nil || group.Name != "devs" || group.Type != groupTypeExternal {
+		t.Fatalf("bad: group: %#v", group)
+	}
+
+	groupAlias, err = i.MemDBAliasByFactors(accessor, "admins", false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if groupAlias != nil {
+		t.Fatal("expected no group alias for a group name in use")
+	}
+
+	// Existing aliases are left alone
+	if err := i.ensureExternalGroups(ctx, accessor, []string{"devs"}); err != nil {
+		t.Fatal(err)
+	}
+	group2, err := i.MemDBGroupByName(ctx, "devs", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group2.ID != group.ID {
+		t.Fatal("expected existing group to be kept")
+	}
+}
@@ -184,6 +184,15 @@ func (c *Core) fetchACLTokenEntryAndEntity(ctx context.Context, req *logical.Req
 		}
 	}
 
+	// Certificate-bound tokens can only be used over connections presenting
+	// the client certificate they were issued to
+	if te.BoundCertFingerprint != "" && req.Connection.ClientCertFingerprint() != te.BoundCertFingerprint {
+		if c.Logger().IsDebug() {
+			c.Logger().Debug("client certificate does not match certificate-bound token", "accessor", te.Accessor)
+		}
+		return nil, nil, nil, nil, logical.ErrPermissionDenied
+	}
+
 	policyNames := make(map[string][]string)
 	// Add tokens policies
 	policyNames[te.NamespaceID] = append(policyNames[te.NamespaceID], te.Policies...)
@@ -1668,6 +1677,11 @@ func (c *Core) LoginCreateToken(ctx context.Context, ns *namespace.Namespace, re
 		}
 	}
 
+	// Batch tokens are not stored, so their use cannot be tied to a certificate
+	if auth.BoundCertFingerprint != "" && auth.TokenType == logical.TokenTypeBatch {
+		return false, logical.ErrorResponse("batch tokens cannot be bound to a certificate"), logical.ErrInvalidRequest
+	}
+
 	var registerFunc RegisterAuthFunc
 	var funcGetErr error
 	// Batch tokens should not be forwarded to perf standby
@@ -1776,6 +1790,8 @@ func (c *Core) RegisterAuth(ctx context.Context, tokenTTL time.Duration, path st
 		ExplicitMaxTTL: auth.ExplicitMaxTTL,
 		Period:         auth.Period,
 		Type:           auth.TokenType,
+
+		BoundCertFingerprint: auth.BoundCertFingerprint,
 	}
 
 	if te.TTL == 0 && (len(te.Policies) != 1 || te.Policies[0] != "root") {
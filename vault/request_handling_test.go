@@ -1,6 +1,9 @@
 package vault
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -470,3 +473,99 @@ func TestRequestHandling_SecretLeaseMetric(t *testing.T) {
 		},
 	)
 }
+
+func TestRequestHandling_CertBoundToken(t *testing.T) {
+	core, _, root := TestCoreUnsealed(t)
+	ctx := namespace.RootContext(nil)
+
+	resp, err := core.HandleRequest(ctx, &logical.Request{
+		Path:        "sys/policy/token-creator",
+		Operation:   logical.UpdateOperation,
+		ClientToken: root,
+		Data: map[string]interface{}{
+			"policy": `path "auth/token/create" { capabilities = ["update"] }
+path "auth/token/create-orphan" { capabilities = ["update"] }`,
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+
+	certConn := func(raw string) *logical.Connection {
+		return &logical.Connection{
+			ConnState: &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{{Raw: []byte(raw)}},
+			},
+		}
+	}
+
+	auth := &logical.Auth{
+		TokenPolicies:        []string{"default", "token-creator"},
+		TokenType:            logical.TokenTypeService,
+		BoundCertFingerprint: logical.CertFingerprint(&x509.Certificate{Raw: []byte("cert-a")}),
+	}
+	if err := core.RegisterAuth(ctx, time.Hour, "auth/token/create", auth, ""); err != nil {
+		t.Fatal(err)
+	}
+	te, err := core.tokenStore.Lookup(ctx, auth.ClientToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lookupSelf := func(conn *logical.Connection) (*logical.Response, error) {
+		return core.HandleRequest(ctx, &logical.Request{
+			Path:        "auth/token/lookup-self",
+			Operation:   logical.ReadOperation,
+			ClientToken: te.ID,
+			Connection:  conn,
+		})
+	}
+
+	resp, err = lookupSelf(certConn("cert-a"))
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v resp: %#v", err, resp)
+	}
+	if resp.Data["bound_cert_fingerprint"] != te.BoundCertFingerprint {
+		t.Fatalf("bad: bound_cert_fingerprint: %#v", resp.Data["bound_cert_fingerprint"])
+	}
+
+	for _, conn := range []*logical.Connection{certConn("cert-b"), {}, nil} {
+		if _, err := lookupSelf(conn); !errors.Is(err, logical.ErrPermissionDenied) {
+			t.Fatalf("expected permission denied, got %v", err)
+		}
+	}
+
+	// Children are bound to the same certificate and cannot be batch tokens
+	resp, err = core.HandleRequest(ctx, &logical.Request{
+		Path:        "auth/token/create",
+		Operation:   logical.UpdateOperation,
+		ClientToken: te.ID,
+		Connection:  certConn("cert-a"),
+		Data: map[string]interface{}{
+			"type": "batch",
+		},
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error creating batch child, got err: %v resp: %#v", err, resp)
+	}
+
+	// So are orphans created by the token
+	for _, path := range []string{"auth/token/create", "auth/token/create-orphan"} {
+		resp, err = core.HandleRequest(ctx, &logical.Request{
+			Path:        path,
+			Operation:   logical.UpdateOperation,
+			ClientToken: te.ID,
+			Connection:  certConn("cert-a"),
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: err: %v resp: %#v", path, err, resp)
+		}
+		child, err := core.tokenStore.Lookup(ctx, resp.Auth.ClientToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if child.BoundCertFingerprint != te.BoundCertFingerprint {
+			t.Fatalf("%s: expected token to be bound to the parent's certificate, got %q", path, child.BoundCertFingerprint)
+		}
+	}
+}
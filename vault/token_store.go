@@ -2869,6 +2869,17 @@ func (ts *TokenStore) handleCreateCommon(ctx context.Context, req *logical.Reque
 		}
 	}
 
+	// Tokens created by certificate-bound tokens, orphans included, are
+	// bound to the same certificate, so that binding cannot be shed by
+	// creating another token.
+	if parent.BoundCertFingerprint != "" {
+		te.BoundCertFingerprint = parent.BoundCertFingerprint
+	}
+
+	if te.Type == logical.TokenTypeBatch && te.BoundCertFingerprint != "" {
+		return logical.ErrorResponse("batch tokens cannot be bound to a certificate"), logical.ErrInvalidRequest
+	}
+
 	var explicitMaxTTLToUse time.Duration
 	if data.ExplicitMaxTTL != "" {
 		dur, err := parseutil.ParseDurationSecond(data.ExplicitMaxTTL)
@@ -3204,6 +3215,10 @@ func (ts *TokenStore) handleLookup(ctx context.Context, req *logical.Request, da
 		resp.Data["bound_cidrs"] = out.BoundCIDRs
 	}
 
+	if out.BoundCertFingerprint != "" {
+		resp.Data["bound_cert_fingerprint"] = out.BoundCertFingerprint
+	}
+
 	tokenNS, err := NamespaceByID(ctx, out.NamespaceID, ts.core)
 	if err != nil {
 		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest